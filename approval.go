@@ -0,0 +1,125 @@
+package digest
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mpolski/gcp-release-digest/pkg/notify"
+	"github.com/mpolski/gcp-release-digest/pkg/report"
+	"github.com/mpolski/gcp-release-digest/pkg/secrets"
+	"github.com/mpolski/gcp-release-digest/pkg/store"
+)
+
+// approval holds digests for review: a run stores its digest and posts it
+// with an approve link to the reviewers' webhook, and the channels only get
+// it once a reviewer follows the link to the approve function.
+type approval struct {
+	webhookURL string
+	approveURL string
+	secret     string
+}
+
+// approvedKey is the context key of the number of the approved digest a
+// run delivers.
+type approvedKey struct{}
+
+// loadApproval reads the approval settings, or returns nil if
+// APPROVAL_WEBHOOK is not set.
+func loadApproval(ctx context.Context) (*approval, error) {
+	webhookURL := os.Getenv("APPROVAL_WEBHOOK")
+	if webhookURL == "" {
+		return nil, nil
+	}
+	a := &approval{webhookURL: webhookURL, approveURL: os.Getenv("APPROVAL_URL")}
+	if a.approveURL == "" {
+		return nil, fmt.Errorf("Set APPROVAL_URL= in environment variables to use APPROVAL_WEBHOOK")
+	}
+	var err error
+	if a.secret, err = secrets.Resolve(ctx, os.Getenv("APPROVAL_SECRET")); err != nil {
+		return nil, fmt.Errorf("Error in APPROVAL_SECRET: %v", err)
+	}
+	if a.secret == "" {
+		return nil, fmt.Errorf("Set APPROVAL_SECRET= in environment variables to use APPROVAL_WEBHOOK")
+	}
+	return a, nil
+}
+
+// token authorizes approving digest number n.
+func (a *approval) token(n int) string {
+	mac := hmac.New(sha256.New, []byte(a.secret))
+	fmt.Fprintf(mac, "approve digest %d", n)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// link returns the URL approving digest number n.
+func (a *approval) link(n int) string {
+	q := url.Values{"digest": {strconv.Itoa(n)}, "token": {a.token(n)}}
+	sep := "?"
+	if strings.Contains(a.approveURL, "?") {
+		sep = "&"
+	}
+	return a.approveURL + sep + q.Encode()
+}
+
+// hold stores the digest of the run and sends it with an approve link to
+// the reviewers instead of to its channels.
+func (a *approval) hold(ctx context.Context, r *run, s store.Store) error {
+	if err := r.doc.Save(ctx, s); err != nil {
+		return fmt.Errorf("Error storing digest #%d for approval: %v", r.doc.Number, err)
+	}
+
+	fmt.Println("--------------------------------------------------")
+	fmt.Printf("Holding digest #%d for approval, sending it to the reviewers...\n\n", r.doc.Number)
+	review := r.preview()
+	var summary []string
+	for _, ch := range r.doc.Channels {
+		summary = append(summary, fmt.Sprintf("%s (%d)", ch.Name, len(ch.Products)))
+		preview := *ch
+		preview.Name = "REVIEW " + ch.Name
+		preview.WebhookURL, preview.Mirrors = a.webhookURL, nil
+		review.deliverChannel(ctx, &preview)
+	}
+
+	text := fmt.Sprintf("*Digest #%d is waiting for approval*\nChannels: %s\n<%s|Approve and deliver digest #%d>",
+		r.doc.Number, strings.Join(summary, ", "), a.link(r.doc.Number), r.doc.Number)
+	status, err := notify.SendText(ctx, a.webhookURL, text)
+	if err != nil {
+		fmt.Printf("Error sending approval request: %v\n", err)
+	}
+	r.report.Record("APPROVAL", a.webhookURL, report.KindApproval, "", status, err)
+	return nil
+}
+
+// approve is the HTTP function behind the approve link of a held digest. It
+// checks the link's token and publishes the stored digest; a digest already
+// published is refused.
+func approve(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if err := reloadConfig(ctx); err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+	a, err := loadApproval(ctx)
+	if err != nil || a == nil {
+		fmt.Println(err)
+		http.Error(w, "approvals are not configured", http.StatusInternalServerError)
+		return
+	}
+	n, err := strconv.Atoi(r.URL.Query().Get("digest"))
+	if err != nil || n <= 0 || !hmac.Equal([]byte(r.URL.Query().Get("token")), []byte(a.token(n))) {
+		http.Error(w, "invalid approve link", http.StatusForbidden)
+		return
+	}
+
+	fmt.Printf("Digest #%d was approved, delivering it.\n", n)
+	runDigest(w, r.WithContext(context.WithValue(ctx, approvedKey{}, n)))
+}
@@ -0,0 +1,136 @@
+package digest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mpolski/gcp-release-digest/pkg/digest"
+	"github.com/mpolski/gcp-release-digest/pkg/flags"
+	"github.com/mpolski/gcp-release-digest/pkg/store"
+)
+
+// canaryKeys are the settings a canary configuration can change, each set
+// with a CANARY_ prefix, e.g. CANARY_MODEL.
+var canaryKeys = []string{"MODEL", "MODEL_LOCATION", "TYPE_SECTIONS", "FEATURES"}
+
+// canaryTestedKey is the store key of the fingerprint of the last canary
+// configuration sent to the canary webhook.
+const canaryTestedKey = "canary/tested"
+
+// canary is a candidate configuration that is first sent to a test webhook,
+// on the data of the real digest, before it is used for the real channels.
+type canary struct {
+	webhookURL string
+	settings   map[string]string
+	// promote is "manual", leaving it to the operator to move the settings
+	// to the real configuration, or "next-run", using them for the real
+	// channels from the run after the one sending them to the test webhook.
+	promote string
+}
+
+// loadCanary reads the canary configuration, or returns nil if
+// CANARY_WEBHOOK is not set.
+func loadCanary() (*canary, error) {
+	webhookURL := os.Getenv("CANARY_WEBHOOK")
+	if webhookURL == "" {
+		return nil, nil
+	}
+	c := &canary{webhookURL: webhookURL, settings: make(map[string]string), promote: os.Getenv("CANARY_PROMOTE")}
+	switch c.promote {
+	case "":
+		c.promote = "manual"
+	case "manual", "next-run":
+	default:
+		return nil, fmt.Errorf("Error in CANARY_PROMOTE: expected manual or next-run, got %q", c.promote)
+	}
+	for _, key := range canaryKeys {
+		if v := os.Getenv("CANARY_" + key); v != "" {
+			c.settings[key] = v
+		}
+	}
+	if len(c.settings) == 0 {
+		return nil, fmt.Errorf("Set at least one of CANARY_%s to use CANARY_WEBHOOK", strings.Join(canaryKeys, ", CANARY_"))
+	}
+	if _, err := flags.Parse(c.settings["FEATURES"]); err != nil {
+		return nil, fmt.Errorf("Error in CANARY_FEATURES: %v", err)
+	}
+	return c, nil
+}
+
+// fingerprint identifies the canary settings.
+func (c *canary) fingerprint() string {
+	h := sha256.New()
+	for _, key := range canaryKeys {
+		fmt.Fprintf(h, "%s=%s\n", key, c.settings[key])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// tested reports whether the canary settings were already sent to the test
+// webhook by an earlier run.
+func (c *canary) tested(ctx context.Context, s store.Store) (bool, error) {
+	data, err := s.Get(ctx, canaryTestedKey)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("Error reading canary state: %v", err)
+	}
+	return string(data) == c.fingerprint(), nil
+}
+
+// apply returns a copy of the run using the canary settings.
+func (c *canary) apply(r *run) *run {
+	cr := *r
+	if v, ok := c.settings["MODEL"]; ok {
+		cr.model = v
+	}
+	if v, ok := c.settings["MODEL_LOCATION"]; ok {
+		cr.modelLocation = v
+	}
+	if v, ok := c.settings["TYPE_SECTIONS"]; ok {
+		cr.typeSections = v == "true"
+	}
+	if v, ok := c.settings["FEATURES"]; ok {
+		// Validated by loadCanary.
+		cr.globalFeatures, _ = flags.Parse(v)
+		cr.features = nil
+	}
+	return &cr
+}
+
+// deliver summarizes the products of the real digest with the canary
+// settings and sends them to the canary webhook, one channel after the
+// other. Nothing is archived or escalated.
+func (c *canary) deliver(ctx context.Context, r *run) {
+	cr := c.apply(r.preview())
+	cr.doc = &digest.Document{Number: r.doc.Number, Created: r.doc.Created, Cadence: r.doc.Cadence, Permalink: r.doc.Permalink}
+	if _, ok := c.settings["FEATURES"]; !ok {
+		cr.features = make(map[string]flags.Set)
+		for _, ch := range r.doc.Channels {
+			cr.features["CANARY "+ch.Name] = r.featuresOf(ch.Name)
+		}
+	}
+
+	fmt.Println("--------------------------------------------------")
+	fmt.Printf("Sending canary configuration to %s...\n\n", c.webhookURL)
+	for _, ch := range r.doc.Channels {
+		cch := cr.doc.AddChannel("CANARY "+ch.Name, c.webhookURL, ch.Types)
+		cch.Cadence, cch.TypeCounts = ch.Cadence, ch.TypeCounts
+		for _, p := range ch.Products {
+			cch.Products = append(cch.Products, &digest.Product{
+				Info:     p.Info,
+				Notes:    p.Notes,
+				Summary:  cr.summarize(ctx, cch, p.Name(), p.Notes),
+				NotesURL: p.NotesURL,
+				Variant:  p.Variant,
+			})
+		}
+		cr.deliverChannel(ctx, cch)
+	}
+}
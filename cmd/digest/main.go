@@ -0,0 +1,80 @@
+// Command digest runs the digest from the command line. With run --local it
+// builds the digest with the settings of the environment and writes it as
+// Markdown to stdout or a file instead of delivering it, so a digest can be
+// generated ad hoc and pasted anywhere:
+//
+//	go run ./cmd/digest run --local -out digest.md
+//
+// With preview --compare it builds the digest with the settings of the
+// environment and with those of a proposed configuration file, and writes
+// both side by side with their differences marked, so a change of prompts
+// or filters can be reviewed before it reaches any channel:
+//
+//	go run ./cmd/digest preview --compare proposed.env
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	releasedigest "github.com/mpolski/gcp-release-digest"
+)
+
+const usage = `Usage:
+  digest run --local [-out file.md] [-channels CHANNEL,...]
+  digest preview --compare config.env [-out file.txt] [-channels CHANNEL,...] [-width N]`
+
+func main() {
+	if len(os.Args) < 2 || (os.Args[1] != "run" && os.Args[1] != "preview") {
+		fmt.Fprintln(os.Stderr, usage)
+		os.Exit(2)
+	}
+	fs := flag.NewFlagSet(os.Args[1], flag.ExitOnError)
+	local := fs.Bool("local", false, "write the digest instead of delivering it")
+	compare := fs.String("compare", "", "configuration file, local or gs://, to compare the digest with")
+	width := fs.Int("width", 80, "width of each column of the comparison")
+	out := fs.String("out", "", "file the digest is written to, default stdout")
+	channels := fs.String("channels", "", "comma separated channels to run, default all")
+	fs.Parse(os.Args[2:])
+
+	if os.Args[1] == "run" && !*local {
+		log.Fatal("Only local runs are supported, use --local; deploy the function to deliver digests")
+	}
+	if os.Args[1] == "preview" && *compare == "" {
+		log.Fatal("Set the proposed configuration file with --compare")
+	}
+	if *width < 20 {
+		log.Fatal("-width must be at least 20")
+	}
+
+	// The progress of the run is logged to stderr, keeping stdout for the
+	// digest.
+	var w io.Writer = os.Stdout
+	os.Stdout = os.Stderr
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			log.Fatalf("Error creating %s: %v", *out, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	ctx := context.Background()
+	var err error
+	if os.Args[1] == "preview" {
+		err = releasedigest.PreviewCompare(ctx, w, *channels, *compare, *width)
+	} else {
+		err = releasedigest.RunLocal(ctx, w, *channels)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *out != "" {
+		log.Printf("Digest written to %s\n", *out)
+	}
+}
@@ -0,0 +1,101 @@
+// Command eval compares prompts and models on a fixed set of release notes.
+// It summarizes every fixture with every combination of prompt and model and
+// prints a report of their average length, key phrase coverage and judge
+// model score, so prompt changes can be decided on data.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mpolski/gcp-release-digest/pkg/eval"
+	"github.com/mpolski/gcp-release-digest/pkg/summarize"
+)
+
+func main() {
+	fixtures := flag.String("fixtures", "cmd/eval/fixtures.json", "JSON file of fixtures")
+	prompts := flag.String("prompts", "default", "comma separated prompt template files, or default for the built-in prompt")
+	models := flag.String("models", os.Getenv("MODEL"), "comma separated models, default MODEL")
+	judge := flag.String("judge", "", "model rating every summary, none if empty")
+	project := flag.String("project", os.Getenv("PROJECT_ID"), "Google Cloud project, default PROJECT_ID")
+	location := flag.String("location", os.Getenv("MODEL_LOCATION"), "Vertex AI location, default MODEL_LOCATION")
+	out := flag.String("out", "", "file the results of every summary are written to as JSON")
+	profile := flag.String("profile", os.Getenv("GENERATION_PROFILE"), "generation profile, default or deterministic")
+	flag.Parse()
+
+	if *project == "" || *location == "" || *models == "" {
+		log.Fatal("Set -project, -location and -models, or PROJECT_ID, MODEL_LOCATION and MODEL in environment variables")
+	}
+	opts, err := summarize.Profile(*profile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	summarize.SetOptions(opts)
+
+	fs, err := eval.LoadFixtures(*fixtures)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var candidates []eval.Candidate
+	for _, spec := range split(*prompts) {
+		prompt, err := loadPrompt(spec)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, model := range split(*models) {
+			candidates = append(candidates, eval.Candidate{Prompt: prompt, Model: model})
+		}
+	}
+
+	settings := eval.Settings{ProjectID: *project, Location: *location, JudgeModel: *judge}
+	results := eval.Run(context.Background(), settings, fs, candidates)
+	for _, r := range results {
+		if r.Error != "" {
+			fmt.Printf("Error with %s on %s: %s\n", r.Candidate, r.Product, r.Error)
+		}
+	}
+	fmt.Println()
+	fmt.Print(eval.Report(results))
+
+	if *out != "" {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := os.WriteFile(*out, data, 0o644); err != nil {
+			log.Fatalf("Error writing results: %v", err)
+		}
+	}
+}
+
+// loadPrompt returns the built-in prompt for "default", or the prompt
+// template in a file, named after the file.
+func loadPrompt(spec string) (*summarize.Prompt, error) {
+	if spec == "default" {
+		return summarize.DefaultPrompt, nil
+	}
+	text, err := os.ReadFile(spec)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading prompt: %v", err)
+	}
+	name := strings.TrimSuffix(filepath.Base(spec), filepath.Ext(spec))
+	return summarize.ParsePrompt(name, string(text))
+}
+
+// split returns the non-empty items of a comma separated list.
+func split(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
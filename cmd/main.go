@@ -1,11 +1,16 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
 	"log"
+	"net/http"
+	"net/http/pprof"
 	"os"
 
 	"github.com/GoogleCloudPlatform/functions-framework-go/funcframework"
 	_ "github.com/mpolski/gcp-release-digest"
+	"github.com/mpolski/gcp-release-digest/pkg/secrets"
 )
 
 func main() {
@@ -22,8 +27,47 @@ func main() {
 	if localOnly := os.Getenv("LOCAL_ONLY"); localOnly == "true" {
 		hostname = "127.0.0.1"
 	}
+
+	// Serve the runtime profiles on a separate address if PPROF_ADDR is set,
+	// e.g. "localhost:6060", to diagnose slow or memory hungry runs.
+	if addr := os.Getenv("PPROF_ADDR"); addr != "" {
+		token, err := secrets.Resolve(context.Background(), os.Getenv("PPROF_TOKEN"))
+		if err != nil {
+			log.Fatalf("Error resolving PPROF_TOKEN: %v\n", err)
+		}
+		if token == "" {
+			log.Fatalf("Set PPROF_TOKEN= in environment variables to use PPROF_ADDR\n")
+		}
+		go func() {
+			log.Printf("Serving profiles on %s\n", addr)
+			if err := http.ListenAndServe(addr, profiles(token)); err != nil {
+				log.Printf("Error serving profiles: %v\n", err)
+			}
+		}()
+	}
+
 	if err := funcframework.StartHostPort(hostname, port); err != nil {
 		log.Fatalf("funcframework.StartHostPort: %v\n", err)
 	}
 	log.Printf("Listening on port %s\n", port)
 }
+
+// profiles serves net/http/pprof under /debug/pprof/ to requests carrying
+// the token as "Authorization: Bearer <token>".
+func profiles(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
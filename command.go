@@ -0,0 +1,321 @@
+package digest
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mpolski/gcp-release-digest/pkg/archive"
+	"github.com/mpolski/gcp-release-digest/pkg/digest"
+	"github.com/mpolski/gcp-release-digest/pkg/i18n"
+	"github.com/mpolski/gcp-release-digest/pkg/notify"
+	"github.com/mpolski/gcp-release-digest/pkg/products"
+	"github.com/mpolski/gcp-release-digest/pkg/releasenotes"
+	"github.com/mpolski/gcp-release-digest/pkg/report"
+	"github.com/mpolski/gcp-release-digest/pkg/secrets"
+	"github.com/mpolski/gcp-release-digest/pkg/summarize"
+	"github.com/mpolski/gcp-release-digest/pkg/tasks"
+)
+
+// commandUsage is the reply to a slash command that could not be read.
+const commandUsage = "Usage: /digest now <product> [days], e.g. /digest now BigQuery 14"
+
+// maxCommandDays is the longest period a slash command can ask for.
+const maxCommandDays = 90
+
+// digestCommand is an on-demand digest asked for with a slash command: the
+// release notes of one product over the last Days days, posted to Target.
+type digestCommand struct {
+	Product string `json:"product"`
+	Days    int    `json:"days"`
+	Target  string `json:"target"`
+	// Signature authenticates a command handed back by the command queue.
+	Signature string `json:"signature,omitempty"`
+}
+
+// sign returns the signature of the command with secret.
+func (c digestCommand) sign(secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "digest command\n%s\n%d\n%s", c.Product, c.Days, c.Target)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// parseCommand reads the text of a slash command, "now <product> [days]",
+// e.g. "now BigQuery 14". The days default to defaultDays.
+func parseCommand(text string, defaultDays int) (digestCommand, error) {
+	fields := strings.Fields(text)
+	if len(fields) < 2 || !strings.EqualFold(fields[0], "now") {
+		return digestCommand{}, errors.New(commandUsage)
+	}
+	fields = fields[1:]
+	// A number at the end is the days, also alone, as in "now 14".
+	days := defaultDays
+	if n, err := strconv.Atoi(fields[len(fields)-1]); err == nil {
+		days, fields = n, fields[:len(fields)-1]
+	}
+	if days < 1 || days > maxCommandDays {
+		return digestCommand{}, fmt.Errorf("Ask for 1 to %d days of release notes. %s", maxCommandDays, commandUsage)
+	}
+	if len(fields) == 0 {
+		return digestCommand{}, fmt.Errorf("Name the product to digest. %s", commandUsage)
+	}
+	return digestCommand{Product: strings.Join(fields, " "), Days: days}, nil
+}
+
+// chatEvent is the part of a Google Chat app event a slash command needs.
+type chatEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Text         string `json:"text"`
+		ArgumentText string `json:"argumentText"`
+	} `json:"message"`
+	Space struct {
+		Name string `json:"name"`
+	} `json:"space"`
+}
+
+// verifySlack checks the signature Slack sends a slash command with, which
+// is rejected if it is over five minutes old.
+func verifySlack(secret string, r *http.Request, body []byte) bool {
+	ts := r.Header.Get("X-Slack-Request-Timestamp")
+	sent, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || time.Since(time.Unix(sent, 0)).Abs() > 5*time.Minute {
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", ts, body)
+	return hmac.Equal([]byte(r.Header.Get("X-Slack-Signature")), []byte("v0="+hex.EncodeToString(mac.Sum(nil))))
+}
+
+// command is the HTTP function behind the /digest slash command of the
+// Google Chat and Slack apps. "/digest now BigQuery 14" posts a digest of
+// the release notes of BigQuery over the last 14 days back to the space it
+// was typed in. Google Chat calls it with COMMAND_TOKEN in the token query
+// parameter, and Slack signs its requests with SLACK_SIGNING_SECRET. With
+// COMMAND_QUEUE set, the digest is prepared by a task calling the function
+// again at COMMAND_URL, as the apps wait only a few seconds for a reply.
+func command(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if err := reloadConfig(ctx); err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+	secret, err := secrets.Resolve(ctx, os.Getenv("COMMAND_TOKEN"))
+	if err == nil && secret == "" {
+		err = errors.New("Set COMMAND_TOKEN= in environment variables to use slash commands")
+	}
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+	defaultDays, err := strconv.Atoi(os.Getenv("CADENCE"))
+	if err != nil || defaultDays <= 0 {
+		defaultDays = 7
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	reply := func(text string) {
+		w.Header().Set("Content-Type", "application/json")
+		if text == "" {
+			w.Write([]byte("{}"))
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"text": text})
+	}
+	var c digestCommand
+	switch {
+	case r.Header.Get("X-Slack-Signature") != "":
+		signingSecret, err := secrets.Resolve(ctx, os.Getenv("SLACK_SIGNING_SECRET"))
+		if err != nil {
+			fmt.Printf("Error in SLACK_SIGNING_SECRET: %v\n", err)
+		}
+		if signingSecret == "" || !verifySlack(signingSecret, r, body) {
+			http.Error(w, "invalid signature", http.StatusUnauthorized)
+			return
+		}
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		c, err = parseCommand(form.Get("text"), defaultDays)
+		if err != nil {
+			reply(err.Error())
+			return
+		}
+		c.Target = form.Get("response_url")
+	case r.URL.Query().Has("token"):
+		if !hmac.Equal([]byte(r.URL.Query().Get("token")), []byte(secret)) {
+			http.Error(w, "invalid token", http.StatusForbidden)
+			return
+		}
+		var event chatEvent
+		if err := json.Unmarshal(body, &event); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		if event.Type != "MESSAGE" || event.Space.Name == "" {
+			reply("")
+			return
+		}
+		c, err = parseCommand(event.Message.ArgumentText, defaultDays)
+		if err != nil {
+			reply(err.Error())
+			return
+		}
+		c.Target = "https://chat.googleapis.com/v1/" + event.Space.Name + "/messages"
+	default:
+		// A command handed back by the command queue.
+		if err := json.Unmarshal(body, &c); err != nil || c.Target == "" || !hmac.Equal([]byte(c.Signature), []byte(c.sign(secret))) {
+			http.Error(w, "invalid command", http.StatusForbidden)
+			return
+		}
+		if err := runCommand(ctx, c); err != nil {
+			fmt.Println(err)
+			http.Error(w, "command error", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, "done")
+		return
+	}
+
+	fmt.Printf("Digest of %s for the last %d days asked for by slash command.\n", c.Product, c.Days)
+	if queueName := os.Getenv("COMMAND_QUEUE"); queueName != "" {
+		c.Signature = c.sign(secret)
+		job, err := json.Marshal(c)
+		if err == nil {
+			err = (&tasks.Queue{Name: queueName, SendURL: os.Getenv("COMMAND_URL")}).Post(ctx, job)
+		}
+		if err != nil {
+			fmt.Printf("Error queueing slash command: %v\n", err)
+			reply("Sorry, the digest could not be started.")
+			return
+		}
+		reply(fmt.Sprintf("Preparing a digest of %s for the last %d days...", c.Product, c.Days))
+		return
+	}
+	if err := runCommand(ctx, c); err != nil {
+		fmt.Println(err)
+		reply("Sorry, the digest could not be prepared.")
+		return
+	}
+	reply("")
+}
+
+// runCommand prepares the digest a slash command asked for and posts it to
+// its target through the preview pipeline, so nothing is archived or
+// escalated. A product with no release notes in the period gets a no news
+// message.
+func runCommand(ctx context.Context, c digestCommand) error {
+	projectID := os.Getenv("PROJECT_ID")
+	model := os.Getenv("MODEL")
+	modelLocation := os.Getenv("MODEL_LOCATION")
+	if projectID == "" || model == "" || modelLocation == "" {
+		return fmt.Errorf("Set PROJECT_ID=, MODEL= and MODEL_LOCATION= in environment variables to run slash commands")
+	}
+	if err := setChatAppToken(ctx, c.Target); err != nil {
+		return err
+	}
+
+	cadence := strconv.Itoa(c.Days)
+	prods, err := products.GetProducts(ctx, projectID, allReleaseNoteTypes, cadence)
+	if err != nil {
+		return fmt.Errorf("Error querying for products: %v", err)
+	}
+	var info *products.Product
+	name := releasenotes.Normalize(c.Product)
+	for i, p := range prods {
+		if releasenotes.Normalize(p.Product) == name {
+			info = &prods[i]
+			break
+		}
+		if info == nil && strings.Contains(releasenotes.Normalize(p.Product), name) {
+			info = &prods[i]
+		}
+	}
+
+	r := &run{
+		projectID:     projectID,
+		model:         model,
+		modelLocation: modelLocation,
+		cadence:       cadence,
+		cadenceInt:    c.Days,
+		noteOpts:      releasenotes.Options{TypePriority: releasenotes.DefaultTypePriority},
+		typePrompts:   summarize.TypePrompts(),
+		concurrency:   1,
+		closingMsg:    i18n.M().Closing,
+		doc:           &digest.Document{Created: time.Now(), Cadence: c.Days},
+		record:        &archive.Digest{},
+		report:        report.New(0),
+	}
+	p := r.preview()
+	ch := p.doc.AddChannel("COMMAND", c.Target, allReleaseNoteTypes)
+	ch.Cadence = c.Days
+	if info != nil {
+		ch.TypeCounts, err = products.GetTypeCounts(ctx, projectID, allReleaseNoteTypes, []string{info.Product}, cadence)
+		if err != nil {
+			return fmt.Errorf("Error counting release notes of %s: %v", info.Product, err)
+		}
+		fetch := func(ctx context.Context, product string) ([]releasenotes.ReleaseNote, error) {
+			return releasenotes.GetReleaseNotes(ctx, projectID, product, allReleaseNoteTypes, cadence, p.noteOpts)
+		}
+		if prod := p.summarizeProduct(ctx, ch, *info, fetch); prod != nil {
+			ch.Products = append(ch.Products, prod)
+		}
+	}
+	if len(ch.Products) == 0 {
+		_, err := notify.NoNews(ctx, c.Target, c.Days, p.announceOpts)
+		if err != nil {
+			return fmt.Errorf("Error sending no news message: %v", err)
+		}
+		return nil
+	}
+	p.deliverChannel(ctx, ch)
+	return nil
+}
+
+// setChatAppToken authorizes posting to a Google Chat space as the Chat app,
+// with a token of the function's service account. Other targets need none.
+func setChatAppToken(ctx context.Context, target string) error {
+	if !strings.HasPrefix(target, "https://chat.googleapis.com/v1/spaces/") {
+		return nil
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token?scopes=https://www.googleapis.com/auth/chat.bot", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("Error getting a Chat app token: %v", err)
+	}
+	defer resp.Body.Close()
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Error getting a Chat app token: %s", resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("Error decoding the Chat app token: %v", err)
+	}
+	notify.SetHeaders(target, http.Header{"Authorization": {"Bearer " + token.AccessToken}})
+	return nil
+}
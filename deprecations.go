@@ -0,0 +1,122 @@
+package digest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/mpolski/gcp-release-digest/pkg/deprecations"
+	"github.com/mpolski/gcp-release-digest/pkg/i18n"
+	"github.com/mpolski/gcp-release-digest/pkg/notify"
+	"github.com/mpolski/gcp-release-digest/pkg/report"
+	"github.com/mpolski/gcp-release-digest/pkg/store"
+)
+
+// deprecationsHandler is the HTTP function serving the open deprecations
+// tracked by digest runs with DEPRECATION_TRACKER=true as JSON, those with a
+// deadline within ?within= days only if set. With ?remind=true it posts the
+// deadlines within DEPRECATION_REMINDER_DAYS (default 90) to
+// DEPRECATIONS_WEBHOOK or GENERAL instead, meant to be scheduled monthly.
+func deprecationsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if err := reloadConfig(ctx); err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+
+	stateStore, err := store.New(ctx, os.Getenv("STATE_BUCKET"), os.Getenv("STATE_DIR"))
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+	if stateStore == nil {
+		fmt.Println("Set STATE_BUCKET= or STATE_DIR= in environment variables to track deprecations")
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+	board, err := deprecations.Load(ctx, stateStore)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "state error", http.StatusInternalServerError)
+		return
+	}
+	now := time.Now().UTC()
+
+	if r.URL.Query().Get("remind") != "true" {
+		open := board.Open()
+		if within := r.URL.Query().Get("within"); within != "" {
+			days, err := strconv.Atoi(within)
+			if err != nil || days < 0 {
+				http.Error(w, fmt.Sprintf("invalid within %q, expected a number of days", within), http.StatusBadRequest)
+				return
+			}
+			open = board.Upcoming(now, days)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(struct {
+			Deprecations []deprecations.Deprecation `json:"deprecations"`
+		}{open})
+		return
+	}
+
+	webhookURL := os.Getenv("DEPRECATIONS_WEBHOOK")
+	if webhookURL == "" {
+		// A GENERAL channel fanning out gets reminders at its first target.
+		if targets := webhookTargets(os.Getenv("GENERAL")); len(targets) > 0 {
+			webhookURL = targets[0]
+		}
+	}
+	if webhookURL == "" {
+		fmt.Println("Set DEPRECATIONS_WEBHOOK= or GENERAL= in environment variables to post deprecation reminders")
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+	days, err := optionalInt("DEPRECATION_REMINDER_DAYS")
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+	if days == 0 {
+		days = 90
+	}
+	if err := i18n.SetLocale(os.Getenv("LOCALE")); err != nil {
+		fmt.Printf("Error in LOCALE: %v", err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+	if err := setTargetCredentials(ctx); err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+	if err := setWebhookClients(ctx); err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+
+	upcoming := board.Upcoming(now, days)
+	rep := report.New(0)
+	fmt.Printf("Posting %d deprecation deadlines...", len(upcoming))
+	status, err := notify.SendText(ctx, webhookURL, deprecations.Render(upcoming, now, days))
+	if err != nil {
+		fmt.Printf(" error: %v\n", err)
+	} else {
+		fmt.Printf(" %s\n", status)
+	}
+	rep.Record("DEPRECATIONS", webhookURL, report.KindDeprecations, "", status, err)
+
+	reportJSON, err := rep.JSON()
+	if err != nil {
+		fmt.Printf("Error encoding run report: %v\n", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(reportJSON)
+}
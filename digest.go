@@ -2,27 +2,113 @@ package digest
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"net"
 	"net/http"
 	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/GoogleCloudPlatform/functions-framework-go/functions"
+	"github.com/mpolski/gcp-release-digest/pkg/archive"
+	"github.com/mpolski/gcp-release-digest/pkg/bqretry"
+	"github.com/mpolski/gcp-release-digest/pkg/compliance"
+	"github.com/mpolski/gcp-release-digest/pkg/config"
+	"github.com/mpolski/gcp-release-digest/pkg/deprecations"
+	"github.com/mpolski/gcp-release-digest/pkg/digest"
+	"github.com/mpolski/gcp-release-digest/pkg/email"
+	"github.com/mpolski/gcp-release-digest/pkg/escalation"
+	"github.com/mpolski/gcp-release-digest/pkg/flags"
+	"github.com/mpolski/gcp-release-digest/pkg/github"
+	"github.com/mpolski/gcp-release-digest/pkg/i18n"
+	"github.com/mpolski/gcp-release-digest/pkg/impact"
+	"github.com/mpolski/gcp-release-digest/pkg/jira"
+	"github.com/mpolski/gcp-release-digest/pkg/lease"
 	"github.com/mpolski/gcp-release-digest/pkg/notify"
+	"github.com/mpolski/gcp-release-digest/pkg/outbox"
+	"github.com/mpolski/gcp-release-digest/pkg/pagerduty"
 	"github.com/mpolski/gcp-release-digest/pkg/products"
+	"github.com/mpolski/gcp-release-digest/pkg/push"
+	"github.com/mpolski/gcp-release-digest/pkg/querycache"
 	"github.com/mpolski/gcp-release-digest/pkg/releasenotes"
+	"github.com/mpolski/gcp-release-digest/pkg/report"
+	"github.com/mpolski/gcp-release-digest/pkg/routing"
+	"github.com/mpolski/gcp-release-digest/pkg/scrub"
+	"github.com/mpolski/gcp-release-digest/pkg/secrets"
+	"github.com/mpolski/gcp-release-digest/pkg/sms"
+	"github.com/mpolski/gcp-release-digest/pkg/source"
+	"github.com/mpolski/gcp-release-digest/pkg/store"
 	"github.com/mpolski/gcp-release-digest/pkg/summarize"
+	"github.com/mpolski/gcp-release-digest/pkg/tasks"
+	"github.com/mpolski/gcp-release-digest/pkg/terraform"
+	"github.com/mpolski/gcp-release-digest/pkg/window"
 )
 
 func init() {
-	functions.HTTP("digest", digest)
+	functions.HTTP("digest", idempotent("digest", runDigest))
+	functions.HTTP("step", idempotent("step", step))
+	functions.HTTP("send", send)
+	functions.HTTP("approve", approve)
+	functions.HTTP("feedback", feedback)
+	functions.HTTP("variants", compareHandler)
+	functions.HTTP("watch", watch)
+	functions.HTTP("stats", statsHandler)
+	functions.HTTP("deprecations", deprecationsHandler)
+	functions.HTTP("expand", expand)
+	functions.HTTP("command", command)
 }
 
-// digest is the main function that handles the HTTP request for the digest service.
+// allReleaseNoteTypes lists the release note types of the dataset, in the
+// order of their channel environment variables.
+var allReleaseNoteTypes = []string{"BREAKING_CHANGE", "DEPRECATION", "FEATURE", "FIX", "ISSUE", "LIBRARIES", "NON_BREAKING_CHANGE", "SECURITY_BULLETIN", "SERVICE_ANNOUNCEMENT"}
+
+// runDigest is the main function that handles the HTTP request for the digest service.
 // It retrieves a list of products with new release notes, summarizes the release notes for each product,
 // and sends the summaries to a webhook URL.
-func digest(w http.ResponseWriter, r *http.Request) {
+func runDigest(w http.ResponseWriter, r *http.Request) {
+	started := time.Now()
+
+	// Apply changes of the configuration file before reading any setting.
+	if err := reloadConfig(r.Context()); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// The notifiers of the targets release what they kept once the run is
+	// over.
+	defer func() {
+		if err := notify.Close(); err != nil {
+			fmt.Printf("Error closing notifiers: %v\n", err)
+		}
+	}()
+
+	// A run started by the approve function, or asked to publish a draft with
+	// ?publish=<number>, delivers the stored digest with this number instead
+	// of building a new one.
+	publish, _ := r.Context().Value(approvedKey{}).(int)
+
+	// A local run started from the command line writes the digest to its
+	// output instead of delivering it, and stores nothing.
+	local, _ := r.Context().Value(localKey{}).(*localRun)
+
+	// A run started by the step function is limited to one step of the
+	// pipeline.
+	st, _ := r.Context().Value(stepKey{}).(*stepRequest)
+
+	if n := r.URL.Query().Get("publish"); n != "" && publish == 0 && local == nil {
+		var err error
+		if publish, err = strconv.Atoi(n); err != nil || publish <= 0 {
+			http.Error(w, fmt.Sprintf("invalid digest number %q", n), http.StatusBadRequest)
+			return
+		}
+	}
+
+	// In draft mode a run stores the rendered digest for an editor instead of
+	// delivering it.
+	draft := publish == 0 && local == nil && st == nil && (os.Getenv("DRAFT") == "true" || r.URL.Query().Get("draft") == "true")
 
 	// Retrieve environment variables required for the service.
 	projectID := os.Getenv("PROJECT_ID")
@@ -53,7 +139,471 @@ func digest(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ctx := context.Background()
+	// The trigger may select the channels of this run, e.g. a daily schedule
+	// for SECURITY_BULLETIN and a weekly one for GENERAL.
+	selected, err := selectChannels(r)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Read optional settings controlling how many release notes are fetched per
+	// product, how they are ordered and how long a single note may be.
+	noteOpts := releasenotes.Options{OrderBy: os.Getenv("NOTES_ORDER_BY")}
+	if err := releasenotes.ValidateOrderBy(noteOpts.OrderBy); err != nil {
+		fmt.Printf("Error in NOTES_ORDER_BY: %v", err)
+		return
+	}
+	if limit := os.Getenv("NOTES_LIMIT"); limit != "" {
+		noteOpts.Limit, err = strconv.Atoi(limit)
+		if err != nil || noteOpts.Limit <= 0 {
+			fmt.Printf("Error converting NOTES_LIMIT to a positive int: %q", limit)
+			return
+		}
+	}
+	if maxChars := os.Getenv("NOTE_MAX_CHARS"); maxChars != "" {
+		noteOpts.MaxChars, err = strconv.Atoi(maxChars)
+		if err != nil || noteOpts.MaxChars < 0 {
+			fmt.Printf("Error converting NOTE_MAX_CHARS to a non-negative int: %q", maxChars)
+			return
+		}
+	}
+
+	// Read the GKE release channels the clusters run on, and whether GKE
+	// release notes for other channels are dropped or annotated.
+	if noteOpts.GKEChannels, err = releasenotes.ParseGKEChannels(os.Getenv("GKE_CHANNELS")); err != nil {
+		fmt.Printf("Error in GKE_CHANNELS: %v\n", err)
+		return
+	}
+	switch action := os.Getenv("GKE_CHANNEL_ACTION"); action {
+	case "", "filter":
+	case "annotate":
+		noteOpts.GKEAnnotate = true
+	default:
+		fmt.Printf("Error in GKE_CHANNEL_ACTION: expected filter or annotate, got %q\n", action)
+		return
+	}
+
+	// Read the pinned versions of products, and whether release notes for
+	// other versions are dropped or labeled.
+	if noteOpts.Pins, err = releasenotes.ParsePins(os.Getenv("VERSION_PINS")); err != nil {
+		fmt.Printf("Error in VERSION_PINS: %v\n", err)
+		return
+	}
+	switch action := os.Getenv("VERSION_PIN_ACTION"); action {
+	case "", "filter":
+	case "label":
+		noteOpts.PinLabel = true
+	default:
+		fmt.Printf("Error in VERSION_PIN_ACTION: expected filter or label, got %q\n", action)
+		return
+	}
+
+	// Read how often and how long queries failing with transient BigQuery
+	// errors are retried.
+	retry := bqretry.DefaultPolicy
+	if attempts, err := optionalInt("BQ_RETRY_ATTEMPTS"); err != nil {
+		fmt.Println(err)
+		return
+	} else if attempts > 0 {
+		retry.Attempts = attempts
+	}
+	if timeout, err := optionalDuration("BQ_RETRY_TIMEOUT"); err != nil {
+		fmt.Println(err)
+		return
+	} else if timeout > 0 {
+		retry.MaxElapsed = timeout
+	}
+	bqretry.SetPolicy(retry)
+
+	// Read optional settings controlling the order products appear in the digest.
+	productOrder := os.Getenv("PRODUCT_ORDER")
+	if err := products.ValidateOrder(productOrder); err != nil {
+		fmt.Printf("Error in PRODUCT_ORDER: %v", err)
+		return
+	}
+	var productPriority []string
+	if priority := os.Getenv("PRODUCT_PRIORITY"); priority != "" {
+		productPriority = strings.Split(priority, ",")
+	}
+
+	// Read the order release note types are presented in when several of them
+	// go to the same channel. "query" keeps the order returned by the query.
+	switch typePriority := os.Getenv("TYPE_PRIORITY"); typePriority {
+	case "":
+		noteOpts.TypePriority = releasenotes.DefaultTypePriority
+	case "query":
+	default:
+		noteOpts.TypePriority = strings.Split(typePriority, ",")
+	}
+
+	// Read whether products with several release note types in one channel get
+	// a summary section per type instead of a single blended summary.
+	typeSections := os.Getenv("TYPE_SECTIONS") == "true"
+
+	// Read whether summaries cite the release notes they are based on, and
+	// whether they link the product's release notes page.
+	citations := os.Getenv("CITATIONS") == "true"
+	docsLinks := os.Getenv("RELEASE_NOTES_LINKS") == "true"
+
+	// Read the alternate prompts summarizing a share of the products, and the
+	// URL of the feedback function readers rate summaries with.
+	variants, err := parseVariants(os.Getenv("PROMPT_VARIANTS"))
+	if err != nil {
+		fmt.Printf("Error in PROMPT_VARIANTS: %v\n", err)
+		return
+	}
+	feedbackURL := os.Getenv("FEEDBACK_URL")
+
+	// Read the prompts specialized for release note types.
+	typePrompts, err := parseTypePrompts(os.Getenv("TYPE_PROMPTS"))
+	if err != nil {
+		fmt.Printf("Error in TYPE_PROMPTS: %v\n", err)
+		return
+	}
+
+	// Read the optional model checking summaries against their release notes,
+	// and whether failing summaries get a warning label or are replaced by
+	// the release notes.
+	verifyModel := os.Getenv("VERIFY_MODEL")
+	verifyAction := os.Getenv("VERIFY_ACTION")
+	switch verifyAction {
+	case "":
+		verifyAction = "label"
+	case "label", "notes":
+	default:
+		fmt.Printf("Error in VERIFY_ACTION: expected label or notes, got %q\n", verifyAction)
+		return
+	}
+
+	// Every release note is scored by its impact, by rules matching its
+	// type, urgent phrases and the criticality of its product, and
+	// optionally by a model.
+	criticality, err := impact.ParseProducts(os.Getenv("IMPACT_PRODUCTS"))
+	if err != nil {
+		fmt.Printf("Error in IMPACT_PRODUCTS: %v\n", err)
+		return
+	}
+	var impactKeywords []string
+	if k := os.Getenv("IMPACT_KEYWORDS"); k != "" {
+		impactKeywords = strings.Split(k, ",")
+	}
+	impactOpts := impactSettings{scorer: impact.NewScorer(impactKeywords, criticality), model: os.Getenv("IMPACT_MODEL")}
+
+	// A candidate configuration can be tried on a test webhook first.
+	canary, err := loadCanary()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if publish > 0 || local != nil || st.partial() {
+		// The stored digest is already built; the canary was tried on it then.
+		// A local run or a step delivering nothing is not delivered anywhere
+		// to try it.
+		canary = nil
+	}
+
+	// Read the language of the digest's static strings and summaries.
+	if err := i18n.SetLocale(os.Getenv("LOCALE")); err != nil {
+		fmt.Printf("Error in LOCALE: %v", err)
+		return
+	}
+
+	// Read the generation settings of the model, e.g. the deterministic
+	// profile for reproducible summaries.
+	genOpts, err := generationOptions()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	summarize.SetOptions(genOpts)
+
+	// Read how many products are summarized at once, and the quotas of
+	// requests per minute keeping the concurrent calls within each model's
+	// limits.
+	concurrency, err := optionalInt("SUMMARIZE_CONCURRENCY")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if concurrency == 0 {
+		concurrency = 4
+	}
+	quotas, defaultQPM, err := summarize.ParseQPM(os.Getenv("MODEL_QPM"))
+	if err != nil {
+		fmt.Printf("Error in MODEL_QPM: %v\n", err)
+		return
+	}
+	summarize.SetQPM(quotas, defaultQPM)
+
+	// Read the time budget of the run, after which no more products are
+	// summarized and the digest is delivered with the summaries done.
+	budget, err := optionalDuration("RUN_BUDGET")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	var deadline time.Time
+	if budget > 0 {
+		deadline = started.Add(budget)
+	}
+
+	// Read optional settings controlling how long product lists are announced.
+	var announceOpts notify.AnnounceOptions
+	if announceOpts.GroupThreshold, err = optionalInt("ANNOUNCE_GROUP_THRESHOLD"); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if announceOpts.MaxChars, err = optionalInt("ANNOUNCE_MAX_CHARS"); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// Read optional settings coalescing several summaries into one message.
+	batchSize, err := optionalInt("BATCH_SUMMARIES")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	batchMaxChars, err := optionalInt("BATCH_MAX_CHARS")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// Read the size limit of summary messages, above which they are truncated
+	// with a link to the archived digest.
+	messageMaxChars, err := optionalInt("MESSAGE_MAX_CHARS")
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// Read optional escalation rules sending an extra copy of matching
+	// summaries to an escalation channel.
+	escalationRules, err := escalation.Parse(os.Getenv("ESCALATION_RULES"))
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	escalationWebhook := os.Getenv("ESCALATION_WEBHOOK")
+	if len(escalationRules) > 0 && escalationWebhook == "" {
+		fmt.Println("Set ESCALATION_WEBHOOK= in environment variables to use ESCALATION_RULES")
+		return
+	}
+
+	// Queries run again for another channel within the run read the
+	// results of the first one.
+	ctx := querycache.WithCache(context.Background())
+
+	// Rating links are signed, so votes only come from the digest.
+	feedbackSecret, err := secrets.Resolve(ctx, os.Getenv("FEEDBACK_SECRET"))
+	if err != nil {
+		fmt.Printf("Error in FEEDBACK_SECRET: %v\n", err)
+		return
+	}
+	if feedbackURL != "" && feedbackSecret == "" {
+		fmt.Println("Set FEEDBACK_SECRET= in environment variables to use FEEDBACK_URL")
+		return
+	}
+
+	// Read the filter of banned phrases applied to summaries before they are
+	// sent, and what it does with a match.
+	var complianceOpts complianceSettings
+	if complianceOpts.filter, err = compliance.Parse(os.Getenv("COMPLIANCE_PHRASES")); err != nil {
+		fmt.Printf("Error in COMPLIANCE_PHRASES: %v\n", err)
+		return
+	}
+	if complianceOpts.defaultAction, err = compliance.ParseAction(os.Getenv("COMPLIANCE_ACTION")); err != nil {
+		fmt.Printf("Error in COMPLIANCE_ACTION: %v\n", err)
+		return
+	}
+	complianceOpts.opsWebhook = os.Getenv("OPS_WEBHOOK")
+
+	// Read the scrubber removing credentials and other sensitive strings from
+	// summaries, which custom sources may leak into them.
+	var scrubber scrub.Scrubber
+	builtin := scrub.NewBuiltin(strings.Split(os.Getenv("SCRUB_INTERNAL_DOMAINS"), ","))
+	switch s := os.Getenv("SCRUBBER"); s {
+	case "", "off":
+	case "builtin":
+		scrubber = builtin
+	case "dlp":
+		var infoTypes []string
+		for _, t := range strings.Split(os.Getenv("SCRUB_INFO_TYPES"), ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				infoTypes = append(infoTypes, t)
+			}
+		}
+		scrubber = &scrub.DLP{ProjectID: projectID, InfoTypes: infoTypes, Builtin: builtin}
+	default:
+		fmt.Printf("Error in SCRUBBER: expected builtin, dlp or off, got %q\n", s)
+		return
+	}
+
+	// Read optional SMS escalation rules texting alerts of critical release
+	// notes to on-call staff through Twilio.
+	escalationOpts := escalationSettings{
+		rules:      escalationRules,
+		webhookURL: escalationWebhook,
+		mention:    os.Getenv("ESCALATION_MENTION"),
+	}
+	if escalationOpts.smsRules, err = escalation.Parse(os.Getenv("ESCALATION_SMS_RULES")); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if len(escalationOpts.smsRules) > 0 {
+		for _, to := range strings.Split(os.Getenv("ESCALATION_SMS_TO"), ",") {
+			if to = strings.TrimSpace(to); to != "" {
+				escalationOpts.smsTo = append(escalationOpts.smsTo, to)
+			}
+		}
+		authToken, err := secrets.Resolve(ctx, os.Getenv("TWILIO_AUTH_TOKEN"))
+		if err != nil {
+			fmt.Printf("Error in TWILIO_AUTH_TOKEN: %v\n", err)
+			return
+		}
+		escalationOpts.sms = &sms.Twilio{AccountSID: os.Getenv("TWILIO_ACCOUNT_SID"), AuthToken: authToken, From: os.Getenv("TWILIO_FROM")}
+		if len(escalationOpts.smsTo) == 0 || escalationOpts.sms.AccountSID == "" || authToken == "" || escalationOpts.sms.From == "" {
+			fmt.Println("Set ESCALATION_SMS_TO=, TWILIO_ACCOUNT_SID=, TWILIO_AUTH_TOKEN= and TWILIO_FROM= in environment variables to use ESCALATION_SMS_RULES")
+			return
+		}
+		if escalationOpts.smsMaxChars, err = optionalInt("SMS_MAX_CHARS"); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	// Products whose release notes match the paging rules, by default those
+	// with security bulletins, can page on-call through PagerDuty.
+	var pagerOpts pagerSettings
+	routingKey, err := secrets.Resolve(ctx, os.Getenv("PAGERDUTY_ROUTING_KEY"))
+	if err != nil {
+		fmt.Printf("Error in PAGERDUTY_ROUTING_KEY: %v\n", err)
+		return
+	}
+	if routingKey != "" {
+		pagerRules := os.Getenv("PAGERDUTY_RULES")
+		if pagerRules == "" {
+			pagerRules = "type=SECURITY_BULLETIN"
+		}
+		if pagerOpts.rules, err = escalation.Parse(pagerRules); err != nil {
+			fmt.Printf("Error in PAGERDUTY_RULES: %v\n", err)
+			return
+		}
+		pagerOpts.severity = os.Getenv("PAGERDUTY_SEVERITY")
+		if pagerOpts.severity == "" {
+			pagerOpts.severity = "critical"
+		}
+		if !slices.Contains(pagerduty.Severities, pagerOpts.severity) {
+			fmt.Printf("Error in PAGERDUTY_SEVERITY: %q, use one of %s\n", pagerOpts.severity, strings.Join(pagerduty.Severities, ", "))
+			return
+		}
+		pagerOpts.events = &pagerduty.Events{RoutingKey: routingKey}
+		pagerOpts.instead = os.Getenv("PAGERDUTY_ONLY") == "true"
+		pagerOpts.paged = make(map[string]bool)
+	}
+
+	// Products whose release notes match the issue rules, by default those
+	// with breaking changes, can get a Jira issue to track remediation.
+	var jiraOpts jiraSettings
+	if jiraURL := os.Getenv("JIRA_URL"); jiraURL != "" {
+		token, err := secrets.Resolve(ctx, os.Getenv("JIRA_API_TOKEN"))
+		if err != nil {
+			fmt.Printf("Error in JIRA_API_TOKEN: %v\n", err)
+			return
+		}
+		jiraOpts.client = &jira.Client{BaseURL: jiraURL, Email: os.Getenv("JIRA_EMAIL"), Token: token, Project: os.Getenv("JIRA_PROJECT"), IssueType: os.Getenv("JIRA_ISSUE_TYPE")}
+		if jiraOpts.client.Project == "" || token == "" {
+			fmt.Println("Set JIRA_PROJECT= and JIRA_API_TOKEN= in environment variables to use JIRA_URL")
+			return
+		}
+		if jiraOpts.client.IssueType == "" {
+			jiraOpts.client.IssueType = "Task"
+		}
+		jiraRules := os.Getenv("JIRA_RULES")
+		if jiraRules == "" {
+			jiraRules = "type=BREAKING_CHANGE"
+		}
+		if jiraOpts.rules, err = escalation.Parse(jiraRules); err != nil {
+			fmt.Printf("Error in JIRA_RULES: %v\n", err)
+			return
+		}
+		for _, label := range strings.Split(os.Getenv("JIRA_LABELS"), ",") {
+			if label = strings.TrimSpace(label); label != "" {
+				jiraOpts.labels = append(jiraOpts.labels, label)
+			}
+		}
+	}
+
+	// Breaking changes and deprecations can also get GitHub issues, one per
+	// product and run, labeled with the types of their release notes.
+	var githubOpts githubSettings
+	if repo := os.Getenv("GITHUB_REPO"); repo != "" {
+		token, err := secrets.Resolve(ctx, os.Getenv("GITHUB_TOKEN"))
+		if err != nil {
+			fmt.Printf("Error in GITHUB_TOKEN: %v\n", err)
+			return
+		}
+		if token == "" || strings.Count(repo, "/") != 1 {
+			fmt.Println("Set GITHUB_REPO=<owner>/<name> and GITHUB_TOKEN= in environment variables to open GitHub issues")
+			return
+		}
+		githubOpts.client = &github.Client{APIURL: os.Getenv("GITHUB_API_URL"), Repo: repo, Token: token}
+		githubRules := os.Getenv("GITHUB_ISSUE_RULES")
+		if githubRules == "" {
+			githubRules = "type in (BREAKING_CHANGE, DEPRECATION)"
+		}
+		if githubOpts.rules, err = escalation.Parse(githubRules); err != nil {
+			fmt.Printf("Error in GITHUB_ISSUE_RULES: %v\n", err)
+			return
+		}
+		for _, label := range strings.Split(os.Getenv("GITHUB_ISSUE_LABELS"), ",") {
+			if label = strings.TrimSpace(label); label != "" {
+				githubOpts.labels = append(githubOpts.labels, label)
+			}
+		}
+		githubOpts.opened = make(map[string]bool)
+	}
+
+	// Read optional thresholds on the release notes of the whole digest,
+	// marking it urgent and notifying a channel such as leadership's when
+	// exceeded.
+	if escalationOpts.thresholds, err = escalation.ParseThresholds(os.Getenv("ALERT_THRESHOLDS")); err != nil {
+		fmt.Printf("Error in ALERT_THRESHOLDS: %v\n", err)
+		return
+	}
+	escalationOpts.thresholdWebhook = os.Getenv("ALERT_WEBHOOK")
+	if len(escalationOpts.thresholds) > 0 && escalationOpts.thresholdWebhook == "" {
+		fmt.Println("Set ALERT_WEBHOOK= in environment variables to use ALERT_THRESHOLDS")
+		return
+	}
+
+	// Webhooks are reached through the shared HTTP client or the proxy and
+	// certificates of their channel, with extra headers and signatures.
+	if err := setWebhookClients(ctx); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// Channels may post to Matrix rooms and Zulip streams instead of webhooks.
+	if err := setTargetCredentials(ctx); err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// Failed sends are handed to a Cloud Tasks queue calling the send function,
+	// if one is configured, instead of being lost.
+	notify.SetRetrier(nil)
+	if retryQueue := os.Getenv("RETRY_QUEUE"); retryQueue != "" {
+		sendURL := os.Getenv("RETRY_SEND_URL")
+		if sendURL == "" {
+			fmt.Println("Set RETRY_SEND_URL= in environment variables to use RETRY_QUEUE")
+			return
+		}
+		notify.SetRetrier(&tasks.Queue{Name: retryQueue, SendURL: sendURL, ServiceAccount: os.Getenv("RETRY_SERVICE_ACCOUNT")})
+	}
 
 	// Read environment variables for webhook channels to send messages to by specific Release Note Type if required
 	chGeneral := os.Getenv("GENERAL") // General is used for everything except if others are specified
@@ -87,14 +637,25 @@ func digest(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	// A local run without channels renders every release note type under
+	// GENERAL; its webhook is never called.
+	if chGeneral == "" && !atLeastOneSpecificChannelSet && local != nil {
+		chGeneral = "local"
+	}
+
 	if chGeneral == "" && !atLeastOneSpecificChannelSet {
 		fmt.Println("Error: At least one channel environment variable needs to be provided (either GENERAL or any of the specific channels).")
 		return
 	}
-	// Create a struct for Release Note Type mappped to a Webhook URI
+	// Create a struct for Release Note Type mappped to a Webhook URI, and
+	// the further targets the channel fans out to
 	type Channel struct {
 		ReleasetNoteType string
 		WebhookURL       string
+		Mirrors          []string
+	}
+	targetsOf := func(c Channel) []string {
+		return append([]string{c.WebhookURL}, c.Mirrors...)
 	}
 	// Create a slice for added Channels
 	var activeChannels []Channel
@@ -102,140 +663,1136 @@ func digest(w http.ResponseWriter, r *http.Request) {
 	var noActiveChannel []string
 
 	// Populate the slice with non-empty channels, except of GENERAL
-	channelNames := []string{"BREAKING_CHANGE", "DEPRECATION", "FEATURE", "FIX", "ISSUE", "LIBRARIES", "NON_BREAKING_CHANGE", "SECURITY_BULLETIN", "SERVICE_ANNOUNCEMENT"}
-
 	for i, v := range channels {
-		if v != "" {
-			activeChannels = append(activeChannels, Channel{ReleasetNoteType: channelNames[i], WebhookURL: v})
-		} else if v == "" {
-			noActiveChannel = append(noActiveChannel, channelNames[i])
+		if targets := webhookTargets(v); len(targets) > 0 {
+			activeChannels = append(activeChannels, Channel{ReleasetNoteType: allReleaseNoteTypes[i], WebhookURL: targets[0], Mirrors: targets[1:]})
+		} else {
+			noActiveChannel = append(noActiveChannel, allReleaseNoteTypes[i])
 		}
 	}
 
 	// Print the active channels
 	fmt.Println("Active channels for the corresponding Release Note Types:")
 	for _, c := range activeChannels {
-		fmt.Printf("Release note type: %s: \n\t%s\n\n", c.ReleasetNoteType, c.WebhookURL)
+		fmt.Printf("Release note type: %s: \n\t%s\n\n", c.ReleasetNoteType, strings.Join(targetsOf(c), "\n\t"))
 	}
 
-	fmt.Println("--------------------------------------------------")
-	// Print the list of products with release notes.
-	fmt.Printf("Querying for products with release notes for the last %d days...\n\n", cadenceInt)
+	// Messages for channels outside their delivery window are queued in the
+	// state store and flushed by the first run inside the window.
+	deliveryChannels := activeChannels
+	if targets := webhookTargets(chGeneral); len(targets) > 0 {
+		chGeneral = targets[0]
+		deliveryChannels = append(deliveryChannels, Channel{ReleasetNoteType: "GENERAL", WebhookURL: chGeneral, Mirrors: targets[1:]})
+	}
+	mirrors := make(map[string][]string)
+	for _, c := range deliveryChannels {
+		if len(c.Mirrors) > 0 {
+			mirrors[c.ReleasetNoteType] = c.Mirrors
+		}
+	}
+	windows := make(map[string]window.Window)
+	needsQueue := false
 
-	// For each active channel, find release not types descriptions
-	for _, c := range activeChannels {
+	// Experimental behavior is enabled with feature flags, for every channel
+	// or one at a time.
+	globalFeatures, err := flags.Parse(os.Getenv("FEATURES"))
+	if err != nil {
+		fmt.Printf("Error in FEATURES: %v\n", err)
+		return
+	}
+	features := make(map[string]flags.Set)
 
-		queryProductsbyReleaseType, err := products.GetProductsbyReleaseType(ctx, projectID, c.ReleasetNoteType, cadence)
-		if err != nil {
-			log.Fatalf("Error querying for release notes by type: %v", err)
+	// Cards show an icon in their header, the product's own or the Google
+	// Cloud logo.
+	card := digest.Card{Icon: digest.DefaultCardIcon}
+	if icon := os.Getenv("CARD_ICON"); icon != "" {
+		card.Icon = icon
+		if icon == "none" {
+			card.Icon = ""
+		}
+	}
+	if card.Icons, err = digest.ParseIcons(os.Getenv("CARD_ICONS")); err != nil {
+		fmt.Printf("Error in CARD_ICONS: %v\n", err)
+		return
+	}
+
+	cadences := make(map[string]int)
+	for _, c := range deliveryChannels {
+		// A channel may cover a different number of days, e.g. one day for a
+		// channel triggered daily.
+		if cadences[c.ReleasetNoteType], err = channelCadence(c.ReleasetNoteType, cadenceInt); err != nil {
+			fmt.Println(err)
+			return
 		}
 
-		// Announce the list and count of products with release notes to the webhook.
-		notify.Announce(ctx, c.WebhookURL, cadenceInt, queryProductsbyReleaseType)
+		w, err := window.Parse(channelSetting(c.ReleasetNoteType, "DELIVERY_WINDOW"), channelSetting(c.ReleasetNoteType, "TIMEZONE"))
 		if err != nil {
-			log.Fatalf("Error sending to Webhook: %v", err)
+			fmt.Printf("Error in delivery window for %s: %v\n", c.ReleasetNoteType, err)
+			return
 		}
+		windows[c.ReleasetNoteType] = w
+		needsQueue = needsQueue || !w.Always()
 
-		for _, t := range queryProductsbyReleaseType {
-			queryReleaseNotesbyType, err := releasenotes.GetReleaseNotesbyType(ctx, projectID, t.Product, c.ReleasetNoteType, cadence)
-			if err != nil {
-				log.Fatalf("Error querying for release notes by type: %v", err)
+		if action := os.Getenv(c.ReleasetNoteType + "_COMPLIANCE_ACTION"); action != "" {
+			if complianceOpts.actions == nil {
+				complianceOpts.actions = make(map[string]string)
 			}
-
-			// Create a slice of strings to hold the release notes.
-			var releaseNotesSlice []string
-			for _, r := range queryReleaseNotesbyType {
-				releaseNotesSlice = append(releaseNotesSlice, r.ReleaseNoteType, r.Description)
+			if complianceOpts.actions[c.ReleasetNoteType], err = compliance.ParseAction(action); err != nil {
+				fmt.Printf("Error in %s_COMPLIANCE_ACTION: %v\n", c.ReleasetNoteType, err)
+				return
 			}
+		}
+
+		if features[c.ReleasetNoteType], err = flags.Parse(os.Getenv("FEATURES"), os.Getenv(c.ReleasetNoteType+"_FEATURES")); err != nil {
+			fmt.Printf("Error in %s_FEATURES: %v\n", c.ReleasetNoteType, err)
+			return
+		}
+	}
+
+	// Blocked summaries must be reported to someone.
+	blocks := complianceOpts.defaultAction == compliance.ActionBlock
+	for _, action := range complianceOpts.actions {
+		blocks = blocks || action == compliance.ActionBlock
+	}
+	if blocks && complianceOpts.filter != nil && complianceOpts.opsWebhook == "" && local == nil {
+		fmt.Println("Set OPS_WEBHOOK= in environment variables to block summaries with COMPLIANCE_ACTION=block")
+		return
+	}
+
+	var stateStore store.Store
+	if local == nil {
+		stateStore, err = store.New(ctx, os.Getenv("STATE_BUCKET"), os.Getenv("STATE_DIR"))
+		if err != nil {
+			fmt.Printf("Error opening state store: %v\n", err)
+			return
+		}
+	}
+	if needsQueue && stateStore == nil && local == nil {
+		fmt.Println("Set STATE_BUCKET= or STATE_DIR= in environment variables to queue messages outside the delivery window")
+		return
+	}
+	// Issues opened are remembered in the state store, if any.
+	jiraOpts.store = stateStore
+	githubOpts.store = stateStore
 
-			// Summarize the release notes using the Vertex AI Generative Model.
-			fmt.Printf("Asking for summary with model %s\n", model)
-			summaryResult, err := summarize.Summarize(ctx, projectID, model, modelLocation, t.Product, releaseNotesSlice)
+	// Overlapping runs, e.g. a retry of Cloud Scheduler while the first run
+	// is still summarizing, would post to the same channels twice, so a run
+	// holds a lease on its channels in the state store. A run finding them
+	// held waits up to RUN_LOCK_WAIT, then gives up.
+	unlock := func() {}
+	if stateStore != nil && os.Getenv("RUN_LOCK") != "false" && !st.partial() {
+		ttl, err := optionalDuration("RUN_LOCK_TTL")
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if ttl == 0 {
+			ttl = defaultRunLockTTL
+		}
+		patience, err := optionalDuration("RUN_LOCK_WAIT")
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		// Only the channels and teams the trigger selected are locked, so
+		// runs delivering to different channels do not wait for each other.
+		var names []string
+		for _, c := range deliveryChannels {
+			if selected.has(c.ReleasetNoteType) {
+				names = append(names, c.ReleasetNoteType)
+			}
+		}
+		if routingFile := os.Getenv("ROUTING_FILE"); routingFile != "" && selected.teams() {
+			routes, err := routing.Load(routingFile)
 			if err != nil {
-				log.Fatalf("Error summarizing: %v", err)
+				fmt.Println(err)
+				return
+			}
+			for _, team := range routes.Teams() {
+				if selected.has(team) {
+					names = append(names, team)
+				}
 			}
+		}
+		unlock, err = lockChannels(ctx, stateStore, names, ttl, patience)
+		var held *lease.HeldError
+		if errors.As(err, &held) {
+			fmt.Printf("Run already in progress: %v\n", held)
+			http.Error(w, fmt.Sprintf("run already in progress: %v", held), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			fmt.Printf("Error locking the channels of the run: %v\n", err)
+			return
+		}
+		defer unlock()
+	}
 
-			// Send the summary of release notes to the webhook.
-			fmt.Print("Sending summary via webhook...")
-			sendToWebhook, err := notify.SendToWebhook(ctx, t.Product, summaryResult, c.WebhookURL)
-			if err != nil {
-				log.Fatalf("Error sending via webhook: %v", err)
+	// Digests may be held for a reviewer's approval before delivery.
+	approval, err := loadApproval(ctx)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	if local != nil || st.partial() {
+		approval = nil
+	}
+	if (approval != nil || draft || publish > 0 || st != nil) && stateStore == nil {
+		fmt.Println("Set STATE_BUCKET= or STATE_DIR= in environment variables to use APPROVAL_WEBHOOK, DRAFT, publish or steps")
+		return
+	}
+
+	// A stored digest is published once; a second approval or publish
+	// request is refused.
+	if publish > 0 {
+		err := stateStore.Create(ctx, fmt.Sprintf("published/digest-%d", publish), []byte(time.Now().Format(time.RFC3339)))
+		if errors.Is(err, store.ErrExists) {
+			http.Error(w, fmt.Sprintf("digest #%d was already published", publish), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			fmt.Printf("Error claiming digest #%d for publishing: %v\n", publish, err)
+			return
+		}
+		fmt.Printf("Publishing digest #%d.\n", publish)
+	}
+
+	now := time.Now()
+	for _, c := range deliveryChannels {
+		if stateStore == nil || st.partial() {
+			break
+		}
+		queue := outbox.New(stateStore, c.ReleasetNoteType)
+		if !windows[c.ReleasetNoteType].Open(now) {
+			fmt.Printf("Channel %s is outside its delivery window (%s), queuing messages.\n", c.ReleasetNoteType, windows[c.ReleasetNoteType])
+			for _, target := range targetsOf(c) {
+				notify.Hold(target, queue)
+				defer notify.Release(target)
 			}
-			fmt.Printf(" %s\n", sendToWebhook)
+			continue
 		}
-		// Send a closing message to the webhook.
+		sent, err := queue.Flush(ctx, notify.SendQueued)
+		if err != nil {
+			fmt.Printf("Error flushing queued messages for %s: %v\n", c.ReleasetNoteType, err)
+		}
+		if sent > 0 {
+			fmt.Printf("Delivered %d queued messages to %s.\n", sent, c.ReleasetNoteType)
+		}
+	}
 
-		if len(queryProductsbyReleaseType) > 0 {
-			fmt.Print("Closing message...")
-			anyMsg := "That's all folks!"
-			closeMessage, err := notify.ClosingMessage(ctx, c.WebhookURL, anyMsg)
-			if err != nil {
-				log.Fatalf("Error closing message: %v", err)
+	// A flush-only request delivers queued messages without running a digest.
+	if r.URL.Query().Get("flush") == "true" {
+		return
+	}
+
+	// With a state store every run gets a sequential number and its summaries
+	// are archived, so the digest can be referenced later.
+	record := &archive.Digest{Created: now, Cadence: cadenceInt}
+	if publish > 0 {
+		record.Number = publish
+	} else if st.is("summarize") {
+		record.Number = st.Digest
+	} else if stateStore != nil {
+		record.Number, err = archive.NextNumber(ctx, stateStore)
+		if err != nil {
+			fmt.Printf("Error assigning digest number: %v\n", err)
+			return
+		}
+	}
+	if stateStore != nil {
+		archiveURL := os.Getenv("ARCHIVE_BASE_URL")
+		if archiveURL == "" && os.Getenv("STATE_BUCKET") != "" {
+			archiveURL = "https://storage.cloud.google.com/" + os.Getenv("STATE_BUCKET")
+		}
+		announceOpts.Number = record.Number
+		announceOpts.Permalink = archive.Permalink(archiveURL, record.Number)
+		fmt.Printf("Running digest #%d\n", record.Number)
+	}
+	m := i18n.M()
+	closingMsg := m.Closing
+	if announceOpts.Permalink != "" {
+		closingMsg += " " + fmt.Sprintf(m.ArchivedAt, record.Number, fmt.Sprintf("<%s|%s>", announceOpts.Permalink, announceOpts.Permalink))
+	} else if record.Number > 0 {
+		closingMsg += " " + fmt.Sprintf(m.DigestNumber, record.Number)
+	}
+
+	// Products with many release notes can link the full notes, uploaded to
+	// the state bucket and shared through a signed URL.
+	attachments := attachmentSettings{serviceAccount: os.Getenv("SIGNING_SERVICE_ACCOUNT"), store: stateStore}
+	if attachments.threshold, err = optionalInt("NOTES_ATTACHMENT_THRESHOLD"); err != nil {
+		fmt.Println(err)
+		return
+	}
+	if attachments.expires, err = optionalDuration("NOTES_ATTACHMENT_EXPIRY"); err != nil {
+		fmt.Println(err)
+		return
+	}
+	// The raw release notes of every channel can be exported too, and linked
+	// from its closing message.
+	if spec := os.Getenv("NOTES_EXPORT"); spec != "" {
+		for _, format := range strings.Split(spec, ",") {
+			format = strings.ToLower(strings.TrimSpace(format))
+			if format != "csv" && format != "json" {
+				fmt.Printf("Error in NOTES_EXPORT: unsupported format %q, use csv or json\n", format)
+				return
 			}
-			fmt.Printf(" %s\n\n", closeMessage)
+			attachments.exportFormats = append(attachments.exportFormats, format)
+		}
+	}
+	if local != nil {
+		// A local run stores nothing to link.
+		attachments.threshold, attachments.exportFormats = 0, nil
+	}
+	if attachments.threshold > 0 || len(attachments.exportFormats) > 0 {
+		signer, ok := stateStore.(store.URLSigner)
+		if !ok {
+			fmt.Println("Set STATE_BUCKET= in environment variables to use NOTES_ATTACHMENT_THRESHOLD or NOTES_EXPORT")
+			return
 		}
+		attachments.signer = signer
 	}
 
-	// Print noActiveChannels
-	if chGeneral != "" {
-		fmt.Println("Since GENERAL channel is set, release note types not send to specific channels will be sent to GENERAL channel:")
-		for _, v := range noActiveChannel {
-			fmt.Printf(" - %s\n", v)
+	// The whole digest can also be emailed as one HTML message, to EMAIL_TO,
+	// to recipient profiles receiving some of the channels and to Google
+	// Groups archiving it.
+	profileSpec := os.Getenv("EMAIL_PROFILES")
+	if to := os.Getenv("EMAIL_TO"); to != "" {
+		profileSpec = "all: * -> " + to + ";" + profileSpec
+	}
+	profiles, err := email.ParseProfiles(profileSpec)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	profiles = append(profiles, email.GroupProfiles(os.Getenv("GOOGLE_GROUPS"))...)
+	// Drafts can be emailed to editors through the same provider.
+	var draftEditors []string
+	for _, to := range strings.Split(os.Getenv("DRAFT_EMAIL"), ",") {
+		if to = strings.TrimSpace(to); to != "" {
+			draftEditors = append(draftEditors, to)
+		}
+	}
+	var emailOpts emailSettings
+	if len(profiles) > 0 || len(draftEditors) > 0 {
+		emailOpts = emailSettings{
+			profiles:       profiles,
+			from:           os.Getenv("EMAIL_FROM"),
+			subject:        os.Getenv("EMAIL_SUBJECT"),
+			unsubscribeURL: os.Getenv("EMAIL_UNSUBSCRIBE_URL"),
+		}
+		if emailOpts.from == "" {
+			fmt.Println("Set EMAIL_FROM= in environment variables to use EMAIL_TO, EMAIL_PROFILES, GOOGLE_GROUPS or DRAFT_EMAIL")
+			return
+		}
+		if emailOpts.sender, err = emailSender(ctx); err != nil {
+			fmt.Println(err)
+			return
+		}
+		if emailOpts.template, err = email.LoadTemplate(os.Getenv("EMAIL_TEMPLATE")); err != nil {
+			fmt.Println(err)
+			return
 		}
-		fmt.Printf("GENERAL channel: %s\n", chGeneral)
+	}
 
-		fmt.Println("--------------------------------------------------")
+	// Summaries can link the expand function posting their full release
+	// notes on demand.
+	var expander *expander
+	if local == nil {
+		if expander, err = loadExpander(ctx, stateStore); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	// Summaries may link the recent releases of the Terraform provider
+	// touching their product. The digest goes out without them if GitHub
+	// cannot be reached.
+	var providerReleases []terraform.Release
+	if os.Getenv("TERRAFORM_CHANGES") == "true" && publish == 0 {
+		days, err := optionalInt("TERRAFORM_DAYS")
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if days == 0 {
+			days = 30
+		}
+		token, err := secrets.Resolve(ctx, os.Getenv("GITHUB_TOKEN"))
+		if err != nil {
+			fmt.Printf("Error in GITHUB_TOKEN: %v\n", err)
+			return
+		}
+		if providerReleases, err = terraform.Fetch(ctx, token, time.Now().AddDate(0, 0, -days)); err != nil {
+			fmt.Printf("%v, continuing without provider changes\n", err)
+		}
+	}
+
+	// Products with far more release notes than usual can be flagged in the
+	// announcement, measured against the history of past runs.
+	var activity *archive.Activity
+	if os.Getenv("UNUSUAL_ACTIVITY") == "true" && publish == 0 && local == nil && !st.is("summarize") {
+		if stateStore == nil {
+			fmt.Println("Set STATE_BUCKET= or STATE_DIR= in environment variables to use UNUSUAL_ACTIVITY")
+			return
+		}
+		if activity, err = archive.LoadActivity(ctx, stateStore); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
+
+	// The deprecations announced by the release notes are tracked until
+	// their deadline, for the deprecations function to list and remind of.
+	var tracker *deprecations.Board
+	if os.Getenv("DEPRECATION_TRACKER") == "true" && publish == 0 && local == nil && !st.is("summarize") {
+		if stateStore == nil {
+			fmt.Println("Set STATE_BUCKET= or STATE_DIR= in environment variables to use DEPRECATION_TRACKER")
+			return
+		}
+		if tracker, err = deprecations.Load(ctx, stateStore); err != nil {
+			fmt.Println(err)
+			return
+		}
+	}
 
-		fmt.Printf("Querying for remainng relese notes the last %d days...\n\n", cadenceInt)
+	// Products of the digest can be pushed as compact notifications to a
+	// Firebase Cloud Messaging topic.
+	var pushTopic *push.FCM
+	if topic := os.Getenv("FCM_TOPIC"); topic != "" {
+		pushTopic = &push.FCM{ProjectID: os.Getenv("FCM_PROJECT_ID"), Topic: topic}
+		if pushTopic.ProjectID == "" {
+			pushTopic.ProjectID = projectID
+		}
+	}
 
-		queryPrducts, err := products.GetProducts(ctx, projectID, noActiveChannel, cadence)
+	run := &run{
+		projectID:        projectID,
+		model:            model,
+		modelLocation:    modelLocation,
+		cadence:          cadence,
+		cadenceInt:       cadenceInt,
+		noteOpts:         noteOpts,
+		productOrder:     productOrder,
+		productPriority:  productPriority,
+		typeSections:     typeSections,
+		verifyModel:      verifyModel,
+		verifyAction:     verifyAction,
+		impact:           impactOpts,
+		citations:        citations,
+		variants:         variants,
+		feedbackSecret:   feedbackSecret,
+		typePrompts:      typePrompts,
+		docsLinks:        docsLinks,
+		providerReleases: providerReleases,
+		expander:         expander,
+		concurrency:      concurrency,
+		deadline:         deadline,
+		activity:         activity,
+		deprecations:     tracker,
+		unlock:           unlock,
+		announceOpts:     announceOpts,
+		batchSize:        batchSize,
+		batchMaxChars:    batchMaxChars,
+		messageMaxChars:  messageMaxChars,
+		closingMsg:       closingMsg,
+		escalation:       escalationOpts,
+		pager:            pagerOpts,
+		jira:             jiraOpts,
+		github:           githubOpts,
+		compliance:       complianceOpts,
+		scrubber:         scrubber,
+		attachments:      attachments,
+		email:            emailOpts,
+		push:             pushTopic,
+		globalFeatures:   globalFeatures,
+		card:             card,
+		features:         features,
+		mirrors:          mirrors,
+		doc:              &digest.Document{Number: record.Number, Created: now, Cadence: cadenceInt, Permalink: announceOpts.Permalink, FeedbackURL: feedbackURL},
+		record:           record,
+		report:           report.New(record.Number),
+	}
+
+	// A canary configuration promoted on the next run replaces the real one
+	// once it was sent to the canary webhook.
+	if canary != nil && canary.promote == "next-run" {
+		if stateStore == nil {
+			fmt.Println("Set STATE_BUCKET= or STATE_DIR= in environment variables to use CANARY_PROMOTE=next-run")
+			return
+		}
+		tested, err := canary.tested(ctx, stateStore)
 		if err != nil {
-			log.Fatalf("Error querying for release notes by type: %v", err)
+			fmt.Println(err)
+			return
+		}
+		if tested {
+			fmt.Println("Promoting the canary configuration to all channels.")
+			run = canary.apply(run)
+			canary = nil
+		}
+	}
+
+	// A digest going straight to its channels is streamed: each product is
+	// sent as soon as it is summarized, and its release notes are dropped
+	// once sent. Drafts, approvals, canaries, steps, alerting thresholds,
+	// channels fanning out to several targets and products ordered by impact
+	// need the whole digest first.
+	run.stream = !draft && approval == nil && canary == nil && publish == 0 && local == nil && st == nil && len(escalationOpts.thresholds) == 0 && len(mirrors) == 0 && productOrder != products.OrderImpact
+	// A fetch step leaves the summaries to the summarize steps.
+	run.fetchOnly = st.is("fetch")
+
+	// A published digest is delivered as it was stored, so its summaries are
+	// not rebuilt.
+	if publish > 0 {
+		if run.doc, err = digest.Load(ctx, stateStore, publish); err != nil {
+			fmt.Println(err)
+			return
 		}
+		record.Created = run.doc.Created
 
-		// Announce the list and count of products with release notes to the webhook.
-		notify.Announce(ctx, chGeneral, cadenceInt, queryPrducts)
+		// The summaries of a digest built in steps were stored by the
+		// summarize steps; it is not delivered before all are done.
+		missing, err := run.doc.ApplySummaries(ctx, stateStore)
 		if err != nil {
-			log.Fatalf("Error sending to Webhook: %v", err)
+			fmt.Println(err)
+			return
+		}
+		if len(missing) > 0 {
+			if err := stateStore.Delete(ctx, fmt.Sprintf("published/digest-%d", publish)); err != nil {
+				fmt.Printf("Error releasing digest #%d for publishing: %v\n", publish, err)
+			}
+			http.Error(w, fmt.Sprintf("digest #%d is not summarized yet: %s", publish, strings.Join(missing, ", ")), http.StatusConflict)
+			return
 		}
 
-		for _, t := range queryPrducts {
-			queryReleaseNotes, err := releasenotes.GetReleaseNotes(ctx, projectID, t.Product, noActiveChannel, cadence)
+		// Editors may have replaced summaries of the stored digest.
+		overrides, err := run.doc.ApplyOverrides(ctx, stateStore)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		for _, o := range overrides {
+			fmt.Printf("Summary of %s in %s replaced by the editor's override %s.\n", o.Product, o.Channel, o.Key)
+			run.report.RecordOverride(o.Channel, o.Product, o.Key, o.Original, o.Summary)
+		}
+	} else if st.is("summarize") {
+		if run.doc, err = digest.Load(ctx, stateStore, st.Digest); err != nil {
+			fmt.Println(err)
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if _, err := run.doc.ApplySummaries(ctx, stateStore); err != nil {
+			fmt.Println(err)
+			return
+		}
+		run.summarizeStep(ctx, stateStore, st)
+	} else {
+		// Products owned by a team in the routing file go to that team's webhook
+		// with all their release notes, before the per-type routing below.
+		// Owned products are left out of the other channels even when the teams
+		// are not selected, as they get them on their own schedule.
+		var routes *routing.Routes
+		if routingFile := os.Getenv("ROUTING_FILE"); routingFile != "" {
+			routes, err = routing.Load(routingFile)
 			if err != nil {
-				log.Fatalf("Error querying for release notes by type: %v", err)
+				fmt.Println(err)
+				return
 			}
+		}
 
-			// Create a slice of strings to hold the release notes.
-			var releaseNotesSlice []string
-			for _, r := range queryReleaseNotes {
-				releaseNotesSlice = append(releaseNotesSlice, r.ReleaseNoteType, r.Description)
+		// A release notes file may replace BigQuery, e.g. for local
+		// development. Otherwise every query may read a copy of the release
+		// notes of the longest cadence instead of scanning the public table
+		// again.
+		notesFile := os.Getenv("NOTES_FILE")
+		if err := source.LoadFile(notesFile); err != nil {
+			fmt.Println(err)
+			return
+		}
+		if dataset := os.Getenv("MATERIALIZE_DATASET"); dataset != "" && notesFile == "" {
+			days := cadenceInt
+			for _, d := range cadences {
+				days = max(days, d)
 			}
-
-			// Summarize the release notes using the Vertex AI Generative Model.
-			fmt.Printf("Asking for summary with model %s\n", model)
-			summaryResult, err := summarize.Summarize(ctx, projectID, model, modelLocation, t.Product, releaseNotesSlice)
+			for _, d := range routes.Cadences() {
+				days = max(days, d)
+			}
+			drop, err := source.Materialize(ctx, projectID, dataset, days)
 			if err != nil {
-				log.Fatalf("Error summarizing: %v", err)
+				fmt.Println(err)
+				return
+			}
+			defer drop(ctx)
+		}
+
+		if routingFile := os.Getenv("ROUTING_FILE"); routingFile != "" && selected.teams() {
+			// Teams with their own cadence get the products of that many days.
+			teamCadences := []int{cadenceInt}
+			for _, days := range routes.Cadences() {
+				if days != cadenceInt {
+					teamCadences = append(teamCadences, days)
+				}
+			}
+			var teams []routing.Team
+			teamProducts := make(map[routing.Team][]products.Product)
+			for _, days := range teamCadences {
+				fmt.Println("--------------------------------------------------")
+				fmt.Printf("Querying for products owned by teams in %s for the last %d days...\n\n", routingFile, days)
+
+				allProducts, err := products.GetProducts(ctx, projectID, allReleaseNoteTypes, strconv.Itoa(days))
+				if err != nil {
+					fmt.Printf("Error querying for release notes by type: %v\n", err)
+					return
+				}
+				for _, p := range allProducts {
+					team, ok := routes.Owner(p.Product)
+					if !ok || teamCadence(team, cadenceInt) != days {
+						continue
+					}
+					if _, seen := teamProducts[team]; !seen {
+						teams = append(teams, team)
+					}
+					teamProducts[team] = append(teamProducts[team], p)
+				}
+			}
+
+			for _, team := range teams {
+				if !selected.has(team.Name) {
+					continue
+				}
+				days := teamCadence(team, cadenceInt)
+				fmt.Printf("Team %s owns %d products.\n", team.Name, len(teamProducts[team]))
+				run.buildChannel(ctx, team.Name, team.WebhookURL, days, teamProducts[team], allReleaseNoteTypes, func(ctx context.Context, product string) ([]releasenotes.ReleaseNote, error) {
+					return releasenotes.GetReleaseNotes(ctx, projectID, product, allReleaseNoteTypes, strconv.Itoa(days), noteOpts)
+				})
+			}
+		}
+
+		fmt.Println("--------------------------------------------------")
+		// Print the list of products with release notes.
+		fmt.Printf("Querying for products with release notes for the last %d days...\n\n", cadenceInt)
+
+		// For each active channel, find release not types descriptions
+		for _, c := range activeChannels {
+			if !selected.has(c.ReleasetNoteType) {
+				continue
 			}
+			cadence := cadences[c.ReleasetNoteType]
 
-			// Send the summary of release notes to the webhook.
-			fmt.Print("Sending summary via webhook...")
-			sendToWebhook, err := notify.SendToWebhook(ctx, t.Product, summaryResult, chGeneral)
+			queryProductsbyReleaseType, err := products.GetProductsbyReleaseType(ctx, projectID, c.ReleasetNoteType, strconv.Itoa(cadence))
 			if err != nil {
-				log.Fatalf("Error sending via webhook: %v", err)
+				fmt.Printf("Error querying for release notes by type: %v\n", err)
+				return
 			}
-			fmt.Printf(" %s\n\n", sendToWebhook)
+
+			releaseNoteType := c.ReleasetNoteType
+			run.buildChannel(ctx, c.ReleasetNoteType, c.WebhookURL, cadence, withoutOwned(queryProductsbyReleaseType, routes), []string{releaseNoteType}, func(ctx context.Context, product string) ([]releasenotes.ReleaseNote, error) {
+				return releasenotes.GetReleaseNotesbyType(ctx, projectID, product, releaseNoteType, strconv.Itoa(cadence), noteOpts)
+			})
 		}
-		// Send a closing message to the webhook.
 
-		if len(queryPrducts) > 0 {
-			fmt.Print("Closing message...")
-			anyMsg := "That's all folks!"
-			closeMessage, err := notify.ClosingMessage(ctx, chGeneral, anyMsg)
+		// Print noActiveChannels
+		if chGeneral != "" && selected.has("GENERAL") {
+			cadence := cadences["GENERAL"]
+			fmt.Println("Since GENERAL channel is set, release note types not send to specific channels will be sent to GENERAL channel:")
+			for _, v := range noActiveChannel {
+				fmt.Printf(" - %s\n", v)
+			}
+			fmt.Printf("GENERAL channel: %s\n", chGeneral)
+
+			fmt.Println("--------------------------------------------------")
+
+			fmt.Printf("Querying for remainng relese notes the last %d days...\n\n", cadence)
+
+			queryPrducts, err := products.GetProducts(ctx, projectID, noActiveChannel, strconv.Itoa(cadence))
 			if err != nil {
-				log.Fatalf("Error closing message: %v", err)
+				fmt.Printf("Error querying for release notes by type: %v\n", err)
+				return
+			}
+
+			run.buildChannel(ctx, "GENERAL", chGeneral, cadence, withoutOwned(queryPrducts, routes), noActiveChannel, func(ctx context.Context, product string) ([]releasenotes.ReleaseNote, error) {
+				return releasenotes.GetReleaseNotes(ctx, projectID, product, noActiveChannel, strconv.Itoa(cadence), noteOpts)
+			})
+		}
+	}
+
+	// The note counts of this run join the baseline of the next ones.
+	if run.activity != nil {
+		if err := run.activity.Save(ctx, stateStore); err != nil {
+			fmt.Println(err)
+		}
+	}
+	if run.deprecations != nil {
+		if closed := run.deprecations.Prune(time.Now()); closed > 0 {
+			fmt.Printf("Closed %d deprecations past their deadline\n", closed)
+		}
+		if err := run.deprecations.Save(ctx, stateStore); err != nil {
+			fmt.Println(err)
+		}
+	}
+
+	// The effective configuration is archived with the digest, so it can be
+	// reproduced or audited later.
+	if stateStore != nil && publish == 0 && !st.is("summarize") && record.Number > 0 {
+		if err := run.configSnapshot().Save(ctx, stateStore); err != nil {
+			fmt.Printf("Error archiving configuration of digest #%d: %v\n", record.Number, err)
+		}
+	}
+
+	// Try the canary configuration on the same data before the real
+	// channels get the digest.
+	if canary != nil {
+		canary.deliver(ctx, run)
+		if stateStore != nil {
+			if err := stateStore.Put(ctx, canaryTestedKey, []byte(canary.fingerprint())); err != nil {
+				fmt.Printf("Error saving canary state: %v\n", err)
+			}
+		}
+	}
+
+	// Summaries are scrubbed of sensitive strings and screened for banned
+	// phrases before anyone sees them; those of a streamed digest were,
+	// one by one, on their way to the channels. Those of a digest built in
+	// steps are once it is delivered.
+	if !run.stream && !st.partial() {
+		run.scrub(ctx)
+		run.screen(ctx)
+	}
+
+	// A draft is only stored for the editor, and a digest held for approval
+	// only sent to the reviewers; publishing delivers them later.
+	switch {
+	case local != nil:
+		if err := local.write(run.doc); err != nil {
+			fmt.Println(err)
+			return
+		}
+	case st.partial():
+		// A step answers with the products of the digest instead of the run
+		// report.
+		if err := run.writeStep(ctx, w, stateStore, st); err != nil {
+			fmt.Println(err)
+		}
+		return
+	case draft:
+		if err := run.writeDraft(ctx, stateStore, draftEditors); err != nil {
+			fmt.Println(err)
+			return
+		}
+	case approval != nil && publish == 0:
+		if err := approval.hold(ctx, run, stateStore); err != nil {
+			fmt.Println(err)
+			return
+		}
+	default:
+		// Every channel of the digest is built; check it against the alerting
+		// thresholds and deliver it, unless it was streamed while being
+		// built.
+		if !run.stream {
+			run.checkThresholds(ctx)
+			for _, ch := range run.doc.Channels {
+				run.deliverChannel(ctx, ch)
 			}
-			fmt.Printf(" %s\n\n", closeMessage)
 		}
+
+		// Archive the summaries of this run.
+		if stateStore != nil {
+			if err := record.Save(ctx, stateStore); err != nil {
+				fmt.Printf("Error archiving digest #%d: %v\n", record.Number, err)
+			}
+		}
+
+		run.sendEmail(ctx)
+		run.sendPush(ctx)
+	}
+
+	// Verify every intended message was confirmed by its target and flag the
+	// gaps in the run report.
+	summary := run.report.Verify()
+	fmt.Printf("Delivered %d of %d messages, %d queued.\n", summary.Confirmed, summary.Intended, summary.Queued)
+	for _, gap := range summary.Gaps {
+		fmt.Printf("WARNING: %s message for %s %s was not confirmed: %s%s\n", gap.Kind, gap.Channel, gap.Product, gap.Status, gap.Error)
+	}
+	if stateStore != nil {
+		if err := run.report.Save(ctx, stateStore); err != nil {
+			fmt.Printf("Error saving run report: %v\n", err)
+		}
+	}
+	reportJSON, err := run.report.JSON()
+	if err != nil {
+		fmt.Printf("Error encoding run report: %v\n", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(reportJSON)
+}
+
+// reloadConfig applies the configuration file set in CONFIG_FILE to the
+// environment if it changed since the last run of this instance.
+func reloadConfig(ctx context.Context) error {
+	location := os.Getenv("CONFIG_FILE")
+	changed, err := config.Reload(ctx, location)
+	if err != nil {
+		return err
+	}
+	if changed && location != "" {
+		fmt.Printf("Applied configuration from %s\n", location)
+	}
+	return nil
+}
+
+// selection is the set of channels delivered by a run, or nil for all of
+// them. Teams of the routing file are selected by name or all at once with
+// TEAMS.
+type selection map[string]bool
+
+func (s selection) has(channel string) bool {
+	return s == nil || s[channel] || (strings.HasPrefix(channel, "#") && s["TEAMS"])
+}
+
+// teams reports whether any team of the routing file may be selected.
+func (s selection) teams() bool {
+	for channel := range s {
+		if channel == "TEAMS" || strings.HasPrefix(channel, "#") {
+			return true
+		}
+	}
+	return s == nil
+}
+
+// selectChannels returns the channels selected by the trigger, either listed
+// in the channels query parameter, as in ?channels=SECURITY_BULLETIN,FIX, or
+// named by the group parameter, as in ?group=daily, with the groups defined
+// in CHANNEL_GROUPS, e.g. "daily=SECURITY_BULLETIN,FIX; weekly=GENERAL".
+func selectChannels(r *http.Request) (selection, error) {
+	q := r.URL.Query()
+	list := q.Get("channels")
+	if group := q.Get("group"); group != "" {
+		groups := make(map[string]string)
+		for _, def := range strings.Split(os.Getenv("CHANNEL_GROUPS"), ";") {
+			if name, channels, ok := strings.Cut(def, "="); ok {
+				groups[strings.TrimSpace(name)] = channels
+			}
+		}
+		channels, ok := groups[group]
+		if !ok {
+			return nil, fmt.Errorf("Error: channel group %q is not defined in CHANNEL_GROUPS", group)
+		}
+		list += "," + channels
+	}
+	if list == "" {
+		return nil, nil
+	}
+	s := make(selection)
+	for _, channel := range strings.Split(list, ",") {
+		if channel = strings.TrimSpace(channel); channel != "" {
+			s[channel] = true
+		}
+	}
+	return s, nil
+}
+
+// channelCadence returns the number of days covered by a channel, set with
+// <CHANNEL>_CADENCE or else the global cadence.
+func channelCadence(channel string, cadence int) (int, error) {
+	v := os.Getenv(channel + "_CADENCE")
+	if v == "" {
+		return cadence, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return 0, fmt.Errorf("Error converting %s_CADENCE to a positive int: %q", channel, v)
+	}
+	return n, nil
+}
+
+// teamCadence returns the number of days covered by a team of the routing
+// file, its own cadence or else the global one.
+func teamCadence(team routing.Team, cadence int) int {
+	if team.Cadence > 0 {
+		return team.Cadence
+	}
+	return cadence
+}
+
+// webhookTargets splits the setting of a channel into its webhook URLs, as a
+// channel may fan out to several targets separated by commas.
+func webhookTargets(setting string) []string {
+	var targets []string
+	for _, t := range strings.Split(setting, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			targets = append(targets, t)
+		}
+	}
+	return targets
+}
+
+// channelSetting returns the per-channel value of an optional setting, e.g.
+// GENERAL_TIMEZONE, falling back to the global one, e.g. TIMEZONE.
+func channelSetting(channel, key string) string {
+	if v := os.Getenv(channel + "_" + key); v != "" {
+		return v
+	}
+	return os.Getenv(key)
+}
+
+// channelClientOptions returns the HTTP client options of a channel setting
+// its own proxy or certificates with <CHANNEL>_WEBHOOK_* variables, based on
+// the shared options. It returns false if the channel sets none of them.
+func channelClientOptions(channel string, shared notify.ClientOptions) (notify.ClientOptions, bool, error) {
+	opts := shared
+	prefix := channel + "_"
+	var err error
+	if proxy := os.Getenv(prefix + "WEBHOOK_PROXY"); proxy != "" {
+		if opts.Proxy, err = notify.ParseProxy(proxy); err != nil {
+			return opts, false, err
+		}
+	}
+	if os.Getenv(prefix+"WEBHOOK_CA_CERT") != "" || os.Getenv(prefix+"WEBHOOK_CLIENT_CERT") != "" || os.Getenv(prefix+"WEBHOOK_CLIENT_KEY") != "" {
+		opts.TLS, err = notify.TLSConfig(channelSetting(channel, "WEBHOOK_CA_CERT"), channelSetting(channel, "WEBHOOK_CLIENT_CERT"), channelSetting(channel, "WEBHOOK_CLIENT_KEY"))
+		if err != nil {
+			return opts, false, err
+		}
+	}
+	return opts, opts.Proxy != shared.Proxy || opts.TLS != shared.TLS, nil
+}
+
+// emailSender returns the mail provider selected by EMAIL_PROVIDER, or
+// SendGrid if only its API key is set, with its credentials resolved from
+// Secret Manager references.
+func emailSender(ctx context.Context) (email.Sender, error) {
+	var missing []string
+	var resolveErr error
+	get := func(key string, required bool) string {
+		v, err := secrets.Resolve(ctx, os.Getenv(key))
+		if err != nil && resolveErr == nil {
+			resolveErr = fmt.Errorf("Error in %s: %v", key, err)
+		}
+		if v == "" && required {
+			missing = append(missing, key)
+		}
+		return v
+	}
+
+	var sender email.Sender
+	provider := os.Getenv("EMAIL_PROVIDER")
+	// A SendGrid API key without a mail relay is enough to pick SendGrid.
+	if provider == "" && os.Getenv("SENDGRID_API_KEY") != "" && os.Getenv("SMTP_ADDR") == "" && os.Getenv("SMTP_HOST") == "" {
+		provider = "sendgrid"
+	}
+	switch provider {
+	case "", "smtp":
+		// The relay is given as SMTP_ADDR=host:port, or as SMTP_HOST and
+		// SMTP_PORT.
+		addr := get("SMTP_ADDR", false)
+		if host := get("SMTP_HOST", false); addr == "" && host != "" {
+			port := os.Getenv("SMTP_PORT")
+			if port == "" {
+				port = "587"
+			}
+			addr = net.JoinHostPort(host, port)
+		}
+		if addr == "" {
+			missing = append(missing, "SMTP_ADDR")
+		}
+		sender = &email.SMTP{Addr: addr, Username: get("SMTP_USERNAME", false), Password: get("SMTP_PASSWORD", false)}
+	case "sendgrid":
+		sender = &email.SendGrid{APIKey: get("SENDGRID_API_KEY", true)}
+	case "mailgun":
+		sender = &email.Mailgun{APIKey: get("MAILGUN_API_KEY", true), Domain: get("MAILGUN_DOMAIN", true), BaseURL: get("MAILGUN_API_BASE", false)}
+	case "ses":
+		sender = &email.SES{Region: get("AWS_REGION", true), AccessKeyID: get("AWS_ACCESS_KEY_ID", true), SecretAccessKey: get("AWS_SECRET_ACCESS_KEY", true)}
+	case "gmail":
+		sender = &email.Gmail{ServiceAccount: get("GMAIL_SERVICE_ACCOUNT", true), User: get("EMAIL_FROM", true)}
+	default:
+		return nil, fmt.Errorf("Error in EMAIL_PROVIDER: unknown provider %q, use one of %s", provider, strings.Join(email.Providers, ", "))
+	}
+	if resolveErr != nil {
+		return nil, resolveErr
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("Set %s= in environment variables to send email", strings.Join(missing, "=, "))
+	}
+	return sender, nil
+}
+
+// setTargetCredentials passes the credentials of channels posting through
+// an API instead of a webhook, such as Matrix rooms, to the notify package.
+// They may reference secrets in Secret Manager.
+func setTargetCredentials(ctx context.Context) error {
+	var resolved []string
+	for _, key := range []string{"MATRIX_ACCESS_TOKEN", "ZULIP_BOT_EMAIL", "ZULIP_API_KEY", "WEBEX_BOT_TOKEN", "TELEGRAM_BOT_TOKEN"} {
+		v, err := secrets.Resolve(ctx, os.Getenv(key))
+		if err != nil {
+			return fmt.Errorf("Error in %s: %v", key, err)
+		}
+		resolved = append(resolved, v)
+	}
+	notify.SetMatrixToken(resolved[0])
+	notify.SetZulipBot(resolved[1], resolved[2])
+	notify.SetWebexToken(resolved[3])
+	notify.SetTelegramToken(resolved[4])
+	return nil
+}
+
+// setWebhookClients configures the requests to the webhooks of all channels:
+// the shared HTTP client, the clients of channels with their own proxy or
+// certificates, extra headers and signing secrets. Every function posting to
+// webhooks calls it, so a retry or a follow-up reaches a webhook the way the
+// digest did.
+func setWebhookClients(ctx context.Context) error {
+	var opts notify.ClientOptions
+	var err error
+	if opts.Timeout, err = optionalDuration("HTTP_TIMEOUT"); err != nil {
+		return err
+	}
+	if opts.MaxIdleConns, err = optionalInt("HTTP_MAX_IDLE_CONNS"); err != nil {
+		return err
+	}
+	if opts.KeepAlive, err = optionalDuration("HTTP_KEEP_ALIVE"); err != nil {
+		return err
+	}
+	if proxy := os.Getenv("WEBHOOK_PROXY"); proxy != "" {
+		if opts.Proxy, err = notify.ParseProxy(proxy); err != nil {
+			return fmt.Errorf("Error in WEBHOOK_PROXY: %v", err)
+		}
+	}
+	if opts.TLS, err = notify.TLSConfig(os.Getenv("WEBHOOK_CA_CERT"), os.Getenv("WEBHOOK_CLIENT_CERT"), os.Getenv("WEBHOOK_CLIENT_KEY")); err != nil {
+		return fmt.Errorf("Error in webhook certificates: %v", err)
+	}
+	notify.SetClientOptions(opts)
+
+	// Extra headers may reference secrets in Secret Manager.
+	notify.ResetHeaders()
+	headers, err := webhookHeaders(ctx, "WEBHOOK_HEADERS")
+	if err != nil {
+		return err
+	}
+	notify.SetHeaders("", headers)
+
+	// A channel may reach its webhooks through its own proxy, with its own
+	// certificates and headers.
+	for _, channel := range append(allReleaseNoteTypes, "GENERAL") {
+		targets := webhookTargets(os.Getenv(channel))
+		if len(targets) == 0 {
+			continue
+		}
+		channelOpts, ok, err := channelClientOptions(channel, opts)
+		if err != nil {
+			return fmt.Errorf("Error in webhook settings for %s: %v", channel, err)
+		}
+		headers, err := webhookHeaders(ctx, channel+"_WEBHOOK_HEADERS")
+		if err != nil {
+			return err
+		}
+		for _, target := range targets {
+			if ok {
+				notify.SetWebhookClient(target, channelOpts)
+			}
+			notify.SetHeaders(target, headers)
+		}
+	}
+	return setWebhookSigning(ctx)
+}
+
+// setWebhookSigning sets the signing secrets of the webhooks of all
+// channels, WEBHOOK_SIGNING_SECRET or the secret of the channel, e.g.
+// GENERAL_WEBHOOK_SIGNING_SECRET, forgetting those of a previous run.
+func setWebhookSigning(ctx context.Context) error {
+	notify.ResetSigning()
+	secret, err := secrets.Resolve(ctx, os.Getenv("WEBHOOK_SIGNING_SECRET"))
+	if err != nil {
+		return fmt.Errorf("Error in WEBHOOK_SIGNING_SECRET: %v", err)
+	}
+	notify.SetSigning("", notify.Signing{Secret: secret, Header: os.Getenv("WEBHOOK_SIGNATURE_HEADER")})
+
+	for _, channel := range append(allReleaseNoteTypes, "GENERAL") {
+		key := channel + "_WEBHOOK_SIGNING_SECRET"
+		if os.Getenv(key) == "" {
+			continue
+		}
+		secret, err := secrets.Resolve(ctx, os.Getenv(key))
+		if err != nil {
+			return fmt.Errorf("Error in %s: %v", key, err)
+		}
+		for _, target := range webhookTargets(os.Getenv(channel)) {
+			notify.SetSigning(target, notify.Signing{Secret: secret, Header: channelSetting(channel, "WEBHOOK_SIGNATURE_HEADER")})
+		}
+	}
+	return nil
+}
+
+// webhookHeaders reads the extra webhook request headers set in the
+// environment variable key, resolving Secret Manager references.
+func webhookHeaders(ctx context.Context, key string) (http.Header, error) {
+	spec, err := secrets.Resolve(ctx, os.Getenv(key))
+	if err != nil {
+		return nil, fmt.Errorf("Error in %s: %v", key, err)
+	}
+	header, err := notify.ParseHeaders(spec)
+	if err != nil {
+		return nil, fmt.Errorf("Error in %s: %v", key, err)
+	}
+	return header, nil
+}
+
+// optionalInt reads a non-negative integer environment variable, returning
+// zero if it is not set.
+func optionalInt(key string) (int, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("Error converting %s to a non-negative int: %q", key, v)
+	}
+	return n, nil
+}
+
+// generationOptions reads the generation profile of the model and the
+// settings overriding it.
+func generationOptions() (summarize.Options, error) {
+	opts, err := summarize.Profile(os.Getenv("GENERATION_PROFILE"))
+	if err != nil {
+		return opts, fmt.Errorf("Error in GENERATION_PROFILE: %v", err)
+	}
+	for key, target := range map[string]*float32{"TEMPERATURE": &opts.Temperature, "TOP_P": &opts.TopP} {
+		if v := os.Getenv(key); v != "" {
+			f, err := strconv.ParseFloat(v, 32)
+			if err != nil || f < 0 {
+				return opts, fmt.Errorf("Error converting %s to a non-negative number: %q", key, v)
+			}
+			*target = float32(f)
+		}
+	}
+	if timeout, err := optionalDuration("STREAM_TIMEOUT"); err != nil {
+		return opts, err
+	} else if timeout > 0 {
+		opts.StreamTimeout = timeout
+	}
+	for key, target := range map[string]*int32{"TOP_K": &opts.TopK, "CANDIDATE_COUNT": &opts.CandidateCount} {
+		n, err := optionalInt(key)
+		if err != nil {
+			return opts, err
+		}
+		if n > 0 {
+			*target = int32(n)
+		}
+	}
+	return opts, nil
+}
+
+// optionalDuration reads a non-negative duration environment variable such as
+// "10s", returning zero if it is not set.
+func optionalDuration(key string) (time.Duration, error) {
+	v := os.Getenv(key)
+	if v == "" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("Error converting %s to a non-negative duration: %q", key, v)
 	}
+	return d, nil
 }
@@ -0,0 +1,78 @@
+package digest
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/mpolski/gcp-release-digest/pkg/digest"
+	"github.com/mpolski/gcp-release-digest/pkg/email"
+	"github.com/mpolski/gcp-release-digest/pkg/i18n"
+	"github.com/mpolski/gcp-release-digest/pkg/report"
+	"github.com/mpolski/gcp-release-digest/pkg/store"
+)
+
+// draftKey returns the store key of the rendered draft of digest number n,
+// with the file extension ext.
+func draftKey(n int, ext string) string {
+	return fmt.Sprintf("drafts/digest-%d.%s", n, ext)
+}
+
+// writeDraft stores the digest of the run together with its rendering as
+// HTML and Markdown for an editor to read, and emails the HTML to the
+// editors. Publishing the draft delivers the stored digest unchanged.
+func (r *run) writeDraft(ctx context.Context, s store.Store, editors []string) error {
+	if err := r.doc.Save(ctx, s); err != nil {
+		return fmt.Errorf("Error storing draft of digest #%d: %v", r.doc.Number, err)
+	}
+	htmlBody, markdown := renderDraft(r.doc)
+	if err := s.Put(ctx, draftKey(r.doc.Number, "html"), []byte(htmlBody)); err != nil {
+		return fmt.Errorf("Error storing draft of digest #%d: %v", r.doc.Number, err)
+	}
+	if err := s.Put(ctx, draftKey(r.doc.Number, "md"), []byte(markdown)); err != nil {
+		return fmt.Errorf("Error storing draft of digest #%d: %v", r.doc.Number, err)
+	}
+
+	fmt.Println("--------------------------------------------------")
+	fmt.Printf("Draft of digest #%d is stored as %s, publish it with ?publish=%d\n\n", r.doc.Number, draftKey(r.doc.Number, "html"), r.doc.Number)
+
+	e := r.email
+	if len(editors) == 0 || e.sender == nil {
+		return nil
+	}
+	subject := fmt.Sprintf("Draft: %s #%d", i18n.M().Title, r.doc.Number)
+	fmt.Printf("Emailing draft to %s...", strings.Join(editors, ", "))
+	status, err := e.sender.Send(ctx, email.Message{From: e.from, To: editors, Subject: subject, HTML: htmlBody, Text: markdown})
+	if err != nil {
+		fmt.Printf(" error: %v\n", err)
+	} else {
+		fmt.Printf(" %s\n", status)
+	}
+	r.report.Record("DRAFT", e.sender.Target(), report.KindEmail, "", status, err)
+	return nil
+}
+
+// renderDraft renders every channel of a digest under its name, as an HTML
+// page and as Markdown.
+func renderDraft(d *digest.Document) (htmlBody, markdown string) {
+	title := fmt.Sprintf("Draft: %s #%d", i18n.M().Title, d.Number)
+	var h strings.Builder
+	fmt.Fprintf(&h, "<!DOCTYPE html>\n<html>\n<head><meta charset=\"utf-8\"><title>%s</title></head>\n<body>\n<h1>%s</h1>\n", html.EscapeString(title), html.EscapeString(title))
+	for _, ch := range d.Channels {
+		fmt.Fprintf(&h, "<h1>%s</h1>\n<p>%d days, %s</p>\n%s", html.EscapeString(ch.Name), ch.Cadence, html.EscapeString(strings.Join(ch.Types, ", ")), digest.HTML{}.Channel(d, ch))
+	}
+	h.WriteString("</body>\n</html>\n")
+	return h.String(), renderMarkdown(d, title)
+}
+
+// renderMarkdown renders every channel of a digest under its name as
+// Markdown, below the title.
+func renderMarkdown(d *digest.Document, title string) string {
+	var md strings.Builder
+	fmt.Fprintf(&md, "# %s\n\n", title)
+	for _, ch := range d.Channels {
+		fmt.Fprintf(&md, "# %s\n\n%d days, %s\n\n%s", ch.Name, ch.Cadence, strings.Join(ch.Types, ", "), digest.Markdown{}.Channel(d, ch))
+	}
+	return md.String()
+}
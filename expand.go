@@ -0,0 +1,200 @@
+package digest
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mpolski/gcp-release-digest/pkg/archive"
+	"github.com/mpolski/gcp-release-digest/pkg/digest"
+	"github.com/mpolski/gcp-release-digest/pkg/i18n"
+	"github.com/mpolski/gcp-release-digest/pkg/notify"
+	"github.com/mpolski/gcp-release-digest/pkg/releasenotes"
+	"github.com/mpolski/gcp-release-digest/pkg/secrets"
+	"github.com/mpolski/gcp-release-digest/pkg/store"
+)
+
+// expander links every summary to the expand function, which posts the
+// full release notes of the product to its channel on demand, keeping the
+// digest itself short.
+type expander struct {
+	expandURL string
+	secret    string
+	store     store.Store
+}
+
+// expandedNotes are the release notes of a product stored for the expand
+// function, with the webhook of the channel they are posted to. They are
+// kept out of the archive, as webhook URLs are secrets.
+type expandedNotes struct {
+	WebhookURL   string                     `json:"webhook_url"`
+	Product      string                     `json:"product"`
+	Cadence      int                        `json:"cadence"`
+	ReleaseNotes []releasenotes.ReleaseNote `json:"release_notes"`
+}
+
+// expandKey returns the store key of the release notes of product sent to
+// channel in digest number n.
+func expandKey(n int, channel, product string) string {
+	return fmt.Sprintf("expand/digest-%d/%s/%s.json", n, archive.Anchor(channel), archive.Anchor(product))
+}
+
+// loadExpander reads the expand settings, or returns nil if EXPAND_URL is
+// not set.
+func loadExpander(ctx context.Context, s store.Store) (*expander, error) {
+	expandURL := os.Getenv("EXPAND_URL")
+	if expandURL == "" {
+		return nil, nil
+	}
+	e := &expander{expandURL: expandURL, store: s}
+	var err error
+	if e.secret, err = secrets.Resolve(ctx, os.Getenv("EXPAND_SECRET")); err != nil {
+		return nil, fmt.Errorf("Error in EXPAND_SECRET: %v", err)
+	}
+	if e.secret == "" || s == nil {
+		return nil, fmt.Errorf("Set EXPAND_SECRET= and STATE_BUCKET= or STATE_DIR= in environment variables to use EXPAND_URL")
+	}
+	return e, nil
+}
+
+// token authorizes expanding the release notes of product sent to channel
+// in digest number n.
+func (e *expander) token(n int, channel, product string) string {
+	mac := hmac.New(sha256.New, []byte(e.secret))
+	fmt.Fprintf(mac, "expand digest %d\n%s\n%s", n, channel, product)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// link stores the release notes of a product of a channel and returns the
+// URL posting them, or an empty string if they could not be stored.
+func (e *expander) link(ctx context.Context, n int, ch *digest.Channel, product string, releaseNotes []releasenotes.ReleaseNote) string {
+	if e == nil || n <= 0 || len(releaseNotes) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(expandedNotes{WebhookURL: ch.WebhookURL, Product: product, Cadence: ch.Cadence, ReleaseNotes: releaseNotes})
+	if err == nil {
+		err = e.store.Put(ctx, expandKey(n, ch.Name, product), data)
+	}
+	if err != nil {
+		fmt.Printf("Error storing release notes of %s to expand: %v\n", product, err)
+		return ""
+	}
+	q := url.Values{"digest": {strconv.Itoa(n)}, "channel": {ch.Name}, "product": {product}, "token": {e.token(n, ch.Name, product)}}
+	sep := "?"
+	if strings.Contains(e.expandURL, "?") {
+		sep = "&"
+	}
+	return e.expandURL + sep + q.Encode()
+}
+
+// expand is the HTTP function behind the expand link of a summary. It checks
+// the link's token, posts the full release notes of the product to the
+// channel the summary was sent to, once per digest, in a thread of their
+// own where the target has threads, and shows them to the reader who
+// followed the link.
+func expand(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if err := reloadConfig(ctx); err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+	stateStore, err := store.New(ctx, os.Getenv("STATE_BUCKET"), os.Getenv("STATE_DIR"))
+	if err == nil && stateStore == nil {
+		err = errors.New("no state store")
+	}
+	var e *expander
+	if err == nil {
+		e, err = loadExpander(ctx, stateStore)
+	}
+	if err != nil || e == nil {
+		fmt.Println(err)
+		http.Error(w, "expand links are not configured", http.StatusInternalServerError)
+		return
+	}
+
+	q := r.URL.Query()
+	channel, product := q.Get("channel"), q.Get("product")
+	n, err := strconv.Atoi(q.Get("digest"))
+	if err != nil || n <= 0 || !hmac.Equal([]byte(q.Get("token")), []byte(e.token(n, channel, product))) {
+		http.Error(w, "invalid expand link", http.StatusForbidden)
+		return
+	}
+	data, err := stateStore.Get(ctx, expandKey(n, channel, product))
+	if errors.Is(err, store.ErrNotFound) {
+		http.Error(w, "release notes not found", http.StatusNotFound)
+		return
+	}
+	var notes expandedNotes
+	if err == nil {
+		err = json.Unmarshal(data, &notes)
+	}
+	if err != nil {
+		fmt.Printf("Error reading release notes of %s to expand: %v\n", product, err)
+		http.Error(w, "state store error", http.StatusInternalServerError)
+		return
+	}
+	if err := setTargetCredentials(ctx); err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+	if err := setWebhookClients(ctx); err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+
+	// The first reader expanding a summary posts the notes for everyone.
+	threadKey := fmt.Sprintf("digest-%d-%s", n, archive.Anchor(product))
+	err = stateStore.Create(ctx, "expanded/"+expandKey(n, channel, product), []byte(time.Now().UTC().Format(time.RFC3339)))
+	switch {
+	case errors.Is(err, store.ErrExists):
+		fmt.Printf("Release notes of %s in digest #%d were already posted to %s.\n", product, n, channel)
+	case err != nil:
+		fmt.Printf("Error claiming release notes of %s to expand: %v\n", product, err)
+	default:
+		fmt.Printf("Posting release notes of %s in digest #%d to %s...", product, n, channel)
+		status, err := notify.SendThread(ctx, notes.WebhookURL, threadKey, expandedText(notes))
+		if err != nil {
+			fmt.Printf(" error: %v\n", err)
+			// Let the next reader try again.
+			stateStore.Delete(ctx, "expanded/"+expandKey(n, channel, product))
+		} else {
+			fmt.Printf(" %s\n", status)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head><meta charset=\"utf-8\"><title>%s</title></head>\n<body>\n%s</body>\n</html>\n",
+		html.EscapeString(notes.Product), notify.Render(expandedText(notes), notify.DialectHTML))
+}
+
+// expandedText renders the release notes of a product in chat markup,
+// grouped by type.
+func expandedText(notes expandedNotes) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%s: %s*\n", notes.Product, fmt.Sprintf(i18n.M().AllNotes, len(notes.ReleaseNotes)))
+	for _, g := range releasenotes.GroupByType(notes.ReleaseNotes) {
+		fmt.Fprintf(&b, "\n*%s*\n", releasenotes.TypeTitle(g.ReleaseNoteType))
+		for _, rn := range g.ReleaseNotes {
+			b.WriteString("\n")
+			if !rn.PublishedAt.IsZero() {
+				fmt.Fprintf(&b, "_%s_ ", rn.PublishedAt.Format("2006-01-02"))
+			}
+			b.WriteString(strings.TrimSpace(rn.Description) + "\n")
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,240 @@
+package digest
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mpolski/gcp-release-digest/pkg/archive"
+	"github.com/mpolski/gcp-release-digest/pkg/digest"
+	"github.com/mpolski/gcp-release-digest/pkg/report"
+	"github.com/mpolski/gcp-release-digest/pkg/secrets"
+	"github.com/mpolski/gcp-release-digest/pkg/store"
+)
+
+// feedbackVote is a reader's rating of a summary, stored under feedback/.
+type feedbackVote struct {
+	Digest  int       `json:"digest"`
+	Product string    `json:"product"`
+	Variant string    `json:"variant"`
+	Vote    string    `json:"vote"`
+	At      time.Time `json:"at"`
+}
+
+// variantStats compares a prompt variant with the others on readers'
+// ratings.
+type variantStats struct {
+	Variant   string `json:"variant"`
+	Summaries int    `json:"summaries"`
+	Up        int    `json:"up"`
+	Down      int    `json:"down"`
+	// Helpful is the share of up votes among the votes.
+	Helpful float64 `json:"helpful"`
+}
+
+// feedbackParams are the parameters of a rating link.
+var feedbackParams = []string{"digest", "product", "variant", "vote", "token"}
+
+// feedback is the HTTP function behind the rating links of the summaries.
+// Following a link shows a form confirming the vote, as link previews and
+// mail scanners follow links too; posting the form stores the vote for the
+// summary of a product in a digest, if the link's token matches
+// FEEDBACK_SECRET. Readers reach it without credentials, so it answers
+// nothing else.
+func feedback(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if err := reloadConfig(ctx); err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+	secret, err := secrets.Resolve(ctx, os.Getenv("FEEDBACK_SECRET"))
+	if err != nil || secret == "" {
+		fmt.Printf("Set FEEDBACK_SECRET= in environment variables to collect feedback: %v\n", err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+	stateStore, err := store.New(ctx, os.Getenv("STATE_BUCKET"), os.Getenv("STATE_DIR"))
+	if err != nil || stateStore == nil {
+		fmt.Printf("Error opening state store: %v\n", err)
+		http.Error(w, "state store error", http.StatusInternalServerError)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "invalid feedback link", http.StatusBadRequest)
+		return
+	}
+	form := url.Values{}
+	for _, key := range feedbackParams {
+		if value := r.Form.Get(key); value != "" {
+			form.Set(key, value)
+		}
+	}
+	v := feedbackVote{Product: form.Get("product"), Variant: form.Get("variant"), Vote: form.Get("vote"), At: time.Now().UTC()}
+	v.Digest, err = strconv.Atoi(form.Get("digest"))
+	token := form.Get("token")
+	if err != nil || v.Digest <= 0 || v.Product == "" || (v.Vote != "up" && v.Vote != "down") ||
+		!hmac.Equal([]byte(token), []byte(digest.FeedbackToken(secret, v.Digest, v.Product, v.Variant, v.Vote))) {
+		http.Error(w, "invalid feedback link", http.StatusBadRequest)
+		return
+	}
+	if r.Method != http.MethodPost {
+		feedbackForm(w, v, form)
+		return
+	}
+	if v.Variant == "" {
+		v.Variant = "default"
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, "invalid feedback link", http.StatusBadRequest)
+		return
+	}
+
+	// A link counts once, or once per voter where the request names one,
+	// e.g. behind Identity-Aware Proxy, so reposting it changes nothing.
+	id := sha256.Sum256([]byte(token + "\n" + r.Header.Get("X-Goog-Authenticated-User-Email")))
+	key := fmt.Sprintf("feedback/digest-%d/%s-%s.json", v.Digest, archive.Anchor(v.Product), hex.EncodeToString(id[:8]))
+	err = stateStore.Create(ctx, key, data)
+	switch {
+	case errors.Is(err, store.ErrExists):
+		fmt.Fprint(w, "Your feedback was already counted.")
+	case err != nil:
+		fmt.Printf("Error storing feedback: %v\n", err)
+		http.Error(w, "state store error", http.StatusInternalServerError)
+	default:
+		fmt.Fprint(w, "Thanks for your feedback!")
+	}
+}
+
+// feedbackForm writes the page confirming the vote of a rating link, posting
+// the link's parameters back.
+func feedbackForm(w http.ResponseWriter, v feedbackVote, form url.Values) {
+	rating := "helpful"
+	if v.Vote == "down" {
+		rating = "not helpful"
+	}
+	var fields strings.Builder
+	for _, key := range feedbackParams {
+		if !form.Has(key) {
+			continue
+		}
+		fmt.Fprintf(&fields, "<input type=\"hidden\" name=\"%s\" value=\"%s\">\n", html.EscapeString(key), html.EscapeString(form.Get(key)))
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(w, "<!DOCTYPE html>\n<html>\n<head><meta charset=\"utf-8\"><title>%s</title></head>\n<body>\n<form method=\"post\">\n<p>Rate the summary of %s in digest #%d as %s?</p>\n%s<button type=\"submit\">Send feedback</button>\n</form>\n</body>\n</html>\n",
+		html.EscapeString(v.Product), html.EscapeString(v.Product), v.Digest, rating, fields.String())
+}
+
+// compareHandler is the HTTP function returning the summaries and votes of
+// every prompt variant. It reads every run report and vote, so unlike the
+// feedback function it is deployed requiring authentication, like the
+// digest function.
+func compareHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if err := reloadConfig(ctx); err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+	stateStore, err := store.New(ctx, os.Getenv("STATE_BUCKET"), os.Getenv("STATE_DIR"))
+	if err != nil || stateStore == nil {
+		fmt.Printf("Error opening state store: %v\n", err)
+		http.Error(w, "state store error", http.StatusInternalServerError)
+		return
+	}
+	stats, err := compareVariants(ctx, stateStore)
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "state store error", http.StatusInternalServerError)
+		return
+	}
+	data, _ := json.MarshalIndent(stats, "", "  ")
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// compareVariants counts the summaries each prompt variant wrote, from the
+// run reports, and the votes they got, from the stored feedback.
+func compareVariants(ctx context.Context, s store.Store) ([]*variantStats, error) {
+	byVariant := make(map[string]*variantStats)
+	get := func(name string) *variantStats {
+		if byVariant[name] == nil {
+			byVariant[name] = &variantStats{Variant: name}
+		}
+		return byVariant[name]
+	}
+
+	keys, err := s.List(ctx, "reports/")
+	if err != nil {
+		return nil, fmt.Errorf("Error listing run reports: %v", err)
+	}
+	for _, key := range keys {
+		data, err := s.Get(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading run report %s: %v", key, err)
+		}
+		var rep report.Report
+		if err := json.Unmarshal(data, &rep); err != nil {
+			fmt.Printf("Skipping run report %s: %v\n", key, err)
+			continue
+		}
+		counted := make(map[string]bool)
+		for _, d := range rep.Deliveries {
+			if d.Kind != report.KindSummary || d.Product == "" || counted[d.Product] ||
+				strings.HasPrefix(d.Channel, "CANARY ") || strings.HasPrefix(d.Channel, "REVIEW ") {
+				continue
+			}
+			counted[d.Product] = true
+			variant := rep.Variants[d.Product]
+			if variant == "" {
+				variant = "default"
+			}
+			get(variant).Summaries++
+		}
+	}
+
+	keys, err = s.List(ctx, "feedback/")
+	if err != nil {
+		return nil, fmt.Errorf("Error listing feedback: %v", err)
+	}
+	for _, key := range keys {
+		data, err := s.Get(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading feedback %s: %v", key, err)
+		}
+		var v feedbackVote
+		if err := json.Unmarshal(data, &v); err != nil {
+			fmt.Printf("Skipping feedback %s: %v\n", key, err)
+			continue
+		}
+		if v.Vote == "up" {
+			get(v.Variant).Up++
+		} else {
+			get(v.Variant).Down++
+		}
+	}
+
+	var stats []*variantStats
+	for _, st := range byVariant {
+		if votes := st.Up + st.Down; votes > 0 {
+			st.Helpful = float64(st.Up) / float64(votes)
+		}
+		stats = append(stats, st)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Variant < stats[j].Variant })
+	return stats, nil
+}
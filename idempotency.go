@@ -0,0 +1,238 @@
+package digest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mpolski/gcp-release-digest/pkg/lease"
+	"github.com/mpolski/gcp-release-digest/pkg/store"
+)
+
+// idempotencyHeader carries the key of a request that retrying callers,
+// such as Cloud Scheduler and Workflows, send unchanged with every attempt.
+const idempotencyHeader = "Idempotency-Key"
+
+// maxIdempotentBody is the largest request body read for its idempotency
+// key and fingerprint; larger requests with a key are refused.
+const maxIdempotentBody = 1 << 20
+
+// storedResponse is the response of a completed request, returned again for
+// repeated requests with its idempotency key.
+type storedResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body"`
+	// Fingerprint identifies the request the response answered, see
+	// fingerprint.
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	Completed   time.Time `json:"completed"`
+}
+
+// idempotentRequest is an idempotency key and the request it was sent with.
+type idempotentRequest struct {
+	key string
+	// scope is the function and, for the step function, the step the key
+	// applies to.
+	scope       string
+	fingerprint string
+}
+
+// idempotent runs requests of the function name carrying an idempotency key
+// once: a repeated request gets the stored response of the first instead of
+// running again, and one arriving while the first is still running is
+// refused. Keys are scoped to the function and step, and a key sent again
+// with a different request is refused. Only responses of completed runs,
+// 2xx with a body, are stored, so a request that failed on the way runs
+// again when retried. Requests without a key, or without a state store to
+// keep the responses, run every time.
+func idempotent(name string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req, status, err := readIdempotent(name, r)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		if req == nil {
+			next(w, r)
+			return
+		}
+		ctx := r.Context()
+		if err := reloadConfig(ctx); err != nil {
+			fmt.Println(err)
+			return
+		}
+		s, err := store.New(ctx, os.Getenv("STATE_BUCKET"), os.Getenv("STATE_DIR"))
+		if err != nil {
+			fmt.Printf("Error opening state store: %v\n", err)
+			return
+		}
+		if s == nil {
+			fmt.Printf("Ignoring %s without STATE_BUCKET= or STATE_DIR= in environment variables\n", idempotencyHeader)
+			next(w, r)
+			return
+		}
+
+		prefix := req.prefix()
+		if replay(ctx, w, s, prefix, req.fingerprint) {
+			return
+		}
+		ttl, err := optionalDuration("RUN_LOCK_TTL")
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		if ttl == 0 {
+			ttl = defaultRunLockTTL
+		}
+		l, err := lease.Acquire(ctx, s, prefix+"lease", lease.Holder(), ttl)
+		var held *lease.HeldError
+		if errors.As(err, &held) {
+			http.Error(w, fmt.Sprintf("a request with this %s is in progress", idempotencyHeader), http.StatusConflict)
+			return
+		}
+		if err != nil {
+			fmt.Printf("Error claiming idempotency key: %v\n", err)
+			return
+		}
+		defer func() {
+			if err := l.Release(context.WithoutCancel(ctx)); err != nil {
+				fmt.Printf("Error releasing idempotency key: %v\n", err)
+			}
+		}()
+		// The first request may have completed while this one claimed the
+		// key.
+		if replay(ctx, w, s, prefix, req.fingerprint) {
+			return
+		}
+
+		rec := httptest.NewRecorder()
+		next(rec, r)
+		for k, v := range rec.Header() {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+
+		if rec.Code < 200 || rec.Code > 299 || rec.Body.Len() == 0 {
+			return
+		}
+		data, err := json.Marshal(storedResponse{Status: rec.Code, ContentType: rec.Header().Get("Content-Type"), Body: rec.Body.Bytes(), Fingerprint: req.fingerprint, Completed: time.Now().UTC()})
+		if err == nil {
+			err = s.Put(context.WithoutCancel(ctx), prefix+"response.json", data)
+		}
+		if err != nil {
+			fmt.Printf("Error storing the response of idempotency key %q: %v\n", req.key, err)
+		}
+	}
+}
+
+// replay writes the stored response of the request with the idempotency key
+// of prefix, if any, and reports whether it did. A response to a different
+// request than fingerprint is refused instead.
+func replay(ctx context.Context, w http.ResponseWriter, s store.Store, prefix, fingerprint string) bool {
+	data, err := s.Get(ctx, prefix+"response.json")
+	if errors.Is(err, store.ErrNotFound) {
+		return false
+	}
+	var resp storedResponse
+	if err == nil {
+		err = json.Unmarshal(data, &resp)
+	}
+	if err != nil {
+		fmt.Printf("Error reading stored response, running again: %v\n", err)
+		return false
+	}
+	if resp.Fingerprint != "" && resp.Fingerprint != fingerprint {
+		http.Error(w, fmt.Sprintf("this %s was used for a different request", idempotencyHeader), http.StatusUnprocessableEntity)
+		return true
+	}
+	fmt.Printf("Returning the response of %s completed at %s\n", idempotencyHeader, resp.Completed.Format(time.RFC3339))
+	if resp.ContentType != "" {
+		w.Header().Set("Content-Type", resp.ContentType)
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(resp.Status)
+	w.Write(resp.Body)
+	return true
+}
+
+// readIdempotent returns the idempotency key of a request to the function
+// name, from the Idempotency-Key header, the idempotency_key query parameter
+// or the idempotency_key field of a JSON body, in that order, with its scope
+// and fingerprint. It returns nil for a request without a key, and an error
+// with its HTTP status for a request that cannot be read.
+func readIdempotent(name string, r *http.Request) (*idempotentRequest, int, error) {
+	key := r.Header.Get(idempotencyHeader)
+	if key == "" {
+		key = r.URL.Query().Get("idempotency_key")
+	}
+	isJSON := strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+	if key == "" && !isJSON {
+		return nil, 0, nil
+	}
+
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(io.LimitReader(r.Body, maxIdempotentBody+1))
+		if err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("Error reading request: %v", err)
+		}
+		if len(body) > maxIdempotentBody {
+			return nil, http.StatusRequestEntityTooLarge, fmt.Errorf("request body over %d bytes", maxIdempotentBody)
+		}
+		// The body stays readable for the run.
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	var fields struct {
+		Key  string `json:"idempotency_key"`
+		Step string `json:"step"`
+	}
+	if isJSON && len(bytes.TrimSpace(body)) > 0 {
+		if err := json.Unmarshal(body, &fields); err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("Error decoding request: %v", err)
+		}
+	}
+	if key == "" {
+		key = fields.Key
+	}
+	if key == "" {
+		return nil, 0, nil
+	}
+
+	// A key sent to each step of a workflow applies to each step on its own.
+	scope := name
+	if name == "step" && fields.Step != "" {
+		scope += "/" + fields.Step
+	}
+	return &idempotentRequest{key: key, scope: scope, fingerprint: fingerprint(r, body)}, 0, nil
+}
+
+// fingerprint identifies a request by its method, path, query and body,
+// without the idempotency key.
+func fingerprint(r *http.Request, body []byte) string {
+	q := r.URL.Query()
+	q.Del("idempotency_key")
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s?%s\n", r.Method, r.URL.Path, q.Encode())
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// prefix is the store prefix of the claim and response of an idempotency
+// key in its scope, hashed as keys may hold any characters.
+func (req *idempotentRequest) prefix() string {
+	sum := sha256.Sum256([]byte(req.key))
+	return "idempotency/" + req.scope + "/" + hex.EncodeToString(sum[:16]) + "/"
+}
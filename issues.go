@@ -0,0 +1,205 @@
+package digest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mpolski/gcp-release-digest/pkg/archive"
+	"github.com/mpolski/gcp-release-digest/pkg/digest"
+	"github.com/mpolski/gcp-release-digest/pkg/escalation"
+	"github.com/mpolski/gcp-release-digest/pkg/github"
+	"github.com/mpolski/gcp-release-digest/pkg/i18n"
+	"github.com/mpolski/gcp-release-digest/pkg/jira"
+	"github.com/mpolski/gcp-release-digest/pkg/releasenotes"
+	"github.com/mpolski/gcp-release-digest/pkg/report"
+	"github.com/mpolski/gcp-release-digest/pkg/store"
+)
+
+// jiraSettings configures the Jira issues opened for products whose release
+// notes match the issue rules, for platform teams to track remediation.
+type jiraSettings struct {
+	client *jira.Client
+	rules  escalation.Rules
+	labels []string
+	// store remembers the issues opened, if set, so the same release notes
+	// get one issue however often they are delivered.
+	store store.Store
+}
+
+// openJiraIssue opens a Jira issue for a product of a channel whose release
+// notes match the issue rules, with its summary and the release notes.
+func (r *run) openJiraIssue(ctx context.Context, channel string, p *digest.Product) {
+	j := r.jira
+	if j.client == nil {
+		return
+	}
+	rule, ok := j.rules.Match(p.Name(), escalationNotes(p.Notes))
+	if !ok {
+		return
+	}
+	claim, ok := claimIssue(ctx, j.store, "jira", p.Name(), p.Notes)
+	if !ok {
+		return
+	}
+
+	var description strings.Builder
+	description.WriteString(digest.PlainText{}.Product(r.doc, p) + "\n")
+	if link := r.doc.Link(p.Name()); link != "" {
+		fmt.Fprintf(&description, "\n[%s|%s]\n", i18n.M().ReadInArchive, link)
+	}
+	for _, rn := range p.Notes {
+		fmt.Fprintf(&description, "\nh4. %s, %s\n%s\n", releasenotes.TypeLabel(rn.ReleaseNoteType, 1), rn.PublishedAt.Format("2006-01-02"), jira.NoFormat(rn.Description))
+	}
+	fmt.Fprintf(&description, "\n_Opened by the GCP Release Digest for channel %s (rule: %s)._", channel, rule)
+	issue := jira.Issue{
+		Summary:     fmt.Sprintf("%s: %s", p.Name(), issueTitle(p.Notes)),
+		Description: description.String(),
+		Labels:      j.labels,
+	}
+
+	fmt.Printf("Opening Jira issue for %s (rule: %s)...", p.Name(), rule)
+	key, status, err := j.client.Create(ctx, issue)
+	if err != nil {
+		fmt.Printf(" error: %v\n", err)
+		releaseIssue(ctx, j.store, claim)
+	} else {
+		fmt.Printf(" %s %s\n", status, j.client.Browse(key))
+	}
+	r.report.Record("JIRA", j.client.Target(), report.KindIssue, p.Name(), status, err)
+}
+
+// githubSettings configures the GitHub issues opened for the release notes
+// matching the issue rules, one per product and run.
+type githubSettings struct {
+	client *github.Client
+	rules  escalation.Rules
+	// labels are added to the labels of the types of the release notes.
+	labels []string
+	store  store.Store
+	// opened records the products an issue was opened for in this run,
+	// shared with the mirrors of the channels.
+	opened map[string]bool
+}
+
+// openGitHubIssue opens a GitHub issue for the release notes of a product of
+// a channel that match the issue rules, labeled with their types, unless
+// one was opened for the product in this run already.
+func (r *run) openGitHubIssue(ctx context.Context, channel string, p *digest.Product) {
+	gh := r.github
+	if gh.client == nil || gh.opened[p.Name()] {
+		return
+	}
+	var matched []releasenotes.ReleaseNote
+	var rules []string
+	for _, rn := range p.Notes {
+		if rule, ok := gh.rules.Match(p.Name(), escalationNotes([]releasenotes.ReleaseNote{rn})); ok {
+			matched = append(matched, rn)
+			if !slices.Contains(rules, rule.String()) {
+				rules = append(rules, rule.String())
+			}
+		}
+	}
+	if len(matched) == 0 {
+		return
+	}
+	gh.opened[p.Name()] = true
+	claim, ok := claimIssue(ctx, gh.store, "github", p.Name(), matched)
+	if !ok {
+		return
+	}
+
+	var body strings.Builder
+	body.WriteString(digest.Markdown{}.Product(r.doc, p) + "\n")
+	if link := r.doc.Link(p.Name()); link != "" {
+		fmt.Fprintf(&body, "\n[%s](%s)\n", i18n.M().ReadInArchive, link)
+	}
+	labels := append([]string{}, gh.labels...)
+	for _, rn := range matched {
+		fmt.Fprintf(&body, "\n### %s, %s\n\n%s\n", releasenotes.TypeLabel(rn.ReleaseNoteType, 1), rn.PublishedAt.Format("2006-01-02"), github.CodeBlock(rn.Description))
+		if label := github.Label(rn.ReleaseNoteType); !slices.Contains(labels, label) {
+			labels = append(labels, label)
+		}
+	}
+	fmt.Fprintf(&body, "\n_Opened by the GCP Release Digest for channel %s (rule: %s)._\n", channel, strings.Join(rules, "; "))
+	issue := github.Issue{
+		Title:  fmt.Sprintf("%s: %s", p.Name(), issueTitle(matched)),
+		Body:   body.String(),
+		Labels: labels,
+	}
+
+	fmt.Printf("Opening GitHub issue for %s...", p.Name())
+	htmlURL, status, err := gh.client.Create(ctx, issue)
+	if err != nil {
+		fmt.Printf(" error: %v\n", err)
+		releaseIssue(ctx, gh.store, claim)
+		// Another channel of the product may try again.
+		gh.opened[p.Name()] = false
+	} else {
+		fmt.Printf(" %s %s\n", status, htmlURL)
+	}
+	r.report.Record("GITHUB", gh.client.Target(), report.KindIssue, p.Name(), status, err)
+}
+
+// issueTitle names the types of the release notes of an issue, e.g. "2
+// breaking changes and 1 deprecation".
+func issueTitle(releaseNotes []releasenotes.ReleaseNote) string {
+	var parts []string
+	for _, g := range releasenotes.GroupByType(releaseNotes) {
+		n := len(g.ReleaseNotes)
+		parts = append(parts, fmt.Sprintf("%d %s", n, releasenotes.TypeLabel(g.ReleaseNoteType, n)))
+	}
+	if len(parts) <= 1 {
+		return strings.Join(parts, "")
+	}
+	return strings.Join(parts[:len(parts)-1], ", ") + " and " + parts[len(parts)-1]
+}
+
+// claimIssue claims the issue of release notes of a product in tracker in
+// the state store, so concurrent or repeated runs open it once. It returns
+// the claimed key, and false if the issue was opened before or could not be
+// claimed. Without a store every delivery opens an issue.
+func claimIssue(ctx context.Context, s store.Store, tracker, product string, releaseNotes []releasenotes.ReleaseNote) (string, bool) {
+	if s == nil {
+		return "", true
+	}
+	key := issueKey(tracker, product, releaseNotes)
+	err := s.Create(ctx, key, []byte(time.Now().UTC().Format(time.RFC3339)))
+	if errors.Is(err, store.ErrExists) {
+		fmt.Printf("An issue was already opened in %s for the release notes of %s.\n", tracker, product)
+		return "", false
+	}
+	if err != nil {
+		fmt.Printf("Error claiming the issue of %s in %s: %v\n", product, tracker, err)
+		return "", false
+	}
+	return key, true
+}
+
+// releaseIssue gives up a claim of an issue that could not be opened, so the
+// next run tries again.
+func releaseIssue(ctx context.Context, s store.Store, key string) {
+	if s == nil || key == "" {
+		return
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		fmt.Printf("Error releasing the claim of %s: %v\n", key, err)
+	}
+}
+
+// issueKey is the store key remembering the issue opened in tracker for the
+// release notes of a product.
+func issueKey(tracker, product string, releaseNotes []releasenotes.ReleaseNote) string {
+	lines := make([]string, len(releaseNotes))
+	for i, rn := range releaseNotes {
+		lines[i] = rn.ReleaseNoteType + "\n" + rn.Description
+	}
+	slices.Sort(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n\n")))
+	return fmt.Sprintf("issues/%s/%s/%s", tracker, archive.Anchor(product), hex.EncodeToString(sum[:12]))
+}
@@ -0,0 +1,98 @@
+package digest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	"github.com/mpolski/gcp-release-digest/pkg/config"
+	"github.com/mpolski/gcp-release-digest/pkg/digest"
+	"github.com/mpolski/gcp-release-digest/pkg/i18n"
+	"github.com/mpolski/gcp-release-digest/pkg/textdiff"
+)
+
+// localKey is the context key of the output of a local run.
+type localKey struct{}
+
+// localRun is the output a local run writes the rendered digest to instead
+// of delivering it.
+type localRun struct {
+	out     io.Writer
+	written bool
+}
+
+// write renders every channel of the digest as Markdown to the output.
+func (l *localRun) write(d *digest.Document) error {
+	title := fmt.Sprintf("%s, %s", i18n.M().Title, d.Created.Format("2006-01-02"))
+	if _, err := io.WriteString(l.out, renderMarkdown(d, title)); err != nil {
+		return fmt.Errorf("Error writing digest: %v", err)
+	}
+	l.written = true
+	return nil
+}
+
+// RunLocal builds the digest with the settings of the environment, like the
+// digest function, and writes it to out as Markdown instead of delivering
+// it. Nothing is sent to webhooks, email or push topics and nothing is
+// stored. If no channel is set, every release note type is rendered under
+// GENERAL. channels selects some of them, as in ?channels=, if not empty.
+func RunLocal(ctx context.Context, out io.Writer, channels string) error {
+	target := "/"
+	if channels != "" {
+		target += "?channels=" + url.QueryEscape(channels)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return fmt.Errorf("Error creating local run: %v", err)
+	}
+	l := &localRun{out: out}
+	rec := httptest.NewRecorder()
+	runDigest(rec, req.WithContext(context.WithValue(ctx, localKey{}, l)))
+	if rec.Code >= http.StatusBadRequest {
+		return fmt.Errorf("Error running digest: %s", rec.Body.String())
+	}
+	if !l.written {
+		return fmt.Errorf("Error running digest, see the log above")
+	}
+	return nil
+}
+
+// PreviewCompare builds the digest twice, like RunLocal, with the settings
+// of the environment and with those of the configuration file at proposed
+// applied on top, and writes both next to each other to out with the lines
+// that differ marked, in columns of width characters. Nothing is delivered
+// or stored. As the model words summaries a little differently every time,
+// unchanged products may show small differences too.
+func PreviewCompare(ctx context.Context, out io.Writer, channels, proposed string, width int) error {
+	vars, err := config.Load(ctx, proposed)
+	if err != nil {
+		return err
+	}
+
+	var current, changed strings.Builder
+	fmt.Println("Building the digest with the current configuration...")
+	if err := RunLocal(ctx, &current, channels); err != nil {
+		return err
+	}
+	fmt.Printf("Building the digest with the configuration of %s...\n", proposed)
+	restore := config.Overlay(vars)
+	err = RunLocal(ctx, &changed, channels)
+	restore()
+	if err != nil {
+		return err
+	}
+
+	if textdiff.Changes(current.String(), changed.String()) == 0 {
+		_, err = fmt.Fprintln(out, "The proposed configuration does not change the digest.")
+		return err
+	}
+	_, err = io.WriteString(out, textdiff.SideBySide("Current configuration", "Proposed: "+proposed, current.String(), changed.String(), width))
+	if err != nil {
+		return fmt.Errorf("Error writing comparison: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/mpolski/gcp-release-digest/pkg/store"
+)
+
+// ActivityKey is the store key of the history of note counts per product.
+const ActivityKey = "archive/activity.json"
+
+// MaxSamples is the number of past runs of a channel the baseline of its
+// products is computed from.
+const MaxSamples = 26
+
+// minSamples is the number of past runs of a channel needed before any of
+// its products is flagged.
+const minSamples = 4
+
+// Activity is the history of the number of release notes of every product
+// in the past runs of every channel, the baseline unusual activity is
+// measured against.
+type Activity struct {
+	Channels map[string][]Sample `json:"channels"`
+}
+
+// Sample is the number of release notes of every product with any in one
+// run of a channel covering Days days.
+type Sample struct {
+	Created time.Time      `json:"created"`
+	Days    int            `json:"days"`
+	Notes   map[string]int `json:"notes"`
+}
+
+// LoadActivity reads the history from the store, or returns an empty one if
+// there is none yet.
+func LoadActivity(ctx context.Context, s store.Store) (*Activity, error) {
+	a := &Activity{Channels: make(map[string][]Sample)}
+	data, err := s.Get(ctx, ActivityKey)
+	if errors.Is(err, store.ErrNotFound) {
+		return a, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading activity history: %v", err)
+	}
+	if err := json.Unmarshal(data, a); err != nil {
+		return nil, fmt.Errorf("Error decoding activity history: %v", err)
+	}
+	if a.Channels == nil {
+		a.Channels = make(map[string][]Sample)
+	}
+	return a, nil
+}
+
+// Save stores the history.
+func (a *Activity) Save(ctx context.Context, s store.Store) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("Error encoding activity history: %v", err)
+	}
+	if err := s.Put(ctx, ActivityKey, data); err != nil {
+		return fmt.Errorf("Error storing activity history: %v", err)
+	}
+	return nil
+}
+
+// Record adds the note counts of a run of channel to its history, keeping
+// the last MaxSamples runs.
+func (a *Activity) Record(channel string, created time.Time, days int, notes map[string]int) {
+	samples := append(a.Channels[channel], Sample{Created: created, Days: days, Notes: notes})
+	if len(samples) > MaxSamples {
+		samples = samples[len(samples)-MaxSamples:]
+	}
+	a.Channels[channel] = samples
+}
+
+// Unusual reports whether notes release notes of product over days days are
+// far above its baseline in channel: more than twice its average daily rate
+// and more than two standard deviations above it. Products in channels with
+// a short history, or with fewer than three notes, are never unusual.
+func (a *Activity) Unusual(channel, product string, notes, days int) bool {
+	samples := a.Channels[channel]
+	if len(samples) < minSamples || notes < 3 || days <= 0 {
+		return false
+	}
+	// A run without notes of the product counts as a zero rate.
+	var sum, sumSquares float64
+	for _, s := range samples {
+		rate := float64(s.Notes[product]) / float64(max(s.Days, 1))
+		sum += rate
+		sumSquares += rate * rate
+	}
+	n := float64(len(samples))
+	mean := sum / n
+	stddev := math.Sqrt(math.Max(sumSquares/n-mean*mean, 0))
+	rate := float64(notes) / float64(days)
+	return rate > 2*mean && rate > mean+2*stddev
+}
@@ -0,0 +1,174 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/mpolski/gcp-release-digest/pkg/store"
+)
+
+// Digest is the archived record of a single run: every summary that was sent,
+// grouped by channel.
+type Digest struct {
+	Number   int
+	Created  time.Time
+	Cadence  int
+	Sections []Section
+}
+
+// Section holds the summaries sent to one channel.
+type Section struct {
+	Channel string
+	// Cadence is the number of days covered by the channel if it differs
+	// from the digest's, or zero.
+	Cadence int
+	Entries []Entry
+}
+
+// Entry is the summary of one product.
+type Entry struct {
+	Product string
+	// Types are the release note types summarized.
+	Types   []string
+	Summary string
+}
+
+// Add records the summary of a product's release notes of the given types
+// sent to channel.
+func (d *Digest) Add(channel, product string, types []string, summary string) {
+	entry := Entry{Product: product, Types: types, Summary: summary}
+	for i := range d.Sections {
+		if d.Sections[i].Channel == channel {
+			d.Sections[i].Entries = append(d.Sections[i].Entries, entry)
+			return
+		}
+	}
+	d.Sections = append(d.Sections, Section{Channel: channel, Entries: []Entry{entry}})
+}
+
+// SetCadence records that the summaries sent to channel cover a different
+// number of days than the digest.
+func (d *Digest) SetCadence(channel string, cadence int) {
+	for i := range d.Sections {
+		if d.Sections[i].Channel == channel {
+			d.Sections[i].Cadence = cadence
+		}
+	}
+}
+
+// Key returns the store key of the archived HTML page of digest number n.
+func Key(n int) string {
+	return fmt.Sprintf("archive/digest-%d.html", n)
+}
+
+// NotesKey returns the store key of the full release notes of product sent to
+// channel in digest number n.
+func NotesKey(n int, channel, product string) string {
+	return fmt.Sprintf("archive/digest-%d/%s/%s.md", n, Anchor(channel), Anchor(product))
+}
+
+// ExportKey returns the store key of the raw release notes of a channel of
+// digest number n exported in format, "csv" or "json".
+func ExportKey(n int, channel, format string) string {
+	return fmt.Sprintf("archive/digest-%d/%s/notes.%s", n, Anchor(channel), format)
+}
+
+// Permalink returns the URL of the archived page of digest number n below
+// baseURL, or an empty string if baseURL is not set.
+func Permalink(baseURL string, n int) string {
+	if baseURL == "" {
+		return ""
+	}
+	return strings.TrimRight(baseURL, "/") + "/" + Key(n)
+}
+
+// Anchor returns the fragment identifying the summary of product on the
+// archived page, e.g. "cloud-sql" for Cloud SQL.
+func Anchor(product string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(product) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return b.String()
+}
+
+// NextNumber assigns the next sequential digest number. The number is claimed
+// with an atomic create, so overlapping runs never share a number.
+func NextNumber(ctx context.Context, s store.Store) (int, error) {
+	last := 0
+	data, err := s.Get(ctx, "digests/last")
+	switch {
+	case err == nil:
+		last, err = strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil {
+			return 0, fmt.Errorf("Error reading last digest number: %v", err)
+		}
+	case !errors.Is(err, store.ErrNotFound):
+		return 0, fmt.Errorf("Error reading last digest number: %v", err)
+	}
+
+	for n := last + 1; ; n++ {
+		stamp := []byte(time.Now().UTC().Format(time.RFC3339))
+		err := s.Create(ctx, fmt.Sprintf("digests/%d", n), stamp)
+		if errors.Is(err, store.ErrExists) {
+			continue
+		}
+		if err != nil {
+			return 0, fmt.Errorf("Error claiming digest number %d: %v", n, err)
+		}
+		if err := s.Put(ctx, "digests/last", []byte(strconv.Itoa(n))); err != nil {
+			return 0, fmt.Errorf("Error saving digest number %d: %v", n, err)
+		}
+		return n, nil
+	}
+}
+
+// Save renders the digest as an HTML page and stores it under Key.
+func (d *Digest) Save(ctx context.Context, s store.Store) error {
+	var page bytes.Buffer
+	if err := pageTemplate.Execute(&page, d); err != nil {
+		return fmt.Errorf("Error rendering archived digest: %v", err)
+	}
+	return s.Put(ctx, Key(d.Number), page.Bytes())
+}
+
+var pageTemplate = template.Must(template.New("digest").Funcs(template.FuncMap{"anchor": Anchor}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>GCP Release Digest #{{.Number}}</title>
+<style>
+body { font-family: sans-serif; max-width: 48em; margin: 2em auto; line-height: 1.5; }
+h2 { border-bottom: 1px solid #ddd; }
+</style>
+</head>
+<body>
+<h1>GCP Release Digest #{{.Number}}</h1>
+<p>Release notes of the last {{.Cadence}} days, published {{.Created.Format "2006-01-02 15:04 MST"}}.</p>
+{{range .Sections}}
+<h2>{{.Channel}}</h2>
+{{if .Cadence}}<p>Release notes of the last {{.Cadence}} days.</p>{{end}}
+{{range .Entries}}
+<h3 id="{{anchor .Product}}">{{.Product}}</h3>
+<p>{{.Summary}}</p>
+{{end}}
+{{end}}
+</body>
+</html>
+`))
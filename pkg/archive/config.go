@@ -0,0 +1,52 @@
+package archive
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/mpolski/gcp-release-digest/pkg/store"
+)
+
+// Config is the effective configuration of a run, archived with its digest
+// so the digest can be reproduced or audited after the live configuration
+// changed. It holds no secrets: webhook URLs keep only their host.
+type Config struct {
+	Number        int             `json:"number"`
+	Created       time.Time       `json:"created"`
+	Cadence       int             `json:"cadence"`
+	Model         string          `json:"model"`
+	ModelLocation string          `json:"model_location"`
+	VerifyModel   string          `json:"verify_model,omitempty"`
+	Channels      []ChannelConfig `json:"channels"`
+	// Prompts are the templates of the prompts the run could summarize
+	// with, by name.
+	Prompts map[string]string `json:"prompts"`
+	// Settings are the environment variables of the run, with secrets
+	// redacted.
+	Settings map[string]string `json:"settings"`
+}
+
+// ChannelConfig is the configuration of one channel of a run.
+type ChannelConfig struct {
+	Name string `json:"name"`
+	// Targets are the hosts of the channel's webhooks.
+	Targets []string `json:"targets"`
+	Types   []string `json:"types"`
+	Cadence int      `json:"cadence"`
+}
+
+// ConfigKey returns the store key of the configuration of digest number n.
+func ConfigKey(n int) string {
+	return fmt.Sprintf("archive/digest-%d/config.json", n)
+}
+
+// Save stores the configuration under ConfigKey.
+func (c *Config) Save(ctx context.Context, s store.Store) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Error encoding configuration of digest #%d: %v", c.Number, err)
+	}
+	return s.Put(ctx, ConfigKey(c.Number), data)
+}
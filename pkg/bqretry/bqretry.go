@@ -0,0 +1,134 @@
+package bqretry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"google.golang.org/api/googleapi"
+)
+
+// Policy caps how often and how long a failing query is retried.
+type Policy struct {
+	// Attempts is the number of times a query is run at most.
+	Attempts int
+	// MaxElapsed is the time after which no further attempt is started.
+	MaxElapsed time.Duration
+	// Backoff is the wait before the second attempt, doubled for each
+	// further one up to maxBackoff.
+	Backoff time.Duration
+}
+
+// DefaultPolicy runs a query up to 5 times within 2 minutes.
+var DefaultPolicy = Policy{Attempts: 5, MaxElapsed: 2 * time.Minute, Backoff: time.Second}
+
+// maxBackoff caps the wait between two attempts.
+const maxBackoff = 30 * time.Second
+
+var (
+	mu     sync.Mutex
+	policy = DefaultPolicy
+)
+
+// SetPolicy sets the policy of the queries run after it.
+func SetPolicy(p Policy) {
+	mu.Lock()
+	defer mu.Unlock()
+	policy = p
+}
+
+func currentPolicy() Policy {
+	mu.Lock()
+	defer mu.Unlock()
+	return policy
+}
+
+// Read runs the query, waits for its job to complete and returns its results.
+// Transient errors, such as internal errors of BigQuery or exceeded rate
+// limits, rerun the query with exponential backoff as the policy allows.
+func Read(ctx context.Context, q *bigquery.Query) (*bigquery.RowIterator, error) {
+	p := currentPolicy()
+	deadline := time.Now().Add(p.MaxElapsed)
+	backoff := p.Backoff
+	for attempt := 1; ; attempt++ {
+		it, err, retryable := read(ctx, q)
+		if err == nil || !retryable || attempt >= p.Attempts {
+			return it, err
+		}
+
+		// Wait a random time up to the backoff, so concurrent queries
+		// failing together do not retry together.
+		wait := time.Duration(rand.Int63n(int64(backoff) + 1))
+		if time.Now().Add(wait).After(deadline) {
+			return nil, err
+		}
+		fmt.Printf("Retrying query after attempt %d in %s: %v\n", attempt, wait.Round(time.Millisecond), err)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return nil, err
+		}
+		backoff = min(2*backoff, maxBackoff)
+	}
+}
+
+// read runs the query once, and reports whether an error is worth another
+// attempt.
+func read(ctx context.Context, q *bigquery.Query) (*bigquery.RowIterator, error, bool) {
+	job, err := q.Run(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Error running query: %v", err), Retryable(err)
+	}
+	status, err := job.Wait(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Job completed with error: %v", err), Retryable(err)
+	}
+	if err := status.Err(); err != nil {
+		return nil, fmt.Errorf("Job completed with error: %v", err), Retryable(err)
+	}
+	it, err := job.Read(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Error iterating over results: %v", err), Retryable(err)
+	}
+	return it, nil, false
+}
+
+// retryableReasons are the error reasons of BigQuery worth another attempt.
+var retryableReasons = map[string]bool{
+	"backendError":      true,
+	"internalError":     true,
+	"jobBackendError":   true,
+	"jobInternalError":  true,
+	"rateLimitExceeded": true,
+}
+
+// Retryable reports whether err is a transient error of BigQuery: a 5xx or
+// 429 response of the API, or a job failing for one of the retryable reasons.
+func Retryable(err error) bool {
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		if apiErr.Code >= http.StatusInternalServerError || apiErr.Code == http.StatusTooManyRequests {
+			return true
+		}
+		for _, item := range apiErr.Errors {
+			if retryableReasons[item.Reason] {
+				return true
+			}
+		}
+		return false
+	}
+	var jobErr *bigquery.Error
+	if errors.As(err, &jobErr) {
+		return retryableReasons[jobErr.Reason]
+	}
+	var jobErrValue bigquery.Error
+	if errors.As(err, &jobErrValue) {
+		return retryableReasons[jobErrValue.Reason]
+	}
+	return false
+}
@@ -0,0 +1,95 @@
+package compliance
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Actions taken on a summary matching the filter.
+const (
+	// ActionRedact replaces the matching text and sends the rest.
+	ActionRedact = "redact"
+	// ActionBlock leaves the summary out of the digest.
+	ActionBlock = "block"
+	// ActionOff sends the summary unchanged.
+	ActionOff = "off"
+)
+
+// Redacted replaces text matching the filter.
+const Redacted = "[redacted]"
+
+// Filter finds banned phrases in generated text.
+type Filter struct {
+	patterns []*regexp.Regexp
+}
+
+// Parse reads banned phrases separated by semicolons. A phrase matches
+// case-insensitively as a whole word or words, unless it is written as
+// /regexp/, which matches as given, e.g.
+//
+//	guaranteed; best in class; /(?i)zero[- ]downtime/
+//
+// It returns nil if spec has no phrases.
+func Parse(spec string) (*Filter, error) {
+	var f Filter
+	for _, phrase := range strings.Split(spec, ";") {
+		phrase = strings.TrimSpace(phrase)
+		if phrase == "" {
+			continue
+		}
+		expr := `(?i)\b` + regexp.QuoteMeta(phrase) + `\b`
+		if len(phrase) > 2 && strings.HasPrefix(phrase, "/") && strings.HasSuffix(phrase, "/") {
+			expr = phrase[1 : len(phrase)-1]
+		}
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("banned phrase %q: %v", phrase, err)
+		}
+		f.patterns = append(f.patterns, re)
+	}
+	if len(f.patterns) == 0 {
+		return nil, nil
+	}
+	return &f, nil
+}
+
+// ParseAction validates an action, returning ActionRedact for an empty one.
+func ParseAction(action string) (string, error) {
+	switch action = strings.ToLower(strings.TrimSpace(action)); action {
+	case "":
+		return ActionRedact, nil
+	case ActionRedact, ActionBlock, ActionOff:
+		return action, nil
+	}
+	return "", fmt.Errorf("unknown action %q, expected redact, block or off", action)
+}
+
+// Find returns the distinct matches of the filter in text.
+func (f *Filter) Find(text string) []string {
+	if f == nil {
+		return nil
+	}
+	var found []string
+	seen := make(map[string]bool)
+	for _, re := range f.patterns {
+		for _, m := range re.FindAllString(text, -1) {
+			if key := strings.ToLower(m); !seen[key] {
+				seen[key] = true
+				found = append(found, m)
+			}
+		}
+	}
+	return found
+}
+
+// Redact replaces every match of the filter in text with Redacted.
+func (f *Filter) Redact(text string) string {
+	if f == nil {
+		return text
+	}
+	for _, re := range f.patterns {
+		text = re.ReplaceAllLiteralString(text, Redacted)
+	}
+	return text
+}
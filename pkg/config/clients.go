@@ -0,0 +1,50 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"google.golang.org/api/firestore/v1"
+	"google.golang.org/api/storage/v1"
+)
+
+// The Cloud Storage and Firestore clients reading the configuration are
+// created on first use and shared by every reload of the instance, instead
+// of a client per run.
+var (
+	clientsMu       sync.Mutex
+	storageClient   *storage.Service
+	firestoreClient *firestore.Service
+)
+
+// storageService returns the shared Cloud Storage client, creating it if
+// needed. The client outlives the request it was created for, so it is not
+// bound to the cancellation of ctx.
+func storageService(ctx context.Context) (*storage.Service, error) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if storageClient == nil {
+		svc, err := storage.NewService(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("Error creating Cloud Storage client: %v", err)
+		}
+		storageClient = svc
+	}
+	return storageClient, nil
+}
+
+// firestoreService returns the shared Firestore client, creating it if
+// needed, like storageService.
+func firestoreService(ctx context.Context) (*firestore.Service, error) {
+	clientsMu.Lock()
+	defer clientsMu.Unlock()
+	if firestoreClient == nil {
+		svc, err := firestore.NewService(context.WithoutCancel(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("Error creating Firestore client: %v", err)
+		}
+		firestoreClient = svc
+	}
+	return firestoreClient, nil
+}
@@ -0,0 +1,258 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A configuration file sets environment variables, in the format of env.vars
+// or env.yaml:
+//
+//	# Channels
+//	GENERAL=https://chat.googleapis.com/v1/spaces/...
+//	export PRODUCT_PRIORITY="Cloud SQL,BigQuery"
+//	TYPE_SECTIONS: "true"
+//
+// It is applied on top of the environment of the deployment and re-read at
+// the start of every run, so channels, filters and other settings can change
+// without a redeploy. A variable removed from the file gets back its value
+// from the deployment. The variables may also be the fields of a Firestore
+// document, see readFirestore.
+
+// Reloader applies a configuration file to the environment whenever its
+// content changes.
+type Reloader struct {
+	mu   sync.Mutex
+	last map[string]string
+	// location and version identify the last file read, so it is not read
+	// again while its Cloud Storage generation or Firestore update time is
+	// the same.
+	location string
+	version  string
+	applied  map[string]bool
+	original map[string]*string
+}
+
+var defaultReloader = &Reloader{}
+
+// Reload applies the configuration file at location with the default
+// Reloader. It reports whether the environment changed.
+func Reload(ctx context.Context, location string) (bool, error) {
+	return defaultReloader.Reload(ctx, location)
+}
+
+// Reload reads the configuration file at location, a local path, a
+// gs://bucket/object URL or a firestore://project/collection/document URL,
+// and applies it if it changed since the last call. An empty location removes
+// the variables applied before.
+func (r *Reloader) Reload(ctx context.Context, location string) (bool, error) {
+	r.mu.Lock()
+	known := ""
+	if location == r.location {
+		known = r.version
+	}
+	r.mu.Unlock()
+
+	vars := map[string]string{}
+	version := ""
+	if location != "" {
+		var err error
+		if vars, version, err = load(ctx, location, known); err != nil {
+			return false, err
+		}
+		if vars == nil {
+			return false, nil
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.location, r.version = location, version
+	if r.applied != nil && maps.Equal(vars, r.last) {
+		return false, nil
+	}
+	if r.applied == nil {
+		r.applied = make(map[string]bool)
+		r.original = make(map[string]*string)
+	}
+
+	// Restore the variables no longer set by the file.
+	for key := range r.applied {
+		if _, ok := vars[key]; ok {
+			continue
+		}
+		if v := r.original[key]; v != nil {
+			os.Setenv(key, *v)
+		} else {
+			os.Unsetenv(key)
+		}
+		delete(r.applied, key)
+	}
+	for key, value := range vars {
+		if _, seen := r.original[key]; !seen {
+			if v, ok := os.LookupEnv(key); ok {
+				r.original[key] = &v
+			} else {
+				r.original[key] = nil
+			}
+		}
+		os.Setenv(key, value)
+		r.applied[key] = true
+	}
+	r.last = vars
+	return true, nil
+}
+
+// Load reads the variables of the configuration file at location, a local
+// path, a gs://bucket/object URL or a firestore://project/collection/document
+// URL, without applying them.
+func Load(ctx context.Context, location string) (map[string]string, error) {
+	vars, _, err := load(ctx, location, "")
+	return vars, err
+}
+
+// load reads the variables of the configuration file at location and its
+// version, or only its version if it is known, when the variables are nil.
+// Local files have no version and are always read.
+func load(ctx context.Context, location, known string) (map[string]string, string, error) {
+	if path, ok := strings.CutPrefix(location, "firestore://"); ok {
+		vars, version, err := readFirestore(ctx, path, known)
+		if err != nil {
+			return nil, "", fmt.Errorf("Error reading configuration document: %v", err)
+		}
+		return vars, version, nil
+	}
+	data, version, err := read(ctx, location, known)
+	if err != nil {
+		return nil, "", fmt.Errorf("Error reading configuration file: %v", err)
+	}
+	if data == nil {
+		return nil, version, nil
+	}
+	vars, err := Parse(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("Error in configuration file %s: %v", location, err)
+	}
+	return vars, version, nil
+}
+
+// Overlay sets the variables in the environment and returns a function
+// restoring their previous values, for trying a configuration in a single
+// process.
+func Overlay(vars map[string]string) (restore func()) {
+	original := make(map[string]*string, len(vars))
+	for key, value := range vars {
+		if v, ok := os.LookupEnv(key); ok {
+			original[key] = &v
+		} else {
+			original[key] = nil
+		}
+		os.Setenv(key, value)
+	}
+	return func() {
+		for key, v := range original {
+			if v != nil {
+				os.Setenv(key, *v)
+			} else {
+				os.Unsetenv(key)
+			}
+		}
+	}
+}
+
+// Parse reads the variables of a configuration file.
+func Parse(data []byte) (map[string]string, error) {
+	vars := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		sep := strings.IndexAny(line, "=:")
+		if sep <= 0 {
+			return nil, fmt.Errorf("line %d: expected KEY=value or KEY: value", lineNo)
+		}
+		key, value := strings.TrimSpace(line[:sep]), line[sep+1:]
+		value, err := unquote(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", lineNo, err)
+		}
+		vars[key] = value
+	}
+	return vars, scanner.Err()
+}
+
+// unquote returns a value without its quotes and without a trailing comment.
+func unquote(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		end := strings.Index(value[1:], `"`)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quote")
+		}
+		return strconv.Unquote(value[:end+2])
+	case strings.HasPrefix(value, "'"):
+		end := strings.Index(value[1:], "'")
+		if end < 0 {
+			return "", fmt.Errorf("unterminated quote")
+		}
+		return value[1 : end+1], nil
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value, nil
+}
+
+// read returns the content of a local file or of a Cloud Storage object, and
+// the object's generation as version. The content is nil if the generation
+// is still known.
+func read(ctx context.Context, location, known string) ([]byte, string, error) {
+	path, ok := strings.CutPrefix(location, "gs://")
+	if !ok {
+		data, err := os.ReadFile(location)
+		if data == nil && err == nil {
+			data = []byte{}
+		}
+		return data, "", err
+	}
+	bucket, object, ok := strings.Cut(path, "/")
+	if !ok || bucket == "" || object == "" {
+		return nil, "", fmt.Errorf("invalid Cloud Storage URL %q, expected gs://bucket/object", location)
+	}
+	svc, err := storageService(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	obj, err := svc.Objects.Get(bucket, object).Context(ctx).Do()
+	if err != nil {
+		return nil, "", err
+	}
+	version := strconv.FormatInt(obj.Generation, 10)
+	if version == known {
+		return nil, version, nil
+	}
+	// The generation read is downloaded, even if the object changed since.
+	resp, err := svc.Objects.Get(bucket, object).Generation(obj.Generation).Context(ctx).Download()
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, version, nil
+}
@@ -0,0 +1,53 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// readFirestore returns the variables set by a Firestore document, given as
+// project/collection/document in the default database, e.g.
+// my-project/config/digest, and its update time as version. Each field of
+// the document sets the variable of its name. Fields are meant to be
+// strings, as in a configuration file; numbers and true are converted, while
+// false and zero read as empty values. The variables are nil if the
+// document's version is still known.
+func readFirestore(ctx context.Context, path, known string) (map[string]string, string, error) {
+	project, doc, ok := strings.Cut(path, "/")
+	if !ok || project == "" || strings.Count(doc, "/")%2 != 1 {
+		return nil, "", fmt.Errorf("invalid Firestore URL %q, expected firestore://project/collection/document", "firestore://"+path)
+	}
+	svc, err := firestoreService(ctx)
+	if err != nil {
+		return nil, "", err
+	}
+	name := fmt.Sprintf("projects/%s/databases/(default)/documents/%s", project, doc)
+	d, err := svc.Projects.Databases.Documents.Get(name).Context(ctx).Do()
+	if err != nil {
+		return nil, "", err
+	}
+	if known != "" && d.UpdateTime == known {
+		return nil, d.UpdateTime, nil
+	}
+
+	vars := make(map[string]string, len(d.Fields))
+	for key, v := range d.Fields {
+		switch {
+		case v.StringValue != "":
+			vars[key] = v.StringValue
+		case v.IntegerValue != 0:
+			vars[key] = strconv.FormatInt(v.IntegerValue, 10)
+		case v.DoubleValue != 0:
+			vars[key] = strconv.FormatFloat(v.DoubleValue, 'f', -1, 64)
+		case v.BooleanValue:
+			vars[key] = "true"
+		case v.ArrayValue != nil, v.MapValue != nil:
+			return nil, "", fmt.Errorf("field %s of %s: expected a string", key, name)
+		default:
+			vars[key] = ""
+		}
+	}
+	return vars, d.UpdateTime, nil
+}
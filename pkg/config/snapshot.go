@@ -0,0 +1,74 @@
+package config
+
+import (
+	"net/url"
+	"os"
+	"strings"
+)
+
+// runtimeVars are set by the platform running the function rather than by
+// its configuration.
+var runtimeVars = map[string]bool{
+	"HOME": true, "HOSTNAME": true, "PATH": true, "PORT": true, "PWD": true,
+	"SHLVL": true, "TERM": true, "USER": true, "LANG": true, "DEBIAN_FRONTEND": true,
+	"LOG_EXECUTION_ID": true, "GOOGLE_APPLICATION_CREDENTIALS": true,
+	"GOROOT": true, "GOPATH": true, "GOCACHE": true,
+}
+
+// runtimePrefixes start the names of further variables set by the platform.
+var runtimePrefixes = []string{"K_", "FUNCTION_", "X_GOOGLE_", "CNB_", "GOOGLE_FUNCTION_", "GOOGLE_RUNTIME", "GOOGLE_ENTRYPOINT"}
+
+// secretWords mark the names of variables holding secrets.
+var secretWords = []string{"SECRET", "TOKEN", "PASSWORD", "_KEY", "CREDENTIALS", "PRIVATE"}
+
+// Snapshot returns the configuration variables of the environment, with
+// secrets and the secret parts of URLs redacted, so it can be stored with
+// the output of a run.
+func Snapshot() map[string]string {
+	vars := make(map[string]string)
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		if key == "" || runtimeVars[key] || strings.ToUpper(key) != key {
+			continue
+		}
+		runtime := false
+		for _, prefix := range runtimePrefixes {
+			runtime = runtime || strings.HasPrefix(key, prefix)
+		}
+		if !runtime {
+			vars[key] = Redact(key, value)
+		}
+	}
+	return vars
+}
+
+// Redact returns the value of a variable without its secrets: the value of
+// a variable named like a secret is replaced unless it references Secret
+// Manager, and URLs, which carry webhook keys and tokens, keep only their
+// scheme and host, e.g. "https://hooks.slack.com/...".
+func Redact(key, value string) string {
+	if value == "" || strings.HasPrefix(value, "sm://") {
+		return value
+	}
+	for _, word := range secretWords {
+		if strings.Contains(key, word) {
+			return "[redacted]"
+		}
+	}
+	if !strings.Contains(value, "://") {
+		return value
+	}
+	var parts []string
+	for _, part := range strings.Split(value, ",") {
+		u, err := url.Parse(strings.TrimSpace(part))
+		switch {
+		case err != nil || u.Host == "":
+			parts = append(parts, "[redacted]")
+		case u.Path == "" && u.RawQuery == "" && u.User == nil:
+			parts = append(parts, u.Scheme+"://"+u.Host)
+		default:
+			parts = append(parts, u.Scheme+"://"+u.Host+"/...")
+		}
+	}
+	return strings.Join(parts, ",")
+}
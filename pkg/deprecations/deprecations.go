@@ -0,0 +1,263 @@
+package deprecations
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mpolski/gcp-release-digest/pkg/i18n"
+	"github.com/mpolski/gcp-release-digest/pkg/notify"
+	"github.com/mpolski/gcp-release-digest/pkg/releasenotes"
+	"github.com/mpolski/gcp-release-digest/pkg/store"
+)
+
+// Key is the store key of the open deprecations.
+const Key = "deprecations/open.json"
+
+// undatedExpiry is how long a deprecation without a deadline stays open
+// after it was last seen.
+const undatedExpiry = 365 * 24 * time.Hour
+
+// Deprecation is a deprecation announced in a DEPRECATION release note.
+type Deprecation struct {
+	// ID identifies the release note by its product and description.
+	ID      string `json:"id"`
+	Product string `json:"product"`
+	// Description is what is deprecated, as the release note puts it.
+	Description string `json:"description"`
+	// Deadline is the day, as YYYY-MM-DD, the deprecated feature goes away,
+	// the latest date the description mentions. It is empty if it mentions
+	// none.
+	Deadline string `json:"deadline,omitempty"`
+	// FirstDigest is the number of the digest that first reported it, zero
+	// if that digest was not numbered.
+	FirstDigest int       `json:"first_digest,omitempty"`
+	FirstSeen   time.Time `json:"first_seen"`
+	LastSeen    time.Time `json:"last_seen"`
+}
+
+// Board is the list of open deprecations, updated by every digest run.
+type Board struct {
+	mu           sync.Mutex
+	Deprecations []Deprecation `json:"deprecations"`
+}
+
+// Load reads the board from the store, or returns an empty one if there is
+// none yet.
+func Load(ctx context.Context, s store.Store) (*Board, error) {
+	b := &Board{}
+	data, err := s.Get(ctx, Key)
+	if errors.Is(err, store.ErrNotFound) {
+		return b, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading open deprecations: %v", err)
+	}
+	if err := json.Unmarshal(data, b); err != nil {
+		return nil, fmt.Errorf("Error decoding open deprecations: %v", err)
+	}
+	return b, nil
+}
+
+// Save stores the board.
+func (b *Board) Save(ctx context.Context, s store.Store) error {
+	b.mu.Lock()
+	data, err := json.Marshal(b)
+	b.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("Error encoding open deprecations: %v", err)
+	}
+	if err := s.Put(ctx, Key, data); err != nil {
+		return fmt.Errorf("Error storing open deprecations: %v", err)
+	}
+	return nil
+}
+
+// Track adds the DEPRECATION release notes of product not on the board yet,
+// as first seen in the digest numbered digestNumber at seen, and marks those
+// already on it as seen again. It returns the number of deprecations added.
+func (b *Board) Track(digestNumber int, seen time.Time, product string, releaseNotes []releasenotes.ReleaseNote) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	added := 0
+	for _, rn := range releaseNotes {
+		if rn.ReleaseNoteType != "DEPRECATION" {
+			continue
+		}
+		id := noteID(product, rn.Description)
+		if i := b.index(id); i >= 0 {
+			b.Deprecations[i].LastSeen = seen
+			continue
+		}
+		b.Deprecations = append(b.Deprecations, Deprecation{
+			ID:          id,
+			Product:     product,
+			Description: rn.Description,
+			Deadline:    Deadline(rn.Description, rn.PublishedAt),
+			FirstDigest: digestNumber,
+			FirstSeen:   seen,
+			LastSeen:    seen,
+		})
+		added++
+	}
+	return added
+}
+
+func (b *Board) index(id string) int {
+	for i, d := range b.Deprecations {
+		if d.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// noteID identifies a release note by its product and description.
+func noteID(product, description string) string {
+	sum := sha256.Sum256([]byte(releasenotes.Normalize(product) + "\x00" + strings.TrimSpace(description)))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Prune closes the deprecations whose deadline passed before now, and those
+// without one not seen for a year. It returns the number closed.
+func (b *Board) Prune(now time.Time) int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	today := now.Format("2006-01-02")
+	var open []Deprecation
+	for _, d := range b.Deprecations {
+		if d.Deadline != "" && d.Deadline < today || d.Deadline == "" && now.Sub(d.LastSeen) > undatedExpiry {
+			continue
+		}
+		open = append(open, d)
+	}
+	closed := len(b.Deprecations) - len(open)
+	b.Deprecations = open
+	return closed
+}
+
+// Open returns the open deprecations, the closest deadline first and those
+// without one last.
+func (b *Board) Open() []Deprecation {
+	b.mu.Lock()
+	open := append([]Deprecation(nil), b.Deprecations...)
+	b.mu.Unlock()
+	sort.SliceStable(open, func(i, j int) bool {
+		a, c := open[i], open[j]
+		if (a.Deadline == "") != (c.Deadline == "") {
+			return c.Deadline == ""
+		}
+		if a.Deadline != c.Deadline {
+			return a.Deadline < c.Deadline
+		}
+		return a.Product < c.Product
+	})
+	return open
+}
+
+// Upcoming returns the open deprecations with a deadline from now to within
+// days days, the closest first.
+func (b *Board) Upcoming(now time.Time, days int) []Deprecation {
+	from, until := now.Format("2006-01-02"), now.AddDate(0, 0, days).Format("2006-01-02")
+	var upcoming []Deprecation
+	for _, d := range b.Open() {
+		if d.Deadline != "" && d.Deadline >= from && d.Deadline <= until {
+			upcoming = append(upcoming, d)
+		}
+	}
+	return upcoming
+}
+
+// Render returns the reminder of the upcoming deprecations within days days
+// of now, in chat markup.
+func Render(upcoming []Deprecation, now time.Time, days int) string {
+	m := i18n.M()
+	if len(upcoming) == 0 {
+		return fmt.Sprintf("*⏳ "+m.NoDeadlines+"*", days)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "*⏳ "+m.Deadlines+"*\n", days)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for _, d := range upcoming {
+		deadline, _ := time.Parse("2006-01-02", d.Deadline)
+		left := int(deadline.Sub(today).Hours() / 24)
+		fmt.Fprintf(&b, "• *%s*: %s (%s", d.Product, notify.Shorten(notify.PlainText(d.Description), 200), fmt.Sprintf(m.DueIn, d.Deadline, left))
+		if d.FirstDigest > 0 {
+			fmt.Fprintf(&b, ", %s", fmt.Sprintf(m.FirstSeen, d.FirstDigest))
+		}
+		b.WriteString(")\n")
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+const monthNames = `(Jan(?:uary)?|Feb(?:ruary)?|Mar(?:ch)?|Apr(?:il)?|May|June?|July?|Aug(?:ust)?|Sep(?:t(?:ember)?)?|Oct(?:ober)?|Nov(?:ember)?|Dec(?:ember)?)`
+
+var (
+	isoDate = regexp.MustCompile(`\b(\d{4})-(\d{2})-(\d{2})\b`)
+	// monthDayYear matches dates like "January 15, 2025" or "Jan 15 2025".
+	monthDayYear = regexp.MustCompile(`\b` + monthNames + `\.? (\d{1,2})(?:st|nd|rd|th)?,? (\d{4})\b`)
+	// dayMonthYear matches dates like "15 January 2025".
+	dayMonthYear = regexp.MustCompile(`\b(\d{1,2}) ` + monthNames + `\.?,? (\d{4})\b`)
+)
+
+// Deadline returns the latest date a release note published at published
+// mentions on or after that day, as YYYY-MM-DD, or an empty string if it
+// mentions none.
+func Deadline(description string, published time.Time) string {
+	var dates []time.Time
+	for _, m := range isoDate.FindAllStringSubmatch(description, -1) {
+		dates = append(dates, date(m[1], m[2], m[3]))
+	}
+	for _, m := range monthDayYear.FindAllStringSubmatch(description, -1) {
+		dates = append(dates, date(m[3], month(m[1]), m[2]))
+	}
+	for _, m := range dayMonthYear.FindAllStringSubmatch(description, -1) {
+		dates = append(dates, date(m[3], month(m[2]), m[1]))
+	}
+	floor := published.Truncate(24 * time.Hour)
+	var latest time.Time
+	for _, d := range dates {
+		if !d.IsZero() && !d.Before(floor) && d.After(latest) {
+			latest = d
+		}
+	}
+	if latest.IsZero() {
+		return ""
+	}
+	return latest.Format("2006-01-02")
+}
+
+// month returns the number of a month by its name or abbreviation.
+func month(name string) string {
+	for i, m := range []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"} {
+		if strings.HasPrefix(strings.ToLower(name), m) {
+			return strconv.Itoa(i + 1)
+		}
+	}
+	return ""
+}
+
+// date returns the day of the given year, month and day numbers, or the zero
+// time if they are not a valid date.
+func date(year, month, day string) time.Time {
+	y, errY := strconv.Atoi(year)
+	m, errM := strconv.Atoi(month)
+	d, errD := strconv.Atoi(day)
+	if errY != nil || errM != nil || errD != nil || m < 1 || m > 12 || d < 1 {
+		return time.Time{}
+	}
+	t := time.Date(y, time.Month(m), d, 0, 0, 0, 0, time.UTC)
+	if t.Day() != d {
+		return time.Time{}
+	}
+	return t
+}
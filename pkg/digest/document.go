@@ -0,0 +1,149 @@
+package digest
+
+import (
+	"slices"
+	"time"
+
+	"github.com/mpolski/gcp-release-digest/pkg/archive"
+	"github.com/mpolski/gcp-release-digest/pkg/products"
+	"github.com/mpolski/gcp-release-digest/pkg/releasenotes"
+)
+
+// Document is the content of one digest run: the products with release notes
+// of every channel, their notes and summaries. It is built by querying and
+// summarizing before anything is sent, and then rendered and delivered to
+// each target.
+type Document struct {
+	Number  int
+	Created time.Time
+	// Cadence is the number of days covered by the digest.
+	Cadence int
+	// Permalink is the URL of the archived digest, if it is archived.
+	Permalink string
+	// FeedbackURL is the URL of the feedback function, if readers can rate
+	// summaries.
+	FeedbackURL string
+	// Urgent marks a digest whose release notes exceeded an alerting
+	// threshold.
+	Urgent   bool
+	Channels []*Channel
+}
+
+// Channel is the part of the digest delivered to one channel.
+type Channel struct {
+	Name       string
+	WebhookURL string
+	// Mirrors are further webhook URLs the channel fans out to, receiving
+	// the same summaries as WebhookURL.
+	Mirrors []string
+	// Types are the release note types the channel receives.
+	Types []string
+	// Cadence is the number of days covered by the channel, which may differ
+	// from the document's.
+	Cadence int
+	// TypeCounts break the channel's release notes down by type.
+	TypeCounts []products.TypeCount
+	// Unusual are the products with far more release notes than usual.
+	Unusual  []string
+	Products []*Product
+}
+
+// Product is the summary of one product's release notes in a channel.
+type Product struct {
+	// Info holds the name and note counts the product was selected with.
+	Info    products.Product
+	Notes   []releasenotes.ReleaseNote
+	Summary string
+	// NotesURL links the full release notes of the product, if they were
+	// uploaded.
+	NotesURL string
+	// DocsURL links the product's release notes page in the documentation,
+	// if set.
+	DocsURL string
+	// ExpandURL posts the full release notes of the product to the channel
+	// on demand, if set.
+	ExpandURL string
+	// ProviderChanges link recent releases of the Terraform provider
+	// touching the product, if set.
+	ProviderChanges []Link
+	// Variant names the prompt variant the summary was written with, or is
+	// empty for the default prompt.
+	Variant string
+}
+
+// Link is a URL with the text it is shown as.
+type Link struct {
+	Text string
+	URL  string
+}
+
+// AddChannel adds a channel receiving the release note types to the document.
+func (d *Document) AddChannel(name, webhookURL string, types []string) *Channel {
+	ch := &Channel{Name: name, WebhookURL: webhookURL, Types: types}
+	d.Channels = append(d.Channels, ch)
+	return ch
+}
+
+// Link returns the URL of the summary of product in the archived digest, or
+// an empty string if the digest is not archived.
+func (d *Document) Link(product string) string {
+	if d.Permalink == "" {
+		return ""
+	}
+	return d.Permalink + "#" + archive.Anchor(product)
+}
+
+// Products returns the products of all channels once each, in the order they
+// first appear. A product summarized in several channels keeps its first
+// summary and gets the release notes of all of them.
+func (d *Document) Products() []*Product {
+	var list []*Product
+	index := make(map[string]int)
+	for _, ch := range d.Channels {
+		for _, p := range ch.Products {
+			i, seen := index[p.Name()]
+			if !seen {
+				index[p.Name()] = len(list)
+				merged := *p
+				merged.Notes = slices.Clone(p.Notes)
+				list = append(list, &merged)
+				continue
+			}
+			list[i].Notes = append(list[i].Notes, p.Notes...)
+		}
+	}
+	return list
+}
+
+// Infos returns the name and note counts of the channel's products, in order.
+func (ch *Channel) Infos() []products.Product {
+	infos := make([]products.Product, 0, len(ch.Products))
+	for _, p := range ch.Products {
+		infos = append(infos, p.Info)
+	}
+	return infos
+}
+
+// Name returns the name of the product.
+func (p *Product) Name() string {
+	return p.Info.Product
+}
+
+// Release drops the descriptions of the product's release notes once its
+// summary was sent, keeping their types and count for the archive, email and
+// push notifications.
+func (p *Product) Release() {
+	for i := range p.Notes {
+		p.Notes[i].Description = ""
+	}
+}
+
+// Types returns the release note types of the product's notes, in the order
+// they first appear.
+func (p *Product) Types() []string {
+	var types []string
+	for _, g := range releasenotes.GroupByType(p.Notes) {
+		types = append(types, g.ReleaseNoteType)
+	}
+	return types
+}
@@ -0,0 +1,57 @@
+package digest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/mpolski/gcp-release-digest/pkg/archive"
+	"github.com/mpolski/gcp-release-digest/pkg/store"
+)
+
+// OverrideKey returns the store key of an editor's text replacing the
+// summary of product in digest number n, e.g.
+// "overrides/digest-42/cloud-sql.txt" for Cloud SQL.
+func OverrideKey(n int, product string) string {
+	return fmt.Sprintf("overrides/digest-%d/%s.txt", n, archive.Anchor(product))
+}
+
+// Override is an editor's text that replaced the summary of a product.
+type Override struct {
+	Channel  string
+	Product  string
+	Key      string
+	Original string
+	Summary  string
+}
+
+// ApplyOverrides replaces the summaries of the products that have an
+// override in the store, in every channel, and returns the replacements.
+func (d *Document) ApplyOverrides(ctx context.Context, s store.Store) ([]Override, error) {
+	var applied []Override
+	texts := make(map[string]*string)
+	for _, ch := range d.Channels {
+		for _, p := range ch.Products {
+			key := OverrideKey(d.Number, p.Name())
+			text, seen := texts[key]
+			if !seen {
+				data, err := s.Get(ctx, key)
+				if err != nil && !errors.Is(err, store.ErrNotFound) {
+					return nil, fmt.Errorf("Error reading override of %s: %v", p.Name(), err)
+				}
+				if err == nil {
+					t := strings.TrimSpace(string(data))
+					text = &t
+				}
+				texts[key] = text
+			}
+			if text == nil || *text == "" {
+				continue
+			}
+			applied = append(applied, Override{Channel: ch.Name, Product: p.Name(), Key: key, Original: p.Summary, Summary: *text})
+			p.Summary = *text
+		}
+	}
+	return applied, nil
+}
@@ -0,0 +1,368 @@
+package digest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mpolski/gcp-release-digest/pkg/archive"
+	"github.com/mpolski/gcp-release-digest/pkg/i18n"
+	"github.com/mpolski/gcp-release-digest/pkg/notify"
+	"github.com/mpolski/gcp-release-digest/pkg/products"
+	"github.com/mpolski/gcp-release-digest/pkg/releasenotes"
+)
+
+// Renderer renders the products of a digest document in one output format,
+// so a new target only needs a renderer for its format or a notifier for its
+// transport.
+type Renderer interface {
+	// Product renders the summary of one product, without its name.
+	Product(d *Document, p *Product) string
+	// Channel renders every product of a channel under its name.
+	Channel(d *Document, ch *Channel) string
+}
+
+// ForDialect returns the renderer of the markup a target renders.
+func ForDialect(dialect notify.Dialect) Renderer {
+	switch dialect {
+	case notify.DialectMarkdown:
+		return Markdown{}
+	case notify.DialectHTML:
+		return HTML{}
+	case notify.DialectPlain:
+		return PlainText{}
+	}
+	return Chat{}
+}
+
+// Chat renders chat markup, with *bold*, _italic_ and <url|text> links, the
+// markup summaries are written in.
+type Chat struct{}
+
+// Product renders the summary, ending with links expanding the full release
+// notes, to them if they were uploaded and to the product's release notes
+// page, if set, and with the related Terraform provider changes.
+func (Chat) Product(d *Document, p *Product) string {
+	m := i18n.M()
+	var links []string
+	if p.ExpandURL != "" {
+		links = append(links, fmt.Sprintf("<%s|%s>", p.ExpandURL, fmt.Sprintf(m.Expand, len(p.Notes))))
+	}
+	if p.NotesURL != "" {
+		links = append(links, fmt.Sprintf("<%s|%s>", p.NotesURL, fmt.Sprintf(m.AllNotes, len(p.Notes))))
+	}
+	if p.DocsURL != "" {
+		links = append(links, fmt.Sprintf("<%s|%s>", p.DocsURL, m.NotesPage))
+	}
+	text := p.Summary
+	if len(links) > 0 {
+		text += "\n\n" + strings.Join(links, " · ")
+	}
+	if len(p.ProviderChanges) > 0 {
+		var changes []string
+		for _, l := range p.ProviderChanges {
+			changes = append(changes, fmt.Sprintf("<%s|%s>", l.URL, l.Text))
+		}
+		text += "\n\n" + fmt.Sprintf(m.ProviderChanges, strings.Join(changes, ", "))
+	}
+	return text
+}
+
+// Channel renders the summaries under their product names.
+func (c Chat) Channel(d *Document, ch *Channel) string {
+	var b strings.Builder
+	for _, p := range ch.Products {
+		fmt.Fprintf(&b, "*%s:*\n\n%s\n\n", p.Name(), c.Product(d, p))
+	}
+	return b.String()
+}
+
+// Feedback renders links rating the summary of a product as helpful or not,
+// signed with secret, or an empty string if the document does not collect
+// feedback.
+func (Chat) Feedback(d *Document, p *Product, secret string) string {
+	if d.FeedbackURL == "" {
+		return ""
+	}
+	sep := "?"
+	if strings.Contains(d.FeedbackURL, "?") {
+		sep = "&"
+	}
+	link := func(vote string) string {
+		q := url.Values{"digest": {strconv.Itoa(d.Number)}, "product": {p.Name()}, "vote": {vote}}
+		if p.Variant != "" {
+			q.Set("variant", p.Variant)
+		}
+		q.Set("token", FeedbackToken(secret, d.Number, p.Name(), p.Variant, vote))
+		return d.FeedbackURL + sep + q.Encode()
+	}
+	m := i18n.M()
+	return fmt.Sprintf("👍 <%s|%s> · 👎 <%s|%s>", link("up"), m.Helpful, link("down"), m.NotHelpful)
+}
+
+// FeedbackToken authorizes a vote on the summary of product written with
+// the prompt variant in digest number n, so votes only come from the rating
+// links and carry the variant they were sent with.
+func FeedbackToken(secret string, n int, product, variant, vote string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "feedback digest %d\n%s\n%s\n%s", n, product, variant, vote)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Footer renders the line ending the summaries of a channel instead of the
+// closing message: the digest number or the time of the run, the period
+// covered, the number of products and a link to the archived digest, if it
+// is archived.
+func (Chat) Footer(d *Document, ch *Channel, count int) string {
+	m := i18n.M()
+	run := fmt.Sprintf(m.RunOf, d.Created.UTC().Format("2006-01-02 15:04 UTC"))
+	if d.Number > 0 {
+		run = fmt.Sprintf("%s #%d", m.Title, d.Number)
+	}
+	parts := []string{
+		run,
+		d.Created.AddDate(0, 0, -ch.Cadence).Format("2006-01-02") + " – " + d.Created.Format("2006-01-02"),
+		fmt.Sprintf(m.Covered, count),
+	}
+	if d.Permalink != "" {
+		parts = append(parts, fmt.Sprintf("<%s|%s>", d.Permalink, m.ReadArchive))
+	}
+	return "_" + strings.Join(parts, " · ") + "_"
+}
+
+// Contents renders the table of contents of a channel's summaries grouped by
+// category, linking each category to its first product in the archived
+// digest, if it is archived.
+func (Chat) Contents(d *Document, groups []products.CategoryGroup) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%s*\n", i18n.M().Contents)
+	for _, g := range groups {
+		title := g.Category
+		if link := d.Link(g.Products[0].Product); link != "" {
+			title = fmt.Sprintf("<%s|%s>", link, g.Category)
+		}
+		fmt.Fprintf(&b, "\n• %s: %s", title, strings.Join(products.Names(g.Products), ", "))
+	}
+	return b.String()
+}
+
+// Section renders the heading of the summaries of a category.
+func (Chat) Section(category string) string {
+	return "*" + strings.ToUpper(category) + "*\n\n"
+}
+
+// Markdown renders common Markdown.
+type Markdown struct{}
+
+// Product renders the summary as Markdown.
+func (Markdown) Product(d *Document, p *Product) string {
+	return notify.Render(Chat{}.Product(d, p), notify.DialectMarkdown)
+}
+
+// Channel renders the summaries under product headings.
+func (m Markdown) Channel(d *Document, ch *Channel) string {
+	var b strings.Builder
+	for _, p := range ch.Products {
+		fmt.Fprintf(&b, "## %s\n\n%s\n\n", p.Name(), m.Product(d, p))
+	}
+	return b.String()
+}
+
+// PlainText renders text without markup.
+type PlainText struct{}
+
+// Product renders the summary as a single line of plain text.
+func (PlainText) Product(d *Document, p *Product) string {
+	return notify.Render(p.Summary, notify.DialectPlain)
+}
+
+// Channel renders one line per product, starting with its name.
+func (t PlainText) Channel(d *Document, ch *Channel) string {
+	var b strings.Builder
+	for _, p := range ch.Products {
+		fmt.Fprintf(&b, "%s: %s\n", p.Name(), t.Product(d, p))
+	}
+	return b.String()
+}
+
+// HTML renders HTML fragments, as used in email and Matrix messages.
+type HTML struct{}
+
+// Product renders the summary as HTML paragraphs and lists.
+func (HTML) Product(d *Document, p *Product) string {
+	return notify.Render(Chat{}.Product(d, p), notify.DialectHTML)
+}
+
+// Channel renders the summaries under product headings linked to the
+// archived digest.
+func (h HTML) Channel(d *Document, ch *Channel) string {
+	var b strings.Builder
+	for _, p := range ch.Products {
+		name := html.EscapeString(p.Name())
+		if link := d.Link(p.Name()); link != "" {
+			name = fmt.Sprintf(`<a href="%s">%s</a>`, html.EscapeString(link), name)
+		}
+		fmt.Fprintf(&b, "<h2>%s</h2>\n%s", name, h.Product(d, p))
+	}
+	return b.String()
+}
+
+// Card renders Google Chat cards, one per product, with the release note
+// types as subtitle, a section listing the release notes of each type and
+// buttons to the product's release notes page and the archived digest.
+type Card struct {
+	// Icons maps normalized product names to the image of their card's
+	// header, read by ParseIcons.
+	Icons map[string]string
+	// Icon is the image of the header of products without one of their
+	// own, if set.
+	Icon string
+}
+
+// DefaultCardIcon is the Google Cloud logo.
+const DefaultCardIcon = "https://www.gstatic.com/images/branding/product/2x/google_cloud_48dp.png"
+
+// cardNoteChars is the longest release note shown in a card's type section.
+const cardNoteChars = 300
+
+// ParseIcons reads the images of product card headers separated by
+// semicolons, each in the form product=URL, e.g.
+// "BigQuery=https://example.com/bigquery.png".
+func ParseIcons(spec string) (map[string]string, error) {
+	icons := make(map[string]string)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		product, icon, ok := strings.Cut(entry, "=")
+		icon = strings.TrimSpace(icon)
+		if !ok || strings.TrimSpace(product) == "" || !strings.HasPrefix(icon, "https://") {
+			return nil, fmt.Errorf("card icon %q: expected product=https://...", entry)
+		}
+		icons[releasenotes.Normalize(product)] = icon
+	}
+	return icons, nil
+}
+
+var (
+	cardLink   = regexp.MustCompile(`&lt;(https?://[^|\s]+?)\|([^&]+?)&gt;`)
+	cardBold   = regexp.MustCompile(`\*([^*\n]+)\*`)
+	cardItalic = regexp.MustCompile(`(^|\s)_([^_\n]+)_`)
+)
+
+// Product renders the card of one product as JSON.
+func (c Card) Product(d *Document, p *Product) string {
+	data, _ := json.Marshal(c.card(d, p))
+	return string(data)
+}
+
+// Channel renders the message payload with the cards of all products.
+func (c Card) Channel(d *Document, ch *Channel) string {
+	return c.Message(d, ch.Products...)
+}
+
+// Message renders the message payload with the cards of the products.
+func (c Card) Message(d *Document, ps ...*Product) string {
+	var cards []map[string]any
+	for _, p := range ps {
+		cards = append(cards, c.card(d, p))
+	}
+	data, _ := json.Marshal(map[string]any{"cardsV2": cards})
+	return string(data)
+}
+
+// card builds the cardsV2 entry of a product: the summary, a collapsed
+// section with the release notes of each type, and the buttons.
+func (c Card) card(d *Document, p *Product) map[string]any {
+	m := i18n.M()
+	var types []string
+	for _, t := range p.Types() {
+		types = append(types, releasenotes.TypeTitle(t))
+	}
+	// The expand and release notes page links are buttons of their own.
+	text := *p
+	text.ExpandURL, text.DocsURL = "", ""
+	sections := []map[string]any{{
+		"widgets": []map[string]any{
+			{"textParagraph": map[string]string{"text": cardText(Chat{}.Product(d, &text))}},
+		},
+	}}
+
+	// Release notes dropped once sent have no description left to list.
+	for _, g := range releasenotes.GroupByType(p.Notes) {
+		var widgets []map[string]any
+		for _, rn := range g.ReleaseNotes {
+			if rn.Description == "" {
+				continue
+			}
+			note := rn.PublishedAt.Format("2006-01-02") + " " + notify.Shorten(strings.Join(strings.Fields(rn.Description), " "), cardNoteChars)
+			widgets = append(widgets, map[string]any{"textParagraph": map[string]string{"text": html.EscapeString(note)}})
+		}
+		if len(widgets) == 0 {
+			continue
+		}
+		sections = append(sections, map[string]any{
+			"header":                    fmt.Sprintf("%s (%d)", releasenotes.TypeTitle(g.ReleaseNoteType), len(g.ReleaseNotes)),
+			"collapsible":               true,
+			"uncollapsibleWidgetsCount": 0,
+			"widgets":                   widgets,
+		})
+	}
+
+	var buttons []map[string]any
+	button := func(label, url string) {
+		buttons = append(buttons, map[string]any{
+			"text":    label,
+			"onClick": map[string]any{"openLink": map[string]string{"url": url}},
+		})
+	}
+	if p.ExpandURL != "" {
+		button(fmt.Sprintf(m.Expand, len(p.Notes)), p.ExpandURL)
+	}
+	if p.DocsURL != "" {
+		button(m.NotesPage, p.DocsURL)
+	} else {
+		button(m.NotesPage, releasenotes.URL(p.Name(), p.Notes))
+	}
+	if link := d.Link(p.Name()); link != "" {
+		button(m.ReadInArchive, link)
+	}
+	sections = append(sections, map[string]any{
+		"widgets": []map[string]any{{"buttonList": map[string]any{"buttons": buttons}}},
+	})
+
+	header := map[string]string{"title": p.Name(), "subtitle": strings.Join(types, ", ")}
+	icon, ok := c.Icons[releasenotes.Normalize(p.Name())]
+	if !ok {
+		icon = c.Icon
+	}
+	if icon != "" {
+		header["imageUrl"] = icon
+		header["imageType"] = "CIRCLE"
+		header["imageAltText"] = p.Name()
+	}
+	return map[string]any{
+		"cardId": "product-" + archive.Anchor(p.Name()),
+		"card": map[string]any{
+			"header":   header,
+			"sections": sections,
+		},
+	}
+}
+
+// cardText converts chat markup to the HTML subset of card text paragraphs.
+func cardText(text string) string {
+	s := html.EscapeString(strings.TrimSpace(text))
+	s = cardLink.ReplaceAllString(s, `<a href="$1">$2</a>`)
+	s = cardBold.ReplaceAllString(s, "<b>$1</b>")
+	s = cardItalic.ReplaceAllString(s, "$1<i>$2</i>")
+	return strings.ReplaceAll(s, "\n", "<br>")
+}
@@ -0,0 +1,153 @@
+package digest
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mpolski/gcp-release-digest/pkg/products"
+	"github.com/mpolski/gcp-release-digest/pkg/releasenotes"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// testDocument returns a digest with two products exercising the markup of
+// summaries, the links of a product and characters to escape.
+func testDocument() (*Document, *Channel) {
+	published := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+	d := &Document{
+		Number:    42,
+		Created:   time.Date(2024, 6, 10, 9, 0, 0, 0, time.UTC),
+		Cadence:   7,
+		Permalink: "https://storage.googleapis.com/digests/42.html",
+	}
+	ch := d.AddChannel("GENERAL", "https://chat.googleapis.com/v1/spaces/AAA/messages", []string{"FEATURE", "BREAKING_CHANGE"})
+	ch.Cadence = 7
+	ch.Products = []*Product{
+		{
+			Info: products.Product{Product: "BigQuery", NoteCount: 2, BreakingChanges: 1},
+			Notes: []releasenotes.ReleaseNote{
+				{ReleaseNoteType: "BREAKING_CHANGE", Description: "Legacy SQL <b>tables</b> are read-only.", PublishedAt: published},
+				{ReleaseNoteType: "FEATURE", Description: "Vector search is generally available.", PublishedAt: published.AddDate(0, 0, 1)},
+			},
+			Summary:   "*Breaking:* legacy SQL tables are read-only.\n• _Vector search_ is GA, see <https://cloud.google.com/bigquery|the docs>.",
+			NotesURL:  "https://storage.googleapis.com/digests/42/bigquery.txt",
+			DocsURL:   "https://cloud.google.com/bigquery/docs/release-notes",
+			ExpandURL: "https://example.com/expand?product=BigQuery",
+			ProviderChanges: []Link{
+				{Text: "v5.32.0", URL: "https://github.com/hashicorp/terraform-provider-google/releases/tag/v5.32.0"},
+			},
+		},
+		{
+			Info: products.Product{Product: "Cloud Run & Functions", NoteCount: 1},
+			Notes: []releasenotes.ReleaseNote{
+				{ReleaseNoteType: "FEATURE", Description: "Services scale to zero faster.", PublishedAt: published},
+			},
+			Summary: "Services scale to zero faster.",
+		},
+	}
+	return d, ch
+}
+
+// golden compares got to the golden file testdata/name, or rewrites it with
+// -update.
+func golden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v, run go test -update to create it", err)
+	}
+	if got != string(want) {
+		t.Errorf("%s differs from the golden file:\ngot:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+func TestRenderGolden(t *testing.T) {
+	card := Card{Icon: DefaultCardIcon, Icons: map[string]string{releasenotes.Normalize("BigQuery"): "https://example.com/bigquery.png"}}
+	for _, tc := range []struct {
+		file     string
+		renderer Renderer
+	}{
+		{"chat.golden", Chat{}},
+		{"markdown.golden", Markdown{}},
+		{"plain.golden", PlainText{}},
+		{"html.golden", HTML{}},
+		{"card.golden", card},
+	} {
+		t.Run(tc.file, func(t *testing.T) {
+			d, ch := testDocument()
+			got := tc.renderer.Channel(d, ch)
+			if _, ok := tc.renderer.(Card); ok {
+				got = indentJSON(t, got)
+			}
+			golden(t, tc.file, got)
+		})
+	}
+}
+
+// indentJSON returns a JSON payload indented and without escaped markup, so
+// golden files read like the cards.
+func indentJSON(t *testing.T, payload string) string {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal([]byte(payload), &v); err != nil {
+		t.Fatalf("payload is not JSON: %v", err)
+	}
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	return b.String()
+}
+
+func TestChatFooterGolden(t *testing.T) {
+	d, ch := testDocument()
+	golden(t, "footer.golden", Chat{}.Footer(d, ch, len(ch.Products))+"\n")
+}
+
+// largeDocument returns a digest of many products with several release notes
+// each, as in large runs.
+func largeDocument(n int) (*Document, *Channel) {
+	d, ch := testDocument()
+	template := ch.Products
+	ch.Products = nil
+	for i := 0; i < n; i++ {
+		p := *template[i%len(template)]
+		p.Info.Product = fmt.Sprintf("%s %d", p.Info.Product, i)
+		p.Notes = append([]releasenotes.ReleaseNote{}, p.Notes...)
+		for j := 0; j < 5; j++ {
+			p.Notes = append(p.Notes, p.Notes[0])
+		}
+		ch.Products = append(ch.Products, &p)
+	}
+	return d, ch
+}
+
+func benchmarkRender(b *testing.B, renderer Renderer) {
+	d, ch := largeDocument(200)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		renderer.Channel(d, ch)
+	}
+}
+
+func BenchmarkRenderChat(b *testing.B)      { benchmarkRender(b, Chat{}) }
+func BenchmarkRenderMarkdown(b *testing.B)  { benchmarkRender(b, Markdown{}) }
+func BenchmarkRenderPlainText(b *testing.B) { benchmarkRender(b, PlainText{}) }
+func BenchmarkRenderHTML(b *testing.B)      { benchmarkRender(b, HTML{}) }
+func BenchmarkRenderCard(b *testing.B)      { benchmarkRender(b, Card{Icon: DefaultCardIcon}) }
@@ -0,0 +1,69 @@
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/mpolski/gcp-release-digest/pkg/archive"
+	"github.com/mpolski/gcp-release-digest/pkg/store"
+)
+
+// Key returns the store key of the document of digest number n.
+func Key(n int) string {
+	return fmt.Sprintf("documents/digest-%d.json", n)
+}
+
+// Save stores the document as JSON under Key, so it can be delivered later
+// exactly as it was built.
+func (d *Document) Save(ctx context.Context, s store.Store) error {
+	data, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, Key(d.Number), data)
+}
+
+// Load reads the document of digest number n.
+func Load(ctx context.Context, s store.Store, n int) (*Document, error) {
+	data, err := s.Get(ctx, Key(n))
+	if err != nil {
+		return nil, fmt.Errorf("Error reading digest #%d: %v", n, err)
+	}
+	var d Document
+	if err := json.Unmarshal(data, &d); err != nil {
+		return nil, fmt.Errorf("Error decoding digest #%d: %v", n, err)
+	}
+	return &d, nil
+}
+
+// SummaryKey returns the store key of the summary of product in channel of
+// digest number n written by a summarize step, e.g.
+// "summaries/digest-42/general/cloud-sql.txt".
+func SummaryKey(n int, channel, product string) string {
+	return fmt.Sprintf("summaries/digest-%d/%s/%s.txt", n, archive.Anchor(channel), archive.Anchor(product))
+}
+
+// ApplySummaries fills the summaries missing from the document with those
+// stored under SummaryKey, and returns the products still missing one.
+func (d *Document) ApplySummaries(ctx context.Context, s store.Store) ([]string, error) {
+	var missing []string
+	for _, ch := range d.Channels {
+		for _, p := range ch.Products {
+			if p.Summary != "" {
+				continue
+			}
+			data, err := s.Get(ctx, SummaryKey(d.Number, ch.Name, p.Name()))
+			if errors.Is(err, store.ErrNotFound) {
+				missing = append(missing, ch.Name+"/"+p.Name())
+				continue
+			}
+			if err != nil {
+				return nil, fmt.Errorf("Error reading summary of %s in %s: %v", p.Name(), ch.Name, err)
+			}
+			p.Summary = string(data)
+		}
+	}
+	return missing, nil
+}
@@ -0,0 +1,186 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Message is a digest email with an HTML body and a plain text alternative.
+type Message struct {
+	From    string
+	To      []string
+	Subject string
+	HTML    string
+	Text    string
+	// UnsubscribeURL is announced in the List-Unsubscribe header if set.
+	UnsubscribeURL string
+	// Headers are further headers of the message, such as X-Digest-Run.
+	Headers map[string]string
+}
+
+// Sender delivers email through a mail provider.
+type Sender interface {
+	// Send delivers msg to all its recipients and returns the provider's
+	// response status, e.g. "250 OK" or "202 Accepted".
+	Send(ctx context.Context, msg Message) (status string, err error)
+	// Target identifies the provider in run reports, e.g. smtp://host.
+	Target() string
+}
+
+// SMTP sends email through an SMTP relay, using STARTTLS when the relay
+// supports it, or TLS from the start on port 465.
+type SMTP struct {
+	// Addr is the host and port of the relay, e.g. smtp.example.com:587.
+	Addr     string
+	Username string
+	Password string
+}
+
+// Send implements Sender.
+func (s *SMTP) Send(ctx context.Context, msg Message) (string, error) {
+	host, port, err := net.SplitHostPort(s.Addr)
+	if err != nil {
+		return "", fmt.Errorf("invalid SMTP address %q: %v", s.Addr, err)
+	}
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, host)
+	}
+	data, err := msg.Bytes()
+	if err != nil {
+		return "", err
+	}
+	if port == implicitTLSPort {
+		err = s.sendTLS(ctx, host, auth, msg, data)
+	} else {
+		err = smtp.SendMail(s.Addr, auth, msg.From, msg.To, data)
+	}
+	if err != nil {
+		return "", fmt.Errorf("Error sending email: %v", err)
+	}
+	return "250 OK", nil
+}
+
+// implicitTLSPort is the port of SMTP submission over TLS, which starts
+// with the TLS handshake instead of upgrading the connection with STARTTLS.
+const implicitTLSPort = "465"
+
+// sendTLS sends data to the recipients of msg over a connection that is
+// encrypted from the start.
+func (s *SMTP) sendTLS(ctx context.Context, host string, auth smtp.Auth, msg Message, data []byte) error {
+	dialer := &tls.Dialer{Config: &tls.Config{ServerName: host}}
+	conn, err := dialer.DialContext(ctx, "tcp", s.Addr)
+	if err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+	if auth != nil {
+		if err := c.Auth(auth); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(msg.From); err != nil {
+		return err
+	}
+	for _, addr := range msg.To {
+		if err := c.Rcpt(addr); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// Target implements Sender.
+func (s *SMTP) Target() string {
+	return "smtp://" + s.Addr
+}
+
+// Bytes renders msg as a MIME multipart/alternative message.
+func (msg Message) Bytes() ([]byte, error) {
+	boundary, err := randomBoundary()
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	header := func(name, value string) {
+		fmt.Fprintf(&b, "%s: %s\r\n", name, value)
+	}
+	header("From", msg.From)
+	header("To", strings.Join(msg.To, ", "))
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	if msg.UnsubscribeURL != "" {
+		header("List-Unsubscribe", "<"+msg.UnsubscribeURL+">")
+	}
+	for _, name := range msg.headerNames() {
+		header(name, mime.QEncoding.Encode("utf-8", msg.Headers[name]))
+	}
+	header("Content-Type", `multipart/alternative; boundary="`+boundary+`"`)
+	b.WriteString("\r\n")
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain", msg.Text},
+		{"text/html", msg.HTML},
+	} {
+		fmt.Fprintf(&b, "--%s\r\n", boundary)
+		header("Content-Type", part.contentType+"; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		b.WriteString("\r\n")
+		w := quotedprintable.NewWriter(&b)
+		if _, err := w.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+		b.WriteString("\r\n")
+	}
+	fmt.Fprintf(&b, "--%s--\r\n", boundary)
+	return b.Bytes(), nil
+}
+
+// headerNames returns the names of the further headers of msg in order.
+func (msg Message) headerNames() []string {
+	names := make([]string, 0, len(msg.Headers))
+	for name := range msg.Headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func randomBoundary() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,62 @@
+package email
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sync"
+
+	"google.golang.org/api/gmail/v1"
+	"google.golang.org/api/impersonate"
+	"google.golang.org/api/option"
+)
+
+// Gmail sends email through the Gmail API as a user of a Google Workspace
+// domain, using a service account with domain-wide delegation. No SMTP
+// credentials are needed: the function's own identity signs the delegation
+// through the IAM Credentials API.
+type Gmail struct {
+	// ServiceAccount is the email of the service account granted domain-wide
+	// delegation of the gmail.send scope.
+	ServiceAccount string
+	// User is the address the email is sent as, e.g. release-digest@example.com.
+	User string
+
+	once sync.Once
+	svc  *gmail.Service
+	err  error
+}
+
+// Send implements Sender.
+func (g *Gmail) Send(ctx context.Context, msg Message) (string, error) {
+	g.once.Do(func() {
+		ts, err := impersonate.CredentialsTokenSource(ctx, impersonate.CredentialsConfig{
+			TargetPrincipal: g.ServiceAccount,
+			Scopes:          []string{gmail.GmailSendScope},
+			Subject:         g.User,
+		})
+		if err != nil {
+			g.err = err
+			return
+		}
+		g.svc, g.err = gmail.NewService(ctx, option.WithTokenSource(ts))
+	})
+	if g.err != nil {
+		return "", fmt.Errorf("Error creating Gmail client: %v", g.err)
+	}
+
+	raw, err := msg.Bytes()
+	if err != nil {
+		return "", err
+	}
+	sent, err := g.svc.Users.Messages.Send("me", &gmail.Message{Raw: base64.URLEncoding.EncodeToString(raw)}).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("Error sending email: %v", err)
+	}
+	return "200 OK " + sent.Id, nil
+}
+
+// Target implements Sender.
+func (g *Gmail) Target() string {
+	return "https://gmail.googleapis.com"
+}
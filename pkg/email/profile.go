@@ -0,0 +1,90 @@
+package email
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mpolski/gcp-release-digest/pkg/archive"
+)
+
+// Profile is a named recipient list receiving the summaries of some channels
+// of the digest.
+type Profile struct {
+	Name string
+	// Channels are the channels included, or all channels if empty.
+	Channels []string
+	To       []string
+	// Group marks a Google Group, which archives the digest for searching.
+	// Its email has a dated subject and leaves unsubscribing to Groups.
+	Group bool
+}
+
+// ParseProfiles reads recipient profiles separated by semicolons, each in the
+// form "name: CHANNEL,CHANNEL -> address,address", e.g.
+//
+//	security: SECURITY_BULLETIN,BREAKING_CHANGE -> secops@example.com; all: * -> eng@example.com
+//
+// A channel list of * includes every channel.
+func ParseProfiles(spec string) ([]Profile, error) {
+	var profiles []Profile
+	for _, p := range strings.Split(spec, ";") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		name, rest, ok := strings.Cut(p, ":")
+		channels, to, ok2 := strings.Cut(rest, "->")
+		name = strings.TrimSpace(name)
+		if !ok || !ok2 || name == "" {
+			return nil, fmt.Errorf("invalid email profile %q, expected \"name: CHANNEL,CHANNEL -> address,address\"", p)
+		}
+		profile := Profile{Name: name, To: splitList(to)}
+		if channels = strings.TrimSpace(channels); channels != "*" {
+			profile.Channels = splitList(channels)
+		}
+		if len(profile.To) == 0 {
+			return nil, fmt.Errorf("email profile %s has no recipients", name)
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+// Filter returns a copy of the digest holding only the sections of the
+// profile's channels.
+func (p Profile) Filter(d *archive.Digest) *archive.Digest {
+	if len(p.Channels) == 0 {
+		return d
+	}
+	filtered := *d
+	filtered.Sections = nil
+	for _, s := range d.Sections {
+		for _, c := range p.Channels {
+			if strings.EqualFold(s.Channel, c) {
+				filtered.Sections = append(filtered.Sections, s)
+				break
+			}
+		}
+	}
+	return &filtered
+}
+
+// GroupProfiles returns a profile receiving all channels for each Google
+// Group address in the comma separated list groups.
+func GroupProfiles(groups string) []Profile {
+	var profiles []Profile
+	for _, addr := range splitList(groups) {
+		profiles = append(profiles, Profile{Name: addr, To: []string{addr}, Group: true})
+	}
+	return profiles
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
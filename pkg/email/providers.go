@@ -0,0 +1,139 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/mpolski/gcp-release-digest/pkg/notify"
+)
+
+// Providers lists the names of the supported mail providers.
+var Providers = []string{"smtp", "sendgrid", "mailgun", "ses", "gmail"}
+
+// SendGrid sends email through the SendGrid v3 Mail Send API.
+type SendGrid struct {
+	APIKey string
+}
+
+const sendGridURL = "https://api.sendgrid.com/v3/mail/send"
+
+// Send implements Sender.
+func (s *SendGrid) Send(ctx context.Context, msg Message) (string, error) {
+	type address struct {
+		Email string `json:"email"`
+	}
+	type content struct {
+		Type  string `json:"type"`
+		Value string `json:"value"`
+	}
+	var to []address
+	for _, addr := range msg.To {
+		to = append(to, address{Email: addr})
+	}
+	body := map[string]any{
+		"personalizations": []map[string]any{{"to": to}},
+		"from":             address{Email: msg.From},
+		"subject":          msg.Subject,
+		"content":          []content{{"text/plain", msg.Text}, {"text/html", msg.HTML}},
+	}
+	headers := maps.Clone(msg.Headers)
+	if msg.UnsubscribeURL != "" {
+		if headers == nil {
+			headers = map[string]string{}
+		}
+		headers["List-Unsubscribe"] = "<" + msg.UnsubscribeURL + ">"
+	}
+	if len(headers) > 0 {
+		body["headers"] = headers
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", sendGridURL, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+	return do(req)
+}
+
+// Target implements Sender.
+func (s *SendGrid) Target() string {
+	return sendGridURL
+}
+
+// Mailgun sends email through the Mailgun messages API.
+type Mailgun struct {
+	APIKey string
+	// Domain is the sending domain configured in Mailgun.
+	Domain string
+	// BaseURL is the API endpoint, https://api.mailgun.net by default or
+	// https://api.eu.mailgun.net for domains in the EU region.
+	BaseURL string
+}
+
+// Send implements Sender.
+func (m *Mailgun) Send(ctx context.Context, msg Message) (string, error) {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	fields := [][2]string{{"from", msg.From}, {"subject", msg.Subject}, {"text", msg.Text}, {"html", msg.HTML}}
+	for _, addr := range msg.To {
+		fields = append(fields, [2]string{"to", addr})
+	}
+	if msg.UnsubscribeURL != "" {
+		fields = append(fields, [2]string{"h:List-Unsubscribe", "<" + msg.UnsubscribeURL + ">"})
+	}
+	for _, name := range msg.headerNames() {
+		fields = append(fields, [2]string{"h:" + name, msg.Headers[name]})
+	}
+	for _, f := range fields {
+		if err := form.WriteField(f[0], f[1]); err != nil {
+			return "", err
+		}
+	}
+	if err := form.Close(); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", m.Target(), &body)
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth("api", m.APIKey)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return do(req)
+}
+
+// Target implements Sender.
+func (m *Mailgun) Target() string {
+	base := m.BaseURL
+	if base == "" {
+		base = "https://api.mailgun.net"
+	}
+	return strings.TrimRight(base, "/") + "/v3/" + m.Domain + "/messages"
+}
+
+// do sends an API request with the shared HTTP client and reports a non-2xx
+// response as an error.
+func do(req *http.Request) (string, error) {
+	resp, err := notify.Client(req.URL.String()).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Error sending email: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.Status, fmt.Errorf("Error sending email: %s %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp.Status, nil
+}
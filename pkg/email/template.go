@@ -0,0 +1,147 @@
+package email
+
+import (
+	"bytes"
+	_ "embed"
+	"fmt"
+	"html/template"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mpolski/gcp-release-digest/pkg/archive"
+	"github.com/mpolski/gcp-release-digest/pkg/notify"
+	"github.com/mpolski/gcp-release-digest/pkg/releasenotes"
+)
+
+//go:embed templates/digest.html
+var defaultTemplate string
+
+// Data is what an email template renders: a digest with a section per
+// channel and the summary of every product in it.
+type Data struct {
+	Number         int
+	Created        time.Time
+	Cadence        int
+	Permalink      string
+	UnsubscribeURL string
+	Sections       []Section
+}
+
+// Section holds the products sent to one channel.
+type Section struct {
+	Channel string
+	// Cadence is the number of days covered by the channel if it differs
+	// from the digest's, or zero.
+	Cadence  int
+	Products []Product
+}
+
+// Product is the summary of one product, rendered as HTML.
+type Product struct {
+	Name    string
+	Types   []string
+	Summary template.HTML
+	// Link points to the product on the archived digest, if it is archived.
+	Link string
+}
+
+// newData prepares the archived record of a digest for an email template.
+func newData(d *archive.Digest, permalink, unsubscribeURL string) Data {
+	data := Data{
+		Number:         d.Number,
+		Created:        d.Created,
+		Cadence:        d.Cadence,
+		Permalink:      permalink,
+		UnsubscribeURL: unsubscribeURL,
+	}
+	for _, s := range d.Sections {
+		section := Section{Channel: s.Channel, Cadence: s.Cadence}
+		for _, e := range s.Entries {
+			p := Product{Name: e.Product, Types: e.Types, Summary: template.HTML(notify.Render(e.Summary, notify.EmailCapabilities.Dialect))}
+			if permalink != "" {
+				p.Link = permalink + "#" + archive.Anchor(e.Product)
+			}
+			section.Products = append(section.Products, p)
+		}
+		data.Sections = append(data.Sections, section)
+	}
+	return data
+}
+
+// Template renders digest emails.
+type Template struct {
+	html *template.Template
+}
+
+var funcs = template.FuncMap{
+	"typeLabel": func(t string) string { return releasenotes.TypeLabel(t, 1) },
+	"badgeColor": func(t string) string {
+		switch t {
+		case "SECURITY_BULLETIN", "BREAKING_CHANGE":
+			return "#d93025"
+		case "DEPRECATION", "ISSUE":
+			return "#f29900"
+		case "FEATURE":
+			return "#1e8e3e"
+		}
+		return "#5f6368"
+	},
+}
+
+// LoadTemplate parses the HTML template in path, or the default template if
+// path is empty. Templates are executed with Data and may use the functions
+// typeLabel, e.g. "feature" for FEATURE, and badgeColor, the color of a
+// release note type badge.
+func LoadTemplate(path string) (*Template, error) {
+	text := defaultTemplate
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("Error reading email template: %v", err)
+		}
+		text = string(b)
+	}
+	t, err := template.New("email").Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing email template: %v", err)
+	}
+	return &Template{html: t}, nil
+}
+
+// Render returns the HTML body of the email of digest d and its plain text
+// alternative. permalink is the URL of the archived digest and
+// unsubscribeURL is linked from the footer; both are optional.
+func (t *Template) Render(d *archive.Digest, permalink, unsubscribeURL string) (htmlBody, textBody string, err error) {
+	var b bytes.Buffer
+	if err := t.html.Execute(&b, newData(d, permalink, unsubscribeURL)); err != nil {
+		return "", "", fmt.Errorf("Error rendering email: %v", err)
+	}
+	return b.String(), plainText(d, permalink, unsubscribeURL), nil
+}
+
+// plainText renders the digest for mail clients not showing HTML.
+func plainText(d *archive.Digest, permalink, unsubscribeURL string) string {
+	var b strings.Builder
+	b.WriteString("GCP Release Digest")
+	if d.Number > 0 {
+		fmt.Fprintf(&b, " #%d", d.Number)
+	}
+	fmt.Fprintf(&b, "\nRelease notes of the last %d days.\n", d.Cadence)
+	for _, s := range d.Sections {
+		fmt.Fprintf(&b, "\n== %s ==\n", s.Channel)
+		if s.Cadence > 0 {
+			fmt.Fprintf(&b, "Release notes of the last %d days.\n", s.Cadence)
+		}
+		for _, e := range s.Entries {
+			fmt.Fprintf(&b, "\n%s\n\n%s\n", e.Product, strings.TrimSpace(e.Summary))
+		}
+	}
+	if permalink != "" {
+		fmt.Fprintf(&b, "\nRead it online: %s\n", permalink)
+	}
+	if unsubscribeURL != "" {
+		fmt.Fprintf(&b, "\nUnsubscribe: %s\n", unsubscribeURL)
+	}
+	return b.String()
+}
@@ -0,0 +1,159 @@
+package escalation
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mpolski/gcp-release-digest/pkg/impact"
+)
+
+// Rule is a set of conditions that must all hold for a product's release
+// notes to be escalated, e.g.
+//
+//	type=SECURITY_BULLETIN AND product in (Cloud SQL, Google Kubernetes Engine)
+type Rule struct {
+	Conditions []Condition
+	text       string
+}
+
+// Condition compares a field of a release note with one or more values.
+type Condition struct {
+	// Field is "type", "product" or "impact".
+	Field string
+	// Values are compared case-insensitively; any of them may match.
+	Values []string
+	// Negate inverts the condition, as in "type!=FIX" or "product not in (...)".
+	Negate bool
+	// AtLeast matches impacts of at least the value, as in "impact>=high".
+	AtLeast bool
+}
+
+// Note is a release note as rules see it.
+type Note struct {
+	Type string
+	// Impact is the impact the note was scored with, e.g. "high".
+	Impact string
+}
+
+// Rules is a list of escalation rules; a product is escalated when any of them
+// matches.
+type Rules []Rule
+
+var (
+	andSeparator = regexp.MustCompile(`(?i)\s+AND\s+`)
+	inCondition  = regexp.MustCompile(`(?i)^(\w+)\s+(not\s+)?in\s*\((.*)\)$`)
+	eqCondition  = regexp.MustCompile(`^(\w+)\s*(!=|>=|=)\s*(.+)$`)
+)
+
+// Parse reads rules separated by semicolons, each made of conditions joined
+// by AND. Conditions take the forms field=value, field!=value,
+// field in (a, b) and field not in (a, b), and impact>=level.
+func Parse(spec string) (Rules, error) {
+	var rules Rules
+	for _, text := range strings.Split(spec, ";") {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		rule := Rule{text: text}
+		for _, part := range andSeparator.Split(text, -1) {
+			c, err := parseCondition(strings.TrimSpace(part))
+			if err != nil {
+				return nil, fmt.Errorf("escalation rule %q: %v", text, err)
+			}
+			rule.Conditions = append(rule.Conditions, c)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+func parseCondition(s string) (Condition, error) {
+	var c Condition
+	if m := inCondition.FindStringSubmatch(s); m != nil {
+		c = Condition{Field: strings.ToLower(m[1]), Negate: m[2] != ""}
+		for _, v := range strings.Split(m[3], ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				c.Values = append(c.Values, v)
+			}
+		}
+	} else if m := eqCondition.FindStringSubmatch(s); m != nil {
+		c = Condition{Field: strings.ToLower(m[1]), Values: []string{strings.TrimSpace(m[3])}, Negate: m[2] == "!=", AtLeast: m[2] == ">="}
+	} else {
+		return c, fmt.Errorf("cannot parse condition %q", s)
+	}
+
+	switch c.Field {
+	case "type", "product":
+		if c.AtLeast {
+			return c, fmt.Errorf("condition %q: >= only compares impacts", s)
+		}
+	case "impact":
+		for _, v := range c.Values {
+			if _, err := impact.ParseLevel(v); err != nil {
+				return c, fmt.Errorf("condition %q: %v", s, err)
+			}
+		}
+	default:
+		return c, fmt.Errorf("unknown field %q, use type, product or impact", c.Field)
+	}
+	if len(c.Values) == 0 {
+		return c, fmt.Errorf("condition %q has no values", s)
+	}
+	return c, nil
+}
+
+// String returns the rule as it was written.
+func (r Rule) String() string {
+	return r.text
+}
+
+// Match returns the first rule matching a product with the given release
+// notes.
+func (rs Rules) Match(product string, notes []Note) (Rule, bool) {
+	for _, r := range rs {
+		for _, n := range notes {
+			if r.matches(product, n) {
+				return r, true
+			}
+		}
+	}
+	return Rule{}, false
+}
+
+// matches reports whether all conditions hold for one release note.
+func (r Rule) matches(product string, n Note) bool {
+	for _, c := range r.Conditions {
+		value := product
+		switch c.Field {
+		case "type":
+			value = n.Type
+		case "impact":
+			value = n.Impact
+		}
+		if c.holds(value) == c.Negate {
+			return false
+		}
+	}
+	return true
+}
+
+// holds reports whether value equals any of the condition's values, or for
+// AtLeast is an impact of at least the value, ignoring the Negate flag.
+func (c Condition) holds(value string) bool {
+	for _, v := range c.Values {
+		if c.AtLeast {
+			level, err := impact.ParseLevel(value)
+			floor, _ := impact.ParseLevel(v)
+			if err == nil && level >= floor {
+				return true
+			}
+			continue
+		}
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,64 @@
+package escalation
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Threshold is a rule counting the release notes of a whole digest that
+// match it, which is exceeded by more than Above of them, e.g.
+//
+//	type=BREAKING_CHANGE AND product in (Cloud SQL, BigQuery) > 3
+type Threshold struct {
+	Rule  Rule
+	Above int
+	text  string
+}
+
+var thresholdComparison = regexp.MustCompile(`^(.*?)\s*(>=|>)\s*(\d+)$`)
+
+// ParseThresholds reads thresholds separated by semicolons, each a rule in
+// the form read by Parse followed by "> n" or ">= n".
+func ParseThresholds(spec string) ([]Threshold, error) {
+	var thresholds []Threshold
+	for _, text := range strings.Split(spec, ";") {
+		text = strings.TrimSpace(text)
+		if text == "" {
+			continue
+		}
+		m := thresholdComparison.FindStringSubmatch(text)
+		if m == nil {
+			return nil, fmt.Errorf("threshold %q: expected a rule followed by > n or >= n", text)
+		}
+		rules, err := Parse(m[1])
+		if err != nil {
+			return nil, fmt.Errorf("threshold %q: %v", text, err)
+		}
+		if len(rules) != 1 {
+			return nil, fmt.Errorf("threshold %q: expected one rule", text)
+		}
+		above, _ := strconv.Atoi(m[3])
+		if m[2] == ">=" {
+			above--
+		}
+		thresholds = append(thresholds, Threshold{Rule: rules[0], Above: above, text: text})
+	}
+	return thresholds, nil
+}
+
+func (t Threshold) String() string {
+	return t.text
+}
+
+// Matches reports whether a release note published for the product counts
+// toward the threshold.
+func (t Threshold) Matches(product string, n Note) bool {
+	return t.Rule.matches(product, n)
+}
+
+// Exceeded reports whether count release notes exceed the threshold.
+func (t Threshold) Exceeded(count int) bool {
+	return count > t.Above
+}
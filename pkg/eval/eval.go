@@ -0,0 +1,176 @@
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/mpolski/gcp-release-digest/pkg/releasenotes"
+	"github.com/mpolski/gcp-release-digest/pkg/summarize"
+)
+
+// Fixture is a fixed set of release notes of one product that every
+// candidate summarizes.
+type Fixture struct {
+	Product      string                     `json:"product"`
+	ReleaseNotes []releasenotes.ReleaseNote `json:"release_notes"`
+	// KeyPhrases are the phrases a good summary mentions, e.g. the name of
+	// a new feature.
+	KeyPhrases []string `json:"key_phrases"`
+}
+
+// LoadFixtures reads a JSON array of fixtures.
+func LoadFixtures(path string) ([]Fixture, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading fixtures: %v", err)
+	}
+	var fixtures []Fixture
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		return nil, fmt.Errorf("Error decoding fixtures %s: %v", path, err)
+	}
+	return fixtures, nil
+}
+
+// Candidate is a combination of a prompt and a model to evaluate.
+type Candidate struct {
+	Prompt *summarize.Prompt
+	Model  string
+}
+
+// Name identifies the candidate, e.g. "default@gemini-1.5-flash".
+func (c Candidate) Name() string {
+	return c.Prompt.Name + "@" + c.Model
+}
+
+// Settings are the project, location and judge model used for every
+// candidate.
+type Settings struct {
+	ProjectID string
+	Location  string
+	// JudgeModel rates every summary if set.
+	JudgeModel string
+}
+
+// Result is the summary of one fixture by one candidate and its metrics.
+type Result struct {
+	Candidate string           `json:"candidate"`
+	Product   string           `json:"product"`
+	Summary   string           `json:"summary,omitempty"`
+	Chars     int              `json:"chars"`
+	Words     int              `json:"words"`
+	Coverage  float64          `json:"coverage"`
+	Score     *summarize.Score `json:"score,omitempty"`
+	Error     string           `json:"error,omitempty"`
+}
+
+// Run summarizes every fixture with every candidate and measures the
+// summaries.
+func Run(ctx context.Context, s Settings, fixtures []Fixture, candidates []Candidate) []Result {
+	var results []Result
+	for _, c := range candidates {
+		for _, f := range fixtures {
+			fmt.Printf("Summarizing %s with %s...\n", f.Product, c.Name())
+			results = append(results, evaluate(ctx, s, c, f))
+		}
+	}
+	return results
+}
+
+// evaluate summarizes one fixture with one candidate.
+func evaluate(ctx context.Context, s Settings, c Candidate, f Fixture) Result {
+	r := Result{Candidate: c.Name(), Product: f.Product}
+	var releaseNotesSlice []string
+	for _, rn := range f.ReleaseNotes {
+		releaseNotesSlice = append(releaseNotesSlice, rn.ReleaseNoteType, rn.Description)
+	}
+	summary, err := c.Prompt.Summarize(ctx, s.ProjectID, c.Model, s.Location, f.Product, releaseNotesSlice)
+	if err != nil {
+		r.Error = err.Error()
+		return r
+	}
+	r.Summary = summary
+	r.Chars = len([]rune(summary))
+	r.Words = len(strings.Fields(summary))
+	r.Coverage = Coverage(summary, f.KeyPhrases)
+
+	if s.JudgeModel != "" {
+		score, err := summarize.Judge(ctx, s.ProjectID, s.JudgeModel, s.Location, f.Product, releaseNotesSlice, summary)
+		if err != nil {
+			r.Error = err.Error()
+		} else {
+			r.Score = &score
+		}
+	}
+	return r
+}
+
+// Coverage returns the share of the key phrases mentioned in the summary,
+// ignoring case, or 1 if there are none.
+func Coverage(summary string, keyPhrases []string) float64 {
+	if len(keyPhrases) == 0 {
+		return 1
+	}
+	summary = strings.ToLower(summary)
+	found := 0
+	for _, phrase := range keyPhrases {
+		if strings.Contains(summary, strings.ToLower(phrase)) {
+			found++
+		}
+	}
+	return float64(found) / float64(len(keyPhrases))
+}
+
+// Report compares the candidates by their average metrics over all
+// fixtures, as a Markdown table in the order the candidates were run.
+func Report(results []Result) string {
+	type totals struct {
+		n, errors, scored int
+		chars, words      int
+		coverage, score   float64
+	}
+	var order []string
+	byCandidate := make(map[string]*totals)
+	for _, r := range results {
+		t, ok := byCandidate[r.Candidate]
+		if !ok {
+			t = &totals{}
+			byCandidate[r.Candidate] = t
+			order = append(order, r.Candidate)
+		}
+		if r.Summary == "" {
+			t.errors++
+			continue
+		}
+		t.n++
+		t.chars += r.Chars
+		t.words += r.Words
+		t.coverage += r.Coverage
+		if r.Score != nil {
+			t.scored++
+			t.score += r.Score.Mean()
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("| Candidate | Summaries | Errors | Avg chars | Avg words | Key phrase coverage | Judge score |\n")
+	b.WriteString("|-----------|-----------|--------|-----------|-----------|---------------------|-------------|\n")
+	for _, name := range order {
+		t := byCandidate[name]
+		avg := func(v float64) float64 {
+			if t.n == 0 {
+				return 0
+			}
+			return v / float64(t.n)
+		}
+		score := "-"
+		if t.scored > 0 {
+			score = fmt.Sprintf("%.2f", t.score/float64(t.scored))
+		}
+		fmt.Fprintf(&b, "| %s | %d | %d | %.0f | %.0f | %.0f%% | %s |\n",
+			name, t.n, t.errors, avg(float64(t.chars)), avg(float64(t.words)), 100*avg(t.coverage), score)
+	}
+	return b.String()
+}
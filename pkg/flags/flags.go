@@ -0,0 +1,67 @@
+package flags
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Feature flags gating experimental behavior, enabled with FEATURES for every
+// channel or with <CHANNEL>_FEATURES for one.
+const (
+	// Cards sends each product's summary as a Google Chat card, with the
+	// release note types as subtitle, a section per type and buttons to the
+	// release notes page and the archived digest, instead of as text.
+	// Targets without cards keep getting text.
+	Cards = "cards"
+	// Sections groups the summaries of a channel by product category, each
+	// category starting a new message under its heading, after a table of
+	// contents linking the categories in the archived digest.
+	Sections = "sections"
+)
+
+// known lists the feature flags with their description.
+var known = map[string]string{
+	Cards:    "send summaries as Google Chat cards",
+	Sections: "group summaries by category after a table of contents",
+}
+
+// Set holds the enabled feature flags.
+type Set map[string]bool
+
+// Parse reads comma separated lists of feature flags. A flag prefixed with
+// "-" is disabled, so a later list, such as a channel's, can turn off a flag
+// enabled by an earlier one.
+func Parse(specs ...string) (Set, error) {
+	set := make(Set)
+	for _, spec := range specs {
+		for _, name := range strings.Split(spec, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			enabled := !strings.HasPrefix(name, "-")
+			name = strings.TrimPrefix(name, "-")
+			if _, ok := known[name]; !ok {
+				return nil, fmt.Errorf("unknown feature %q, expected one of %s", name, strings.Join(Names(), ", "))
+			}
+			set[name] = enabled
+		}
+	}
+	return set, nil
+}
+
+// Enabled reports whether the feature flag name is enabled.
+func (s Set) Enabled(name string) bool {
+	return s[name]
+}
+
+// Names lists the known feature flags.
+func Names() []string {
+	var names []string
+	for name := range known {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,110 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/mpolski/gcp-release-digest/pkg/notify"
+)
+
+// DefaultAPIURL is the address of the REST API of github.com.
+const DefaultAPIURL = "https://api.github.com"
+
+// Client opens issues in a repository through the GitHub REST API.
+type Client struct {
+	// APIURL is the address of the REST API, DefaultAPIURL if empty, or
+	// e.g. https://github.example.com/api/v3 for GitHub Enterprise Server.
+	APIURL string
+	// Repo is the repository issues are opened in, as owner/name.
+	Repo string
+	// Token is a personal access token or an app installation token
+	// allowed to write issues of the repository.
+	Token string
+}
+
+// Issue is an issue to open.
+type Issue struct {
+	Title string
+	// Body is in GitHub flavored Markdown.
+	Body string
+	// Labels are created in the repository if they do not exist yet.
+	Labels []string
+}
+
+// Create opens the issue and returns the address of its page.
+func (c *Client) Create(ctx context.Context, issue Issue) (htmlURL, status string, err error) {
+	fields := map[string]any{
+		"title": notify.Shorten(issue.Title, 256),
+		"body":  notify.Shorten(issue.Body, 65536),
+	}
+	if len(issue.Labels) > 0 {
+		fields["labels"] = issue.Labels
+	}
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return "", "", fmt.Errorf("Error encoding GitHub issue: %v", err)
+	}
+
+	endpoint := c.Target() + "/issues"
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(data))
+	if err != nil {
+		return "", "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := notify.Client(endpoint).Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", resp.Status, fmt.Errorf("Error creating GitHub issue: %s %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var created struct {
+		HTMLURL string `json:"html_url"`
+	}
+	if err := json.Unmarshal(body, &created); err != nil {
+		return "", resp.Status, fmt.Errorf("Error decoding created GitHub issue: %v", err)
+	}
+	return created.HTMLURL, resp.Status, nil
+}
+
+// Target identifies the repository in run reports.
+func (c *Client) Target() string {
+	api := c.APIURL
+	if api == "" {
+		api = DefaultAPIURL
+	}
+	return strings.TrimSuffix(api, "/") + "/repos/" + c.Repo
+}
+
+// Label returns the label of a release note type, e.g. "breaking-change"
+// for BREAKING_CHANGE.
+func Label(releaseNoteType string) string {
+	return strings.ToLower(strings.ReplaceAll(releaseNoteType, "_", "-"))
+}
+
+// CodeBlock returns text as a fenced code block, shown as is rather than as
+// Markdown, with a fence longer than any run of backticks in text.
+func CodeBlock(text string) string {
+	fence, run := "```", 0
+	for _, r := range text {
+		if r != '`' {
+			run = 0
+			continue
+		}
+		if run++; run >= len(fence) {
+			fence += "`"
+		}
+	}
+	return fence + "\n" + text + "\n" + fence
+}
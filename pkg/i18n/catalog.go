@@ -0,0 +1,225 @@
+package i18n
+
+// catalog holds the messages of every supported locale, by language tag.
+var catalog = map[string]*Messages{
+	"en": {
+		Language:        "English",
+		Title:           "GCP Release Digest",
+		Found:           "Found release notes for %d products since %s",
+		Continued:       "Found release notes (continued)",
+		NoNews:          "No release notes for your products in the last %d days",
+		HereItIs:        "And here it is...",
+		ReadArchive:     "Read the archived digest",
+		Urgent:          "🚨 Urgent",
+		Unusual:         "%s: unusually high activity",
+		Across:          "%s across %d %s",
+		Product:         "product",
+		Products:        "products",
+		And:             "and",
+		ReadMore:        "Read more",
+		AllNotes:        "All %d release notes",
+		Expand:          "Expand all %d release notes",
+		NotesPage:       "Release notes page",
+		ProviderChanges: "Related provider changes: %s",
+		ReadInArchive:   "Read in the archive",
+		StatsTitle:      "Release notes in %s",
+		StatsTotal:      "%d release notes across %d products, %s vs. %s",
+		StatsByType:     "By type",
+		StatsMostActive: "Most active products",
+		StatsByCategory: "By category",
+		StatsNew:        "new",
+		Closing:         "That's all folks!",
+		ArchivedAt:      "Digest #%d is archived at %s",
+		Exported:        "Raw release notes: %s",
+		DigestNumber:    "(digest #%d)",
+		RunOf:           "Run of %s",
+		Covered:         "Products covered: %d",
+		Unverified:      "⚠️ _This summary may contain statements not found in the release notes._",
+		RawNotes:        "_The summary could not be verified, here are the release notes:_",
+		NoSummary:       "_No summary could be written, here are the release notes:_",
+		Contents:        "Contents",
+		Helpful:         "Helpful",
+		NotHelpful:      "Not helpful",
+		Deadlines:       "Deprecation deadlines in the next %d days",
+		NoDeadlines:     "No deprecation deadlines in the next %d days",
+		DueIn:           "due %s, in %d days",
+		FirstSeen:       "first in digest #%d",
+		Correction:      "_Correction: %d of the %d products announced were sent: %s. Left out: %s._",
+		Types: map[string][2]string{
+			"BREAKING_CHANGE":      {"breaking change", "breaking changes"},
+			"DEPRECATION":          {"deprecation", "deprecations"},
+			"FEATURE":              {"feature", "features"},
+			"FIX":                  {"fix", "fixes"},
+			"ISSUE":                {"issue", "issues"},
+			"LIBRARIES":            {"library update", "library updates"},
+			"NON_BREAKING_CHANGE":  {"non-breaking change", "non-breaking changes"},
+			"SECURITY_BULLETIN":    {"security bulletin", "security bulletins"},
+			"SERVICE_ANNOUNCEMENT": {"service announcement", "service announcements"},
+		},
+	},
+	"de": {
+		Language:        "German",
+		Title:           "GCP Release Digest",
+		Found:           "Versionshinweise für %d Produkte seit %s",
+		Continued:       "Versionshinweise (Fortsetzung)",
+		NoNews:          "Keine Versionshinweise für Ihre Produkte in den letzten %d Tagen",
+		HereItIs:        "Und hier sind sie...",
+		ReadArchive:     "Im Archiv lesen",
+		Urgent:          "🚨 Dringend",
+		Unusual:         "%s: ungewöhnlich hohe Aktivität",
+		Across:          "%s in %d %s",
+		Product:         "Produkt",
+		Products:        "Produkten",
+		And:             "und",
+		ReadMore:        "Weiterlesen",
+		AllNotes:        "Alle %d Versionshinweise",
+		Expand:          "Alle %d Versionshinweise aufklappen",
+		NotesPage:       "Seite der Versionshinweise",
+		ProviderChanges: "Zugehörige Änderungen am Provider: %s",
+		ReadInArchive:   "Im Archiv lesen",
+		StatsTitle:      "Versionshinweise im %s",
+		StatsTotal:      "%d Versionshinweise zu %d Produkten, %s ggü. %s",
+		StatsByType:     "Nach Typ",
+		StatsMostActive: "Aktivste Produkte",
+		StatsByCategory: "Nach Kategorie",
+		StatsNew:        "neu",
+		Closing:         "Das war's!",
+		ArchivedAt:      "Digest #%d ist archiviert unter %s",
+		Exported:        "Rohdaten der Versionshinweise: %s",
+		DigestNumber:    "(Digest #%d)",
+		RunOf:           "Lauf vom %s",
+		Covered:         "Abgedeckte Produkte: %d",
+		Unverified:      "⚠️ _Diese Zusammenfassung enthält möglicherweise Aussagen, die nicht in den Versionshinweisen stehen._",
+		RawNotes:        "_Die Zusammenfassung konnte nicht geprüft werden, hier sind die Versionshinweise:_",
+		NoSummary:       "_Es konnte keine Zusammenfassung erstellt werden, hier sind die Versionshinweise:_",
+		Contents:        "Inhalt",
+		Helpful:         "Hilfreich",
+		NotHelpful:      "Nicht hilfreich",
+		Deadlines:       "Abkündigungsfristen in den nächsten %d Tagen",
+		NoDeadlines:     "Keine Abkündigungsfristen in den nächsten %d Tagen",
+		DueIn:           "fällig am %s, in %d Tagen",
+		FirstSeen:       "zuerst in Digest #%d",
+		Correction:      "_Korrektur: %d der %d angekündigten Produkte wurden gesendet: %s. Ausgelassen: %s._",
+		Types: map[string][2]string{
+			"BREAKING_CHANGE":      {"inkompatible Änderung", "inkompatible Änderungen"},
+			"DEPRECATION":          {"Abkündigung", "Abkündigungen"},
+			"FEATURE":              {"Funktion", "Funktionen"},
+			"FIX":                  {"Fehlerbehebung", "Fehlerbehebungen"},
+			"ISSUE":                {"bekanntes Problem", "bekannte Probleme"},
+			"LIBRARIES":            {"Bibliotheksupdate", "Bibliotheksupdates"},
+			"NON_BREAKING_CHANGE":  {"kompatible Änderung", "kompatible Änderungen"},
+			"SECURITY_BULLETIN":    {"Sicherheitsbulletin", "Sicherheitsbulletins"},
+			"SERVICE_ANNOUNCEMENT": {"Dienstankündigung", "Dienstankündigungen"},
+		},
+	},
+	"fr": {
+		Language:        "French",
+		Title:           "GCP Release Digest",
+		Found:           "Notes de version pour %d produits depuis le %s",
+		Continued:       "Notes de version (suite)",
+		NoNews:          "Aucune note de version pour vos produits ces %d derniers jours",
+		HereItIs:        "Et les voici...",
+		ReadArchive:     "Lire le digest archivé",
+		Urgent:          "🚨 Urgent",
+		Unusual:         "%s : activité inhabituellement élevée",
+		Across:          "%s pour %d %s",
+		Product:         "produit",
+		Products:        "produits",
+		And:             "et",
+		ReadMore:        "Lire la suite",
+		AllNotes:        "Les %d notes de version",
+		Expand:          "Développer les %d notes de version",
+		NotesPage:       "Page des notes de version",
+		ProviderChanges: "Modifications associées du provider : %s",
+		ReadInArchive:   "Lire dans l'archive",
+		StatsTitle:      "Notes de version de %s",
+		StatsTotal:      "%d notes de version pour %d produits, %s par rapport à %s",
+		StatsByType:     "Par type",
+		StatsMostActive: "Produits les plus actifs",
+		StatsByCategory: "Par catégorie",
+		StatsNew:        "nouveau",
+		Closing:         "C'est tout pour aujourd'hui !",
+		ArchivedAt:      "Le digest n° %d est archivé sur %s",
+		Exported:        "Notes de version brutes : %s",
+		DigestNumber:    "(digest n° %d)",
+		RunOf:           "Exécution du %s",
+		Covered:         "Produits couverts : %d",
+		Unverified:      "⚠️ _Ce résumé contient peut-être des affirmations absentes des notes de version._",
+		RawNotes:        "_Le résumé n'a pas pu être vérifié, voici les notes de version :_",
+		NoSummary:       "_Aucun résumé n'a pu être rédigé, voici les notes de version :_",
+		Contents:        "Sommaire",
+		Helpful:         "Utile",
+		NotHelpful:      "Pas utile",
+		Deadlines:       "Échéances de dépréciation dans les %d prochains jours",
+		NoDeadlines:     "Aucune échéance de dépréciation dans les %d prochains jours",
+		DueIn:           "échéance le %s, dans %d jours",
+		FirstSeen:       "signalé dans le digest n° %d",
+		Correction:      "_Correction : %d des %d produits annoncés ont été envoyés : %s. Omis : %s._",
+		Types: map[string][2]string{
+			"BREAKING_CHANGE":      {"changement incompatible", "changements incompatibles"},
+			"DEPRECATION":          {"abandon", "abandons"},
+			"FEATURE":              {"fonctionnalité", "fonctionnalités"},
+			"FIX":                  {"correctif", "correctifs"},
+			"ISSUE":                {"problème connu", "problèmes connus"},
+			"LIBRARIES":            {"mise à jour de bibliothèque", "mises à jour de bibliothèques"},
+			"NON_BREAKING_CHANGE":  {"changement compatible", "changements compatibles"},
+			"SECURITY_BULLETIN":    {"bulletin de sécurité", "bulletins de sécurité"},
+			"SERVICE_ANNOUNCEMENT": {"annonce de service", "annonces de service"},
+		},
+	},
+	"es": {
+		Language:        "Spanish",
+		Title:           "GCP Release Digest",
+		Found:           "Notas de la versión de %d productos desde el %s",
+		Continued:       "Notas de la versión (continuación)",
+		NoNews:          "No hay notas de la versión de sus productos en los últimos %d días",
+		HereItIs:        "Y aquí están...",
+		ReadArchive:     "Leer el resumen archivado",
+		Urgent:          "🚨 Urgente",
+		Unusual:         "%s: actividad inusualmente alta",
+		Across:          "%s en %d %s",
+		Product:         "producto",
+		Products:        "productos",
+		And:             "y",
+		ReadMore:        "Leer más",
+		AllNotes:        "Las %d notas de la versión",
+		Expand:          "Expandir las %d notas de la versión",
+		NotesPage:       "Página de notas de la versión",
+		ProviderChanges: "Cambios relacionados del proveedor: %s",
+		ReadInArchive:   "Leer en el archivo",
+		StatsTitle:      "Notas de la versión de %s",
+		StatsTotal:      "%d notas de la versión en %d productos, %s frente a %s",
+		StatsByType:     "Por tipo",
+		StatsMostActive: "Productos más activos",
+		StatsByCategory: "Por categoría",
+		StatsNew:        "nuevo",
+		Closing:         "¡Eso es todo!",
+		ArchivedAt:      "El resumen n.º %d está archivado en %s",
+		Exported:        "Notas de la versión sin procesar: %s",
+		DigestNumber:    "(resumen n.º %d)",
+		RunOf:           "Ejecución del %s",
+		Covered:         "Productos cubiertos: %d",
+		Unverified:      "⚠️ _Este resumen puede contener afirmaciones que no están en las notas de la versión._",
+		RawNotes:        "_No se pudo verificar el resumen, estas son las notas de la versión:_",
+		NoSummary:       "_No se pudo escribir un resumen, estas son las notas de la versión:_",
+		Contents:        "Contenido",
+		Helpful:         "Útil",
+		NotHelpful:      "No es útil",
+		Deadlines:       "Plazos de obsolescencia en los próximos %d días",
+		NoDeadlines:     "Ningún plazo de obsolescencia en los próximos %d días",
+		DueIn:           "vence el %s, en %d días",
+		FirstSeen:       "primero en el resumen n.º %d",
+		Correction:      "_Corrección: se enviaron %d de los %d productos anunciados: %s. Omitidos: %s._",
+		Types: map[string][2]string{
+			"BREAKING_CHANGE":      {"cambio incompatible", "cambios incompatibles"},
+			"DEPRECATION":          {"obsolescencia", "obsolescencias"},
+			"FEATURE":              {"función", "funciones"},
+			"FIX":                  {"corrección", "correcciones"},
+			"ISSUE":                {"problema conocido", "problemas conocidos"},
+			"LIBRARIES":            {"actualización de bibliotecas", "actualizaciones de bibliotecas"},
+			"NON_BREAKING_CHANGE":  {"cambio compatible", "cambios compatibles"},
+			"SECURITY_BULLETIN":    {"boletín de seguridad", "boletines de seguridad"},
+			"SERVICE_ANNOUNCEMENT": {"anuncio de servicio", "anuncios de servicio"},
+		},
+	},
+}
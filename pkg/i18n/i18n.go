@@ -0,0 +1,170 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Messages are the static strings of the digest in one language. Strings with
+// verbs are fmt formats.
+type Messages struct {
+	// Language is the English name of the language, used to ask the model
+	// for summaries in it.
+	Language string
+
+	// Title is the name of the digest, as in "GCP Release Digest #12".
+	Title string
+	// Found heads the announcement: "Found release notes for %d products
+	// since %s".
+	Found string
+	// Continued heads the following messages of a split announcement.
+	Continued string
+	// NoNews replaces the announcement of a channel without release notes:
+	// "No release notes for your products in the last %d days".
+	NoNews string
+	// HereItIs ends the announcement, before the summaries.
+	HereItIs string
+	// ReadArchive links the archived digest from the announcement.
+	ReadArchive string
+	// Urgent labels a digest exceeding an alerting threshold.
+	Urgent string
+	// Unusual flags a product in the announcement: "%s: unusually high
+	// activity".
+	Unusual string
+	// Across is the type breakdown: "%s across %d %s", with the list of
+	// counts, the number of products and Product or Products.
+	Across   string
+	Product  string
+	Products string
+	// And joins the last two items of a list.
+	And string
+	// ReadMore links the full summary from a truncated one.
+	ReadMore string
+	// AllNotes links the full release notes of a product: "All %d release
+	// notes".
+	AllNotes string
+	// Expand posts the full release notes of a product to the channel:
+	// "Expand all %d release notes".
+	Expand string
+	// NotesPage links a product's release notes page in the documentation.
+	NotesPage string
+	// ProviderChanges links the related releases of the Terraform provider:
+	// "Related provider changes: %s".
+	ProviderChanges string
+	// ReadInArchive is the button of a card linking the archived digest.
+	ReadInArchive string
+	// StatsTitle heads the monthly statistics: "Release notes in %s", with
+	// the month.
+	StatsTitle string
+	// StatsTotal is "%d release notes across %d products, %s vs. %s", with
+	// the trend and the previous month.
+	StatsTotal string
+	// StatsByType, StatsMostActive and StatsByCategory head the lists of
+	// the monthly statistics.
+	StatsByType     string
+	StatsMostActive string
+	StatsByCategory string
+	// StatsNew is the trend of counts without any the month before.
+	StatsNew string
+	// Closing is the closing message.
+	Closing string
+	// ArchivedAt follows the closing message of an archived digest: "Digest
+	// #%d is archived at %s".
+	ArchivedAt string
+	// Exported follows the closing message of a channel whose raw release
+	// notes were exported: "Raw release notes: %s".
+	Exported string
+	// DigestNumber follows the closing message of a numbered digest that is
+	// not archived: "(digest #%d)".
+	DigestNumber string
+	// RunOf names an unnumbered run in the footer: "Run of %s", with its
+	// time.
+	RunOf string
+	// Covered counts the products in the footer: "Products covered: %d".
+	Covered string
+	// Unverified labels a summary with claims the release notes do not
+	// support.
+	Unverified string
+	// RawNotes heads the release notes sent instead of such a summary.
+	RawNotes string
+	// NoSummary heads the release notes sent when the model wrote no
+	// summary of them.
+	NoSummary string
+	// Contents heads the table of contents of summaries grouped by category.
+	Contents string
+	// Helpful and NotHelpful are the links rating a summary.
+	Helpful    string
+	NotHelpful string
+	// Deadlines heads the reminder of deprecation deadlines: "Deprecation
+	// deadlines in the next %d days".
+	Deadlines string
+	// NoDeadlines is the reminder without any: "No deprecation deadlines in
+	// the next %d days".
+	NoDeadlines string
+	// DueIn follows a deprecation in the reminder: "due %s, in %d days".
+	DueIn string
+	// FirstSeen follows a deprecation first reported in a numbered digest:
+	// "first in digest #%d".
+	FirstSeen string
+	// Correction follows the summaries of a streamed channel that left out
+	// products it announced: "Correction: %d of the %d products announced
+	// were sent: %s. Left out: %s."
+	Correction string
+
+	// Types holds the singular and plural names of the release note types.
+	Types map[string][2]string
+}
+
+var (
+	mu      sync.RWMutex
+	current = catalog["en"]
+)
+
+// Lookup returns the messages of a locale, such as "de", "de-DE" or "de_DE".
+// A locale with a region falls back to its language.
+func Lookup(locale string) (*Messages, error) {
+	tag := strings.ToLower(strings.ReplaceAll(locale, "_", "-"))
+	if m, ok := catalog[tag]; ok {
+		return m, nil
+	}
+	lang, _, _ := strings.Cut(tag, "-")
+	if m, ok := catalog[lang]; ok {
+		return m, nil
+	}
+	return nil, fmt.Errorf("unsupported locale %q, expected one of %s", locale, strings.Join(Locales(), ", "))
+}
+
+// Locales lists the supported locales.
+func Locales() []string {
+	var locales []string
+	for tag := range catalog {
+		locales = append(locales, tag)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// SetLocale selects the language of the digest's static strings. An empty
+// locale selects English.
+func SetLocale(locale string) error {
+	m := catalog["en"]
+	if locale != "" {
+		var err error
+		if m, err = Lookup(locale); err != nil {
+			return err
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	current = m
+	return nil
+}
+
+// M returns the messages of the selected locale.
+func M() *Messages {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
@@ -0,0 +1,163 @@
+package impact
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mpolski/gcp-release-digest/pkg/releasenotes"
+)
+
+// Level is the impact of a release note on its readers.
+type Level int
+
+const (
+	Info Level = iota
+	Low
+	Medium
+	High
+	Critical
+)
+
+var levelNames = []string{"info", "low", "medium", "high", "critical"}
+
+func (l Level) String() string {
+	if l < Info || l > Critical {
+		return levelNames[Info]
+	}
+	return levelNames[l]
+}
+
+// ParseLevel reads a level by its name, e.g. "high".
+func ParseLevel(name string) (Level, error) {
+	for i, n := range levelNames {
+		if strings.EqualFold(strings.TrimSpace(name), n) {
+			return Level(i), nil
+		}
+	}
+	return Info, fmt.Errorf("unknown impact %q, use one of %s", name, strings.Join(levelNames, ", "))
+}
+
+// clamp keeps l within Info and Critical.
+func clamp(l Level) Level {
+	return min(max(l, Info), Critical)
+}
+
+// typeLevels is the impact a release note starts with by its type; other
+// types start at Info.
+var typeLevels = map[string]Level{
+	"SECURITY_BULLETIN":    High,
+	"BREAKING_CHANGE":      High,
+	"DEPRECATION":          Medium,
+	"ISSUE":                Medium,
+	"FEATURE":              Low,
+	"FIX":                  Low,
+	"SERVICE_ANNOUNCEMENT": Low,
+}
+
+// DefaultKeywords are phrases asking readers to act, raising the impact of
+// a release note mentioning any of them by one level.
+var DefaultKeywords = []string{
+	"will be shut down", "shutdown", "shut down", "turned down", "end of life", "end-of-life",
+	"requires action", "action required", "must migrate", "must upgrade", "will be removed",
+	"no longer supported", "no longer available", "critical",
+}
+
+// Scorer assigns every release note an impact by rules: its type sets the
+// starting level, a keyword raises it by one, and the criticality of its
+// product shifts it by the distance of the criticality from Medium.
+type Scorer struct {
+	// Keywords are matched case-insensitively against the description.
+	Keywords []string
+	// Products maps normalized product names to their criticality.
+	Products map[string]Level
+}
+
+// NewScorer returns a scorer matching the default keywords and those
+// given, with the criticality of products read by ParseProducts.
+func NewScorer(keywords []string, products map[string]Level) *Scorer {
+	s := &Scorer{Products: products}
+	for _, k := range append(append([]string{}, DefaultKeywords...), keywords...) {
+		if k = strings.ToLower(strings.TrimSpace(k)); k != "" {
+			s.Keywords = append(s.Keywords, k)
+		}
+	}
+	return s
+}
+
+// ParseProducts reads the criticality of products separated by semicolons,
+// each in the form product=level, e.g. "Cloud SQL=critical; Looker=low".
+func ParseProducts(spec string) (map[string]Level, error) {
+	products := make(map[string]Level)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		product, name, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(product) == "" {
+			return nil, fmt.Errorf("product criticality %q: expected product=level", entry)
+		}
+		level, err := ParseLevel(name)
+		if err != nil {
+			return nil, fmt.Errorf("product criticality %q: %v", entry, err)
+		}
+		products[releasenotes.Normalize(product)] = level
+	}
+	return products, nil
+}
+
+// Score returns the impact of a release note of product by the rules.
+func (s *Scorer) Score(product string, rn releasenotes.ReleaseNote) Level {
+	level := typeLevels[rn.ReleaseNoteType]
+	description := strings.ToLower(rn.Description)
+	for _, k := range s.Keywords {
+		if strings.Contains(description, k) {
+			level++
+			break
+		}
+	}
+	if c, ok := s.Products[releasenotes.Normalize(product)]; ok {
+		level += c - Medium
+	}
+	return clamp(level)
+}
+
+// Apply sets the impact of every release note of product by the rules, or
+// by the levels rated, one per note, where those are higher. Ratings are
+// ignored unless there is one for each note.
+func (s *Scorer) Apply(product string, releaseNotes []releasenotes.ReleaseNote, rated []Level) {
+	for i := range releaseNotes {
+		level := s.Score(product, releaseNotes[i])
+		if len(rated) == len(releaseNotes) {
+			level = max(level, clamp(rated[i]))
+		}
+		releaseNotes[i].Impact = level.String()
+	}
+}
+
+// Of returns the impact of a release note as it was set, Info if it was
+// not.
+func Of(rn releasenotes.ReleaseNote) Level {
+	level, _ := ParseLevel(rn.Impact)
+	return level
+}
+
+// Highest returns the highest impact of the release notes.
+func Highest(releaseNotes []releasenotes.ReleaseNote) Level {
+	highest := Info
+	for _, rn := range releaseNotes {
+		highest = max(highest, Of(rn))
+	}
+	return highest
+}
+
+// AtLeast returns the release notes with an impact of at least floor.
+func AtLeast(releaseNotes []releasenotes.ReleaseNote, floor Level) []releasenotes.ReleaseNote {
+	var kept []releasenotes.ReleaseNote
+	for _, rn := range releaseNotes {
+		if Of(rn) >= floor {
+			kept = append(kept, rn)
+		}
+	}
+	return kept
+}
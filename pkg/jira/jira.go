@@ -0,0 +1,102 @@
+package jira
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/mpolski/gcp-release-digest/pkg/notify"
+)
+
+// Client opens issues through the REST API v2 of Jira Cloud or Jira Data
+// Center.
+type Client struct {
+	// BaseURL is the address of the Jira site, e.g.
+	// https://example.atlassian.net.
+	BaseURL string
+	// Email and Token are the credentials of the user opening issues: the
+	// account's email and an API token on Jira Cloud. Without an email the
+	// token is sent as the bearer token of a Data Center personal access
+	// token.
+	Email string
+	Token string
+	// Project is the key of the project issues are opened in, e.g. PLAT.
+	Project string
+	// IssueType is the name of the type of the issues, e.g. Task.
+	IssueType string
+}
+
+// Issue is an issue to open.
+type Issue struct {
+	Summary string
+	// Description is in Jira's wiki markup.
+	Description string
+	Labels      []string
+}
+
+// Create opens the issue and returns its key, e.g. PLAT-123.
+func (c *Client) Create(ctx context.Context, issue Issue) (key, status string, err error) {
+	fields := map[string]any{
+		"project":     map[string]string{"key": c.Project},
+		"issuetype":   map[string]string{"name": c.IssueType},
+		"summary":     notify.Shorten(issue.Summary, 255),
+		"description": issue.Description,
+	}
+	if len(issue.Labels) > 0 {
+		fields["labels"] = issue.Labels
+	}
+	data, err := json.Marshal(map[string]any{"fields": fields})
+	if err != nil {
+		return "", "", fmt.Errorf("Error encoding Jira issue: %v", err)
+	}
+
+	endpoint := c.Target() + "/rest/api/2/issue"
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(data))
+	if err != nil {
+		return "", "", err
+	}
+	if c.Email != "" {
+		req.SetBasicAuth(c.Email, c.Token)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := notify.Client(endpoint).Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", resp.Status, fmt.Errorf("Error creating Jira issue: %s %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var created struct {
+		Key string `json:"key"`
+	}
+	if err := json.Unmarshal(body, &created); err != nil {
+		return "", resp.Status, fmt.Errorf("Error decoding created Jira issue: %v", err)
+	}
+	return created.Key, resp.Status, nil
+}
+
+// Browse returns the address of an issue.
+func (c *Client) Browse(key string) string {
+	return c.Target() + "/browse/" + key
+}
+
+// Target identifies the Jira site in run reports.
+func (c *Client) Target() string {
+	return strings.TrimSuffix(c.BaseURL, "/")
+}
+
+// NoFormat returns text as a preformatted block, shown as is rather than as
+// wiki markup.
+func NoFormat(text string) string {
+	return "{noformat}\n" + strings.ReplaceAll(text, "{noformat}", "{ noformat}") + "\n{noformat}"
+}
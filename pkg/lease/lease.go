@@ -0,0 +1,193 @@
+package lease
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mpolski/gcp-release-digest/pkg/store"
+)
+
+// Lease is a claim on a key of a state store, held by one run until it is
+// released or expires. An expired lease, left behind by a run that crashed
+// or timed out, is taken over by the next run asking for it.
+type Lease struct {
+	s   store.Store
+	key string
+	// takeover is the key claimed to take over an expired lease, if it was.
+	takeover string
+	record   record
+}
+
+// record is the content of a lease in the store.
+type record struct {
+	Holder   string    `json:"holder"`
+	Acquired time.Time `json:"acquired"`
+	Expires  time.Time `json:"expires"`
+}
+
+// same reports whether two records are the same lease.
+func (r record) same(other record) bool {
+	return r.Holder == other.Holder && r.Acquired.Equal(other.Acquired)
+}
+
+// HeldError is returned by Acquire when another holder has the lease.
+type HeldError struct {
+	Key     string
+	Holder  string
+	Expires time.Time
+}
+
+func (e *HeldError) Error() string {
+	return fmt.Sprintf("%s is held by %s until %s", e.Key, e.Holder, e.Expires.Format(time.RFC3339))
+}
+
+// Holder returns a name identifying this process as the holder of leases,
+// its host name and a random suffix.
+func Holder() string {
+	host, _ := os.Hostname()
+	b := make([]byte, 4)
+	rand.Read(b)
+	return fmt.Sprintf("%s-%s", host, hex.EncodeToString(b))
+}
+
+// Acquire claims the lease on key for holder until ttl from now. It returns a
+// *HeldError if another holder has the lease and it did not expire yet.
+func Acquire(ctx context.Context, s store.Store, key, holder string, ttl time.Duration) (*Lease, error) {
+	now := time.Now().UTC()
+	l := &Lease{s: s, key: key, record: record{Holder: holder, Acquired: now, Expires: now.Add(ttl)}}
+	data, err := json.Marshal(l.record)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Create(ctx, key, data)
+	if err == nil {
+		return l, nil
+	}
+	if !errors.Is(err, store.ErrExists) {
+		return nil, fmt.Errorf("Error claiming %s: %v", key, err)
+	}
+
+	held, err := read(ctx, s, key)
+	if errors.Is(err, store.ErrNotFound) {
+		// Released since, so claim it again.
+		return Acquire(ctx, s, key, holder, ttl)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if now.Before(held.Expires) {
+		return nil, &HeldError{Key: key, Holder: held.Holder, Expires: held.Expires}
+	}
+
+	// Of several runs finding the same expired lease, the one claiming its
+	// takeover replaces it; the others see it held.
+	l.takeover = fmt.Sprintf("%s.takeover-%d", key, held.Acquired.UnixNano())
+	err = s.Create(ctx, l.takeover, data)
+	if errors.Is(err, store.ErrExists) {
+		return nil, &HeldError{Key: key, Holder: held.Holder, Expires: held.Expires}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error claiming the takeover of %s: %v", key, err)
+	}
+	current, err := read(ctx, s, key)
+	if err != nil || !current.same(held) {
+		s.Delete(ctx, l.takeover)
+		switch {
+		case errors.Is(err, store.ErrNotFound):
+			// Released while claiming the takeover, so claim it again.
+			return Acquire(ctx, s, key, holder, ttl)
+		case err != nil:
+			return nil, err
+		}
+		// Taken over by another holder while claiming the takeover.
+		return nil, &HeldError{Key: key, Holder: current.Holder, Expires: current.Expires}
+	}
+	fmt.Printf("Taking over %s, held by %s until %s.\n", key, held.Holder, held.Expires.Format(time.RFC3339))
+	if err := s.Put(ctx, key, data); err != nil {
+		s.Delete(ctx, l.takeover)
+		return nil, fmt.Errorf("Error taking over %s: %v", key, err)
+	}
+	l.deleteStaleTakeovers(ctx)
+	return l, nil
+}
+
+// deleteStaleTakeovers deletes the takeover keys of the lease other than the
+// one of this holder. They are left behind by holders that took the lease
+// over and crashed before releasing it; none can be in use, as the lease is
+// held by this holder now.
+func (l *Lease) deleteStaleTakeovers(ctx context.Context) {
+	keys, err := l.s.List(ctx, l.key+".takeover-")
+	if err != nil {
+		fmt.Printf("Error listing takeovers of %s: %v\n", l.key, err)
+		return
+	}
+	for _, key := range keys {
+		if key != l.takeover {
+			l.s.Delete(ctx, key)
+		}
+	}
+}
+
+// Wait acquires the lease on key like Acquire, but while another holder has
+// it, tries again every interval until it gets it or patience runs out, when
+// it returns the last *HeldError.
+func Wait(ctx context.Context, s store.Store, key, holder string, ttl, patience, interval time.Duration) (*Lease, error) {
+	deadline := time.Now().Add(patience)
+	for {
+		l, err := Acquire(ctx, s, key, holder, ttl)
+		var held *HeldError
+		if !errors.As(err, &held) || time.Now().Add(interval).After(deadline) {
+			return l, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// Release gives up the lease, unless it expired and was taken over by
+// another holder since.
+func (l *Lease) Release(ctx context.Context) error {
+	if l.takeover != "" {
+		defer l.s.Delete(ctx, l.takeover)
+	}
+	current, err := read(ctx, l.s, l.key)
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if !current.same(l.record) {
+		return fmt.Errorf("%s was taken over by %s", l.key, current.Holder)
+	}
+	if err := l.s.Delete(ctx, l.key); err != nil {
+		return fmt.Errorf("Error releasing %s: %v", l.key, err)
+	}
+	return nil
+}
+
+// read returns the lease stored under key.
+func read(ctx context.Context, s store.Store, key string) (record, error) {
+	var r record
+	data, err := s.Get(ctx, key)
+	if err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			return r, err
+		}
+		return r, fmt.Errorf("Error reading %s: %v", key, err)
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, fmt.Errorf("Error decoding %s: %v", key, err)
+	}
+	return r, nil
+}
@@ -0,0 +1,108 @@
+package notify
+
+import (
+	"context"
+	"strings"
+	"unicode/utf8"
+)
+
+// Batch coalesces the summaries of several products into one webhook message,
+// reducing the number of requests counted against the webhook's rate limit.
+// Chat and Slack webhooks render the combined text like separate messages
+// with the product names as headings.
+type Batch struct {
+	webhookURL string
+	maxItems   int
+	maxChars   int
+	onSent     func(products []string, status string, err error)
+
+	// metadata describes the summaries of each message if set.
+	metadata *Metadata
+
+	products  []string
+	described []ProductMetadata
+	texts     []string
+	size      int
+	// heading starts the next message, if set.
+	heading string
+}
+
+// NewBatch returns a Batch sending up to maxItems summaries per message to
+// webhookURL, keeping each message within maxChars characters, the size limit
+// of the webhook. A maxItems of
+// one or less sends every summary on its own, and a maxChars of zero means
+// DefaultMessageMaxChars. onSent is called after each message with the
+// products it contained and the result of the send.
+func NewBatch(webhookURL string, maxItems, maxChars int, onSent func(products []string, status string, err error)) *Batch {
+	if maxItems < 1 {
+		maxItems = 1
+	}
+	if maxChars <= 0 {
+		maxChars = DefaultMessageMaxChars
+	}
+	return &Batch{webhookURL: webhookURL, maxItems: maxItems, maxChars: maxChars, onSent: onSent}
+}
+
+// SetMetadata sends the metadata of the summaries of each message along
+// with its text, m with the products of the message.
+func (b *Batch) SetMetadata(m Metadata) {
+	b.metadata = &m
+}
+
+// Add adds the summary of a product to the batch, sending the pending
+// summaries first if it would not fit, and the batch once it is full. A
+// summary too long for a message on its own is truncated with a link to
+// readMoreURL, if set, rather than being rejected by the webhook.
+func (b *Batch) Add(ctx context.Context, product, summaryResult, readMoreURL string) {
+	b.AddProduct(ctx, ProductMetadata{Product: product}, summaryResult, readMoreURL)
+}
+
+// AddProduct adds the summary of a product like Add, described by meta in
+// the metadata of its message.
+func (b *Batch) AddProduct(ctx context.Context, meta ProductMetadata, summaryResult, readMoreURL string) {
+	product := meta.Product
+	text := productText(product, summaryResult)
+	size := utf8.RuneCountInString(text)
+	if size > b.maxChars {
+		text = truncateText(text, b.maxChars, readMoreURL)
+		size = utf8.RuneCountInString(text)
+	}
+	if len(b.texts) > 0 && b.size+size > b.maxChars {
+		b.Flush(ctx)
+	}
+	b.products = append(b.products, product)
+	b.described = append(b.described, meta)
+	b.texts = append(b.texts, text)
+	b.size += size
+	if len(b.texts) >= b.maxItems {
+		b.Flush(ctx)
+	}
+}
+
+// Section starts a new section of summaries: the pending summaries are sent,
+// and the next message begins with heading.
+func (b *Batch) Section(ctx context.Context, heading string) {
+	b.Flush(ctx)
+	b.heading = heading
+	b.size = utf8.RuneCountInString(heading)
+}
+
+// Flush sends the pending summaries, if any.
+func (b *Batch) Flush(ctx context.Context) {
+	if len(b.texts) == 0 {
+		return
+	}
+	webhookRateLimiter.acquire()
+	text := b.heading + strings.Join(b.texts, "")
+	msgStr := textPayload(text)
+	if b.metadata != nil {
+		m := *b.metadata
+		m.Products = b.described
+		msgStr = metadataPayload(text, m)
+	}
+	status, err := SendMessage(ctx, b.webhookURL, msgStr)
+	if b.onSent != nil {
+		b.onSent(b.products, status, err)
+	}
+	b.products, b.described, b.texts, b.size, b.heading = nil, nil, nil, 0, ""
+}
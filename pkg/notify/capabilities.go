@@ -0,0 +1,73 @@
+package notify
+
+// Dialect is the markup a target renders messages in.
+type Dialect int
+
+const (
+	// DialectChat is the markup of Google Chat and Slack messages, with
+	// *bold*, _italic_ and <url|text> links. Messages are written in it.
+	DialectChat Dialect = iota
+	// DialectMarkdown is common Markdown, with **bold**, *italic* and
+	// [text](url) links.
+	DialectMarkdown
+	// DialectHTML is HTML, with lists as <ul> elements.
+	DialectHTML
+	// DialectPlain is text without markup, on a single line.
+	DialectPlain
+	// DialectTelegram is Telegram's MarkdownV2, with *bold*, _italic_ and
+	// [text](url) links, and reserved characters escaped elsewhere.
+	DialectTelegram
+)
+
+// Capabilities describes what a delivery target can show, so messages are
+// rendered for it in one place instead of by each target.
+type Capabilities struct {
+	// Dialect is the markup the target renders.
+	Dialect Dialect
+	// MaxChars is the largest message the target accepts, in characters.
+	MaxChars int
+	// Threads reports whether the target groups messages in threads or
+	// topics.
+	Threads bool
+	// Cards reports whether the target shows structured cards or
+	// attachments.
+	Cards bool
+	// Buttons reports whether the target shows link buttons.
+	Buttons bool
+	// Metadata reports whether the target needs the metadata of messages,
+	// whatever the channel's PAYLOAD_METADATA.
+	Metadata bool
+}
+
+// Capabilities of the targets other than channels, which do not have URLs.
+var (
+	// EmailCapabilities are those of HTML email.
+	EmailCapabilities = Capabilities{Dialect: DialectHTML, Buttons: true}
+	// SMSCapabilities are those of a single SMS segment.
+	SMSCapabilities = Capabilities{Dialect: DialectPlain, MaxChars: 160}
+)
+
+// chatCapabilities are those of Google Chat, within its limit of 4096
+// characters per message.
+var chatCapabilities = Capabilities{Dialect: DialectChat, MaxChars: DefaultMessageMaxChars, Threads: true, Cards: true, Buttons: true}
+
+// TargetCapabilities returns the capabilities of the target of a channel's
+// webhook URL, as its backend was registered.
+func TargetCapabilities(webhookURL string) Capabilities {
+	return backendFor(webhookURL).caps
+}
+
+// Render converts a message written in chat markup to the dialect d.
+func Render(text string, d Dialect) string {
+	switch d {
+	case DialectMarkdown:
+		return Markdown(text)
+	case DialectHTML:
+		return HTML(text)
+	case DialectPlain:
+		return PlainText(text)
+	case DialectTelegram:
+		return TelegramMarkdown(text)
+	}
+	return text
+}
@@ -0,0 +1,74 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mpolski/gcp-release-digest/pkg/i18n"
+)
+
+// splitText splits text into chunks of at most maxChars characters. It breaks
+// between lines where possible, and within an overlong line after the last
+// ", " or space that fits, so words and list items are not cut in half.
+func splitText(text string, maxChars int) []string {
+	if maxChars <= 0 || utf8.RuneCountInString(text) <= maxChars {
+		return []string{text}
+	}
+
+	var chunks []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			chunks = append(chunks, strings.TrimRight(current.String(), "\n"))
+			current.Reset()
+		}
+	}
+
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if utf8.RuneCountInString(current.String())+utf8.RuneCountInString(line) <= maxChars {
+			current.WriteString(line)
+			continue
+		}
+		flush()
+		for utf8.RuneCountInString(line) > maxChars {
+			head, tail := splitLine(line, maxChars)
+			chunks = append(chunks, head)
+			line = tail
+		}
+		current.WriteString(line)
+	}
+	flush()
+	return chunks
+}
+
+// splitLine cuts line into a head of at most maxChars characters and the
+// remaining tail, preferring to cut after a list separator or a space.
+func splitLine(line string, maxChars int) (head, tail string) {
+	runes := []rune(line)
+	limit := string(runes[:maxChars])
+	for _, sep := range []string{", ", " "} {
+		if i := strings.LastIndex(limit, sep); i > 0 {
+			return line[:i+len(sep)-1], line[i+len(sep):]
+		}
+	}
+	return limit, string(runes[maxChars:])
+}
+
+// truncateText shortens text to at most maxChars characters, cutting between
+// lines or words and ending it with an ellipsis and, if readMoreURL is set, a
+// link to the full text.
+func truncateText(text string, maxChars int, readMoreURL string) string {
+	if maxChars <= 0 || utf8.RuneCountInString(text) <= maxChars {
+		return text
+	}
+	suffix := "…"
+	if readMoreURL != "" {
+		suffix += fmt.Sprintf("\n<%s|%s>", readMoreURL, i18n.M().ReadMore)
+	}
+	room := maxChars - utf8.RuneCountInString(suffix)
+	if room <= 0 {
+		return suffix
+	}
+	return strings.TrimRight(splitText(text, room)[0], " ,") + suffix
+}
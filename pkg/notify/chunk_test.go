@@ -0,0 +1,30 @@
+package notify
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// largeDigest returns the text of a digest with many products, each with a
+// summary of list items and a few overlong lines, as in large runs.
+func largeDigest(products int) string {
+	var b strings.Builder
+	for i := 0; i < products; i++ {
+		fmt.Fprintf(&b, "*Product %d:*\n\n", i)
+		for j := 0; j < 5; j++ {
+			fmt.Fprintf(&b, "• Release note %d of product %d, with a description of a few words.\n", j, i)
+		}
+		b.WriteString(strings.Repeat("A long line without breaks, as written by some models, ", 20) + "\n\n")
+	}
+	return b.String()
+}
+
+func BenchmarkSplitText(b *testing.B) {
+	text := largeDigest(200)
+	b.SetBytes(int64(len(text)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		splitText(text, DefaultMessageMaxChars)
+	}
+}
@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Defaults of the HTTP client shared by all webhook sends.
+const (
+	DefaultTimeout      = 30 * time.Second
+	DefaultMaxIdleConns = 10
+	DefaultKeepAlive    = 30 * time.Second
+)
+
+// ClientOptions configures the HTTP client shared by all webhook sends. Zero
+// values mean the defaults.
+type ClientOptions struct {
+	// Timeout limits a whole request, including reading the response, so a
+	// hanging webhook cannot stall the run.
+	Timeout time.Duration
+	// MaxIdleConns is the number of idle connections kept per host.
+	MaxIdleConns int
+	// KeepAlive is the interval of TCP keep-alive probes on open connections.
+	KeepAlive time.Duration
+	// Proxy is the URL of the proxy requests go through. If nil, the proxy is
+	// taken from the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment
+	// variables.
+	Proxy *url.URL
+	// TLS configures the CA certificates trusted and the client certificate
+	// presented, see TLSConfig. If nil, the system roots are trusted.
+	TLS *tls.Config
+}
+
+// NewClient returns an HTTP client configured by opts.
+func NewClient(opts ClientOptions) *http.Client {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	if opts.MaxIdleConns <= 0 {
+		opts.MaxIdleConns = DefaultMaxIdleConns
+	}
+	if opts.KeepAlive <= 0 {
+		opts.KeepAlive = DefaultKeepAlive
+	}
+	proxy := http.ProxyFromEnvironment
+	if opts.Proxy != nil {
+		proxy = http.ProxyURL(opts.Proxy)
+	}
+	dialer := &net.Dialer{Timeout: opts.Timeout, KeepAlive: opts.KeepAlive}
+	return &http.Client{
+		Timeout: opts.Timeout,
+		Transport: &http.Transport{
+			Proxy:               proxy,
+			TLSClientConfig:     opts.TLS,
+			DialContext:         dialer.DialContext,
+			MaxIdleConns:        opts.MaxIdleConns,
+			MaxIdleConnsPerHost: opts.MaxIdleConns,
+			IdleConnTimeout:     90 * time.Second,
+			TLSHandshakeTimeout: 10 * time.Second,
+			ForceAttemptHTTP2:   true,
+		},
+	}
+}
+
+// ParseProxy parses the URL of a proxy, such as "http://proxy.example.com:3128".
+func ParseProxy(proxy string) (*url.URL, error) {
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy URL %q", proxy)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+		return u, nil
+	}
+	return nil, fmt.Errorf("invalid proxy URL %q: scheme must be http, https or socks5", proxy)
+}
+
+var (
+	clientMu      sync.Mutex
+	client        = NewClient(ClientOptions{})
+	webhookClient = map[string]*http.Client{}
+)
+
+// SetClientOptions replaces the shared HTTP client with one configured by
+// opts, and drops the clients of webhooks set with SetWebhookClient.
+func SetClientOptions(opts ClientOptions) {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+	client.CloseIdleConnections()
+	for _, c := range webhookClient {
+		c.CloseIdleConnections()
+	}
+	client = NewClient(opts)
+	webhookClient = map[string]*http.Client{}
+}
+
+// SetWebhookClient makes requests to webhookURL use a client configured by
+// opts instead of the shared client, e.g. to go through a different proxy or
+// present a client certificate.
+func SetWebhookClient(webhookURL string, opts ClientOptions) {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+	webhookClient[webhookURL] = NewClient(opts)
+}
+
+// Client returns the HTTP client used for requests to webhookURL: the shared
+// client unless one was set for the webhook with SetWebhookClient.
+func Client(webhookURL string) *http.Client {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+	if c, ok := webhookClient[webhookURL]; ok {
+		return c
+	}
+	return client
+}
@@ -0,0 +1,140 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// Discord webhooks, recognized by their https://discord.com/api/webhooks/
+// URLs or selected by prefixing any other URL with "discord+", get each
+// product's summary as an embed titled with the product. Discord limits the
+// text of a message to 2000 characters and its embeds to 6000, so longer
+// messages are sent as several, in order.
+
+const discordPrefix = "discord+"
+
+// discordWebhookPrefixes start the URLs of Discord webhooks.
+var discordWebhookPrefixes = []string{"https://discord.com/api/webhooks/", "https://discordapp.com/api/webhooks/"}
+
+const (
+	// discordMaxContent is the longest text of a message.
+	discordMaxContent = 2000
+	// discordMaxTitle is the longest title of an embed.
+	discordMaxTitle = 256
+	// discordMaxDescription is the longest description of an embed.
+	discordMaxDescription = 4096
+	// discordMaxEmbeds is the largest number of embeds of a message.
+	discordMaxEmbeds = 10
+	// discordMaxEmbedChars is the largest number of characters of all the
+	// embeds of a message.
+	discordMaxEmbedChars = 6000
+	// discordColor is the color bar of the summary embeds.
+	discordColor = 0x1a73e8
+)
+
+func init() {
+	Register(isDiscord, Capabilities{Dialect: DialectMarkdown, MaxChars: discordMaxContent, Cards: true}, discordNotifier{})
+}
+
+func isDiscord(webhookURL string) bool {
+	if strings.HasPrefix(webhookURL, discordPrefix) {
+		return true
+	}
+	for _, prefix := range discordWebhookPrefixes {
+		if strings.HasPrefix(webhookURL, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// discordNotifier sends messages to Discord webhooks.
+type discordNotifier struct{ webhook }
+
+// Send converts a JSON text message payload to Discord messages and posts
+// them one after another, stopping at the first one not accepted.
+func (discordNotifier) Send(ctx context.Context, webhookURL, payload string, header http.Header) (status string, err error) {
+	text, err := payloadText(payload)
+	if err != nil {
+		return "", fmt.Errorf("Error reading message for Discord: %v", err)
+	}
+	messages, err := discordPayloads(text)
+	if err != nil {
+		return "", err
+	}
+	endpoint := strings.TrimPrefix(webhookURL, discordPrefix)
+	for _, m := range messages {
+		status, err = postJSON(ctx, webhookURL, endpoint, m, header)
+		if err != nil || !strings.HasPrefix(status, "2") {
+			return status, err
+		}
+	}
+	return status, nil
+}
+
+// discordEmbed is a card with a title and a description.
+type discordEmbed struct {
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description"`
+	Color       int    `json:"color"`
+}
+
+// discordMessage is the payload of a Discord webhook.
+type discordMessage struct {
+	Content string         `json:"content,omitempty"`
+	Embeds  []discordEmbed `json:"embeds,omitempty"`
+}
+
+// discordPayloads returns the payloads of the messages a text in chat markup
+// is sent as: the text before the first product as content, in chunks of
+// the longest content, and the summaries of the products as embeds, as many
+// per message as fit.
+func discordPayloads(text string) ([]string, error) {
+	var messages []discordMessage
+	var current discordMessage
+	embedChars := 0
+	flush := func() {
+		if current.Content != "" || len(current.Embeds) > 0 {
+			messages = append(messages, current)
+		}
+		current, embedChars = discordMessage{}, 0
+	}
+
+	for _, s := range productSections(text) {
+		body := Render(s.Text, DialectMarkdown)
+		if s.Product == "" {
+			for _, chunk := range splitText(body, discordMaxContent) {
+				flush()
+				current.Content = chunk
+			}
+			continue
+		}
+		title := Shorten(s.Product, discordMaxTitle)
+		for _, chunk := range splitText(body, discordMaxDescription) {
+			e := discordEmbed{Title: title, Description: chunk, Color: discordColor}
+			chars := utf8.RuneCountInString(e.Title) + utf8.RuneCountInString(e.Description)
+			if len(current.Embeds) == discordMaxEmbeds || embedChars+chars > discordMaxEmbedChars {
+				flush()
+			}
+			current.Embeds = append(current.Embeds, e)
+			embedChars += chars
+			// Later parts of a long summary continue the embed above.
+			title = ""
+		}
+	}
+	flush()
+
+	payloads := make([]string, len(messages))
+	for i, m := range messages {
+		data, err := json.Marshal(m)
+		if err != nil {
+			return nil, err
+		}
+		payloads[i] = string(data)
+	}
+	return payloads, nil
+}
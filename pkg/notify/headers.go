@@ -0,0 +1,128 @@
+package notify
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// DefaultSignatureHeader is the header carrying the payload signature.
+const DefaultSignatureHeader = "X-Digest-Signature"
+
+// Signing configures HMAC-SHA256 signing of webhook payloads, so receivers can
+// verify a message was sent by the digest. The signature is sent as
+// "sha256=<hex digest of the payload>" in Header.
+type Signing struct {
+	Secret string
+	// Header is the name of the signature header. Empty means
+	// DefaultSignatureHeader.
+	Header string
+}
+
+var signing = map[string]Signing{}
+
+// ResetSigning forgets the signing of all webhooks, so a run sets it anew
+// and a secret removed from the configuration is no longer used.
+func ResetSigning() {
+	holdersMu.Lock()
+	defer holdersMu.Unlock()
+	signing = map[string]Signing{}
+}
+
+// SetSigning signs the payloads sent to webhookURL with s. An empty
+// webhookURL sets the signing of all webhooks without their own, and an
+// empty secret disables signing.
+func SetSigning(webhookURL string, s Signing) {
+	holdersMu.Lock()
+	defer holdersMu.Unlock()
+	signing[webhookURL] = s
+}
+
+func signingFor(webhookURL string) Signing {
+	holdersMu.Lock()
+	defer holdersMu.Unlock()
+	if s, ok := signing[webhookURL]; ok {
+		return s
+	}
+	return signing[""]
+}
+
+// Sign returns the signature of payload with secret, as sent in the signature
+// header.
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+var extraHeaders = map[string]http.Header{}
+
+// ParseHeaders parses extra request headers given as "Name: value" pairs
+// separated by semicolons or newlines, e.g.
+// "Authorization: Bearer abc; X-Routing-Key: releases".
+func ParseHeaders(spec string) (http.Header, error) {
+	header := http.Header{}
+	for _, field := range strings.FieldsFunc(spec, func(r rune) bool { return r == ';' || r == '\n' }) {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(field, ":")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !ok || name == "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid header %q, expected \"Name: value\"", field)
+		}
+		header.Add(name, value)
+	}
+	return header, nil
+}
+
+// ResetHeaders forgets the extra headers of all webhooks, so a run sets them
+// anew and a header removed from the configuration is no longer sent.
+func ResetHeaders() {
+	holdersMu.Lock()
+	defer holdersMu.Unlock()
+	extraHeaders = map[string]http.Header{}
+}
+
+// SetHeaders adds header to the requests sent to webhookURL. An empty
+// webhookURL sets headers added to the requests of all webhooks; headers set
+// for a webhook replace those of the same name.
+func SetHeaders(webhookURL string, header http.Header) {
+	holdersMu.Lock()
+	defer holdersMu.Unlock()
+	extraHeaders[webhookURL] = header
+}
+
+// requestHeader returns the headers of a request to webhookURL. The
+// signature is added when the request is sent, over the body in the format
+// of the target.
+func requestHeader(webhookURL string) http.Header {
+	header := http.Header{}
+	header.Set("Content-Type", "application/json; charset=UTF-8")
+	holdersMu.Lock()
+	for _, extra := range []http.Header{extraHeaders[""], extraHeaders[webhookURL]} {
+		for name, values := range extra {
+			header[http.CanonicalHeaderKey(name)] = values
+		}
+	}
+	holdersMu.Unlock()
+	return header
+}
+
+// sign sets the signature of body, as sent to webhookURL, in header if the
+// webhook has a signing secret.
+func sign(header http.Header, webhookURL string, body []byte) {
+	s := signingFor(webhookURL)
+	if s.Secret == "" {
+		return
+	}
+	name := s.Header
+	if name == "" {
+		name = DefaultSignatureHeader
+	}
+	header.Set(name, Sign(s.Secret, body))
+}
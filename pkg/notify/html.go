@@ -0,0 +1,50 @@
+package notify
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	escapedLink = regexp.MustCompile(`&lt;(https?://[^|\s]+?)\|([^&]+?)&gt;`)
+	chatBold    = regexp.MustCompile(`\*([^*\n]+)\*`)
+	chatItalic  = regexp.MustCompile(`(^|\s)_([^_\n]+)_`)
+)
+
+// HTML converts a message written in chat markup, with *bold*, _italic_,
+// <url|text> links and lines starting with "* " or "- " as list items, to
+// HTML for targets rendering it, such as email and Matrix.
+func HTML(text string) string {
+	var b strings.Builder
+	inList := false
+	for _, line := range strings.Split(strings.TrimSpace(text), "\n") {
+		line = strings.TrimSpace(line)
+		item := strings.HasPrefix(line, "* ") || strings.HasPrefix(line, "- ")
+		if item && !inList {
+			b.WriteString("<ul>\n")
+		} else if !item && inList {
+			b.WriteString("</ul>\n")
+		}
+		inList = item
+		switch {
+		case item:
+			b.WriteString("<li>" + inline(line[2:]) + "</li>\n")
+		case line != "":
+			b.WriteString("<p>" + inline(line) + "</p>\n")
+		}
+	}
+	if inList {
+		b.WriteString("</ul>\n")
+	}
+	return b.String()
+}
+
+// inline escapes a line and converts its links and emphasis.
+func inline(line string) string {
+	s := html.EscapeString(line)
+	s = escapedLink.ReplaceAllString(s, `<a href="$1">$2</a>`)
+	s = chatBold.ReplaceAllString(s, "<strong>$1</strong>")
+	s = chatItalic.ReplaceAllString(s, "$1<em>$2</em>")
+	return s
+}
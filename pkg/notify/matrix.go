@@ -0,0 +1,104 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Matrix rooms are addressed like webhooks, with URLs of the form
+//
+//	matrix://homeserver.example.com/!roomid:example.com
+//
+// The access token of the sending user is taken from the user part of the
+// URL, as in matrix://<token>@homeserver.example.com/!roomid:example.com, or
+// else from SetMatrixToken. Messages are sent as m.room.message events with
+// an HTML body, so Element and other clients show the formatting.
+
+var (
+	matrixMu    sync.Mutex
+	matrixToken string
+	matrixTxn   atomic.Int64
+)
+
+// SetMatrixToken sets the access token used for Matrix rooms without a token
+// in their URL.
+func SetMatrixToken(token string) {
+	matrixMu.Lock()
+	defer matrixMu.Unlock()
+	matrixToken = token
+}
+
+func init() {
+	Register(isMatrix, Capabilities{Dialect: DialectHTML, MaxChars: 30000, Threads: true}, matrixNotifier{})
+}
+
+// matrixNotifier sends messages to Matrix rooms.
+type matrixNotifier struct{ webhook }
+
+func (matrixNotifier) Send(ctx context.Context, roomURL, payload string, _ http.Header) (status string, err error) {
+	return postMatrix(ctx, roomURL, payload)
+}
+
+func isMatrix(webhookURL string) bool {
+	return strings.HasPrefix(webhookURL, "matrix://")
+}
+
+// postMatrix sends the text of a JSON text message payload to a Matrix room.
+func postMatrix(ctx context.Context, roomURL, payload string) (status string, err error) {
+	u, err := url.Parse(roomURL)
+	if err != nil || u.Host == "" || !strings.HasPrefix(u.Path, "/!") {
+		return "", fmt.Errorf("invalid Matrix room URL, expected matrix://homeserver/!roomid:server")
+	}
+	roomID := strings.TrimPrefix(u.Path, "/")
+	token := u.User.Username()
+	if token == "" {
+		matrixMu.Lock()
+		token = matrixToken
+		matrixMu.Unlock()
+	}
+	if token == "" {
+		return "", fmt.Errorf("no Matrix access token for room %s", roomID)
+	}
+
+	text, err := payloadText(payload)
+	if err != nil {
+		return "", fmt.Errorf("Error reading message for Matrix: %v", err)
+	}
+	body, err := json.Marshal(map[string]string{
+		"msgtype":        "m.text",
+		"body":           text,
+		"format":         "org.matrix.custom.html",
+		"formatted_body": Render(text, TargetCapabilities(roomURL).Dialect),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	// The transaction ID makes the homeserver ignore repeated requests of
+	// the same event.
+	txnID := fmt.Sprintf("digest-%d-%d", time.Now().UnixNano(), matrixTxn.Add(1))
+	endpoint := fmt.Sprintf("https://%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s", u.Host, url.PathEscape(roomID), txnID)
+	req, err := http.NewRequestWithContext(ctx, "PUT", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := Client(roomURL).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp.Status, nil
+}
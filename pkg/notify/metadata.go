@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// Metadata describes the products of a message in machine-readable form,
+// sent alongside its text to targets accepting extra fields so receivers
+// can route or automate on it without parsing the summaries.
+type Metadata struct {
+	// Run identifies the digest run, e.g. "digest-42".
+	Run      string            `json:"run"`
+	Channel  string            `json:"channel"`
+	Products []ProductMetadata `json:"products"`
+	// From and To are the first and last day, as YYYY-MM-DD, of the window
+	// the release notes were published in.
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+}
+
+// ProductMetadata describes the summary of one product.
+type ProductMetadata struct {
+	Product string `json:"product"`
+	// Types are the release note types summarized.
+	Types []string `json:"types"`
+	// Notes is the number of release notes summarized.
+	Notes int `json:"notes"`
+	// Severity is that of the most severe type, e.g. "critical".
+	Severity string `json:"severity"`
+}
+
+// metadataPayload returns the JSON payload of a text message with its
+// metadata in a "metadata" field.
+func metadataPayload(text string, m Metadata) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(struct {
+		Text     string   `json:"text"`
+		Metadata Metadata `json:"metadata"`
+	}{text, m})
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// payloadMetadata returns the metadata of a JSON message payload, empty if
+// it has none.
+func payloadMetadata(payload string) Metadata {
+	var msg struct {
+		Metadata Metadata `json:"metadata"`
+	}
+	json.Unmarshal([]byte(payload), &msg)
+	return msg.Metadata
+}
@@ -0,0 +1,136 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Notifier delivers messages to one kind of target. Messages are written as
+// JSON payloads of Google Chat messages in chat markup, which a Notifier
+// converts to the format of its target, so new targets are added by
+// registering a Notifier rather than by changing the callers.
+type Notifier interface {
+	// Announce sends the announcement opening a digest, a text in chat
+	// markup that fits the target's size limit.
+	Announce(ctx context.Context, webhookURL, text string) (status string, err error)
+	// Send posts a message payload to the target once, with the request
+	// headers configured for webhookURL.
+	Send(ctx context.Context, webhookURL, payload string, header http.Header) (status string, err error)
+	// Close releases what the Notifier keeps between messages once a run is
+	// over.
+	Close() error
+}
+
+// backend is a registered kind of target.
+type backend struct {
+	match    func(webhookURL string) bool
+	caps     Capabilities
+	notifier Notifier
+}
+
+var (
+	backendsMu sync.Mutex
+	backends   []backend
+)
+
+// chatBackend handles the URLs no registered backend recognizes, taken to
+// be Google Chat or Slack webhooks.
+var chatBackend = backend{caps: chatCapabilities, notifier: webhook{}}
+
+// Register adds a kind of target, recognized by match from the URLs it
+// handles, with its capabilities and the Notifier delivering to it. A URL
+// goes to the first registered backend recognizing it.
+func Register(match func(webhookURL string) bool, caps Capabilities, n Notifier) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	backends = append(backends, backend{match: match, caps: caps, notifier: n})
+}
+
+func backendFor(webhookURL string) backend {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	for _, b := range backends {
+		if b.match(webhookURL) {
+			return b
+		}
+	}
+	return chatBackend
+}
+
+// NotifierFor returns the Notifier delivering to a webhook URL.
+func NotifierFor(webhookURL string) Notifier {
+	return backendFor(webhookURL).notifier
+}
+
+// Close closes the Notifiers of all targets at the end of a run. It returns
+// the first error.
+func Close() error {
+	backendsMu.Lock()
+	notifiers := []Notifier{chatBackend.notifier}
+	for _, b := range backends {
+		notifiers = append(notifiers, b.notifier)
+	}
+	backendsMu.Unlock()
+
+	var first error
+	for _, n := range notifiers {
+		if err := n.Close(); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// webhook is the Notifier of Google Chat and Slack webhooks, which take the
+// payloads as they are written. The Notifiers of other targets embed it for
+// its Announce and Close.
+type webhook struct{}
+
+// Announce sends the announcement as a text message, held or retried like
+// any other message.
+func (webhook) Announce(ctx context.Context, webhookURL, text string) (status string, err error) {
+	return SendMessage(ctx, webhookURL, textPayload(text))
+}
+
+func (webhook) Send(ctx context.Context, webhookURL, payload string, header http.Header) (status string, err error) {
+	// Google Chat webhooks post to the thread of the context, if any.
+	endpoint, err := threadEndpoint(webhookURL, threadOf(ctx))
+	if err != nil {
+		return "", err
+	}
+	return postJSON(ctx, webhookURL, endpoint, payload, header)
+}
+
+func (webhook) Close() error {
+	return nil
+}
+
+// postJSON posts a payload to endpoint with the HTTP client and signing of
+// webhookURL and returns the status of the response.
+func postJSON(ctx context.Context, webhookURL, endpoint, payload string, header http.Header) (status string, err error) {
+	// Create a new HTTP POST request with the message body.
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewBufferString(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header = header.Clone()
+	// The signature covers the payload as sent, which Notifiers may have
+	// converted to the format of their target.
+	sign(req.Header, webhookURL, []byte(payload))
+
+	// Send the request with the shared HTTP client.
+	resp, err := Client(webhookURL).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	// Drain the body so the connection can be reused.
+	io.Copy(io.Discard, resp.Body)
+
+	// Return the status code of the response.
+	return resp.Status, nil
+}
@@ -5,10 +5,13 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/mpolski/gcp-release-digest/pkg/i18n"
 	"github.com/mpolski/gcp-release-digest/pkg/products"
+	"github.com/mpolski/gcp-release-digest/pkg/releasenotes"
 )
 
 // Introduce rate limiting for Google Chat Space (limit is to 60 writes per minute to a chat space)
@@ -46,12 +49,47 @@ func (rl *rateLimiter) acquire() {
 // Set to 45 messages per minute to allow for others
 var webhookRateLimiter = newRateLimiter(50, time.Minute)
 
+// Default limits for rendering the product list of the announce message.
+const (
+	// DefaultGroupThreshold is the number of products from which the list is
+	// grouped by category.
+	DefaultGroupThreshold = 20
+	// DefaultAnnounceMaxChars is the maximum size of a single announce message.
+	DefaultAnnounceMaxChars = 4000
+	// DefaultMessageMaxChars is the maximum size of a summary message, within
+	// the 4096 character limit of Google Chat messages.
+	DefaultMessageMaxChars = 4000
+)
+
+// AnnounceOptions controls how the product list of the announce message is
+// rendered.
+type AnnounceOptions struct {
+	// GroupThreshold is the number of products from which the list is grouped
+	// by category, one line per category. Zero means DefaultGroupThreshold.
+	GroupThreshold int
+	// MaxChars is the maximum size of a single message; longer lists are split
+	// across several messages. Zero means DefaultAnnounceMaxChars.
+	MaxChars int
+	// Number is the sequential number of the digest, shown in the header if
+	// set.
+	Number int
+	// Permalink is the URL of the archived digest, linked if set.
+	Permalink string
+	// Urgent heads the announcement with the urgent label.
+	Urgent bool
+	// Unusual are the products flagged for unusually high activity below
+	// the list.
+	Unusual []string
+}
+
 // Announce sends a notification message to the webhook URL, announcing the
 // products with new release notes published within the specified cadence.
 //
 // It calculates the date based on the cadence and formats a message
-// containing the list of products and a count of their number.
-func Announce(ctx context.Context, webhookURL string, cadenceInt int, products []products.Product) (status string, err error) {
+// containing the list of products, a count of their number and a breakdown of
+// the release notes by type. Long product lists are grouped by category and
+// split across several messages.
+func Announce(ctx context.Context, webhookURL string, cadenceInt int, products []products.Product, counts []products.TypeCount, opts AnnounceOptions) (status string, err error) {
 
 	// Calculate the date of today minus the number of days specified by cadenceInt.
 	date := time.Now().AddDate(0, 0, -cadenceInt)
@@ -63,19 +101,126 @@ func Announce(ctx context.Context, webhookURL string, cadenceInt int, products [
 	// If there are products with release notes, format a message with the list
 	// and count.
 	if count > 0 {
-		var productList string
-		for _, product := range products {
-			productList += fmt.Sprintf("* *%s*\n", product.Product)
+		msgText.WriteString(digestHeader(opts))
+		m := i18n.M()
+		msgText.WriteString(fmt.Sprintf("*"+m.Found+"*\n%s%s\n%s\n*%s*",
+			count, dateStr, typeBreakdown(counts, count), productList(products, opts), unusualList(opts.Unusual), m.HereItIs))
+	}
+
+	// Split the message if the list does not fit in a single one.
+	maxChars := opts.MaxChars
+	if maxChars <= 0 {
+		maxChars = DefaultAnnounceMaxChars
+	}
+	if limit := TargetCapabilities(webhookURL).MaxChars; limit < maxChars {
+		maxChars = limit
+	}
+	for i, chunk := range splitText(msgText.String(), maxChars) {
+		if i > 0 {
+			chunk = "*" + i18n.M().Continued + "*\n" + chunk
 		}
 
-		msgText.WriteString(fmt.Sprintf("*Found release notes for %d products since %s*\n%s\n\n*And here it is...*",
-			count, dateStr, productList))
+		// Send the formatted message through the webhook's Notifier, keeping
+		// the first unsuccessful status so a partial delivery is not masked.
+		chunkStatus, err := NotifierFor(webhookURL).Announce(ctx, webhookURL, chunk)
+		if err != nil {
+			return chunkStatus, err
+		}
+		if status == "" || strings.HasPrefix(status, "2") {
+			status = chunkStatus
+		}
 	}
+	return status, nil
+}
 
-	msgStr := fmt.Sprintf(`{"text": "%s"}`, msgText.String())
+// NoNews tells the webhook URL that no products had release notes published
+// within the specified cadence, under the same header as an announcement, so
+// readers know the digest ran.
+func NoNews(ctx context.Context, webhookURL string, cadenceInt int, opts AnnounceOptions) (status string, err error) {
+	return SendText(ctx, webhookURL, digestHeader(opts)+fmt.Sprintf(i18n.M().NoNews, cadenceInt))
+}
 
-	// Send the formatted message to the webhook.
-	return SendMessage(ctx, webhookURL, msgStr)
+// digestHeader renders the digest number and a link to the archived digest,
+// or nothing if neither is known.
+func digestHeader(opts AnnounceOptions) string {
+	m := i18n.M()
+	var header string
+	if opts.Urgent {
+		header += fmt.Sprintf("*%s*\n", m.Urgent)
+	}
+	if opts.Number > 0 {
+		header += fmt.Sprintf("*%s #%d*\n", m.Title, opts.Number)
+	}
+	if opts.Permalink != "" {
+		header += fmt.Sprintf("<%s|%s>\n", opts.Permalink, m.ReadArchive)
+	}
+	if header != "" {
+		header += "\n"
+	}
+	return header
+}
+
+// unusualList renders a line flagging each product with unusually high
+// activity, after an empty line, or nothing without any.
+func unusualList(unusual []string) string {
+	var b strings.Builder
+	for _, product := range unusual {
+		b.WriteString("\n⚠️ " + fmt.Sprintf(i18n.M().Unusual, product))
+	}
+	if b.Len() > 0 {
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// typeBreakdown renders a line like "12 features, 3 breaking changes and
+// 2 security bulletins across 9 products", or nothing without counts.
+func typeBreakdown(counts []products.TypeCount, productCount int) string {
+	var items []string
+	for _, c := range counts {
+		if c.Count > 0 {
+			items = append(items, fmt.Sprintf("%d %s", c.Count, releasenotes.TypeLabel(c.ReleaseNoteType, c.Count)))
+		}
+	}
+	if len(items) == 0 {
+		return ""
+	}
+	m := i18n.M()
+	list := items[0]
+	if len(items) > 1 {
+		list = strings.Join(items[:len(items)-1], ", ") + " " + m.And + " " + items[len(items)-1]
+	}
+	noun := m.Products
+	if productCount == 1 {
+		noun = m.Product
+	}
+	return "_" + fmt.Sprintf(m.Across, list, productCount, noun) + "_\n\n"
+}
+
+// productList renders one line per product, or one line per category listing
+// its products once there are at least opts.GroupThreshold products.
+func productList(list []products.Product, opts AnnounceOptions) string {
+	threshold := opts.GroupThreshold
+	if threshold <= 0 {
+		threshold = DefaultGroupThreshold
+	}
+
+	var b strings.Builder
+	if len(list) < threshold {
+		for _, product := range list {
+			b.WriteString(fmt.Sprintf("* *%s*\n", product.Product))
+		}
+		return b.String()
+	}
+
+	for _, group := range products.GroupByCategory(list) {
+		names := make([]string, 0, len(group.Products))
+		for _, product := range group.Products {
+			names = append(names, product.Product)
+		}
+		b.WriteString(fmt.Sprintf("*%s* (%d): %s\n", group.Category, len(names), strings.Join(names, ", ")))
+	}
+	return b.String()
 }
 
 // SendToWebhook sends a summary of release notes for a given product to the
@@ -85,51 +230,163 @@ func SendToWebhook(ctx context.Context, product, summaryResult, webhookURL strin
 	webhookRateLimiter.acquire() // Acquire a token or wait until one is available
 
 	// Format the message string for sending to the webhook.
-	msgStr := fmt.Sprintf(`{"text": "*%s:*\n\n%s`+"\n\n"+`"}`, product, summaryResult)
+	msgStr := textPayload(productText(product, summaryResult))
 
 	// Send the formatted message to the webhook.
 	return SendMessage(ctx, webhookURL, msgStr)
 }
 
+// SendText sends a text message in chat markup to the webhook URL.
+func SendText(ctx context.Context, webhookURL, text string) (status string, err error) {
+	webhookRateLimiter.acquire()
+	return SendMessage(ctx, webhookURL, textPayload(text))
+}
+
+// SendPayload sends a message payload rendered by the caller, such as a
+// message of Google Chat cards, to the webhook URL.
+func SendPayload(ctx context.Context, webhookURL, payload string) (status string, err error) {
+	webhookRateLimiter.acquire()
+	return SendMessage(ctx, webhookURL, payload)
+}
+
+// productText renders the summary of a product under its name.
+func productText(product, summaryResult string) string {
+	return fmt.Sprintf("*%s:*\n\n%s\n\n", product, summaryResult)
+}
+
 // ClosingMessage sends a closing message to the webhook URL, indicating that
 // all summaries have been published.
 // It formats a message with the provided closing message text.
 func ClosingMessage(ctx context.Context, webhookURL, anyMsg string) (status string, err error) {
 
 	// Format the message string for sending to the webhook.
-	msgStr := fmt.Sprintf(`{ "text": "*%s*"}`, anyMsg)
+	msgStr := textPayload("*" + anyMsg + "*")
 
 	// Send the formatted message to the webhook.
 	return SendMessage(ctx, webhookURL, msgStr)
 }
 
-// SendMessage sends a message to the specified webhook URL.
-// It formats the message as JSON and sends it using an HTTP POST request.
-func SendMessage(ctx context.Context, webhookURL, msgStr string) (status string, err error) {
+// Holder keeps messages for a webhook that is outside its delivery window.
+type Holder interface {
+	Hold(ctx context.Context, webhookURL, payload string) error
+}
+
+var (
+	holdersMu sync.Mutex
+	holders   = map[string]Holder{}
+)
 
-	// Convert the message string to JSON bytes.
-	var jsonStr = []byte(msgStr)
+// Hold makes SendMessage pass every message for webhookURL to h instead of
+// sending it, until Release is called for the same webhook.
+func Hold(webhookURL string, h Holder) {
+	holdersMu.Lock()
+	defer holdersMu.Unlock()
+	holders[webhookURL] = h
+}
+
+// Release resumes sending messages to webhookURL.
+func Release(webhookURL string) {
+	holdersMu.Lock()
+	defer holdersMu.Unlock()
+	delete(holders, webhookURL)
+}
+
+func holderFor(webhookURL string) Holder {
+	holdersMu.Lock()
+	defer holdersMu.Unlock()
+	return holders[webhookURL]
+}
 
-	// Create a new HTTP POST request with the message body.
-	req, err := http.NewRequest("POST", webhookURL, bytes.NewBuffer(jsonStr))
+// Statuses returned by SendMessage for messages that were not sent right away.
+const (
+	// StatusQueued means the message is held until the delivery window opens.
+	StatusQueued = "QUEUED"
+	// StatusRetrying means the send failed and was handed to the Retrier.
+	StatusRetrying = "RETRY_QUEUED"
+)
+
+// Retrier takes over messages whose delivery failed with a retryable error,
+// e.g. by enqueuing them to Cloud Tasks.
+// The request headers are passed along, so the message is retried as sent.
+type Retrier interface {
+	Enqueue(ctx context.Context, webhookURL, payload string, header http.Header) error
+}
 
+var retrier Retrier
+
+// SetRetrier makes SendMessage hand failed messages to r. A nil r disables
+// retries.
+func SetRetrier(r Retrier) {
+	holdersMu.Lock()
+	defer holdersMu.Unlock()
+	retrier = r
+}
+
+func currentRetrier() Retrier {
+	holdersMu.Lock()
+	defer holdersMu.Unlock()
+	return retrier
+}
+
+// Retryable reports whether a send that returned status and err may succeed
+// when retried: transport errors, rate limiting and server errors.
+func Retryable(status string, err error) bool {
+	return err != nil || strings.HasPrefix(status, "429") || strings.HasPrefix(status, "5")
+}
+
+// SendQueued sends a message that was previously held for webhookURL.
+// Unlike SendMessage it reports a non-2xx response as an error, so the caller
+// can keep the message queued.
+func SendQueued(ctx context.Context, webhookURL, msgStr string) error {
+	webhookRateLimiter.acquire()
+
+	status, err := Post(ctx, webhookURL, msgStr)
 	if err != nil {
-		return "", err
+		return err
+	}
+	if !strings.HasPrefix(status, "2") {
+		return fmt.Errorf("webhook responded with %s", status)
 	}
+	return nil
+}
 
-	// Set the Content-Type header to application/json.
-	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+// SendMessage sends a message to the specified webhook URL.
+// Messages for a webhook registered with Hold are queued instead, and messages
+// failing with a retryable error are handed to the Retrier if one is set.
+func SendMessage(ctx context.Context, webhookURL, msgStr string) (status string, err error) {
 
-	// Create an HTTP client and send the request.
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return "", err
+	// Queue the message if the webhook is outside its delivery window.
+	if h := holderFor(webhookURL); h != nil {
+		if err := h.Hold(ctx, webhookURL, msgStr); err != nil {
+			return "", err
+		}
+		return StatusQueued, nil
 	}
-	defer resp.Body.Close()
 
-	// Return the status code of the response.
-	return resp.Status, nil
+	header := requestHeader(webhookURL)
+	status, err = PostHeader(ctx, webhookURL, msgStr, header)
+
+	// Leave retries of failed sends to the Retrier, which may outlive this run.
+	if r := currentRetrier(); r != nil && Retryable(status, err) {
+		if qErr := r.Enqueue(ctx, webhookURL, msgStr, header); qErr != nil {
+			fmt.Printf("Error enqueuing retry: %v\n", qErr)
+			return status, err
+		}
+		return StatusRetrying, nil
+	}
+	return status, err
+}
+
+// Post sends a message to the specified webhook URL once, with the headers
+// configured for the webhook, such as its signature.
+func Post(ctx context.Context, webhookURL, msgStr string) (status string, err error) {
+	return PostHeader(ctx, webhookURL, msgStr, requestHeader(webhookURL))
+}
+
+// PostHeader sends a message to the specified webhook URL once, with the
+// given request headers, through the Notifier of its target.
+func PostHeader(ctx context.Context, webhookURL, msgStr string, header http.Header) (status string, err error) {
+	return NotifierFor(webhookURL).Send(ctx, webhookURL, msgStr, header)
 }
 
 type rateLimiter struct {
@@ -0,0 +1,29 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+)
+
+// textPayload returns the JSON payload of a text message, understood by
+// Google Chat and Slack webhooks.
+func textPayload(text string) string {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	enc.Encode(struct {
+		Text string `json:"text"`
+	}{text})
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// payloadText returns the text of a JSON text message payload, for targets
+// needing the message in another format.
+func payloadText(payload string) (string, error) {
+	var msg struct {
+		Text string `json:"text"`
+	}
+	err := json.Unmarshal([]byte(payload), &msg)
+	return msg.Text, err
+}
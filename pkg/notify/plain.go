@@ -0,0 +1,45 @@
+package notify
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	chatLink   = regexp.MustCompile(`<https?://[^|>\s]+\|([^>]+)>`)
+	chatMarkup = strings.NewReplacer("*", "", "_", "", "`", "")
+	spaces     = regexp.MustCompile(`\s+`)
+	sentence   = regexp.MustCompile(`^.+?[.!?](\s|$)`)
+)
+
+// PlainText strips the chat markup of a message, such as *bold* and
+// <url|text> links, and joins its lines, for targets showing plain text.
+func PlainText(text string) string {
+	text = chatLink.ReplaceAllString(text, "$1")
+	text = chatMarkup.Replace(text)
+	return strings.TrimSpace(spaces.ReplaceAllString(text, " "))
+}
+
+// Headline returns the first sentence of a message as plain text, cut at a
+// word boundary to at most maxChars characters.
+func Headline(text string, maxChars int) string {
+	text = PlainText(text)
+	if m := sentence.FindString(text); m != "" {
+		text = strings.TrimSpace(m)
+	}
+	return Shorten(text, maxChars)
+}
+
+// Shorten cuts text at a word boundary to at most maxChars characters, ending
+// it with an ellipsis. A maxChars of zero or less leaves text unchanged.
+func Shorten(text string, maxChars int) string {
+	if maxChars <= 0 || utf8.RuneCountInString(text) <= maxChars {
+		return text
+	}
+	cut := string([]rune(text)[:maxChars-1])
+	if i := strings.LastIndex(cut, " "); i > 0 {
+		cut = cut[:i]
+	}
+	return strings.TrimRight(cut, " ,.;:-") + "…"
+}
@@ -0,0 +1,122 @@
+package notify
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/pubsub/v1"
+)
+
+// Pub/Sub topics are addressed like webhooks, with URLs of the form
+//
+//	pubsub://projects/<project>/topics/<topic>
+//
+// Each product's summary is published as a JSON message of its own,
+// describing it like the payload metadata does, so downstream systems can
+// consume the digest without parsing chat messages. Messages without
+// products, such as the announcement, are not published.
+
+const pubsubPrefix = "pubsub://"
+
+// pubsubMaxChars keeps the summaries of a message well within the 10 MB a
+// publish request may carry.
+const pubsubMaxChars = 100000
+
+var (
+	pubsubOnce    sync.Once
+	pubsubService *pubsub.Service
+	pubsubErr     error
+)
+
+func init() {
+	Register(isPubSub, Capabilities{Dialect: DialectMarkdown, MaxChars: pubsubMaxChars, Metadata: true}, pubsubNotifier{})
+}
+
+func isPubSub(webhookURL string) bool {
+	return strings.HasPrefix(webhookURL, pubsubPrefix)
+}
+
+// pubsubNotifier publishes summaries to Pub/Sub topics.
+type pubsubNotifier struct{ webhook }
+
+// pubsubMessage is the data of a published message: a product's summary in
+// Markdown with what the metadata of its message tells about it and the
+// window its release notes were published in.
+type pubsubMessage struct {
+	Run     string `json:"run,omitempty"`
+	Channel string `json:"channel,omitempty"`
+	ProductMetadata
+	Summary string `json:"summary"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+}
+
+// Send publishes a message per product summarized in a JSON text message
+// payload to the topic of topicURL, all in one request.
+func (pubsubNotifier) Send(ctx context.Context, topicURL, payload string, _ http.Header) (status string, err error) {
+	topic := strings.TrimPrefix(topicURL, pubsubPrefix)
+	if parts := strings.Split(topic, "/"); len(parts) != 4 || parts[0] != "projects" || parts[2] != "topics" || parts[1] == "" || parts[3] == "" {
+		return "", fmt.Errorf("invalid Pub/Sub topic URL, expected pubsub://projects/<project>/topics/<topic>")
+	}
+	text, err := payloadText(payload)
+	if err != nil {
+		return "", fmt.Errorf("Error reading message for Pub/Sub: %v", err)
+	}
+	meta := payloadMetadata(payload)
+
+	var messages []*pubsub.PubsubMessage
+	for _, s := range productSections(text) {
+		if s.Product == "" {
+			continue
+		}
+		m := pubsubMessage{Run: meta.Run, Channel: meta.Channel, ProductMetadata: ProductMetadata{Product: s.Product}, Summary: Render(s.Text, DialectMarkdown), From: meta.From, To: meta.To}
+		for _, p := range meta.Products {
+			if p.Product == s.Product {
+				m.ProductMetadata = p
+				break
+			}
+		}
+		data, err := json.Marshal(m)
+		if err != nil {
+			return "", err
+		}
+		// Attributes let subscriptions filter without decoding the data.
+		attributes := map[string]string{"product": m.Product}
+		if m.Channel != "" {
+			attributes["channel"] = m.Channel
+		}
+		if m.Severity != "" {
+			attributes["severity"] = m.Severity
+		}
+		messages = append(messages, &pubsub.PubsubMessage{Data: base64.StdEncoding.EncodeToString(data), Attributes: attributes})
+	}
+	if len(messages) == 0 {
+		return "204 No Content", nil
+	}
+
+	pubsubOnce.Do(func() {
+		pubsubService, pubsubErr = pubsub.NewService(ctx)
+	})
+	if pubsubErr != nil {
+		return "", fmt.Errorf("Error creating Pub/Sub client: %v", pubsubErr)
+	}
+	resp, err := pubsubService.Projects.Topics.Publish(topic, &pubsub.PublishRequest{Messages: messages}).Context(ctx).Do()
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) {
+		// Report refusals like webhook responses, so only those worth
+		// retrying are retried.
+		fmt.Printf("Pub/Sub refused to publish to %s: %s\n", topic, apiErr.Message)
+		return fmt.Sprintf("%d %s", apiErr.Code, http.StatusText(apiErr.Code)), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("Error publishing to Pub/Sub: %v", err)
+	}
+	return "200 OK " + strings.Join(resp.MessageIds, ","), nil
+}
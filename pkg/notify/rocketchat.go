@@ -0,0 +1,73 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Rocket.Chat incoming webhooks are selected by prefixing the webhook URL
+// with "rocketchat+", as in
+//
+//	rocketchat+https://chat.example.com/hooks/<id>/<token>
+//
+// Each product's summary is sent as an attachment titled with the product,
+// the format Rocket.Chat renders as a card.
+
+const rocketChatPrefix = "rocketchat+"
+
+// rocketChatColor is the color bar of the summary attachments.
+const rocketChatColor = "#1a73e8"
+
+func init() {
+	Register(isRocketChat, Capabilities{Dialect: DialectMarkdown, MaxChars: 5000, Cards: true}, rocketChatNotifier{})
+}
+
+// rocketChatNotifier sends messages to Rocket.Chat incoming webhooks.
+type rocketChatNotifier struct{ webhook }
+
+func (rocketChatNotifier) Send(ctx context.Context, webhookURL, payload string, header http.Header) (status string, err error) {
+	endpoint, payload, err := rocketChatPayload(webhookURL, payload)
+	if err != nil {
+		return "", err
+	}
+	return postJSON(ctx, webhookURL, endpoint, payload, header)
+}
+
+func isRocketChat(webhookURL string) bool {
+	return strings.HasPrefix(webhookURL, rocketChatPrefix)
+}
+
+// rocketChatPayload returns the webhook URL without the prefix and the
+// payload of a JSON text message in Rocket.Chat's attachment format.
+func rocketChatPayload(webhookURL, payload string) (string, string, error) {
+	text, err := payloadText(payload)
+	if err != nil {
+		return "", "", fmt.Errorf("Error reading message for Rocket.Chat: %v", err)
+	}
+
+	type attachment struct {
+		Title string `json:"title"`
+		Text  string `json:"text"`
+		Color string `json:"color"`
+	}
+	msg := struct {
+		Text        string       `json:"text,omitempty"`
+		Attachments []attachment `json:"attachments,omitempty"`
+	}{}
+	for _, s := range productSections(text) {
+		body := Render(s.Text, TargetCapabilities(webhookURL).Dialect)
+		if s.Product == "" {
+			msg.Text = body
+			continue
+		}
+		msg.Attachments = append(msg.Attachments, attachment{Title: s.Product, Text: body, Color: rocketChatColor})
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return "", "", err
+	}
+	return strings.TrimPrefix(webhookURL, rocketChatPrefix), string(data), nil
+}
@@ -0,0 +1,55 @@
+package notify
+
+import (
+	"regexp"
+	"strings"
+)
+
+// productHeading matches the heading productText puts above a summary.
+var productHeading = regexp.MustCompile(`(?m)^\*([^*\n]+):\*\n\n`)
+
+// section is the part of a message about one product, or the text before
+// the first product when Product is empty.
+type section struct {
+	Product string
+	Text    string
+}
+
+// productSections splits a message into the summaries of its products, for
+// targets presenting products separately, e.g. as topics or attachments.
+// Messages without product headings, such as the announcement, are returned
+// as one section without a product.
+func productSections(text string) []section {
+	matches := productHeading.FindAllStringSubmatchIndex(text, -1)
+	if len(matches) == 0 {
+		return []section{{Text: strings.TrimSpace(text)}}
+	}
+	var sections []section
+	if lead := strings.TrimSpace(text[:matches[0][0]]); lead != "" {
+		sections = append(sections, section{Text: lead})
+	}
+	for i, m := range matches {
+		end := len(text)
+		if i+1 < len(matches) {
+			end = matches[i+1][0]
+		}
+		sections = append(sections, section{Product: text[m[2]:m[3]], Text: strings.TrimSpace(text[m[1]:end])})
+	}
+	return sections
+}
+
+var (
+	markdownLink   = regexp.MustCompile(`<(https?://[^|>\s]+)\|([^>]+)>`)
+	markdownBold   = regexp.MustCompile(`(^|[^*\w])\*([^*\s][^*\n]*?)\*`)
+	markdownItalic = regexp.MustCompile(`(^|\s)_([^_\n]+)_`)
+)
+
+// Markdown converts a message written in chat markup, with *bold*, _italic_
+// and <url|text> links, to common Markdown, with **bold**, *italic* and
+// [text](url) links.
+func Markdown(text string) string {
+	text = markdownLink.ReplaceAllString(text, "[$2]($1)")
+	text = markdownItalic.ReplaceAllString(text, "$1\x00$2\x00")
+	text = markdownBold.ReplaceAllString(text, "$1**$2**")
+	return strings.ReplaceAll(text, "\x00", "*")
+}
@@ -0,0 +1,125 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Slack incoming webhooks, recognized by their https://hooks.slack.com/ URLs
+// or selected by prefixing any other URL with "slack+", as in
+//
+//	slack+https://slack-proxy.example.com/services/<id>
+//
+// get their messages as Block Kit blocks: each product's summary under a
+// header with its name, and text in sections of Slack mrkdwn, which is the
+// chat markup messages are written in. The text is sent along as the
+// notification fallback.
+
+const slackPrefix = "slack+"
+
+// slackWebhookPrefix starts the URLs of Slack incoming webhooks, and of the
+// response URLs of slash commands.
+const slackWebhookPrefix = "https://hooks.slack.com/"
+
+const (
+	// slackMaxSection is the longest text of a section block.
+	slackMaxSection = 3000
+	// slackMaxHeader is the longest text of a header block.
+	slackMaxHeader = 150
+	// slackMaxBlocks is the largest number of blocks of a message.
+	slackMaxBlocks = 50
+	// slackMaxFallback is the length of the notification fallback text.
+	slackMaxFallback = 150
+)
+
+func init() {
+	Register(isSlack, Capabilities{Dialect: DialectChat, MaxChars: 12000}, slackNotifier{})
+}
+
+func isSlack(webhookURL string) bool {
+	return strings.HasPrefix(webhookURL, slackPrefix) || strings.HasPrefix(webhookURL, slackWebhookPrefix)
+}
+
+// slackNotifier sends messages to Slack incoming webhooks.
+type slackNotifier struct{ webhook }
+
+// Announce sends the announcement as blocks ending with a divider, setting
+// it apart from the summaries that follow.
+func (slackNotifier) Announce(ctx context.Context, webhookURL, text string) (status string, err error) {
+	payload, err := slackPayload(text, true)
+	if err != nil {
+		return "", err
+	}
+	return SendMessage(ctx, webhookURL, payload)
+}
+
+// Send converts a JSON text message payload to blocks. Payloads already
+// made of blocks are sent as they are.
+func (slackNotifier) Send(ctx context.Context, webhookURL, payload string, header http.Header) (status string, err error) {
+	var msg struct {
+		Text   string            `json:"text"`
+		Blocks []json.RawMessage `json:"blocks"`
+	}
+	if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+		return "", fmt.Errorf("Error reading message for Slack: %v", err)
+	}
+	if len(msg.Blocks) == 0 {
+		if payload, err = slackPayload(msg.Text, false); err != nil {
+			return "", err
+		}
+	}
+	return postJSON(ctx, webhookURL, strings.TrimPrefix(webhookURL, slackPrefix), payload, header)
+}
+
+// slackText is a text object of a block.
+type slackText struct {
+	Type  string `json:"type"`
+	Text  string `json:"text"`
+	Emoji bool   `json:"emoji,omitempty"`
+}
+
+// slackBlock is a header, section or divider block.
+type slackBlock struct {
+	Type string     `json:"type"`
+	Text *slackText `json:"text,omitempty"`
+}
+
+// slackPayload returns the Block Kit payload of a text in chat markup, with
+// a header and sections per product and a divider between products, and
+// after the text if divider is set. A text needing more blocks than a
+// message may have is sent as plain text.
+func slackPayload(text string, divider bool) (string, error) {
+	var blocks []slackBlock
+	for i, s := range productSections(text) {
+		if i > 0 && s.Product != "" {
+			blocks = append(blocks, slackBlock{Type: "divider"})
+		}
+		if s.Product != "" {
+			blocks = append(blocks, slackBlock{Type: "header", Text: &slackText{Type: "plain_text", Text: Shorten(s.Product, slackMaxHeader), Emoji: true}})
+		}
+		for _, chunk := range splitText(s.Text, slackMaxSection) {
+			if strings.TrimSpace(chunk) != "" {
+				blocks = append(blocks, slackBlock{Type: "section", Text: &slackText{Type: "mrkdwn", Text: chunk}})
+			}
+		}
+	}
+	if divider {
+		blocks = append(blocks, slackBlock{Type: "divider"})
+	}
+
+	msg := struct {
+		Text   string       `json:"text"`
+		Blocks []slackBlock `json:"blocks,omitempty"`
+	}{Text: text}
+	if len(blocks) <= slackMaxBlocks {
+		msg.Text, msg.Blocks = Headline(text, slackMaxFallback), blocks
+	}
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,161 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Telegram chats are addressed like webhooks, with URLs of the form
+//
+//	telegram://<chat ID>
+//
+// where the chat ID is the numeric ID of a group, e.g. -1001234567890, or
+// the @username of a public channel. The bot's token is taken from the user
+// part of the URL, as in telegram://123456:ABC-DEF@<chat ID>, or else from
+// SetTelegramToken. Messages are sent as MarkdownV2, with every character
+// Telegram reserves escaped outside the formatting.
+
+// telegramAPIURL is the endpoint of the Telegram Bot API.
+const telegramAPIURL = "https://api.telegram.org"
+
+// telegramMaxChars is the longest message Telegram accepts.
+const telegramMaxChars = 4096
+
+var (
+	telegramMu    sync.Mutex
+	telegramToken string
+)
+
+// SetTelegramToken sets the bot token used for Telegram chats without a
+// token in their URL.
+func SetTelegramToken(token string) {
+	telegramMu.Lock()
+	defer telegramMu.Unlock()
+	telegramToken = token
+}
+
+func init() {
+	Register(isTelegram, Capabilities{Dialect: DialectTelegram, MaxChars: telegramMaxChars}, telegramNotifier{})
+}
+
+func isTelegram(webhookURL string) bool {
+	return strings.HasPrefix(webhookURL, "telegram://")
+}
+
+// telegramNotifier sends messages to Telegram chats as the bot.
+type telegramNotifier struct{ webhook }
+
+// Send converts the text of a JSON text message payload to MarkdownV2 and
+// sends it to the chat, in several messages if it is too long for one,
+// stopping at the first one not accepted.
+func (telegramNotifier) Send(ctx context.Context, chatURL, payload string, _ http.Header) (status string, err error) {
+	rest := strings.TrimPrefix(chatURL, "telegram://")
+	// Bot tokens contain a colon, which tells them apart from the @ of a
+	// channel username.
+	token, chatID, ok := strings.Cut(rest, "@")
+	if !ok || !strings.Contains(token, ":") {
+		token, chatID = "", rest
+	}
+	chatID = strings.Trim(chatID, "/")
+	if token == "" && strings.Contains(rest, ":") {
+		return "", fmt.Errorf("invalid Telegram chat URL, expected telegram://<token>@<chat ID>")
+	}
+	if chatID == "" {
+		return "", fmt.Errorf("invalid Telegram chat URL, expected telegram://<chat ID>")
+	}
+	if token == "" {
+		telegramMu.Lock()
+		token = telegramToken
+		telegramMu.Unlock()
+	}
+	if token == "" {
+		return "", fmt.Errorf("no Telegram bot token for chat %s", chatID)
+	}
+
+	text, err := payloadText(payload)
+	if err != nil {
+		return "", fmt.Errorf("Error reading message for Telegram: %v", err)
+	}
+	// Split before escaping, so no chunk ends within an escape or an
+	// entity.
+	for _, chunk := range splitText(strings.TrimSpace(text), telegramMaxChars) {
+		body, err := json.Marshal(map[string]any{
+			"chat_id":                  chatID,
+			"text":                     TelegramMarkdown(chunk),
+			"parse_mode":               "MarkdownV2",
+			"disable_web_page_preview": true,
+		})
+		if err != nil {
+			return "", err
+		}
+		status, err = postTelegram(ctx, chatURL, token, body)
+		if err != nil || !strings.HasPrefix(status, "2") {
+			return status, err
+		}
+	}
+	return status, nil
+}
+
+// postTelegram calls the sendMessage method of the bot with body.
+func postTelegram(ctx context.Context, chatURL, token string, body []byte) (status string, err error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", telegramAPIURL+"/bot"+token+"/sendMessage", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := Client(chatURL).Do(req)
+	if err != nil {
+		// The request URL carries the token, so it is left out of the
+		// error.
+		return "", fmt.Errorf("Error sending to Telegram: %v", strings.ReplaceAll(err.Error(), token, "<token>"))
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp.Status, nil
+}
+
+var (
+	// telegramEntity matches the links, bold and italic text of chat
+	// markup.
+	telegramEntity = regexp.MustCompile(`<(https?://[^|>\s]+)\|([^>]+)>|\*([^*\s][^*\n]*?)\*|\b_([^_\n]+)_`)
+	// telegramReserved escapes the characters MarkdownV2 reserves.
+	telegramReserved = strings.NewReplacer(
+		`\`, `\\`, `_`, `\_`, `*`, `\*`, `[`, `\[`, `]`, `\]`, `(`, `\(`, `)`, `\)`, `~`, `\~`, "`", "\\`",
+		`>`, `\>`, `#`, `\#`, `+`, `\+`, `-`, `\-`, `=`, `\=`, `|`, `\|`, `{`, `\{`, `}`, `\}`, `.`, `\.`, `!`, `\!`,
+	)
+	// telegramURL escapes the characters reserved within the URL of a link.
+	telegramURL = strings.NewReplacer(`\`, `\\`, `)`, `\)`)
+	// telegramListItem matches the list markers of chat markup.
+	telegramListItem = regexp.MustCompile(`(?m)^(\s*)[*-] `)
+)
+
+// TelegramMarkdown converts a message written in chat markup, with *bold*,
+// _italic_ and <url|text> links, to Telegram's MarkdownV2, escaping the
+// reserved characters of the text. List items are marked with bullets.
+func TelegramMarkdown(text string) string {
+	text = telegramListItem.ReplaceAllString(text, "$1• ")
+	var b strings.Builder
+	last := 0
+	for _, m := range telegramEntity.FindAllStringSubmatchIndex(text, -1) {
+		b.WriteString(telegramReserved.Replace(text[last:m[0]]))
+		switch {
+		case m[2] >= 0:
+			b.WriteString("[" + telegramReserved.Replace(text[m[4]:m[5]]) + "](" + telegramURL.Replace(text[m[2]:m[3]]) + ")")
+		case m[6] >= 0:
+			b.WriteString("*" + telegramReserved.Replace(text[m[6]:m[7]]) + "*")
+		default:
+			b.WriteString("_" + telegramReserved.Replace(text[m[8]:m[9]]) + "_")
+		}
+		last = m[1]
+	}
+	b.WriteString(telegramReserved.Replace(text[last:]))
+	return b.String()
+}
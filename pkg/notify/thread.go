@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"context"
+	"net/url"
+	"strings"
+)
+
+// isGoogleChat reports whether the webhook URL is a Google Chat webhook.
+func isGoogleChat(webhookURL string) bool {
+	return strings.HasPrefix(webhookURL, "https://chat.googleapis.com/")
+}
+
+// SendThread sends a text in chat markup to the webhook URL right away,
+// split into as many messages as the target needs. Google Chat webhooks get
+// them in the thread named threadKey, which is started if it does not exist
+// yet; other targets get them as messages of their own. It returns the
+// first unsuccessful status.
+func SendThread(ctx context.Context, webhookURL, threadKey, text string) (status string, err error) {
+	// The Notifier of the webhook adds the thread to its URL, so the
+	// message goes out with the client, headers and signature of the
+	// webhook, and threadKey replaces any thread of ctx.
+	ctx = WithThread(ctx, threadKey)
+	for _, chunk := range splitText(text, TargetCapabilities(webhookURL).MaxChars) {
+		webhookRateLimiter.acquire()
+		chunkStatus, err := PostHeader(ctx, webhookURL, textPayload(chunk), requestHeader(webhookURL))
+		if err != nil {
+			return chunkStatus, err
+		}
+		if status == "" || strings.HasPrefix(status, "2") {
+			status = chunkStatus
+		}
+	}
+	return status, nil
+}
+
+// threadEndpoint returns the URL posting to the thread named threadKey of a
+// Google Chat webhook, starting it if it does not exist yet, or webhookURL
+// itself for other targets or without a thread.
+func threadEndpoint(webhookURL, threadKey string) (string, error) {
+	if !isGoogleChat(webhookURL) || threadKey == "" {
+		return webhookURL, nil
+	}
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return "", err
+	}
+	q := u.Query()
+	q.Set("threadKey", threadKey)
+	q.Set("messageReplyOption", "REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD")
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+type threadKey struct{}
+
+// WithThread returns a context sending the messages posted with it right
+// away to Google Chat webhooks in the thread named key, replacing the thread
+// of ctx, if any; an empty key posts new messages. Messages queued outside
+// the delivery window or retried later are posted as new messages.
+func WithThread(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, threadKey{}, key)
+}
+
+// threadOf returns the thread set in the context with WithThread, if any.
+func threadOf(ctx context.Context) string {
+	key, _ := ctx.Value(threadKey{}).(string)
+	return key
+}
@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// threadRecorder records the sends of a Notifier instead of posting them.
+type threadRecorder struct {
+	webhook
+	urls    []string
+	threads []string
+}
+
+func (t *threadRecorder) Send(ctx context.Context, webhookURL, payload string, header http.Header) (string, error) {
+	t.urls = append(t.urls, webhookURL)
+	t.threads = append(t.threads, threadOf(ctx))
+	return "200 OK", nil
+}
+
+func TestSendThread(t *testing.T) {
+	const webhookURL = "https://chat.googleapis.com/v1/spaces/THREAD/messages?key=k&token=t"
+	rec := &threadRecorder{}
+	Register(func(u string) bool { return u == webhookURL }, chatCapabilities, rec)
+
+	// The key of the expanded notes replaces the thread of the run.
+	ctx := WithThread(context.Background(), "run-1")
+	if _, err := SendThread(ctx, webhookURL, "run-1-bigquery", "release notes"); err != nil {
+		t.Fatal(err)
+	}
+	if len(rec.urls) != 1 || rec.urls[0] != webhookURL {
+		t.Errorf("sent to %q, want the webhook URL %q, whose client and signing apply", rec.urls, webhookURL)
+	}
+	if len(rec.threads) != 1 || rec.threads[0] != "run-1-bigquery" {
+		t.Errorf("sent in thread %q, want run-1-bigquery", rec.threads)
+	}
+}
+
+func TestThreadEndpoint(t *testing.T) {
+	endpoint, err := threadEndpoint("https://chat.googleapis.com/v1/spaces/AAA/messages?key=k", "run-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if got := q["threadKey"]; len(got) != 1 || got[0] != "run-1" {
+		t.Errorf("threadKey = %q, want [run-1]", got)
+	}
+	if q.Get("key") != "k" || q.Get("messageReplyOption") != "REPLY_MESSAGE_FALLBACK_TO_NEW_THREAD" {
+		t.Errorf("endpoint %s lost the webhook's parameters or the reply option", endpoint)
+	}
+
+	// Other targets and messages without a thread post to the webhook.
+	for _, tc := range []struct{ webhookURL, key string }{
+		{"https://hooks.slack.com/services/T/B/X", "run-1"},
+		{"https://chat.googleapis.com/v1/spaces/AAA/messages?key=k", ""},
+	} {
+		if got, _ := threadEndpoint(tc.webhookURL, tc.key); got != tc.webhookURL {
+			t.Errorf("threadEndpoint(%q, %q) = %q, want the webhook URL", tc.webhookURL, tc.key, got)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Webex spaces are reached either through an incoming webhook, whose
+// https://webexapis.com/v1/webhooks/incoming/... URL is used as is, or
+// through a bot, with URLs of the form
+//
+//	webex://<room ID>
+//
+// The bot's access token is taken from the user part of the URL, as in
+// webex://<token>@<room ID>, or else from SetWebexToken. Both ways send the
+// message as Webex markdown.
+
+// webexMessagesURL is the endpoint of the Webex messages API.
+const webexMessagesURL = "https://webexapis.com/v1/messages"
+
+// webexWebhookPrefix starts the URLs of Webex incoming webhooks.
+const webexWebhookPrefix = "https://webexapis.com/v1/webhooks/incoming/"
+
+var (
+	webexMu    sync.Mutex
+	webexToken string
+)
+
+// SetWebexToken sets the bot access token used for Webex rooms without a
+// token in their URL.
+func SetWebexToken(token string) {
+	webexMu.Lock()
+	defer webexMu.Unlock()
+	webexToken = token
+}
+
+func init() {
+	Register(isWebex, Capabilities{Dialect: DialectMarkdown, MaxChars: 7000, Threads: true, Cards: true, Buttons: true}, webexNotifier{})
+	Register(isWebexWebhook, Capabilities{Dialect: DialectMarkdown, MaxChars: 7000}, webexWebhookNotifier{})
+}
+
+// webexNotifier sends messages to Webex rooms as the bot.
+type webexNotifier struct{ webhook }
+
+func (webexNotifier) Send(ctx context.Context, roomURL, payload string, _ http.Header) (status string, err error) {
+	return postWebex(ctx, roomURL, payload)
+}
+
+// webexWebhookNotifier sends messages to Webex incoming webhooks.
+type webexWebhookNotifier struct{ webhook }
+
+func (webexWebhookNotifier) Send(ctx context.Context, webhookURL, payload string, header http.Header) (status string, err error) {
+	if payload, err = webexWebhookPayload(webhookURL, payload); err != nil {
+		return "", err
+	}
+	return postJSON(ctx, webhookURL, webhookURL, payload, header)
+}
+
+func isWebex(webhookURL string) bool {
+	return strings.HasPrefix(webhookURL, "webex://")
+}
+
+func isWebexWebhook(webhookURL string) bool {
+	return strings.HasPrefix(webhookURL, webexWebhookPrefix)
+}
+
+// webexMarkdown returns the text of a JSON text message payload rendered for
+// the Webex target webhookURL.
+func webexMarkdown(webhookURL, payload string) (string, error) {
+	text, err := payloadText(payload)
+	if err != nil {
+		return "", fmt.Errorf("Error reading message for Webex: %v", err)
+	}
+	return Render(text, TargetCapabilities(webhookURL).Dialect), nil
+}
+
+// webexWebhookPayload converts a JSON text message payload to the payload of
+// a Webex incoming webhook.
+func webexWebhookPayload(webhookURL, payload string) (string, error) {
+	markdown, err := webexMarkdown(webhookURL, payload)
+	if err != nil {
+		return "", err
+	}
+	data, err := json.Marshal(map[string]string{"markdown": markdown})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// postWebex sends the text of a JSON text message payload to a Webex room as
+// the bot.
+func postWebex(ctx context.Context, roomURL, payload string) (status string, err error) {
+	rest := strings.TrimPrefix(roomURL, "webex://")
+	token, roomID, ok := strings.Cut(rest, "@")
+	if !ok {
+		roomID, token = rest, ""
+	}
+	roomID = strings.Trim(roomID, "/")
+	if roomID == "" {
+		return "", fmt.Errorf("invalid Webex room URL, expected webex://<room ID>")
+	}
+	if token == "" {
+		webexMu.Lock()
+		token = webexToken
+		webexMu.Unlock()
+	}
+	if token == "" {
+		return "", fmt.Errorf("no Webex bot token for room %s", roomID)
+	}
+
+	markdown, err := webexMarkdown(roomURL, payload)
+	if err != nil {
+		return "", err
+	}
+	body, err := json.Marshal(map[string]string{
+		"roomId":   roomID,
+		"markdown": markdown,
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", webexMessagesURL, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := Client(roomURL).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	return resp.Status, nil
+}
@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Zulip streams are addressed like webhooks, with URLs of the form
+//
+//	zulip://chat.example.com/releases?topic=GCP%20Release%20Digest
+//
+// Each product's summary is posted under its own topic, named after the
+// product, so every product gets its own thread; other messages, such as the
+// announcement, go to the topic parameter (default "GCP Release Digest").
+// The bot's email and API key are taken from the user part of the URL, as
+// in zulip://bot%40example.com:<api key>@chat.example.com/releases, or else
+// from SetZulipBot.
+
+// DefaultZulipTopic is the topic of messages not about a single product.
+const DefaultZulipTopic = "GCP Release Digest"
+
+// zulipMaxTopic is the longest topic name Zulip accepts.
+const zulipMaxTopic = 60
+
+var (
+	zulipMu     sync.Mutex
+	zulipEmail  string
+	zulipAPIKey string
+)
+
+// SetZulipBot sets the credentials used for Zulip streams without
+// credentials in their URL.
+func SetZulipBot(email, apiKey string) {
+	zulipMu.Lock()
+	defer zulipMu.Unlock()
+	zulipEmail, zulipAPIKey = email, apiKey
+}
+
+func init() {
+	Register(isZulip, Capabilities{Dialect: DialectMarkdown, MaxChars: 10000, Threads: true}, zulipNotifier{})
+}
+
+// zulipNotifier posts messages to Zulip streams.
+type zulipNotifier struct{ webhook }
+
+func (zulipNotifier) Send(ctx context.Context, streamURL, payload string, _ http.Header) (status string, err error) {
+	return postZulip(ctx, streamURL, payload)
+}
+
+func isZulip(webhookURL string) bool {
+	return strings.HasPrefix(webhookURL, "zulip://")
+}
+
+// postZulip posts the text of a JSON text message payload to a Zulip stream,
+// one message per product topic. It returns the first unsuccessful status.
+func postZulip(ctx context.Context, streamURL, payload string) (status string, err error) {
+	u, err := url.Parse(streamURL)
+	if err != nil || u.Host == "" || strings.Trim(u.Path, "/") == "" {
+		return "", fmt.Errorf("invalid Zulip stream URL, expected zulip://server/stream")
+	}
+	stream := strings.Trim(u.Path, "/")
+	email := u.User.Username()
+	apiKey, _ := u.User.Password()
+	if email == "" {
+		zulipMu.Lock()
+		email, apiKey = zulipEmail, zulipAPIKey
+		zulipMu.Unlock()
+	}
+	if email == "" || apiKey == "" {
+		return "", fmt.Errorf("no Zulip bot credentials for stream %s", stream)
+	}
+	defaultTopic := u.Query().Get("topic")
+	if defaultTopic == "" {
+		defaultTopic = DefaultZulipTopic
+	}
+
+	text, err := payloadText(payload)
+	if err != nil {
+		return "", fmt.Errorf("Error reading message for Zulip: %v", err)
+	}
+	for _, s := range productSections(text) {
+		topic := defaultTopic
+		if s.Product != "" {
+			topic = s.Product
+		}
+		if utf8.RuneCountInString(topic) > zulipMaxTopic {
+			topic = string([]rune(topic)[:zulipMaxTopic])
+		}
+		form := url.Values{
+			"type":    {"stream"},
+			"to":      {stream},
+			"topic":   {topic},
+			"content": {Render(s.Text, TargetCapabilities(streamURL).Dialect)},
+		}
+		req, err := http.NewRequestWithContext(ctx, "POST", "https://"+u.Host+"/api/v1/messages", strings.NewReader(form.Encode()))
+		if err != nil {
+			return "", err
+		}
+		req.SetBasicAuth(email, apiKey)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		resp, err := Client(streamURL).Do(req)
+		if err != nil {
+			return "", err
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if status == "" || strings.HasPrefix(status, "2") {
+			status = resp.Status
+		}
+	}
+	return status, nil
+}
@@ -0,0 +1,79 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/mpolski/gcp-release-digest/pkg/store"
+)
+
+// Message is a webhook payload held back until its channel's delivery window
+// opens.
+type Message struct {
+	WebhookURL string    `json:"webhook_url"`
+	Payload    string    `json:"payload"`
+	QueuedAt   time.Time `json:"queued_at"`
+}
+
+// Queue holds messages for a single channel in a Store.
+type Queue struct {
+	store   store.Store
+	channel string
+}
+
+// seq keeps keys unique and ordered for messages queued within the same
+// nanosecond.
+var seq atomic.Uint64
+
+// New returns the queue of channel kept in s.
+func New(s store.Store, channel string) *Queue {
+	return &Queue{store: s, channel: channel}
+}
+
+func (q *Queue) prefix() string {
+	return "outbox/" + q.channel + "/"
+}
+
+// Hold stores the payload for webhookURL until the queue is flushed.
+func (q *Queue) Hold(ctx context.Context, webhookURL, payload string) error {
+	now := time.Now().UTC()
+	data, err := json.Marshal(Message{WebhookURL: webhookURL, Payload: payload, QueuedAt: now})
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("%s%020d-%06d.json", q.prefix(), now.UnixNano(), seq.Add(1)%1000000)
+	return q.store.Put(ctx, key, data)
+}
+
+// Flush sends the held messages in the order they were queued, removing each
+// one once send succeeds. It stops at the first failure so the remaining
+// messages keep their order for the next flush, and returns how many messages
+// were sent.
+func (q *Queue) Flush(ctx context.Context, send func(ctx context.Context, webhookURL, payload string) error) (int, error) {
+	keys, err := q.store.List(ctx, q.prefix())
+	if err != nil {
+		return 0, fmt.Errorf("Error listing queued messages: %v", err)
+	}
+	sent := 0
+	for _, key := range keys {
+		data, err := q.store.Get(ctx, key)
+		if err != nil {
+			return sent, fmt.Errorf("Error reading queued message %s: %v", key, err)
+		}
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return sent, fmt.Errorf("Error decoding queued message %s: %v", key, err)
+		}
+		if err := send(ctx, msg.WebhookURL, msg.Payload); err != nil {
+			return sent, err
+		}
+		if err := q.store.Delete(ctx, key); err != nil {
+			return sent, fmt.Errorf("Error removing queued message %s: %v", key, err)
+		}
+		sent++
+	}
+	return sent, nil
+}
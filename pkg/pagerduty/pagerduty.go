@@ -0,0 +1,122 @@
+package pagerduty
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/mpolski/gcp-release-digest/pkg/notify"
+)
+
+// eventsURL is the endpoint of the PagerDuty Events API v2.
+const eventsURL = "https://events.pagerduty.com/v2/enqueue"
+
+// maxSummary is the longest summary of an event PagerDuty accepts.
+const maxSummary = 1024
+
+// Severities are the severities of an event, most severe first.
+var Severities = []string{"critical", "error", "warning", "info"}
+
+// Event is an alert triggering, or adding to, an incident of the service.
+type Event struct {
+	// Summary is the title of the alert.
+	Summary string
+	// Source is what the alert is about, e.g. the product.
+	Source   string
+	Severity string
+	// Component, Group and Class are shown with the alert and can be used
+	// by event rules.
+	Component string
+	Group     string
+	Class     string
+	// DedupKey identifies the alert, so triggering it again adds to the
+	// same incident.
+	DedupKey string
+	// Details are shown with the alert.
+	Details map[string]any
+	// Link is shown with the alert, if set, under LinkText.
+	Link     string
+	LinkText string
+}
+
+// Events triggers alerts through the PagerDuty Events API v2.
+type Events struct {
+	// RoutingKey is the integration key of the service.
+	RoutingKey string
+}
+
+type link struct {
+	Href string `json:"href"`
+	Text string `json:"text,omitempty"`
+}
+
+type payload struct {
+	Summary       string         `json:"summary"`
+	Source        string         `json:"source"`
+	Severity      string         `json:"severity"`
+	Component     string         `json:"component,omitempty"`
+	Group         string         `json:"group,omitempty"`
+	Class         string         `json:"class,omitempty"`
+	CustomDetails map[string]any `json:"custom_details,omitempty"`
+}
+
+type request struct {
+	RoutingKey  string  `json:"routing_key"`
+	EventAction string  `json:"event_action"`
+	DedupKey    string  `json:"dedup_key,omitempty"`
+	Payload     payload `json:"payload"`
+	Client      string  `json:"client"`
+	Links       []link  `json:"links,omitempty"`
+}
+
+// Trigger sends e as a trigger event.
+func (p *Events) Trigger(ctx context.Context, e Event) (status string, err error) {
+	body := request{
+		RoutingKey:  p.RoutingKey,
+		EventAction: "trigger",
+		DedupKey:    e.DedupKey,
+		Payload: payload{
+			Summary:       notify.Shorten(e.Summary, maxSummary),
+			Source:        e.Source,
+			Severity:      e.Severity,
+			Component:     e.Component,
+			Group:         e.Group,
+			Class:         e.Class,
+			CustomDetails: e.Details,
+		},
+		Client: "GCP Release Digest",
+	}
+	if e.Link != "" {
+		body.Links = []link{{Href: e.Link, Text: e.LinkText}}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return "", fmt.Errorf("Error encoding PagerDuty event: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", eventsURL, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := notify.Client(eventsURL).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.Status, fmt.Errorf("Error triggering PagerDuty event: %s %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp.Status, nil
+}
+
+// Target identifies PagerDuty in run reports.
+func (p *Events) Target() string {
+	return "https://events.pagerduty.com"
+}
@@ -0,0 +1,67 @@
+package products
+
+import "strings"
+
+// OtherCategory is the category of products not matching any known keyword.
+const OtherCategory = "Other"
+
+// categories maps product categories, in the order they are presented, to the
+// words or phrases identifying their products. The first matching category
+// wins, so more specific categories come first.
+var categories = []struct {
+	name     string
+	keywords []string
+}{
+	{"AI and Machine Learning", []string{"ai", "vertex", "gemini", "dialogflow", "speech-to-text", "text-to-speech", "translation", "vision", "natural language", "contact center", "recommendations", "automl", "tpu", "agent"}},
+	{"Security and Identity", []string{"security", "iam", "identity", "kms", "key management", "secret manager", "assured workloads", "recaptcha", "identity-aware proxy", "beyondcorp", "chronicle", "certificate", "binary authorization", "access", "armor", "sensitive data protection", "dlp"}},
+	{"Databases", []string{"sql", "spanner", "bigtable", "firestore", "datastore", "alloydb", "memorystore", "database", "firebase"}},
+	{"Data Analytics", []string{"bigquery", "dataflow", "dataproc", "pub/sub", "looker", "data fusion", "composer", "dataplex", "datastream", "analytics hub", "data catalog", "dataform"}},
+	{"Compute", []string{"compute engine", "cloud run", "kubernetes", "gke", "app engine", "cloud functions", "batch", "vmware", "bare metal", "anthos", "distributed cloud", "gpu"}},
+	{"Storage", []string{"storage", "filestore", "backup", "netapp", "transfer"}},
+	{"Networking", []string{"vpc", "load balancing", "network", "networking", "dns", "cdn", "interconnect", "nat", "vpn", "service directory", "router", "traffic director", "service mesh"}},
+	{"Operations", []string{"monitoring", "logging", "trace", "profiler", "error reporting", "observability", "operations", "cloud asset inventory"}},
+	{"Developer Tools", []string{"build", "artifact registry", "deploy", "source", "workstations", "code", "scheduler", "tasks", "workflows", "apigee", "api gateway", "endpoints", "eventarc", "integration", "shell", "sdk", "terraform"}},
+}
+
+// Category returns the category of a product, e.g. "Databases" for Cloud SQL,
+// or OtherCategory if the product is not recognised.
+func Category(product string) string {
+	// Pad and normalise the name so keywords only match whole words.
+	name := " " + strings.NewReplacer("(", " ", ")", " ", ",", " ").Replace(strings.ToLower(product)) + " "
+	for _, c := range categories {
+		for _, kw := range c.keywords {
+			if strings.Contains(name, " "+kw+" ") {
+				return c.name
+			}
+		}
+	}
+	return OtherCategory
+}
+
+// CategoryGroup is a list of products sharing a category.
+type CategoryGroup struct {
+	Category string
+	Products []Product
+}
+
+// GroupByCategory groups products by Category, keeping the order of products
+// within each group. Groups are returned in a fixed category order with
+// OtherCategory last.
+func GroupByCategory(products []Product) []CategoryGroup {
+	byCategory := make(map[string][]Product)
+	for _, p := range products {
+		c := Category(p.Product)
+		byCategory[c] = append(byCategory[c], p)
+	}
+
+	var groups []CategoryGroup
+	for _, c := range categories {
+		if ps, ok := byCategory[c.name]; ok {
+			groups = append(groups, CategoryGroup{Category: c.name, Products: ps})
+		}
+	}
+	if ps, ok := byCategory[OtherCategory]; ok {
+		groups = append(groups, CategoryGroup{Category: OtherCategory, Products: ps})
+	}
+	return groups
+}
@@ -0,0 +1,65 @@
+package products
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/mpolski/gcp-release-digest/pkg/source"
+)
+
+// fileProducts counts the release notes of the given types per product, like
+// the product queries, from the notes of a release notes file.
+func fileProducts(notes []source.Note, releaseNoteTypes []string) []Product {
+	byName := make(map[string]*Product)
+	for _, n := range notes {
+		if !slices.Contains(releaseNoteTypes, n.ReleaseNoteType) {
+			continue
+		}
+		p, ok := byName[n.ProductName]
+		if !ok {
+			p = &Product{Product: n.ProductName}
+			byName[n.ProductName] = p
+		}
+		p.NoteCount++
+		switch n.ReleaseNoteType {
+		case "BREAKING_CHANGE":
+			p.BreakingChanges++
+		case "SECURITY_BULLETIN":
+			p.SecurityBulletins++
+		case "DEPRECATION":
+			p.Deprecations++
+		}
+	}
+
+	products := make([]Product, 0, len(byName))
+	for _, p := range byName {
+		products = append(products, *p)
+	}
+	sort.Slice(products, func(i, j int) bool { return products[i].Product < products[j].Product })
+	fmt.Printf("Found release notes for %d products in the release notes file.\n", len(products))
+	return products
+}
+
+// fileTypeCounts counts the release notes of the given types and products,
+// like the type count query, from the notes of a release notes file.
+func fileTypeCounts(notes []source.Note, releaseNoteTypes []string, productNames []string) []TypeCount {
+	byType := make(map[string]int)
+	for _, n := range notes {
+		if slices.Contains(releaseNoteTypes, n.ReleaseNoteType) && slices.Contains(productNames, n.ProductName) {
+			byType[n.ReleaseNoteType]++
+		}
+	}
+
+	counts := make([]TypeCount, 0, len(byType))
+	for t, c := range byType {
+		counts = append(counts, TypeCount{ReleaseNoteType: t, Count: c})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].ReleaseNoteType < counts[j].ReleaseNoteType
+	})
+	return counts
+}
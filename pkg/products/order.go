@@ -0,0 +1,80 @@
+package products
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Supported product orderings.
+const (
+	// OrderName lists products alphabetically, as returned by the queries.
+	OrderName = "name"
+	// OrderCount lists products with the most release notes first.
+	OrderCount = "count"
+	// OrderSignificance lists products with security bulletins first, then
+	// products with breaking changes, then products with deprecations, then
+	// everything else, each group by note count.
+	OrderSignificance = "significance"
+	// OrderPriority lists products from a user-defined priority list first,
+	// in the order given, followed by the remaining products alphabetically.
+	OrderPriority = "priority"
+	// OrderImpact lists products with the highest impact of their release
+	// notes first. Products are summarized by significance, and ordered by
+	// impact once their release notes are scored.
+	OrderImpact = "impact"
+)
+
+// ValidateOrder checks that order is one of the supported product orderings.
+// An empty order is valid and means OrderName.
+func ValidateOrder(order string) error {
+	switch order {
+	case "", OrderName, OrderCount, OrderSignificance, OrderPriority, OrderImpact:
+		return nil
+	}
+	return fmt.Errorf("unsupported product order %q, use one of %s, %s, %s, %s, %s",
+		order, OrderName, OrderCount, OrderSignificance, OrderPriority, OrderImpact)
+}
+
+// Sort orders products in place so the most relevant ones come first.
+// The priority list is only used by OrderPriority and is matched
+// case-insensitively against the product names.
+func Sort(products []Product, order string, priority []string) {
+	rank := make(map[string]int, len(priority))
+	for i, p := range priority {
+		rank[strings.ToLower(strings.TrimSpace(p))] = i
+	}
+
+	sort.SliceStable(products, func(i, j int) bool {
+		a, b := products[i], products[j]
+		switch order {
+		case OrderCount:
+			if a.NoteCount != b.NoteCount {
+				return a.NoteCount > b.NoteCount
+			}
+		case OrderSignificance, OrderImpact:
+			if (a.SecurityBulletins > 0) != (b.SecurityBulletins > 0) {
+				return a.SecurityBulletins > 0
+			}
+			if (a.BreakingChanges > 0) != (b.BreakingChanges > 0) {
+				return a.BreakingChanges > 0
+			}
+			if (a.Deprecations > 0) != (b.Deprecations > 0) {
+				return a.Deprecations > 0
+			}
+			if a.NoteCount != b.NoteCount {
+				return a.NoteCount > b.NoteCount
+			}
+		case OrderPriority:
+			ra, okA := rank[strings.ToLower(a.Product)]
+			rb, okB := rank[strings.ToLower(b.Product)]
+			if okA != okB {
+				return okA
+			}
+			if okA && ra != rb {
+				return ra < rb
+			}
+		}
+		return a.Product < b.Product
+	})
+}
@@ -3,12 +3,19 @@ package products
 import (
 	"context"
 	"fmt"
+	"slices"
 
 	"cloud.google.com/go/bigquery"
-	"google.golang.org/api/iterator"
+	"github.com/mpolski/gcp-release-digest/pkg/query"
+	"github.com/mpolski/gcp-release-digest/pkg/querycache"
+	"github.com/mpolski/gcp-release-digest/pkg/source"
 )
 
 func GetProductsbyReleaseType(ctx context.Context, projectID string, releaseNotebyType string, cadence string) ([]Product, error) {
+	if notes, ok := source.FileNotes(cadence); ok {
+		return fileProducts(notes, []string{releaseNotebyType}), nil
+	}
+
 	client, err := bigquery.NewClient(ctx, projectID)
 	if err != nil {
 		return nil, fmt.Errorf("Error creating BQ client: %v", err)
@@ -17,71 +24,21 @@ func GetProductsbyReleaseType(ctx context.Context, projectID string, releaseNote
 
 	fmt.Printf("Asking for products for release notes type: %s... ", releaseNotebyType)
 	// Define the BigQuery query to retrieve distinct products with release notes.
-	q := client.Query(`
-SELECT 
-	DISTINCT product_name as product
-FROM bigquery-public-data.google_cloud_release_notes.release_notes
-WHERE
-	published_at >= DATE_SUB(CURRENT_DATE(), INTERVAL ` + cadence + ` DAY)
-	AND release_note_type = @release_note_type
-ORDER BY product_name ASC
-	`)
-
-	// Set the query location to US.
-	q.Location = "US"
-
-	q.Parameters = []bigquery.QueryParameter{
-		{
-			Name:  "release_note_type",
-			Value: releaseNotebyType,
-		},
-	}
-	// Run the BigQuery query.
-	job, err := q.Run(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("Error running query: %v", err)
-	}
-
-	// Wait for the query job to complete.
-	status, err := job.Wait(ctx)
+	q, err := productsQuery(cadence, []string{releaseNotebyType}).Query(client)
 	if err != nil {
-		return nil, fmt.Errorf("Job completed with error: %v", status.Err())
-	}
-	if err := status.Err(); err != nil {
-		return nil, fmt.Errorf("Job completed with error: %v", status.Err())
+		return nil, err
 	}
 
-	// Read the query results.
-	it, err := job.Read(ctx)
+	// Run the BigQuery query, retrying transient errors, and read its
+	// results, or those of the same query run before in this run.
+	rows, err := querycache.Read[Product](ctx, q)
 	if err != nil {
-		return nil, fmt.Errorf("Error iterating over results: %v", err)
+		return nil, err
 	}
 
-	// Initialize a slice to store the retrieved products.
-	var products []Product
-
-	// Iterate over the query results and populate the products slice.
-	rowCount := 0
-	for {
-		var row []bigquery.Value
-		err := it.Next(&row)
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("Error reading row: %v", err)
-		}
-
-		// Extract the product name from the row.
-		product := Product{
-			Product: getStringValue(row[0]),
-		}
-
-		// Append the product to the products slice.
-		products = append(products, product)
-		rowCount++
-
-	}
+	// Copy the products, as the rows read are shared with later reads.
+	products := slices.Clone(rows)
+	rowCount := len(products)
 
 	// Print the number of products found for informational purposes.
 	switch rowCount {
@@ -105,6 +62,9 @@ ORDER BY product_name ASC
 // GetProducts retrieves a list of distinct products from BigQuery's public dataset
 // that have release notes published within the specified cadence.
 func GetProducts(ctx context.Context, projectID string, noActiveChannel []string, cadence string) ([]Product, error) {
+	if notes, ok := source.FileNotes(cadence); ok {
+		return fileProducts(notes, noActiveChannel), nil
+	}
 
 	fmt.Printf("This is noActiveChannel slice content in GetProducts: %v", noActiveChannel)
 	// Create a BigQuery client.
@@ -115,72 +75,21 @@ func GetProducts(ctx context.Context, projectID string, noActiveChannel []string
 	defer client.Close()
 
 	// Define the BigQuery query to retrieve distinct products for release notes.
-	q := client.Query(`
-	SELECT 
-		DISTINCT product_name as product
-	FROM bigquery-public-data.google_cloud_release_notes.release_notes
-	WHERE
-		published_at >= DATE_SUB(CURRENT_DATE(), INTERVAL ` + cadence + ` DAY)
-		AND release_note_type IN UNNEST(@noActiveChannel)
-    ORDER BY product_name ASC
-		`)
-
-	// Set the query location to US.
-	q.Location = "US"
-
-	q.Parameters = []bigquery.QueryParameter{
-		{
-			Name:  "noActiveChannel",
-			Value: noActiveChannel,
-		},
-	}
-
-	// Run the BigQuery query.
-	job, err := q.Run(ctx)
+	q, err := productsQuery(cadence, noActiveChannel).Query(client)
 	if err != nil {
-		return nil, fmt.Errorf("Error running query: %v", err)
-	}
-
-	// Wait for the query job to complete.
-	status, err := job.Wait(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("Job completed with error: %v", status.Err())
-	}
-	if err := status.Err(); err != nil {
-		return nil, fmt.Errorf("Job completed with error: %v", status.Err())
+		return nil, err
 	}
 
-	// Read the query results.
-	it, err := job.Read(ctx)
+	// Run the BigQuery query, retrying transient errors, and read its
+	// results, or those of the same query run before in this run.
+	rows, err := querycache.Read[Product](ctx, q)
 	if err != nil {
-		return nil, fmt.Errorf("Error iterating over results: %v", err)
+		return nil, err
 	}
 
-	// Initialize a slice to store the retrieved products.
-	var products []Product
-
-	// Iterate over the query results and populate the products slice.
-	rowCount := 0
-	for {
-		var row []bigquery.Value
-		err := it.Next(&row)
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			return nil, fmt.Errorf("Error reading row: %v", err)
-		}
-
-		// Extract the product name from the row.
-		product := Product{
-			Product: getStringValue(row[0]),
-		}
-
-		// Append the product to the products slice.
-		products = append(products, product)
-		rowCount++
-
-	}
+	// Copy the products, as the rows read are shared with later reads.
+	products := slices.Clone(rows)
+	rowCount := len(products)
 
 	// Print the number of products found for informational purposes.
 	fmt.Printf("Release note types for unspecified channels: %v", noActiveChannel)
@@ -202,15 +111,79 @@ func GetProducts(ctx context.Context, projectID string, noActiveChannel []string
 	return products, nil
 }
 
-// getStringValue returns the string value of a bigquery.Value.
-func getStringValue(v bigquery.Value) string {
-	if v == nil {
-		return "NULL"
-	}
-	return v.(string)
+// productsQuery selects the products with release notes of the given types
+// published within the last cadence days, with their note counts.
+func productsQuery(cadence string, types []string) *query.Builder {
+	return query.Select(
+		"product_name AS product",
+		"COUNT(*) AS note_count",
+		"COUNTIF(release_note_type = 'BREAKING_CHANGE') AS breaking_changes",
+		"COUNTIF(release_note_type = 'SECURITY_BULLETIN') AS security_bulletins",
+		"COUNTIF(release_note_type = 'DEPRECATION') AS deprecations",
+	).
+		From(source.Table()).
+		Window(cadence).
+		Types(types).
+		GroupBy("product_name").
+		OrderBy("product_name ASC")
 }
 
 // Product represents a Google Cloud product with release notes.
 type Product struct {
-	Product string `bigquery:"product"`
+	Product           string `bigquery:"product"`
+	NoteCount         int    `bigquery:"note_count"`
+	BreakingChanges   int    `bigquery:"breaking_changes"`
+	SecurityBulletins int    `bigquery:"security_bulletins"`
+	Deprecations      int    `bigquery:"deprecations"`
+}
+
+// GetTypeCounts counts the release notes of each of the given release note
+// types published for the given products within the specified cadence, most
+// frequent type first.
+func GetTypeCounts(ctx context.Context, projectID string, releaseNoteTypes []string, productNames []string, cadence string) ([]TypeCount, error) {
+	if notes, ok := source.FileNotes(cadence); ok {
+		return fileTypeCounts(notes, releaseNoteTypes, productNames), nil
+	}
+
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating BQ client: %v", err)
+	}
+	defer client.Close()
+
+	// Define the BigQuery query counting release notes per release note type.
+	q, err := query.Select("release_note_type", "COUNT(*) AS note_count").
+		From(source.Table()).
+		Window(cadence).
+		Types(releaseNoteTypes).
+		Products(productNames).
+		GroupBy("release_note_type").
+		OrderBy("note_count DESC", "release_note_type ASC").
+		Query(client)
+	if err != nil {
+		return nil, err
+	}
+
+	// Run the BigQuery query, retrying transient errors, and read its
+	// results, or those of the same query run before in this run.
+	counts, err := querycache.Read[TypeCount](ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	return slices.Clone(counts), nil
+}
+
+// TypeCount is the number of release notes of one release note type.
+type TypeCount struct {
+	ReleaseNoteType string `bigquery:"release_note_type"`
+	Count           int    `bigquery:"note_count"`
+}
+
+// Names returns the names of the products.
+func Names(products []Product) []string {
+	names := make([]string, 0, len(products))
+	for _, p := range products {
+		names = append(names, p.Product)
+	}
+	return names
 }
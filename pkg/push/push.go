@@ -0,0 +1,56 @@
+package push
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"google.golang.org/api/fcm/v1"
+)
+
+// Notification is a compact release alert: the product as title and the
+// headline of its summary as body. Data is delivered to the app as is.
+type Notification struct {
+	Title string
+	Body  string
+	Data  map[string]string
+}
+
+// FCM pushes notifications to a Firebase Cloud Messaging topic, which a
+// companion app or dashboard subscribes to.
+type FCM struct {
+	// ProjectID is the Firebase project.
+	ProjectID string
+	// Topic is the name of the topic, e.g. gcp-releases.
+	Topic string
+
+	once sync.Once
+	svc  *fcm.Service
+	err  error
+}
+
+// Send pushes n to the topic and returns the name of the sent message.
+func (f *FCM) Send(ctx context.Context, n Notification) (status string, err error) {
+	f.once.Do(func() {
+		f.svc, f.err = fcm.NewService(ctx)
+	})
+	if f.err != nil {
+		return "", fmt.Errorf("Error creating Firebase Cloud Messaging client: %v", f.err)
+	}
+
+	msg := &fcm.Message{
+		Topic:        f.Topic,
+		Notification: &fcm.Notification{Title: n.Title, Body: n.Body},
+		Data:         n.Data,
+	}
+	sent, err := f.svc.Projects.Messages.Send("projects/"+f.ProjectID, &fcm.SendMessageRequest{Message: msg}).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("Error sending push notification: %v", err)
+	}
+	return "200 OK " + sent.Name, nil
+}
+
+// Target identifies the topic in run reports.
+func (f *FCM) Target() string {
+	return "https://fcm.googleapis.com/projects/" + f.ProjectID + "/topics/" + f.Topic
+}
@@ -0,0 +1,124 @@
+package query
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/bigquery"
+)
+
+// Builder assembles a SELECT over the release notes table from its parts,
+// passing every value as a query parameter, so new filters are added as
+// calls rather than by concatenating SQL.
+type Builder struct {
+	columns []string
+	table   string
+	where   []string
+	params  []bigquery.QueryParameter
+	groupBy []string
+	orderBy []string
+	limit   int
+	err     error
+}
+
+// Select starts a query of the columns, each an expression with an
+// optional alias, e.g. "COUNT(*) AS note_count".
+func Select(columns ...string) *Builder {
+	return &Builder{columns: columns}
+}
+
+// From sets the table queried, e.g. source.Table().
+func (b *Builder) From(table string) *Builder {
+	b.table = table
+	return b
+}
+
+// Where adds a condition, combined with the others by AND, and the
+// parameters it references.
+func (b *Builder) Where(condition string, params ...bigquery.QueryParameter) *Builder {
+	b.where = append(b.where, condition)
+	b.params = append(b.params, params...)
+	return b
+}
+
+// Window keeps the release notes published within the last cadence days.
+func (b *Builder) Window(cadence string) *Builder {
+	days, err := strconv.Atoi(cadence)
+	if err != nil || days < 0 {
+		b.err = fmt.Errorf("invalid cadence %q, expected a number of days", cadence)
+		return b
+	}
+	return b.Where("published_at >= DATE_SUB(CURRENT_DATE(), INTERVAL @days DAY)", bigquery.QueryParameter{Name: "days", Value: days})
+}
+
+// Types keeps the release notes of the given types.
+func (b *Builder) Types(types []string) *Builder {
+	return b.Where("release_note_type IN UNNEST(@types)", bigquery.QueryParameter{Name: "types", Value: types})
+}
+
+// Products keeps the release notes of the given products.
+func (b *Builder) Products(products []string) *Builder {
+	return b.Where("product_name IN UNNEST(@products)", bigquery.QueryParameter{Name: "products", Value: products})
+}
+
+// Product keeps the release notes of one product.
+func (b *Builder) Product(product string) *Builder {
+	return b.Where("product_name = @product", bigquery.QueryParameter{Name: "product", Value: product})
+}
+
+// GroupBy groups the rows by the columns.
+func (b *Builder) GroupBy(columns ...string) *Builder {
+	b.groupBy = append(b.groupBy, columns...)
+	return b
+}
+
+// OrderBy sorts the rows by the terms, e.g. "note_count DESC".
+func (b *Builder) OrderBy(terms ...string) *Builder {
+	b.orderBy = append(b.orderBy, terms...)
+	return b
+}
+
+// Limit returns at most n rows; zero or less returns all of them.
+func (b *Builder) Limit(n int) *Builder {
+	b.limit = n
+	return b
+}
+
+// SQL returns the text of the query and its parameters.
+func (b *Builder) SQL() (string, []bigquery.QueryParameter, error) {
+	if b.err != nil {
+		return "", nil, b.err
+	}
+	if len(b.columns) == 0 || b.table == "" {
+		return "", nil, fmt.Errorf("query needs columns and a table")
+	}
+	var sql strings.Builder
+	sql.WriteString("SELECT\n\t" + strings.Join(b.columns, ",\n\t") + "\nFROM " + b.table)
+	if len(b.where) > 0 {
+		sql.WriteString("\nWHERE\n\t" + strings.Join(b.where, "\n\tAND "))
+	}
+	if len(b.groupBy) > 0 {
+		sql.WriteString("\nGROUP BY " + strings.Join(b.groupBy, ", "))
+	}
+	if len(b.orderBy) > 0 {
+		sql.WriteString("\nORDER BY " + strings.Join(b.orderBy, ", "))
+	}
+	if b.limit > 0 {
+		sql.WriteString("\nLIMIT " + strconv.Itoa(b.limit))
+	}
+	return sql.String(), b.params, nil
+}
+
+// Query returns the query to run with client, in the US location of the
+// public release notes dataset.
+func (b *Builder) Query(client *bigquery.Client) (*bigquery.Query, error) {
+	sql, params, err := b.SQL()
+	if err != nil {
+		return nil, err
+	}
+	q := client.Query(sql)
+	q.Parameters = params
+	q.Location = "US"
+	return q, nil
+}
@@ -0,0 +1,110 @@
+package querycache
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/mpolski/gcp-release-digest/pkg/bqretry"
+	"google.golang.org/api/iterator"
+)
+
+// Cache keeps the rows of the queries run during one digest run, so
+// channels covering the same release note types or products read them from
+// memory instead of running the same query again.
+type Cache struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+// entry holds the rows of a query once done is closed. Concurrent reads of
+// the same query wait for the first one instead of running it too.
+type entry struct {
+	done chan struct{}
+	rows any
+	err  error
+}
+
+type cacheKey struct{}
+
+// WithCache returns a context with an empty cache, used by Read for the
+// queries run with it.
+func WithCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, cacheKey{}, &Cache{entries: make(map[string]*entry)})
+}
+
+// key identifies a query by its text, location and parameters.
+func key(q *bigquery.Query) (string, error) {
+	params, err := json.Marshal(q.Parameters)
+	if err != nil {
+		return "", fmt.Errorf("Error encoding query parameters: %v", err)
+	}
+	return q.Location + "\x00" + q.Q + "\x00" + string(params), nil
+}
+
+// Read runs the query, retrying transient errors, and loads its rows into
+// values of T. With a cache in the context, the rows of a query run before
+// with the same text and parameters are returned instead; a failed query is
+// not kept, so it runs again when next asked for. The returned slice is
+// shared with later reads and must not be modified.
+func Read[T any](ctx context.Context, q *bigquery.Query) ([]T, error) {
+	c, _ := ctx.Value(cacheKey{}).(*Cache)
+	if c == nil {
+		return read[T](ctx, q)
+	}
+	k, err := key(q)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if e, ok := c.entries[k]; ok {
+		c.mu.Unlock()
+		<-e.done
+		if e.err == nil {
+			if rows, ok := e.rows.([]T); ok {
+				fmt.Println("Serving query results from the run cache")
+				return rows, nil
+			}
+		}
+		// A failed query, or the same one read into other values, is run
+		// again without the cache.
+		return read[T](ctx, q)
+	}
+	e := &entry{done: make(chan struct{})}
+	c.entries[k] = e
+	c.mu.Unlock()
+
+	rows, err := read[T](ctx, q)
+	e.rows, e.err = rows, err
+	if err != nil {
+		c.mu.Lock()
+		delete(c.entries, k)
+		c.mu.Unlock()
+	}
+	close(e.done)
+	return rows, err
+}
+
+// read runs the query and loads all of its rows.
+func read[T any](ctx context.Context, q *bigquery.Query) ([]T, error) {
+	it, err := bqretry.Read(ctx, q)
+	if err != nil {
+		return nil, err
+	}
+	var rows []T
+	for {
+		var row T
+		err := it.Next(&row)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Error reading row: %v", err)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
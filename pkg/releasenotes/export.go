@@ -0,0 +1,77 @@
+package releasenotes
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Markdown renders the release notes of a product as a Markdown document with
+// a section per release note type, for readers wanting the full detail
+// behind a summary.
+func Markdown(product string, cadence int, releaseNotes []ReleaseNote) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n%d release notes of the last %d days.\n", product, len(releaseNotes), cadence)
+	for _, g := range GroupByType(releaseNotes) {
+		fmt.Fprintf(&b, "\n## %s\n\n", TypeTitle(g.ReleaseNoteType))
+		for _, rn := range g.ReleaseNotes {
+			if !rn.PublishedAt.IsZero() {
+				fmt.Fprintf(&b, "_%s_\n\n", rn.PublishedAt.Format("2006-01-02"))
+			}
+			fmt.Fprintf(&b, "%s\n\n---\n\n", strings.TrimSpace(rn.Description))
+		}
+	}
+	return b.String()
+}
+
+// Export collects the release notes of the products of a channel for
+// analysts, in the columns NOTES_FILE reads and their impact.
+type Export struct {
+	rows []exportRow
+}
+
+// exportRow is a release note of a product in an export.
+type exportRow struct {
+	Product string `json:"product_name"`
+	ReleaseNote
+}
+
+// Add adds the release notes of a product.
+func (e *Export) Add(product string, releaseNotes []ReleaseNote) {
+	for _, rn := range releaseNotes {
+		e.rows = append(e.rows, exportRow{Product: product, ReleaseNote: rn})
+	}
+}
+
+// Len returns the number of release notes added.
+func (e *Export) Len() int {
+	return len(e.rows)
+}
+
+// CSV renders the release notes as CSV with a header row.
+func (e *Export) CSV() []byte {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	w.Write([]string{"product_name", "release_note_type", "published_at", "description", "impact"})
+	for _, r := range e.rows {
+		published := ""
+		if !r.PublishedAt.IsZero() {
+			published = r.PublishedAt.Format("2006-01-02")
+		}
+		w.Write([]string{r.Product, r.ReleaseNoteType, published, r.Description, r.Impact})
+	}
+	w.Flush()
+	return b.Bytes()
+}
+
+// JSON renders the release notes as newline-delimited JSON objects.
+func (e *Export) JSON() []byte {
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	for _, r := range e.rows {
+		enc.Encode(r)
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,72 @@
+package releasenotes
+
+import (
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/mpolski/gcp-release-digest/pkg/source"
+)
+
+// fileReleaseNotes selects the release notes of a product of the given types,
+// like the release note queries, from the notes of a release notes file:
+// identical notes once with their latest date, ordered, limited and
+// truncated as set in opts.
+func fileReleaseNotes(notes []source.Note, product string, releaseNoteTypes []string, opts Options) []ReleaseNote {
+	type key struct{ releaseNoteType, description string }
+	index := make(map[key]int)
+	var releaseNotes []ReleaseNote
+	for _, n := range notes {
+		if n.ProductName != product || !slices.Contains(releaseNoteTypes, n.ReleaseNoteType) {
+			continue
+		}
+		k := key{n.ReleaseNoteType, n.Description}
+		if i, seen := index[k]; seen {
+			if n.Published().After(releaseNotes[i].PublishedAt) {
+				releaseNotes[i].PublishedAt = n.Published()
+			}
+			continue
+		}
+		index[k] = len(releaseNotes)
+		releaseNotes = append(releaseNotes, ReleaseNote{ReleaseNoteType: n.ReleaseNoteType, Description: n.Description, PublishedAt: n.Published()})
+	}
+
+	// Release notes for GKE release channels or versions not in use may be
+	// dropped.
+	kept := releaseNotes[:0]
+	for _, rn := range releaseNotes {
+		if rn, keep := opts.relevance(product, rn); keep {
+			kept = append(kept, rn)
+		}
+	}
+	releaseNotes = kept
+
+	// Order by the column of opts.OrderBy, as validated by ValidateOrderBy.
+	column, desc := "release_note_type", false
+	if fields := strings.Fields(opts.OrderBy); len(fields) > 0 && ValidateOrderBy(opts.OrderBy) == nil {
+		column = strings.ToLower(fields[0])
+		desc = len(fields) == 2 && strings.EqualFold(fields[1], "DESC")
+	}
+	sort.SliceStable(releaseNotes, func(i, j int) bool {
+		a, b := releaseNotes[i], releaseNotes[j]
+		if desc {
+			a, b = b, a
+		}
+		switch column {
+		case "published_at":
+			return a.PublishedAt.Before(b.PublishedAt)
+		case "description":
+			return a.Description < b.Description
+		}
+		return a.ReleaseNoteType < b.ReleaseNoteType
+	})
+
+	if len(releaseNotes) > opts.limit() {
+		releaseNotes = releaseNotes[:opts.limit()]
+	}
+	for i := range releaseNotes {
+		releaseNotes[i].Description = truncate(releaseNotes[i].Description, opts.MaxChars)
+	}
+	SortByTypePriority(releaseNotes, opts.TypePriority)
+	return releaseNotes
+}
@@ -0,0 +1,95 @@
+package releasenotes
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// GKEProduct is the product name of the Google Kubernetes Engine release
+// notes.
+const GKEProduct = "Google Kubernetes Engine"
+
+// GKEReleaseChannels are the GKE release channels, from the newest versions
+// to the longest supported ones.
+var GKEReleaseChannels = []string{"Rapid", "Regular", "Stable", "Extended"}
+
+var (
+	gkeChannel = regexp.MustCompile(`(?i)\b(rapid|regular|stable|extended)\s+(?:release\s+)?channels?\b`)
+	gkeVersion = regexp.MustCompile(`\b\d+\.\d+\.\d+-gke\.\d+\b`)
+)
+
+// GKERelease is what a GKE release note refers to: the release channels and
+// the GKE versions it mentions.
+type GKERelease struct {
+	Channels []string
+	Versions []string
+}
+
+// ParseGKE reads the release channels, in the order of GKEReleaseChannels,
+// and the versions, in the order they appear, mentioned by the description
+// of a GKE release note, e.g. "available in the Rapid channel: 1.31.1-gke.1146000".
+func ParseGKE(description string) GKERelease {
+	var r GKERelease
+	mentioned := make(map[string]bool)
+	for _, m := range gkeChannel.FindAllStringSubmatch(description, -1) {
+		mentioned[strings.ToLower(m[1])] = true
+	}
+	for _, c := range GKEReleaseChannels {
+		if mentioned[strings.ToLower(c)] {
+			r.Channels = append(r.Channels, c)
+		}
+	}
+	for _, v := range gkeVersion.FindAllString(description, -1) {
+		if !slices.Contains(r.Versions, v) {
+			r.Versions = append(r.Versions, v)
+		}
+	}
+	return r
+}
+
+// ParseGKEChannels reads a comma separated list of GKE release channels,
+// e.g. "Stable,Regular", in any case.
+func ParseGKEChannels(spec string) ([]string, error) {
+	var channels []string
+	for _, c := range strings.Split(spec, ",") {
+		if c = strings.TrimSpace(c); c == "" {
+			continue
+		}
+		i := slices.IndexFunc(GKEReleaseChannels, func(known string) bool { return strings.EqualFold(known, c) })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown GKE release channel %q, expected one of %s", c, strings.Join(GKEReleaseChannels, ", "))
+		}
+		channels = append(channels, GKEReleaseChannels[i])
+	}
+	return channels, nil
+}
+
+// gke applies the GKE release channels of opts to a release note of product.
+// It reports false for a GKE note that only mentions channels not in
+// opts.GKEChannels, unless opts.GKEAnnotate is set, which instead prefixes
+// every GKE note mentioning channels with them, its versions and whether
+// the channels are run.
+func (o Options) gke(product string, rn ReleaseNote) (ReleaseNote, bool) {
+	if product != GKEProduct || len(o.GKEChannels) == 0 {
+		return rn, true
+	}
+	r := ParseGKE(rn.Description)
+	if len(r.Channels) == 0 {
+		return rn, true
+	}
+	ours := slices.ContainsFunc(r.Channels, func(c string) bool { return slices.Contains(o.GKEChannels, c) })
+	if !o.GKEAnnotate {
+		return rn, ours
+	}
+	annotation := "GKE channels: " + strings.Join(r.Channels, ", ")
+	if len(r.Versions) > 0 {
+		annotation += "; versions: " + strings.Join(r.Versions, ", ")
+	}
+	if !ours {
+		annotation += "; not a channel of our clusters"
+	}
+	rn.Description = "[" + annotation + "] " + rn.Description
+	return rn, true
+}
@@ -0,0 +1,122 @@
+package releasenotes
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// docsBaseURL is the root of the Google Cloud documentation.
+const docsBaseURL = "https://cloud.google.com/"
+
+// AllNotesURL is the page of the release notes of all products, linked for
+// products without a page of their own.
+const AllNotesURL = docsBaseURL + "release-notes"
+
+// docsPaths maps normalized product names to the path of their release notes
+// page below docsBaseURL.
+var docsPaths = map[string]string{
+	"alloydb for postgresql":         "alloydb/docs/release-notes",
+	"armor":                          "armor/docs/release-notes",
+	"artifact registry":              "artifact-registry/docs/release-notes",
+	"batch":                          "batch/docs/release-notes",
+	"bigquery":                       "bigquery/docs/release-notes",
+	"bigtable":                       "bigtable/docs/release-notes",
+	"build":                          "build/docs/release-notes",
+	"composer":                       "composer/docs/release-notes",
+	"compute engine":                 "compute/docs/release-notes",
+	"data fusion":                    "data-fusion/docs/release-notes",
+	"dataflow":                       "dataflow/docs/release-notes",
+	"dataplex":                       "dataplex/docs/release-notes",
+	"dataproc":                       "dataproc/docs/release-notes",
+	"deploy":                         "deploy/docs/release-notes",
+	"dns":                            "dns/docs/release-notes",
+	"eventarc":                       "eventarc/docs/release-notes",
+	"filestore":                      "filestore/docs/release-notes",
+	"firestore":                      "firestore/docs/release-notes",
+	"functions":                      "functions/docs/release-notes",
+	"identity and access management": "iam/docs/release-notes",
+	"key management service":         "kms/docs/release-notes",
+	"kubernetes engine":              "kubernetes-engine/docs/release-notes",
+	"load balancing":                 "load-balancing/docs/release-notes",
+	"logging":                        "logging/docs/release-notes",
+	"looker":                         "looker/docs/release-notes",
+	"memorystore for redis":          "memorystore/docs/redis/release-notes",
+	"monitoring":                     "monitoring/docs/release-notes",
+	"pub sub":                        "pubsub/docs/release-notes",
+	"run":                            "run/docs/release-notes",
+	"scheduler":                      "scheduler/docs/release-notes",
+	"secret manager":                 "secret-manager/docs/release-notes",
+	"security command center":        "security-command-center/docs/release-notes",
+	"spanner":                        "spanner/docs/release-notes",
+	"sql":                            "sql/docs/release-notes",
+	"storage":                        "storage/docs/release-notes",
+	"tasks":                          "tasks/docs/release-notes",
+	"vertex ai":                      "vertex-ai/docs/release-notes",
+	"virtual private cloud":          "vpc/docs/release-notes",
+	"vmware engine":                  "vmware-engine/docs/release-notes",
+	"workflows":                      "workflows/docs/release-notes",
+	"workstations":                   "workstations/docs/release-notes",
+}
+
+var (
+	nonWord = regexp.MustCompile(`[^a-z0-9]+`)
+
+	// docsCache remembers the page found for each product name.
+	docsCache sync.Map
+)
+
+// Normalize reduces a product name to lowercase words separated by spaces,
+// without the "Google" and "Cloud" prefixes, e.g. "pub sub" for "Cloud
+// Pub/Sub".
+func Normalize(product string) string {
+	name := strings.TrimSpace(nonWord.ReplaceAllString(strings.ToLower(product), " "))
+	for _, prefix := range []string{"google ", "cloud "} {
+		name = strings.TrimPrefix(name, prefix)
+	}
+	return name
+}
+
+// docsPath returns the path of the release notes page of a product, matching
+// its name exactly or else the longest known name it starts with, e.g.
+// BigQuery's page for "BigQuery BI Engine", or an empty string.
+func docsPath(product string) string {
+	if path, ok := docsCache.Load(product); ok {
+		return path.(string)
+	}
+	name := Normalize(product)
+	path, ok := docsPaths[name]
+	if !ok {
+		best := ""
+		for known := range docsPaths {
+			if len(known) > len(best) && strings.HasPrefix(name, known+" ") {
+				best = known
+			}
+		}
+		path = docsPaths[best]
+	}
+	docsCache.Store(product, path)
+	return path
+}
+
+// URL returns the documentation page of a product's release notes, anchored
+// at the day of the newest of its release notes when they are dated, or
+// AllNotesURL for products without a known page.
+func URL(product string, releaseNotes []ReleaseNote) string {
+	path := docsPath(product)
+	if path == "" {
+		return AllNotesURL
+	}
+	url := docsBaseURL + path
+	var newest ReleaseNote
+	for _, rn := range releaseNotes {
+		if rn.PublishedAt.After(newest.PublishedAt) {
+			newest = rn
+		}
+	}
+	if !newest.PublishedAt.IsZero() {
+		// The pages have an anchor per day, e.g. #June_03_2024.
+		url += "#" + newest.PublishedAt.Format("January_02_2006")
+	}
+	return url
+}
@@ -0,0 +1,139 @@
+package releasenotes
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Pin is a version of a product its users run, e.g. PostgreSQL 14 of Cloud
+// SQL or 1.29 of Google Kubernetes Engine.
+type Pin struct {
+	// Name names what is versioned, e.g. "PostgreSQL", or is empty for
+	// versions of the product itself.
+	Name    string
+	Version string
+}
+
+func (p Pin) String() string {
+	return strings.TrimSpace(p.Name + " " + p.Version)
+}
+
+var (
+	pinVersion  = regexp.MustCompile(`^v?(\d+(?:\.\d+)*)$`)
+	bareVersion = regexp.MustCompile(`\bv?(\d+(?:\.\d+)+)`)
+)
+
+// ParsePins reads the pinned versions of products separated by semicolons,
+// each in the form product=pins with the pins separated by commas, and each
+// pin a version optionally preceded by a name, e.g.
+// "Cloud SQL=PostgreSQL 14, MySQL 8.0; Google Kubernetes Engine=1.29".
+func ParsePins(spec string) (map[string][]Pin, error) {
+	pins := make(map[string][]Pin)
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		product, list, ok := strings.Cut(entry, "=")
+		product = strings.TrimSpace(product)
+		if !ok || product == "" {
+			return nil, fmt.Errorf("version pin %q: expected product=pins", entry)
+		}
+		for _, text := range strings.Split(list, ",") {
+			fields := strings.Fields(text)
+			if len(fields) == 0 {
+				continue
+			}
+			m := pinVersion.FindStringSubmatch(fields[len(fields)-1])
+			if m == nil {
+				return nil, fmt.Errorf("version pin %q of %s: expected a version, e.g. 14 or 1.29", strings.TrimSpace(text), product)
+			}
+			pins[product] = append(pins[product], Pin{Name: strings.Join(fields[:len(fields)-1], " "), Version: m[1]})
+		}
+		if len(pins[product]) == 0 {
+			return nil, fmt.Errorf("version pin %q: no versions", entry)
+		}
+	}
+	return pins, nil
+}
+
+// mentions returns the versions of what the pin versions that a description
+// mentions, e.g. "15" in "upgrade to PostgreSQL 15" for the pin PostgreSQL
+// 14. Pins without a name find versions of at least two components with the
+// same major version, e.g. "1.30.2" in "1.30.2-gke.1000" for the pin 1.29.
+func (p Pin) mentions(description string) []string {
+	var versions []string
+	if p.Name != "" {
+		named := regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(p.Name) + `\s+(?:version\s+)?v?(\d+(?:\.\d+)*)`)
+		for _, m := range named.FindAllStringSubmatch(description, -1) {
+			versions = append(versions, m[1])
+		}
+		return versions
+	}
+	major, _, _ := strings.Cut(p.Version, ".")
+	for _, m := range bareVersion.FindAllStringSubmatch(description, -1) {
+		if strings.HasPrefix(m[1], major+".") {
+			versions = append(versions, m[1])
+		}
+	}
+	return versions
+}
+
+// matches reports whether a mentioned version is the pinned one or one of
+// its minor or patch versions, e.g. 1.29.4 for the pin 1.29.
+func (p Pin) matches(version string) bool {
+	return version == p.Version || strings.HasPrefix(version, p.Version+".")
+}
+
+// PinRelevance extracts the versions of the pins mentioned by the
+// description of a release note. It reports whether it mentions any, and if
+// so whether one of them is pinned; notes mentioning none concern every
+// version.
+func PinRelevance(pins []Pin, description string) (mentioned, relevant bool) {
+	for _, p := range pins {
+		for _, v := range p.mentions(description) {
+			mentioned = true
+			relevant = relevant || p.matches(v)
+		}
+	}
+	return mentioned, relevant
+}
+
+// relevance applies the GKE release channels and the pinned versions of
+// opts to a release note of product, reporting false if it is dropped.
+func (o Options) relevance(product string, rn ReleaseNote) (ReleaseNote, bool) {
+	rn, keep := o.gke(product, rn)
+	if !keep {
+		return rn, false
+	}
+	return o.pins(product, rn)
+}
+
+// pins applies the pinned versions of opts to a release note of product. It
+// reports false for a note only mentioning versions that are not pinned,
+// unless opts.PinLabel is set, which instead labels every note mentioning
+// versions as concerning the pinned ones or not.
+func (o Options) pins(product string, rn ReleaseNote) (ReleaseNote, bool) {
+	pins := o.Pins[product]
+	if len(pins) == 0 {
+		return rn, true
+	}
+	mentioned, relevant := PinRelevance(pins, rn.Description)
+	if !mentioned {
+		return rn, true
+	}
+	if !o.PinLabel {
+		return rn, relevant
+	}
+	names := make([]string, len(pins))
+	for i, p := range pins {
+		names[i] = p.String()
+	}
+	label := "Concerns our pinned versions: "
+	if !relevant {
+		label = "Does not concern our pinned versions: "
+	}
+	rn.Description = "[" + label + strings.Join(names, ", ") + "] " + rn.Description
+	return rn, true
+}
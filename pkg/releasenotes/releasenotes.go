@@ -3,9 +3,15 @@ package releasenotes
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"cloud.google.com/go/bigquery"
-	"google.golang.org/api/iterator"
+	"github.com/mpolski/gcp-release-digest/pkg/query"
+	"github.com/mpolski/gcp-release-digest/pkg/querycache"
+	"github.com/mpolski/gcp-release-digest/pkg/source"
 )
 
 // GetReleaseNotes retrieves release notes for a specific product from BigQuery's
@@ -15,9 +21,15 @@ import (
 // published within the specified time frame. The query uses parameterized
 // values for the product name and cadence to ensure safe and efficient execution.
 //
+// The number of rows, their ordering and the maximum length of each description
+// are controlled by opts.
+//
 // The function returns a slice of ReleaseNote structs containing the release
 // note type and description, or an error if any occurs during the process.
-func GetReleaseNotes(ctx context.Context, projectID string, product string, noActiveChannel []string, cadence string) ([]ReleaseNote, error) {
+func GetReleaseNotes(ctx context.Context, projectID string, product string, noActiveChannel []string, cadence string, opts Options) ([]ReleaseNote, error) {
+	if notes, ok := source.FileNotes(cadence); ok {
+		return fileReleaseNotes(notes, product, noActiveChannel, opts), nil
+	}
 
 	// Create a BigQuery client to interact with the BigQuery service.
 	client, err := bigquery.NewClient(ctx, projectID)
@@ -27,49 +39,14 @@ func GetReleaseNotes(ctx context.Context, projectID string, product string, noAc
 	defer client.Close() // Close the client when the function exits.
 
 	// Define the BigQuery query to retrieve release notes for the specified product and specific release note
-	q := client.Query(`
-	SELECT
-		release_note_type,
-		description,
-	FROM bigquery-public-data.google_cloud_release_notes.release_notes
-	WHERE
-		published_at >= DATE_SUB(CURRENT_DATE(), INTERVAL ` + cadence + ` DAY)
-		AND product_name = @product
-		AND release_note_type IN UNNEST(@noActiveChannel)
-	GROUP BY release_note_type, description
-	ORDER BY release_note_type ASC
-	LIMIT 1000;
-		`)
-
-	// Set the query parameters for the product name.
-	q.Parameters = []bigquery.QueryParameter{
-		{
-			Name:  "product",
-			Value: product,
-		},
-		{
-			Name:  "noActiveChannel",
-			Value: noActiveChannel,
-		},
-	}
-	// Set the query location to US.
-	q.Location = "US"
-
-	// Run the BigQuery query and wait for it to complete.
-	job, err := q.Run(ctx)
+	q, err := releaseNotesQuery(cadence, product, noActiveChannel, opts).Query(client)
 	if err != nil {
 		return nil, err
 	}
-	status, err := job.Wait(ctx)
-	if err != nil {
-		return nil, status.Err()
-	}
-	if err := status.Err(); err != nil {
-		return nil, status.Err()
-	}
 
-	// Read the query results.
-	it, err := job.Read(ctx)
+	// Run the BigQuery query, retrying transient errors, and read its
+	// results, or those of the same query run before in this run.
+	rows, err := querycache.Read[ReleaseNote](ctx, q)
 	if err != nil {
 		return nil, err
 	}
@@ -77,29 +54,25 @@ func GetReleaseNotes(ctx context.Context, projectID string, product string, noAc
 	// Initialize a slice to store the retrieved release notes.
 	var releaseNotes []ReleaseNote
 
-	// Iterate over the query results and populate the releaseNotes slice.
+	// Iterate over the rows and populate the releaseNotes slice.
 	rowCount := 0
-	for {
-		var row []bigquery.Value
-		err := it.Next(&row)
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			return nil, err
-		}
-
-		// Extract the release note type and description from the row.
-		releaseNote := ReleaseNote{
-			ReleaseNoteType: getStringValue(row[0]),
-			Description:     getStringValue(row[1]),
+	for _, releaseNote := range rows {
+		// Release notes for GKE release channels or versions not in use
+		// may be dropped.
+		var keep bool
+		if releaseNote, keep = opts.relevance(product, releaseNote); !keep {
+			continue
 		}
+		releaseNote.Description = truncate(releaseNote.Description, opts.MaxChars)
 
 		// Append the release note to the releaseNotes slice.
 		releaseNotes = append(releaseNotes, releaseNote)
 		rowCount++
 	}
 
+	// Present the release note types in the configured priority order.
+	SortByTypePriority(releaseNotes, opts.TypePriority)
+
 	// Print the number of release notes found for informational purposes.
 	if rowCount > 1 {
 		fmt.Printf("\nFound %d entires for: %s\n", rowCount, product)
@@ -112,7 +85,13 @@ func GetReleaseNotes(ctx context.Context, projectID string, product string, noAc
 
 }
 
-func GetReleaseNotesbyType(ctx context.Context, projectID string, product string, releaseNotebyType string, cadence string) ([]ReleaseNote, error) {
+// GetReleaseNotesbyType retrieves release notes of a single release note type
+// for a specific product within the specified cadence, honouring opts the same
+// way as GetReleaseNotes.
+func GetReleaseNotesbyType(ctx context.Context, projectID string, product string, releaseNotebyType string, cadence string, opts Options) ([]ReleaseNote, error) {
+	if notes, ok := source.FileNotes(cadence); ok {
+		return fileReleaseNotes(notes, product, []string{releaseNotebyType}, opts), nil
+	}
 
 	// Get RELEASE_NOTE_TYPE env var to filer release notes only to a specific type
 	//	releaseNoteType := ("BREAKING_CHANGE")
@@ -125,50 +104,14 @@ func GetReleaseNotesbyType(ctx context.Context, projectID string, product string
 	defer client.Close() // Close the client when the function exits.
 
 	// Define the BigQuery query to retrieve release notes for the specified product.
-	q := client.Query(`
-	SELECT
-		release_note_type,
-		description,
-	FROM bigquery-public-data.google_cloud_release_notes.release_notes
-	WHERE
-		published_at >= DATE_SUB(CURRENT_DATE(), INTERVAL ` + cadence + ` DAY)
-		AND product_name = @product
-		AND release_note_type = @release_note_type
-	GROUP BY release_note_type, description
-	ORDER BY release_note_type ASC
-	LIMIT 1000;
-		`)
-
-	// Set the query parameters for the product name.
-	q.Parameters = []bigquery.QueryParameter{
-		{
-			Name:  "release_note_type",
-			Value: releaseNotebyType,
-		},
-		{
-			Name:  "product",
-			Value: product,
-		},
-	}
-
-	// Set the query location to US.
-	q.Location = "US"
-
-	// Run the BigQuery query and wait for it to complete.
-	job, err := q.Run(ctx)
+	q, err := releaseNotesQuery(cadence, product, []string{releaseNotebyType}, opts).Query(client)
 	if err != nil {
 		return nil, err
 	}
-	status, err := job.Wait(ctx)
-	if err != nil {
-		return nil, status.Err()
-	}
-	if err := status.Err(); err != nil {
-		return nil, status.Err()
-	}
 
-	// Read the query results.
-	it, err := job.Read(ctx)
+	// Run the BigQuery query, retrying transient errors, and read its
+	// results, or those of the same query run before in this run.
+	rows, err := querycache.Read[ReleaseNote](ctx, q)
 	if err != nil {
 		return nil, err
 	}
@@ -176,23 +119,16 @@ func GetReleaseNotesbyType(ctx context.Context, projectID string, product string
 	// Initialize a slice to store the retrieved release notes.
 	var releaseNotes []ReleaseNote
 
-	// Iterate over the query results and populate the releaseNotes slice.
+	// Iterate over the rows and populate the releaseNotes slice.
 	rowCount := 0
-	for {
-		var row []bigquery.Value
-		err := it.Next(&row)
-		if err == iterator.Done {
-			break
-		}
-		if err != nil {
-			return nil, err
-		}
-
-		// Extract the release note type and description from the row.
-		releaseNote := ReleaseNote{
-			ReleaseNoteType: getStringValue(row[0]),
-			Description:     getStringValue(row[1]),
+	for _, releaseNote := range rows {
+		// Release notes for GKE release channels or versions not in use
+		// may be dropped.
+		var keep bool
+		if releaseNote, keep = opts.relevance(product, releaseNote); !keep {
+			continue
 		}
+		releaseNote.Description = truncate(releaseNote.Description, opts.MaxChars)
 
 		// Append the release note to the releaseNotes slice.
 		releaseNotes = append(releaseNotes, releaseNote)
@@ -211,16 +147,162 @@ func GetReleaseNotesbyType(ctx context.Context, projectID string, product string
 
 }
 
-// getStringValue returns the string value of a bigquery.Value.
-func getStringValue(v bigquery.Value) string {
-	if v == nil {
-		return "NULL"
+// releaseNotesQuery selects the distinct release notes of the given types
+// published for product within the last cadence days, as opts orders and
+// limits them.
+func releaseNotesQuery(cadence, product string, types []string, opts Options) *query.Builder {
+	return query.Select(
+		"release_note_type",
+		"IFNULL(description, '') AS description",
+		"TIMESTAMP(MAX(published_at)) AS published_at",
+	).
+		From(source.Table()).
+		Window(cadence).
+		Product(product).
+		Types(types).
+		GroupBy("release_note_type", "description").
+		OrderBy(opts.orderByClause()).
+		Limit(opts.limit())
+}
+
+// Options controls how release notes are fetched for a single product.
+type Options struct {
+	// Limit is the maximum number of release notes returned per product.
+	// Zero means DefaultLimit.
+	Limit int
+	// OrderBy is the column the release notes are ordered by, optionally
+	// followed by ASC or DESC, e.g. "published_at DESC". Empty means
+	// "release_note_type ASC".
+	OrderBy string
+	// MaxChars truncates each description to at most this many characters,
+	// ending it with an ellipsis. Zero disables truncation.
+	MaxChars int
+	// TypePriority lists release note types in the order they should appear
+	// when several types are returned for one product. Types not listed keep
+	// the query order after the listed ones. Nil keeps the query order.
+	TypePriority []string
+	// GKEChannels are the GKE release channels the clusters run on. GKE
+	// release notes only mentioning other channels are dropped, or annotated
+	// if GKEAnnotate is set. Nil keeps GKE release notes as they are.
+	GKEChannels []string
+	// GKEAnnotate prefixes GKE release notes mentioning release channels
+	// with them, their versions and whether the clusters run on them,
+	// instead of dropping those for other channels.
+	GKEAnnotate bool
+	// Pins are the pinned versions of products. Release notes of a product
+	// with pins that only mention other versions are dropped, or labeled if
+	// PinLabel is set.
+	Pins map[string][]Pin
+	// PinLabel labels release notes mentioning versions of a product with
+	// pins as concerning the pinned versions or not, instead of dropping
+	// those for other versions.
+	PinLabel bool
+}
+
+// DefaultTypePriority is the order release note types are presented in when
+// several of them go to the same channel.
+var DefaultTypePriority = []string{"SECURITY_BULLETIN", "BREAKING_CHANGE", "DEPRECATION", "FEATURE", "FIX"}
+
+// SortByTypePriority orders release notes in place by the position of their
+// type in priority. The sort is stable, so notes of the same type keep the
+// order returned by the query.
+func SortByTypePriority(releaseNotes []ReleaseNote, priority []string) {
+	if len(priority) == 0 {
+		return
+	}
+	rank := make(map[string]int, len(priority))
+	for i, t := range priority {
+		rank[strings.ToUpper(strings.TrimSpace(t))] = i
+	}
+	position := func(t string) int {
+		if r, ok := rank[t]; ok {
+			return r
+		}
+		return len(priority)
+	}
+	sort.SliceStable(releaseNotes, func(i, j int) bool {
+		return position(releaseNotes[i].ReleaseNoteType) < position(releaseNotes[j].ReleaseNoteType)
+	})
+}
+
+// DefaultLimit is the number of release notes fetched per product when
+// Options.Limit is not set.
+const DefaultLimit = 1000
+
+// orderColumns maps the columns release notes can be ordered by to the SQL
+// expression used in the grouped query.
+var orderColumns = map[string]string{
+	"release_note_type": "release_note_type",
+	"description":       "description",
+	"published_at":      "MAX(published_at)",
+}
+
+// ValidateOrderBy checks that orderBy names a supported column and an
+// optional ASC or DESC direction.
+func ValidateOrderBy(orderBy string) error {
+	if orderBy == "" {
+		return nil
+	}
+	fields := strings.Fields(orderBy)
+	if len(fields) > 2 {
+		return fmt.Errorf("invalid order %q, expected \"<column> [ASC|DESC]\"", orderBy)
+	}
+	if _, ok := orderColumns[strings.ToLower(fields[0])]; !ok {
+		return fmt.Errorf("unsupported order column %q, use one of release_note_type, published_at, description", fields[0])
+	}
+	if len(fields) == 2 {
+		switch strings.ToUpper(fields[1]) {
+		case "ASC", "DESC":
+		default:
+			return fmt.Errorf("unsupported order direction %q, use ASC or DESC", fields[1])
+		}
+	}
+	return nil
+}
+
+// orderByClause returns the ORDER BY expression for the query. Invalid values
+// fall back to the default ordering, callers are expected to validate first.
+func (o Options) orderByClause() string {
+	if o.OrderBy == "" || ValidateOrderBy(o.OrderBy) != nil {
+		return "release_note_type ASC"
+	}
+	fields := strings.Fields(o.OrderBy)
+	direction := "ASC"
+	if len(fields) == 2 {
+		direction = strings.ToUpper(fields[1])
+	}
+	return orderColumns[strings.ToLower(fields[0])] + " " + direction
+}
+
+// limit returns the configured row limit or DefaultLimit.
+func (o Options) limit() int {
+	if o.Limit <= 0 {
+		return DefaultLimit
+	}
+	return o.Limit
+}
+
+// truncate shortens s to at most maxChars characters, replacing the tail with
+// an ellipsis. A maxChars of zero or less leaves s unchanged.
+func truncate(s string, maxChars int) string {
+	if maxChars <= 0 || utf8.RuneCountInString(s) <= maxChars {
+		return s
+	}
+	if maxChars == 1 {
+		return "…"
 	}
-	return fmt.Sprintf("%v", v)
+	runes := []rune(s)
+	return strings.TrimSpace(string(runes[:maxChars-1])) + "…"
 }
 
 // ReleaseNote represents a release note.
 type ReleaseNote struct {
 	ReleaseNoteType string `bigquery:"release_note_type" json:"release_note_type"`
 	Description     string `bigquery:"description" json:"description"`
+	// PublishedAt is the day the note was published, the latest one of
+	// identical notes.
+	PublishedAt time.Time `bigquery:"published_at" json:"published_at"`
+	// Impact is the impact the note was scored with, e.g. "high", or empty
+	// before it was scored.
+	Impact string `bigquery:"-" json:"impact,omitempty"`
 }
@@ -0,0 +1,52 @@
+package releasenotes
+
+import (
+	"strings"
+
+	"github.com/mpolski/gcp-release-digest/pkg/i18n"
+)
+
+// TypeLabel returns the reader-facing name of a release note type for count
+// items in the selected locale, e.g. "breaking change" for 1 and "breaking
+// changes" for 3.
+func TypeLabel(releaseNoteType string, count int) string {
+	labels, ok := i18n.M().Types[releaseNoteType]
+	if !ok {
+		label := strings.ToLower(strings.ReplaceAll(releaseNoteType, "_", " "))
+		labels = [2]string{label, label}
+	}
+	if count == 1 {
+		return labels[0]
+	}
+	return labels[1]
+}
+
+// TypeTitle returns a heading for a section of release notes of one type,
+// e.g. "Breaking changes".
+func TypeTitle(releaseNoteType string) string {
+	label := []rune(TypeLabel(releaseNoteType, 2))
+	return strings.ToUpper(string(label[:1])) + string(label[1:])
+}
+
+// TypeGroup holds the release notes of a single release note type.
+type TypeGroup struct {
+	ReleaseNoteType string
+	ReleaseNotes    []ReleaseNote
+}
+
+// GroupByType groups release notes by type in the order the types first
+// appear, so a slice sorted with SortByTypePriority keeps its type order.
+func GroupByType(releaseNotes []ReleaseNote) []TypeGroup {
+	var groups []TypeGroup
+	index := make(map[string]int)
+	for _, r := range releaseNotes {
+		i, ok := index[r.ReleaseNoteType]
+		if !ok {
+			i = len(groups)
+			index[r.ReleaseNoteType] = i
+			groups = append(groups, TypeGroup{ReleaseNoteType: r.ReleaseNoteType})
+		}
+		groups[i].ReleaseNotes = append(groups[i].ReleaseNotes, r)
+	}
+	return groups
+}
@@ -0,0 +1,259 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mpolski/gcp-release-digest/pkg/store"
+)
+
+// Kinds of delivered messages.
+const (
+	KindAnnounce     = "announce"
+	KindSummary      = "summary"
+	KindClosing      = "closing"
+	KindEscalation   = "escalation"
+	KindEmail        = "email"
+	KindPush         = "push"
+	KindApproval     = "approval"
+	KindAlert        = "alert"
+	KindStats        = "stats"
+	KindNoNews       = "no_news"
+	KindDeprecations = "deprecations"
+	KindPage         = "page"
+	KindIssue        = "issue"
+)
+
+// Statuses of messages that were not delivered right away but will be later.
+const (
+	// StatusQueued is the status of a message held back until its channel's
+	// delivery window opens.
+	StatusQueued = "QUEUED"
+	// StatusRetrying is the status of a failed message handed to the retry
+	// queue.
+	StatusRetrying = "RETRY_QUEUED"
+)
+
+// StatusSilent is the status of a message deliberately not sent, such as the
+// no news message of a channel set to stay silent. It records that the
+// channel was covered without counting as an intended delivery.
+const StatusSilent = "SILENT"
+
+// Fallbacks of products the model returned no summary for, blocked or
+// empty.
+const (
+	// FallbackRetried means the summary was written with the retry prompt.
+	FallbackRetried = "retry_prompt"
+	// FallbackRawNotes means the release notes were sent instead of a
+	// summary.
+	FallbackRawNotes = "raw_notes"
+)
+
+// Delivery is the outcome of sending one message.
+type Delivery struct {
+	Channel string    `json:"channel"`
+	Target  string    `json:"target"`
+	Kind    string    `json:"kind"`
+	Product string    `json:"product,omitempty"`
+	Status  string    `json:"status,omitempty"`
+	Error   string    `json:"error,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// Confirmed reports whether the target acknowledged the message with a 2xx
+// response.
+func (d Delivery) Confirmed() bool {
+	return d.Error == "" && strings.HasPrefix(d.Status, "2")
+}
+
+// Queued reports whether the message was held for a later delivery window or
+// handed to the retry queue.
+func (d Delivery) Queued() bool {
+	return d.Error == "" && (d.Status == StatusQueued || d.Status == StatusRetrying)
+}
+
+// Override records an editor's text replacing a generated summary before
+// delivery.
+type Override struct {
+	Channel  string    `json:"channel"`
+	Product  string    `json:"product"`
+	Key      string    `json:"key"`
+	Original string    `json:"original"`
+	Summary  string    `json:"summary"`
+	At       time.Time `json:"at"`
+}
+
+// Deferral records a product left unsummarized because the run's time
+// budget ran out.
+type Deferral struct {
+	Channel string    `json:"channel"`
+	Product string    `json:"product"`
+	At      time.Time `json:"at"`
+}
+
+// Report records what a run intended to deliver and what was confirmed.
+type Report struct {
+	Number     int        `json:"number,omitempty"`
+	Started    time.Time  `json:"started"`
+	Finished   time.Time  `json:"finished"`
+	Deliveries []Delivery `json:"deliveries"`
+	Overrides  []Override `json:"overrides,omitempty"`
+	// Deferred are the products the next run has to cover.
+	Deferred []Deferral `json:"deferred,omitempty"`
+	// Variants maps the products summarized with a prompt variant to its
+	// name.
+	Variants map[string]string `json:"variants,omitempty"`
+	// Fallbacks maps the products the model returned no summary for to
+	// what was sent instead.
+	Fallbacks map[string]string `json:"fallbacks,omitempty"`
+	Summary   *Summary          `json:"summary,omitempty"`
+
+	mu sync.Mutex
+}
+
+// Summary compares intended and confirmed deliveries.
+type Summary struct {
+	Intended  int        `json:"intended"`
+	Confirmed int        `json:"confirmed"`
+	Queued    int        `json:"queued"`
+	Gaps      []Delivery `json:"gaps,omitempty"`
+}
+
+// New starts the report of a run.
+func New(number int) *Report {
+	return &Report{Number: number, Started: time.Now().UTC()}
+}
+
+// RunID identifies the run to receivers of its messages: "digest-<number>"
+// for a numbered digest, or the time it started, e.g. "run-20240102T150405Z".
+func (r *Report) RunID() string {
+	if r.Number > 0 {
+		return fmt.Sprintf("digest-%d", r.Number)
+	}
+	return "run-" + r.Started.Format("20060102T150405Z")
+}
+
+// Record adds the outcome of sending one message. The webhook URL is reduced
+// to its host, as webhook URLs usually embed credentials.
+func (r *Report) Record(channel, webhookURL, kind, product, status string, err error) {
+	d := Delivery{
+		Channel: channel,
+		Target:  redact(webhookURL),
+		Kind:    kind,
+		Product: product,
+		Status:  status,
+		At:      time.Now().UTC(),
+	}
+	if err != nil {
+		d.Error = err.Error()
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Deliveries = append(r.Deliveries, d)
+}
+
+// RecordOverride adds an editor's replacement of the generated summary of a
+// product, keeping the original.
+func (r *Report) RecordOverride(channel, product, key, original, summary string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Overrides = append(r.Overrides, Override{
+		Channel:  channel,
+		Product:  product,
+		Key:      key,
+		Original: original,
+		Summary:  summary,
+		At:       time.Now().UTC(),
+	})
+}
+
+// Defer adds a product of a channel left for the next run.
+func (r *Report) Defer(channel, product string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.Deferred = append(r.Deferred, Deferral{Channel: channel, Product: product, At: time.Now().UTC()})
+}
+
+// SetVariant records the prompt variant the summary of product was written
+// with.
+func (r *Report) SetVariant(product, variant string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Variants == nil {
+		r.Variants = make(map[string]string)
+	}
+	r.Variants[product] = variant
+}
+
+// SetFallback records that the model returned no summary of product and
+// what was sent instead, FallbackRetried or FallbackRawNotes.
+func (r *Report) SetFallback(product, fallback string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.Fallbacks == nil {
+		r.Fallbacks = make(map[string]string)
+	}
+	r.Fallbacks[product] = fallback
+}
+
+// Verify compares the intended deliveries with the confirmed ones and stores
+// the result in the report. Every message that was neither confirmed nor
+// queued is reported as a gap.
+func (r *Report) Verify() *Summary {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := &Summary{}
+	for _, d := range r.Deliveries {
+		if d.Status == StatusSilent {
+			continue
+		}
+		s.Intended++
+		switch {
+		case d.Confirmed():
+			s.Confirmed++
+		case d.Queued():
+			s.Queued++
+		default:
+			s.Gaps = append(s.Gaps, d)
+		}
+	}
+	r.Finished = time.Now().UTC()
+	r.Summary = s
+	return s
+}
+
+// Key returns the store key of the report of digest number n.
+func Key(n int) string {
+	return fmt.Sprintf("reports/digest-%d.json", n)
+}
+
+// JSON returns the report as indented JSON.
+func (r *Report) JSON() ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return json.MarshalIndent(r, "", "  ")
+}
+
+// Save stores the report as JSON under Key.
+func (r *Report) Save(ctx context.Context, s store.Store) error {
+	data, err := r.JSON()
+	if err != nil {
+		return err
+	}
+	return s.Put(ctx, Key(r.Number), data)
+}
+
+// redact reduces a webhook URL to its scheme and host.
+func redact(webhookURL string) string {
+	u, err := url.Parse(webhookURL)
+	if err != nil || u.Host == "" {
+		return "(invalid URL)"
+	}
+	return u.Scheme + "://" + u.Host
+}
@@ -0,0 +1,174 @@
+package routing
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Routes maps products to the teams owning them, CODEOWNERS-style.
+//
+// A routing file contains team definitions and rules, one per line:
+//
+//	# Teams and their webhooks, optionally with their own cadence.
+//	#db-team = https://chat.googleapis.com/v1/spaces/...
+//	#platform = https://hooks.slack.com/services/...
+//	#leadership = https://chat.googleapis.com/v1/spaces/... cadence=30
+//
+//	# Rules: product pattern -> team or webhook URL.
+//	* -> #platform
+//	Cloud SQL -> #db-team
+//	AlloyDB* -> #db-team
+//
+// Patterns match product names case-insensitively, with * matching any run
+// of characters and ? a single character. As in CODEOWNERS, the last
+// matching rule wins, so a catch-all rule goes first. Other lines starting
+// with # are comments.
+type Routes struct {
+	teams map[string]Team
+	rules []rule
+}
+
+type rule struct {
+	pattern string
+	team    string
+	re      *regexp.Regexp
+}
+
+// Team is a routing target: a team name and the webhook its summaries go to.
+type Team struct {
+	Name       string
+	WebhookURL string
+	// Cadence is the number of days covered by the team's digest, or zero
+	// for the global cadence.
+	Cadence int
+}
+
+var teamDefinition = regexp.MustCompile(`^(#[\w.-]+)\s*=\s*(\S+)(?:\s+cadence=(\d+))?$`)
+
+// Load reads a routing file.
+func Load(path string) (*Routes, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error opening routing file: %v", err)
+	}
+	defer f.Close()
+	return Parse(f)
+}
+
+// Parse reads routing rules in the format described on Routes.
+func Parse(r io.Reader) (*Routes, error) {
+	routes := &Routes{teams: make(map[string]Team)}
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if m := teamDefinition.FindStringSubmatch(line); m != nil {
+			team := Team{Name: m[1], WebhookURL: m[2]}
+			if m[3] != "" {
+				team.Cadence, _ = strconv.Atoi(m[3])
+			}
+			routes.teams[m[1]] = team
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		pattern, target, ok := strings.Cut(line, "->")
+		if !ok {
+			return nil, fmt.Errorf("routing file line %d: expected \"<product pattern> -> <team>\"", lineNo)
+		}
+		pattern, target = strings.TrimSpace(pattern), strings.TrimSpace(target)
+		if pattern == "" || target == "" {
+			return nil, fmt.Errorf("routing file line %d: empty pattern or team", lineNo)
+		}
+		routes.rules = append(routes.rules, rule{pattern: pattern, team: target, re: compile(pattern)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Every team referenced by a rule must be defined.
+	for _, rl := range routes.rules {
+		if strings.HasPrefix(rl.team, "#") {
+			if _, ok := routes.teams[rl.team]; !ok {
+				return nil, fmt.Errorf("routing file: team %s is used but not defined", rl.team)
+			}
+		}
+	}
+	return routes, nil
+}
+
+// compile turns a product pattern into an anchored, case-insensitive regexp.
+func compile(pattern string) *regexp.Regexp {
+	var b strings.Builder
+	b.WriteString("(?i)^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// Cadences returns the cadences set for teams in the routing file, in
+// ascending order.
+func (rt *Routes) Cadences() []int {
+	var cadences []int
+	if rt == nil {
+		return cadences
+	}
+	for _, team := range rt.teams {
+		if team.Cadence > 0 && !slices.Contains(cadences, team.Cadence) {
+			cadences = append(cadences, team.Cadence)
+		}
+	}
+	slices.Sort(cadences)
+	return cadences
+}
+
+// Teams returns the names of the teams products can be routed to, as Owner
+// names them, in ascending order.
+func (rt *Routes) Teams() []string {
+	var names []string
+	if rt == nil {
+		return names
+	}
+	for _, rl := range rt.rules {
+		if !slices.Contains(names, rl.team) {
+			names = append(names, rl.team)
+		}
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Owner returns the team owning product, or false if no rule matches.
+func (rt *Routes) Owner(product string) (Team, bool) {
+	if rt == nil {
+		return Team{}, false
+	}
+	for i := len(rt.rules) - 1; i >= 0; i-- {
+		rl := rt.rules[i]
+		if !rl.re.MatchString(product) {
+			continue
+		}
+		if team, ok := rt.teams[rl.team]; ok {
+			return team, true
+		}
+		return Team{Name: rl.team, WebhookURL: rl.team}, true
+	}
+	return Team{}, false
+}
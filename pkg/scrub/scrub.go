@@ -0,0 +1,160 @@
+package scrub
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/dlp/v2"
+)
+
+// Scrubber removes sensitive strings, such as credentials and email
+// addresses, from text before it is sent.
+type Scrubber interface {
+	// Scrub returns text with sensitive strings replaced by the name of
+	// their kind in brackets, e.g. [EMAIL_ADDRESS], and the kinds found.
+	Scrub(ctx context.Context, text string) (string, []string, error)
+}
+
+// pattern matches one kind of sensitive string.
+type pattern struct {
+	kind string
+	re   *regexp.Regexp
+}
+
+// builtinPatterns match credentials and email addresses by their shape.
+var builtinPatterns = []pattern{
+	{"PRIVATE_KEY", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+	{"AUTH_TOKEN", regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`)},
+	{"AUTH_TOKEN", regexp.MustCompile(`(?i)\bbearer\s+[A-Za-z0-9._~+/-]{20,}=*`)},
+	{"API_KEY", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}`)},
+	{"API_KEY", regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+	{"API_KEY", regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},
+	{"API_KEY", regexp.MustCompile(`\bxox[abpr]-[A-Za-z0-9-]{10,}`)},
+	{"EMAIL_ADDRESS", regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}\b`)},
+}
+
+// Builtin scrubs with regular expressions, without calling any service.
+type Builtin struct {
+	patterns []pattern
+}
+
+// NewBuiltin returns a Builtin scrubber of credentials, email addresses and
+// the host names below the internal domains, e.g. "corp.example.com".
+func NewBuiltin(internalDomains []string) *Builtin {
+	b := &Builtin{patterns: builtinPatterns}
+	for _, domain := range internalDomains {
+		domain = strings.Trim(strings.TrimSpace(domain), ".")
+		if domain == "" {
+			continue
+		}
+		re := regexp.MustCompile(`(?i)\b(?:[a-z0-9-]+\.)+` + regexp.QuoteMeta(domain) + `\b`)
+		b.patterns = append(b.patterns, pattern{"INTERNAL_HOST", re})
+	}
+	return b
+}
+
+// Scrub replaces the matches of every pattern.
+func (b *Builtin) Scrub(ctx context.Context, text string) (string, []string, error) {
+	found := make(map[string]bool)
+	for _, p := range b.patterns {
+		text = p.re.ReplaceAllStringFunc(text, func(string) string {
+			found[p.kind] = true
+			return "[" + p.kind + "]"
+		})
+	}
+	return text, kinds(found), nil
+}
+
+// DefaultInfoTypes are the Cloud DLP infoTypes scrubbed by DLP unless others
+// are set.
+var DefaultInfoTypes = []string{"EMAIL_ADDRESS", "PHONE_NUMBER", "AUTH_TOKEN", "GCP_API_KEY", "AWS_CREDENTIALS", "GCP_CREDENTIALS", "ENCRYPTION_KEY", "PASSWORD"}
+
+// DLP scrubs with the built-in patterns first and then with the Cloud
+// Data Loss Prevention API, which finds sensitive strings by their context
+// too.
+type DLP struct {
+	ProjectID string
+	InfoTypes []string
+	Builtin   *Builtin
+
+	mu  sync.Mutex
+	svc *dlp.Service
+}
+
+// Scrub replaces the sensitive strings found by the built-in patterns and
+// by Cloud DLP. On an error of Cloud DLP, the text scrubbed by the built-in
+// patterns is returned with the error.
+func (d *DLP) Scrub(ctx context.Context, text string) (string, []string, error) {
+	text, found, _ := d.Builtin.Scrub(ctx, text)
+	svc, err := d.service(ctx)
+	if err != nil {
+		return text, found, err
+	}
+
+	infoTypes := d.InfoTypes
+	if len(infoTypes) == 0 {
+		infoTypes = DefaultInfoTypes
+	}
+	var types []*dlp.GooglePrivacyDlpV2InfoType
+	for _, name := range infoTypes {
+		types = append(types, &dlp.GooglePrivacyDlpV2InfoType{Name: name})
+	}
+	req := &dlp.GooglePrivacyDlpV2DeidentifyContentRequest{
+		Item:          &dlp.GooglePrivacyDlpV2ContentItem{Value: text},
+		InspectConfig: &dlp.GooglePrivacyDlpV2InspectConfig{InfoTypes: types},
+		DeidentifyConfig: &dlp.GooglePrivacyDlpV2DeidentifyConfig{
+			InfoTypeTransformations: &dlp.GooglePrivacyDlpV2InfoTypeTransformations{
+				Transformations: []*dlp.GooglePrivacyDlpV2InfoTypeTransformation{{
+					PrimitiveTransformation: &dlp.GooglePrivacyDlpV2PrimitiveTransformation{
+						ReplaceWithInfoTypeConfig: &dlp.GooglePrivacyDlpV2ReplaceWithInfoTypeConfig{},
+					},
+				}},
+			},
+		},
+	}
+	resp, err := svc.Projects.Content.Deidentify("projects/"+d.ProjectID, req).Context(ctx).Do()
+	if err != nil {
+		return text, found, fmt.Errorf("Error scrubbing with Cloud DLP: %v", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, kind := range found {
+		seen[kind] = true
+	}
+	if resp.Overview != nil {
+		for _, s := range resp.Overview.TransformationSummaries {
+			if s.InfoType != nil && s.TransformedBytes > 0 {
+				seen[s.InfoType.Name] = true
+			}
+		}
+	}
+	return resp.Item.Value, kinds(seen), nil
+}
+
+// service returns the Cloud DLP client, creating it on first use.
+func (d *DLP) service(ctx context.Context) (*dlp.Service, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.svc == nil {
+		svc, err := dlp.NewService(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("Error creating Cloud DLP client: %v", err)
+		}
+		d.svc = svc
+	}
+	return d.svc, nil
+}
+
+// kinds lists the kinds found in order.
+func kinds(found map[string]bool) []string {
+	var names []string
+	for kind := range found {
+		names = append(names, kind)
+	}
+	sort.Strings(names)
+	return names
+}
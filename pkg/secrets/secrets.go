@@ -0,0 +1,81 @@
+package secrets
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+
+	"google.golang.org/api/secretmanager/v1"
+)
+
+// Prefix marks a Secret Manager reference in a setting.
+const Prefix = "sm://"
+
+// reference matches a Secret Manager reference such as
+// sm://projects/my-project/secrets/webhook-token or
+// sm://projects/my-project/secrets/webhook-token/versions/3.
+var reference = regexp.MustCompile(`sm://(projects/[^/\s]+/secrets/[^/\s]+)(/versions/[^/\s]+)?`)
+
+var (
+	mu    sync.Mutex
+	svc   *secretmanager.Service
+	cache = map[string]string{}
+)
+
+// Resolve replaces every Secret Manager reference in value with the secret it
+// points to, so settings like "Bearer sm://projects/p/secrets/token" can be
+// kept out of environment variables. References without a version use the
+// latest one. Values without references are returned unchanged.
+func Resolve(ctx context.Context, value string) (string, error) {
+	if !strings.Contains(value, Prefix) {
+		return value, nil
+	}
+	var resolveErr error
+	resolved := reference.ReplaceAllStringFunc(value, func(ref string) string {
+		m := reference.FindStringSubmatch(ref)
+		version := m[2]
+		if version == "" {
+			version = "/versions/latest"
+		}
+		secret, err := access(ctx, m[1]+version)
+		if err != nil && resolveErr == nil {
+			resolveErr = err
+		}
+		return secret
+	})
+	if resolveErr != nil {
+		return "", resolveErr
+	}
+	return resolved, nil
+}
+
+// access returns the payload of a secret version, caching it for the lifetime
+// of the instance.
+func access(ctx context.Context, name string) (string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if secret, ok := cache[name]; ok {
+		return secret, nil
+	}
+	if svc == nil {
+		s, err := secretmanager.NewService(ctx)
+		if err != nil {
+			return "", fmt.Errorf("Error creating Secret Manager client: %v", err)
+		}
+		svc = s
+	}
+	resp, err := svc.Projects.Secrets.Versions.Access(name).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("Error accessing secret %s: %v", name, err)
+	}
+	data, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("Error decoding secret %s: %v", name, err)
+	}
+	secret := strings.TrimRight(string(data), "\r\n")
+	cache[name] = secret
+	return secret, nil
+}
@@ -0,0 +1,69 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/mpolski/gcp-release-digest/pkg/notify"
+)
+
+// DefaultMaxChars keeps a message within a single SMS segment.
+const DefaultMaxChars = 160
+
+// Twilio sends text messages through the Twilio Messages API.
+type Twilio struct {
+	AccountSID string
+	AuthToken  string
+	// From is the Twilio phone number or messaging service SID sending the
+	// message.
+	From string
+}
+
+// Send texts body to the phone number to, in E.164 format.
+func (t *Twilio) Send(ctx context.Context, to, body string) (status string, err error) {
+	form := url.Values{"To": {to}, "Body": {body}}
+	if strings.HasPrefix(t.From, "MG") {
+		form.Set("MessagingServiceSid", t.From)
+	} else {
+		form.Set("From", t.From)
+	}
+
+	endpoint := t.Target() + "/2010-04-01/Accounts/" + url.PathEscape(t.AccountSID) + "/Messages.json"
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.SetBasicAuth(t.AccountSID, t.AuthToken)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := notify.Client(endpoint).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.Status, fmt.Errorf("Error sending SMS: %s %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return resp.Status, nil
+}
+
+// Target identifies Twilio in run reports.
+func (t *Twilio) Target() string {
+	return "https://api.twilio.com"
+}
+
+// Render builds a plain text alert of at most maxChars characters, like
+// "GCP security bulletin: Cloud SQL - <start of the summary>…". Chat markup
+// is stripped and the summary is cut at a word boundary. A maxChars of zero
+// means DefaultMaxChars.
+func Render(label, product, summary string, maxChars int) string {
+	if maxChars <= 0 {
+		maxChars = DefaultMaxChars
+	}
+	return notify.Shorten(fmt.Sprintf("GCP %s: %s - %s", label, product, notify.Render(summary, notify.SMSCapabilities.Dialect)), maxChars)
+}
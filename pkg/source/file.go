@@ -0,0 +1,162 @@
+package source
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Note is a release note read from a file, with the columns of PublicTable
+// the digest uses.
+type Note struct {
+	ProductName     string `json:"product_name"`
+	ReleaseNoteType string `json:"release_note_type"`
+	Description     string `json:"description"`
+	// PublishedAt is a date, e.g. "2024-06-01", or an RFC 3339 timestamp.
+	PublishedAt string `json:"published_at"`
+
+	published time.Time
+}
+
+// Published returns the time the note was published.
+func (n Note) Published() time.Time {
+	return n.published
+}
+
+// fileNotes are the notes of the loaded file, if any, and newest the time
+// of the latest of them.
+var (
+	fileNotes []Note
+	newest    time.Time
+)
+
+// LoadFile reads release notes from a JSON or CSV file and answers every
+// query from it instead of BigQuery, e.g. for local development. A JSON file
+// holds an array or newline-delimited objects with the columns of Note, as
+// exported from BigQuery; a CSV file has a header row naming them. An empty
+// path goes back to BigQuery.
+func LoadFile(path string) error {
+	if path == "" {
+		mu.Lock()
+		defer mu.Unlock()
+		fileNotes = nil
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("Error reading release notes file: %v", err)
+	}
+	var notes []Note
+	if strings.EqualFold(filepath.Ext(path), ".csv") {
+		notes, err = readCSV(data)
+	} else {
+		notes, err = readJSON(data)
+	}
+	if err != nil {
+		return fmt.Errorf("Error decoding release notes file %s: %v", path, err)
+	}
+
+	var latest time.Time
+	for i := range notes {
+		n := &notes[i]
+		if n.published, err = parseDate(n.PublishedAt); err != nil {
+			return fmt.Errorf("Error decoding release notes file %s: note %d: %v", path, i+1, err)
+		}
+		if n.published.After(latest) {
+			latest = n.published
+		}
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	fileNotes, newest = notes, latest
+	return nil
+}
+
+// FileNotes returns the notes of the loaded file published within cadence
+// days before the newest of them, so a fixture keeps returning notes as it
+// ages, and whether a file is loaded.
+func FileNotes(cadence string) ([]Note, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	if fileNotes == nil {
+		return nil, false
+	}
+	days, _ := strconv.Atoi(cadence)
+	since := newest.AddDate(0, 0, -days)
+	var notes []Note
+	for _, n := range fileNotes {
+		if !n.published.Before(since) {
+			notes = append(notes, n)
+		}
+	}
+	return notes, true
+}
+
+// readJSON decodes a JSON array of notes or newline-delimited notes.
+func readJSON(data []byte) ([]Note, error) {
+	notes := []Note{}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		err := json.Unmarshal(data, &notes)
+		return notes, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		var n Note
+		if err := dec.Decode(&n); err == io.EOF {
+			return notes, nil
+		} else if err != nil {
+			return nil, err
+		}
+		notes = append(notes, n)
+	}
+}
+
+// readCSV decodes notes from CSV with a header row.
+func readCSV(data []byte) ([]Note, error) {
+	records, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("missing header row")
+	}
+	column := make(map[string]int)
+	for i, name := range records[0] {
+		column[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"product_name", "release_note_type", "description", "published_at"} {
+		if _, ok := column[name]; !ok {
+			return nil, fmt.Errorf("missing column %s", name)
+		}
+	}
+	notes := []Note{}
+	for _, r := range records[1:] {
+		notes = append(notes, Note{
+			ProductName:     r[column["product_name"]],
+			ReleaseNoteType: r[column["release_note_type"]],
+			Description:     r[column["description"]],
+			PublishedAt:     r[column["published_at"]],
+		})
+	}
+	return notes, nil
+}
+
+// parseDate reads a date or an RFC 3339 timestamp.
+func parseDate(s string) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("published_at %q is neither a date nor an RFC 3339 timestamp", s)
+	}
+	return t, nil
+}
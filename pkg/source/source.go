@@ -0,0 +1,96 @@
+package source
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/mpolski/gcp-release-digest/pkg/bqretry"
+)
+
+// PublicTable is the public BigQuery table of Google Cloud release notes.
+const PublicTable = "bigquery-public-data.google_cloud_release_notes.release_notes"
+
+// expiry removes a materialized table that was not dropped, e.g. because the
+// run crashed.
+const expiry = 24 * time.Hour
+
+var (
+	mu    sync.Mutex
+	table = PublicTable
+)
+
+// Table returns the table the release notes are queried from, quoted for a
+// FROM clause.
+func Table() string {
+	mu.Lock()
+	defer mu.Unlock()
+	return quote(table)
+}
+
+// quote quotes a table name with backticks.
+func quote(name string) string {
+	return "`" + name + "`"
+}
+
+// setTable sets the table the release notes are queried from.
+func setTable(t string) {
+	mu.Lock()
+	defer mu.Unlock()
+	table = t
+}
+
+// Materialize copies the release notes of the last days from the public
+// table into a new table of dataset, "project.dataset" or a dataset of
+// projectID, and queries that much smaller table from then on. The dataset
+// has to be in the US, like the public table. The returned drop function
+// deletes the table and goes back to the public one.
+func Materialize(ctx context.Context, projectID, dataset string, days int) (drop func(context.Context), err error) {
+	datasetProject, datasetID, ok := strings.Cut(dataset, ".")
+	if !ok {
+		datasetProject, datasetID = projectID, dataset
+	}
+	tableID := "release_notes_" + time.Now().UTC().Format("20060102_150405_000000000")
+	name := datasetProject + "." + datasetID + "." + tableID
+
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating BQ client: %v", err)
+	}
+	defer client.Close()
+
+	fmt.Printf("Materializing release notes of the last %d days into %s... ", days, name)
+	q := client.Query(`
+	CREATE TABLE ` + quote(name) + `
+	OPTIONS (expiration_timestamp = TIMESTAMP_ADD(CURRENT_TIMESTAMP(), INTERVAL ` + strconv.Itoa(int(expiry.Hours())) + ` HOUR))
+	AS SELECT *
+	FROM ` + quote(PublicTable) + `
+	WHERE published_at >= DATE_SUB(CURRENT_DATE(), INTERVAL ` + strconv.Itoa(days) + ` DAY)
+	`)
+
+	// Set the query location to US.
+	q.Location = "US"
+
+	if _, err := bqretry.Read(ctx, q); err != nil {
+		return nil, fmt.Errorf("Error materializing release notes: %v", err)
+	}
+	fmt.Println("done.")
+	setTable(name)
+
+	return func(ctx context.Context) {
+		setTable(PublicTable)
+		client, err := bigquery.NewClient(ctx, projectID)
+		if err != nil {
+			fmt.Printf("Error creating BQ client: %v\n", err)
+			return
+		}
+		defer client.Close()
+		if err := client.DatasetInProject(datasetProject, datasetID).Table(tableID).Delete(ctx); err != nil {
+			fmt.Printf("Error deleting %s, it expires in %s: %v\n", name, expiry, err)
+		}
+	}, nil
+}
@@ -0,0 +1,185 @@
+package stats
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/bigquery"
+	"github.com/mpolski/gcp-release-digest/pkg/bqretry"
+	"github.com/mpolski/gcp-release-digest/pkg/i18n"
+	"github.com/mpolski/gcp-release-digest/pkg/products"
+	"github.com/mpolski/gcp-release-digest/pkg/query"
+	"github.com/mpolski/gcp-release-digest/pkg/releasenotes"
+	"github.com/mpolski/gcp-release-digest/pkg/source"
+	"google.golang.org/api/iterator"
+)
+
+// Volume is the number of release notes published in one month, by product
+// and by release note type.
+type Volume struct {
+	// Month is the first day of the month.
+	Month    time.Time
+	Total    int
+	Products map[string]int
+	Types    map[string]int
+}
+
+func newVolume(month time.Time) *Volume {
+	return &Volume{Month: month, Products: make(map[string]int), Types: make(map[string]int)}
+}
+
+func (v *Volume) add(product, releaseNoteType string, count int) {
+	v.Total += count
+	v.Products[product] += count
+	v.Types[releaseNoteType] += count
+}
+
+// row is the number of release notes of a product and type in the month, or
+// in the month before it.
+type row struct {
+	Product         string `bigquery:"product"`
+	ReleaseNoteType string `bigquery:"release_note_type"`
+	Count           int    `bigquery:"note_count"`
+	Current         bool   `bigquery:"current"`
+}
+
+// Get returns the volume of release notes of the month starting at month,
+// and that of the month before it to compare with.
+func Get(ctx context.Context, projectID string, month time.Time) (current, previous *Volume, err error) {
+	month = time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	current, previous = newVolume(month), newVolume(month.AddDate(0, -1, 0))
+
+	// A release notes file is counted by the dates of its notes.
+	if notes, ok := source.FileNotes("100000"); ok {
+		end := month.AddDate(0, 1, 0)
+		for _, n := range notes {
+			switch published := n.Published(); {
+			case !published.Before(month) && published.Before(end):
+				current.add(n.ProductName, n.ReleaseNoteType, 1)
+			case !published.Before(previous.Month) && published.Before(month):
+				previous.add(n.ProductName, n.ReleaseNoteType, 1)
+			}
+		}
+		return current, previous, nil
+	}
+
+	client, err := bigquery.NewClient(ctx, projectID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Error creating BQ client: %v", err)
+	}
+	defer client.Close()
+
+	// Count the release notes of the two months per product and type.
+	q, err := query.Select(
+		"product_name AS product",
+		"release_note_type",
+		"COUNT(*) AS note_count",
+		"published_at >= DATE(@month) AS current",
+	).
+		From(source.Table()).
+		Where("published_at >= DATE_SUB(DATE(@month), INTERVAL 1 MONTH)", bigquery.QueryParameter{Name: "month", Value: month.Format("2006-01-02")}).
+		Where("published_at < DATE_ADD(DATE(@month), INTERVAL 1 MONTH)").
+		GroupBy("product", "release_note_type", "current").
+		Query(client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Run the BigQuery query, retrying transient errors, and read its results.
+	it, err := bqretry.Read(ctx, q)
+	if err != nil {
+		return nil, nil, err
+	}
+	for {
+		var r row
+		err := it.Next(&r)
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("Error reading row: %v", err)
+		}
+		if r.Current {
+			current.add(r.Product, r.ReleaseNoteType, r.Count)
+		} else {
+			previous.add(r.Product, r.ReleaseNoteType, r.Count)
+		}
+	}
+	return current, previous, nil
+}
+
+// Render formats the volume of the month compared with the month before as
+// a message in chat markup: the total, the notes per type and the top most
+// active products, each with its trend, and the notes per category.
+func Render(current, previous *Volume, top int) string {
+	m := i18n.M()
+	var b strings.Builder
+	fmt.Fprintf(&b, "*📊 "+m.StatsTitle+"*\n", current.Month.Format("January 2006"))
+	fmt.Fprintf(&b, m.StatsTotal+"\n", current.Total, len(current.Products), trend(current.Total, previous.Total), previous.Month.Format("January 2006"))
+
+	if len(current.Types) > 0 {
+		fmt.Fprintf(&b, "\n*%s*\n", m.StatsByType)
+		for _, t := range ranked(current.Types) {
+			count := current.Types[t]
+			fmt.Fprintf(&b, "• %d %s (%s)\n", count, releasenotes.TypeLabel(t, count), trend(count, previous.Types[t]))
+		}
+	}
+
+	names := ranked(current.Products)
+	if len(names) > top {
+		names = names[:top]
+	}
+	if len(names) > 0 {
+		fmt.Fprintf(&b, "\n*%s*\n", m.StatsMostActive)
+		for i, name := range names {
+			fmt.Fprintf(&b, "%d. %s: %d (%s)\n", i+1, name, current.Products[name], trend(current.Products[name], previous.Products[name]))
+		}
+
+		// Categories show where the changes concentrate beyond single
+		// products.
+		categories := make(map[string]int)
+		for name, count := range current.Products {
+			categories[products.Category(name)] += count
+		}
+		fmt.Fprintf(&b, "\n*%s*\n", m.StatsByCategory)
+		for _, c := range ranked(categories) {
+			fmt.Fprintf(&b, "• %s: %d\n", c, categories[c])
+		}
+	}
+	return b.String()
+}
+
+// ranked returns the keys of the counts, highest count first and by name on
+// a tie.
+func ranked(counts map[string]int) []string {
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}
+
+// trend renders the change from the previous count, e.g. "+12%", or "new"
+// if there was none.
+func trend(current, previous int) string {
+	if previous == 0 {
+		if current == 0 {
+			return "±0%"
+		}
+		return i18n.M().StatsNew
+	}
+	change := 100 * float64(current-previous) / float64(previous)
+	if change > -0.5 && change < 0.5 {
+		return "±0%"
+	}
+	return fmt.Sprintf("%+.0f%%", change)
+}
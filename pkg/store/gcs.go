@@ -0,0 +1,103 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/storage/v1"
+)
+
+// GCS is a Store keeping each key as an object in a Cloud Storage bucket.
+type GCS struct {
+	bucket string
+	svc    *storage.Service
+}
+
+// NewGCS returns a Store backed by bucket, using the default credentials.
+func NewGCS(ctx context.Context, bucket string) (*GCS, error) {
+	svc, err := storage.NewService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating Cloud Storage client: %v", err)
+	}
+	return &GCS{bucket: bucket, svc: svc}, nil
+}
+
+// Get implements Store.
+func (g *GCS) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := g.svc.Objects.Get(g.bucket, key).Context(ctx).Download()
+	if isNotFound(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// Put implements Store.
+func (g *GCS) Put(ctx context.Context, key string, data []byte) error {
+	obj := &storage.Object{Name: key, ContentType: contentType(key)}
+	_, err := g.svc.Objects.Insert(g.bucket, obj).Media(bytes.NewReader(data)).Context(ctx).Do()
+	return err
+}
+
+// Create implements Store using a generation precondition, so the write
+// fails if the object already exists.
+func (g *GCS) Create(ctx context.Context, key string, data []byte) error {
+	obj := &storage.Object{Name: key, ContentType: contentType(key)}
+	_, err := g.svc.Objects.Insert(g.bucket, obj).IfGenerationMatch(0).Media(bytes.NewReader(data)).Context(ctx).Do()
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusPreconditionFailed {
+		return ErrExists
+	}
+	return err
+}
+
+// List implements Store.
+func (g *GCS) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := g.svc.Objects.List(g.bucket).Prefix(prefix).Pages(ctx, func(objs *storage.Objects) error {
+		for _, obj := range objs.Items {
+			keys = append(keys, obj.Name)
+		}
+		return nil
+	})
+	sort.Strings(keys)
+	return keys, err
+}
+
+// Delete implements Store.
+func (g *GCS) Delete(ctx context.Context, key string) error {
+	err := g.svc.Objects.Delete(g.bucket, key).Context(ctx).Do()
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// isNotFound reports whether err is a Cloud Storage 404 response.
+func isNotFound(err error) bool {
+	var apiErr *googleapi.Error
+	return errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound
+}
+
+// contentType guesses the content type of an object from its key.
+func contentType(key string) string {
+	switch path.Ext(key) {
+	case ".json":
+		return "application/json"
+	case ".html":
+		return "text/html; charset=utf-8"
+	case ".md", ".txt":
+		return "text/plain; charset=utf-8"
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,105 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/iamcredentials/v1"
+)
+
+// URLSigner is implemented by stores that can grant temporary read access to
+// a key through a signed URL.
+type URLSigner interface {
+	// SignedURL returns a URL to download key until it expires, signed as
+	// serviceAccount, or as the default service account if it is empty.
+	SignedURL(ctx context.Context, key, serviceAccount string, expires time.Duration) (string, error)
+}
+
+// MaxSignedURLExpiry is the longest a signed URL can be valid.
+const MaxSignedURLExpiry = 7 * 24 * time.Hour
+
+// SignedURL implements URLSigner with a V4 signed URL. The signature is made
+// by the IAM Credentials API, so no private key is needed, but the caller
+// needs roles/iam.serviceAccountTokenCreator on serviceAccount.
+func (g *GCS) SignedURL(ctx context.Context, key, serviceAccount string, expires time.Duration) (string, error) {
+	if expires <= 0 || expires > MaxSignedURLExpiry {
+		expires = MaxSignedURLExpiry
+	}
+	if serviceAccount == "" {
+		var err error
+		if serviceAccount, err = defaultServiceAccount(ctx); err != nil {
+			return "", err
+		}
+	}
+
+	now := time.Now().UTC()
+	datetime := now.Format("20060102T150405Z")
+	scope := now.Format("20060102") + "/auto/storage/goog4_request"
+
+	var segments []string
+	for _, s := range strings.Split(key, "/") {
+		segments = append(segments, url.PathEscape(s))
+	}
+	path := "/" + g.bucket + "/" + strings.Join(segments, "/")
+
+	query := url.Values{
+		"X-Goog-Algorithm":     {"GOOG4-RSA-SHA256"},
+		"X-Goog-Credential":    {serviceAccount + "/" + scope},
+		"X-Goog-Date":          {datetime},
+		"X-Goog-Expires":       {strconv.Itoa(int(expires.Seconds()))},
+		"X-Goog-SignedHeaders": {"host"},
+	}.Encode()
+	canonicalRequest := strings.Join([]string{"GET", path, query, "host:storage.googleapis.com\n", "host", "UNSIGNED-PAYLOAD"}, "\n")
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{"GOOG4-RSA-SHA256", datetime, scope, hex.EncodeToString(hash[:])}, "\n")
+
+	signature, err := signBlob(ctx, serviceAccount, []byte(stringToSign))
+	if err != nil {
+		return "", err
+	}
+	return "https://storage.googleapis.com" + path + "?" + query + "&X-Goog-Signature=" + hex.EncodeToString(signature), nil
+}
+
+// signBlob signs data with the key of serviceAccount.
+func signBlob(ctx context.Context, serviceAccount string, data []byte) ([]byte, error) {
+	svc, err := iamcredentials.NewService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Error creating IAM Credentials client: %v", err)
+	}
+	resp, err := svc.Projects.ServiceAccounts.SignBlob("projects/-/serviceAccounts/"+serviceAccount, &iamcredentials.SignBlobRequest{
+		Payload: base64.StdEncoding.EncodeToString(data),
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("Error signing URL: %v", err)
+	}
+	return base64.StdEncoding.DecodeString(resp.SignedBlob)
+}
+
+// defaultServiceAccount returns the email of the service account the
+// function runs as, from the metadata server.
+func defaultServiceAccount(ctx context.Context) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/email", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("Error looking up the default service account: %v", err)
+	}
+	defer resp.Body.Close()
+	email, err := io.ReadAll(resp.Body)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("Error looking up the default service account: %s", resp.Status)
+	}
+	return strings.TrimSpace(string(email)), nil
+}
@@ -0,0 +1,134 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ErrNotFound is returned by Get when the key does not exist.
+var ErrNotFound = errors.New("store: key not found")
+
+// ErrExists is returned by Create when the key already exists.
+var ErrExists = errors.New("store: key already exists")
+
+// Store keeps small pieces of state between runs, such as queued messages.
+// Keys are slash separated paths, e.g. "outbox/general/0001.json".
+type Store interface {
+	// Get returns the data stored under key, or ErrNotFound.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Put stores data under key, replacing any previous value.
+	Put(ctx context.Context, key string, data []byte) error
+	// Create stores data under key only if the key does not exist yet, and
+	// returns ErrExists otherwise. It is atomic, so concurrent runs can use it
+	// to claim a key.
+	Create(ctx context.Context, key string, data []byte) error
+	// List returns the keys starting with prefix in lexical order.
+	List(ctx context.Context, prefix string) ([]string, error)
+	// Delete removes key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// New returns a Store backed by the Cloud Storage bucket if bucket is set,
+// otherwise by the local directory dir. It returns nil if neither is set.
+func New(ctx context.Context, bucket, dir string) (Store, error) {
+	switch {
+	case bucket != "":
+		return NewGCS(ctx, bucket)
+	case dir != "":
+		return NewDir(dir)
+	}
+	return nil, nil
+}
+
+// Dir is a Store keeping each key as a file below a local directory.
+type Dir struct {
+	root string
+}
+
+// NewDir returns a Store rooted at dir, creating the directory if needed.
+func NewDir(dir string) (*Dir, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("Error creating state directory: %v", err)
+	}
+	return &Dir{root: dir}, nil
+}
+
+func (d *Dir) path(key string) string {
+	return filepath.Join(d.root, filepath.FromSlash(key))
+}
+
+// Get implements Store.
+func (d *Dir) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(d.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Put implements Store. The file is written atomically via a rename.
+func (d *Dir) Put(ctx context.Context, key string, data []byte) error {
+	path := d.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Create implements Store.
+func (d *Dir) Create(ctx context.Context, key string, data []byte) error {
+	path := d.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, os.ErrExist) {
+		return ErrExists
+	}
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// List implements Store.
+func (d *Dir) List(ctx context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.WalkDir(d.root, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() || strings.HasSuffix(path, ".tmp") {
+			return err
+		}
+		rel, err := filepath.Rel(d.root, path)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	sort.Strings(keys)
+	return keys, err
+}
+
+// Delete implements Store.
+func (d *Dir) Delete(ctx context.Context, key string) error {
+	err := os.Remove(d.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,73 @@
+package summarize
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+// Bullet is one point of a summary with the release notes it is based on.
+type Bullet struct {
+	Text string `json:"text"`
+	// Notes are the 1-based indices of the release notes the point is
+	// based on.
+	Notes []int `json:"notes"`
+}
+
+// SummarizeWithCitations uses a Vertex AI Generative Model to summarize the
+// release notes of a product as bullet points, each citing the release notes
+// it is based on, so readers can trace every claim back to its source.
+func SummarizeWithCitations(ctx context.Context, projectID string, vertexModel string, location string, product string, releaseNotes []string) ([]Bullet, error) {
+	type note struct {
+		Index int    `json:"index"`
+		Text  string `json:"text"`
+	}
+	var numbered []note
+	for i, text := range releaseNotes {
+		numbered = append(numbered, note{Index: i + 1, Text: text})
+	}
+	releaseNotesJSON, err := json.Marshal(numbered)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal: %v", err)
+	}
+
+	prompt := genai.Text(
+		"Here are numbered release notes for " + product + ": " + string(releaseNotesJSON) +
+			" Summarize them as a few short bullet points like one person would say it to another. " +
+			"Don't mention the type of release notes. Don't go into details about specific versions. " +
+			"Cover the most important changes first. Every bullet point must only state what its cited release notes say. " +
+			"Answer with JSON only, an array of objects of the form " +
+			`{"text": "the bullet point", "notes": [indices of the release notes it is based on]}. ` + languageInstruction())
+
+	answer, err := generate(ctx, projectID, vertexModel, location, "cited summary of "+product, prompt)
+	if err != nil {
+		return nil, err
+	}
+	return parseBullets(answer, len(releaseNotes))
+}
+
+// parseBullets reads the JSON answer of the model, which may be wrapped in a
+// Markdown code block, dropping citations of release notes that do not exist.
+func parseBullets(answer string, count int) ([]Bullet, error) {
+	start, end := strings.Index(answer, "["), strings.LastIndex(answer, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("summary with citations is not JSON: %q", answer)
+	}
+	var bullets []Bullet
+	if err := json.Unmarshal([]byte(answer[start:end+1]), &bullets); err != nil {
+		return nil, fmt.Errorf("Error decoding summary with citations: %v", err)
+	}
+	for i := range bullets {
+		var valid []int
+		for _, n := range bullets[i].Notes {
+			if n >= 1 && n <= count {
+				valid = append(valid, n)
+			}
+		}
+		bullets[i].Notes = valid
+	}
+	return bullets, nil
+}
@@ -0,0 +1,39 @@
+package summarize
+
+import (
+	"context"
+	"sync"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+// clientPool holds a Vertex AI client per project and region, created on
+// first use and shared by every summary of the instance, including those
+// running concurrently, instead of a client per product.
+type clientPool struct {
+	mu      sync.Mutex
+	clients map[string]*genai.Client
+}
+
+var vertexClients = &clientPool{}
+
+// get returns the client of the project in location, creating it if needed.
+// The client outlives the request it was created for, so it is not bound to
+// the cancellation of ctx.
+func (p *clientPool) get(ctx context.Context, projectID, location string) (*genai.Client, error) {
+	key := projectID + "/" + location
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if client, ok := p.clients[key]; ok {
+		return client, nil
+	}
+	client, err := genai.NewClient(context.WithoutCancel(ctx), projectID, location)
+	if err != nil {
+		return nil, err
+	}
+	if p.clients == nil {
+		p.clients = make(map[string]*genai.Client)
+	}
+	p.clients[key] = client
+	return client, nil
+}
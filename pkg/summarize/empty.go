@@ -0,0 +1,69 @@
+package summarize
+
+import (
+	"errors"
+	"fmt"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+// EmptyResponseError is returned when the model answered without any text:
+// the prompt or the response was blocked, e.g. by safety filters, or the
+// response had no candidates.
+type EmptyResponseError struct {
+	// Task names the response, e.g. "summary of Cloud SQL".
+	Task string
+	// Reason says why there is no text, e.g. "finished with
+	// FinishReasonSafety".
+	Reason string
+}
+
+func (e *EmptyResponseError) Error() string {
+	return fmt.Sprintf("no text in the %s: %s", e.Task, e.Reason)
+}
+
+// IsEmptyResponse reports whether err is an EmptyResponseError.
+func IsEmptyResponse(err error) bool {
+	var empty *EmptyResponseError
+	return errors.As(err, &empty)
+}
+
+// RetryPrompt is the prompt a summary is asked for again with when the
+// first prompt got no text back. It asks for a plainly factual summary,
+// which is less likely to be blocked.
+var RetryPrompt = MustParsePrompt("retry",
+	"Here are release notes of the Google Cloud product {{.Product}}: {{.Notes}}"+
+		"Write a short, neutral and factual summary of these software changes for the engineers using the product, "+
+		"in a single plain paragraph. {{.Length}}{{.Language}}")
+
+// emptyResponse returns the error of a response without text, with the
+// reason given by the prompt feedback or the finish reason of its first
+// candidate.
+func emptyResponse(task string, feedback *genai.PromptFeedback, candidate *genai.Candidate) error {
+	reason := "empty text"
+	switch {
+	case feedback != nil && feedback.BlockReason != 0:
+		reason = "prompt blocked with " + feedback.BlockReason.String()
+		if feedback.BlockReasonMessage != "" {
+			reason += ": " + feedback.BlockReasonMessage
+		}
+	case candidate == nil:
+		reason = "no candidates"
+	case candidate.FinishReason != genai.FinishReasonUnspecified && candidate.FinishReason != genai.FinishReasonStop:
+		reason = "finished with " + candidate.FinishReason.String()
+		if candidate.FinishMessage != "" {
+			reason += ": " + candidate.FinishMessage
+		}
+	}
+	return &EmptyResponseError{Task: task, Reason: reason}
+}
+
+// blockedResponse converts the error of a blocked response to an
+// EmptyResponseError, and returns other errors as they are.
+func blockedResponse(task string, err error) error {
+	var blocked *genai.BlockedError
+	if !errors.As(err, &blocked) {
+		return err
+	}
+	return emptyResponse(task, blocked.PromptFeedback, blocked.Candidate)
+}
@@ -0,0 +1,56 @@
+package summarize
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+// RateImpact asks a Vertex AI Generative Model to rate the impact of each
+// release note of a product on the teams using it, as one of info, low,
+// medium, high or critical. It returns one rating per release note, in
+// order.
+func RateImpact(ctx context.Context, projectID string, vertexModel string, location string, product string, releaseNotesSlice []string) ([]string, error) {
+	releaseNotesSliceJSON, err := json.Marshal(releaseNotesSlice)
+	if err != nil {
+		return nil, fmt.Errorf("json.Marshal: %v", err)
+	}
+
+	prompt := genai.Text(
+		"Here are release notes for " + product + ": " + string(releaseNotesSliceJSON) +
+			" Rate the impact of each release note on the teams running workloads on " + product + ": " +
+			"critical if they must act urgently, e.g. for an exploited vulnerability or an imminent shutdown, " +
+			"high if they must act, e.g. migrate or upgrade before a date, medium if they should review it, " +
+			"low for improvements they may use and info for anything else. Answer with JSON only, an array " +
+			`with one rating per release note in the same order, e.g. ["high", "low"].`)
+
+	answer, err := generate(ctx, projectID, vertexModel, location, "impact rating of "+product, prompt)
+	if err != nil {
+		return nil, err
+	}
+	ratings, err := parseRatings(answer)
+	if err != nil {
+		return nil, err
+	}
+	if len(ratings) != len(releaseNotesSlice) {
+		return nil, fmt.Errorf("impact rating of %s: got %d ratings for %d release notes", product, len(ratings), len(releaseNotesSlice))
+	}
+	return ratings, nil
+}
+
+// parseRatings reads the JSON array answered by the model, which may be
+// wrapped in a Markdown code block.
+func parseRatings(answer string) ([]string, error) {
+	start, end := strings.Index(answer, "["), strings.LastIndex(answer, "]")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("impact rating answer is not JSON: %q", answer)
+	}
+	var ratings []string
+	if err := json.Unmarshal([]byte(answer[start:end+1]), &ratings); err != nil {
+		return nil, fmt.Errorf("Error decoding impact rating answer: %v", err)
+	}
+	return ratings, nil
+}
@@ -0,0 +1,55 @@
+package summarize
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+// Score is a judge model's rating of a summary, each on a scale from 1 to 5.
+type Score struct {
+	// Accuracy rates how well the summary sticks to the release notes.
+	Accuracy int `json:"accuracy"`
+	// Coverage rates how many of the important changes it mentions.
+	Coverage int `json:"coverage"`
+	// Clarity rates how easy it is to read.
+	Clarity int    `json:"clarity"`
+	Comment string `json:"comment"`
+}
+
+// Mean returns the average of the ratings.
+func (s Score) Mean() float64 {
+	return float64(s.Accuracy+s.Coverage+s.Clarity) / 3
+}
+
+// Judge asks a Vertex AI Generative Model to rate a summary of release notes.
+func Judge(ctx context.Context, projectID string, vertexModel string, location string, product string, releaseNotesSlice []string, summary string) (Score, error) {
+	releaseNotesSliceJSON, err := json.Marshal(releaseNotesSlice)
+	if err != nil {
+		return Score{}, fmt.Errorf("json.Marshal: %v", err)
+	}
+
+	prompt := genai.Text(
+		"Here are release notes for " + product + ": " + string(releaseNotesSliceJSON) +
+			" Here is a summary of them for engineers: " + summary +
+			" Rate the summary from 1 (poor) to 5 (excellent) for accuracy (it only states what the release notes say), " +
+			"coverage (it mentions the important changes) and clarity (it is short and easy to read). Answer with JSON only, in the form " +
+			`{"accuracy": 1-5, "coverage": 1-5, "clarity": 1-5, "comment": "one sentence"}.`)
+
+	answer, err := generate(ctx, projectID, vertexModel, location, "rating of "+product, prompt)
+	if err != nil {
+		return Score{}, err
+	}
+	start, end := strings.Index(answer, "{"), strings.LastIndex(answer, "}")
+	if start < 0 || end < start {
+		return Score{}, fmt.Errorf("judge answer is not JSON: %q", answer)
+	}
+	var s Score
+	if err := json.Unmarshal([]byte(answer[start:end+1]), &s); err != nil {
+		return Score{}, fmt.Errorf("Error decoding judge answer: %v", err)
+	}
+	return s, nil
+}
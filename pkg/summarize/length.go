@@ -0,0 +1,124 @@
+package summarize
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// Length is the target length of a summary, at most Chars characters and
+// Sentences sentences. Zero means no limit.
+type Length struct {
+	Chars     int
+	Sentences int
+}
+
+// ParseLength reads a target length of characters, sentences or both,
+// separated by a comma, e.g. "600", "600 chars", "3 sentences" or
+// "600 chars, 3 sentences".
+func ParseLength(spec string) (Length, error) {
+	var l Length
+	for _, part := range strings.Split(spec, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 {
+			continue
+		}
+		n, err := strconv.Atoi(fields[0])
+		if err != nil || n <= 0 || len(fields) > 2 {
+			return Length{}, fmt.Errorf("length %q: expected e.g. 600 chars or 3 sentences", strings.TrimSpace(part))
+		}
+		unit := "chars"
+		if len(fields) == 2 {
+			unit = strings.ToLower(fields[1])
+		}
+		switch unit {
+		case "chars", "characters":
+			l.Chars = n
+		case "sentence", "sentences":
+			l.Sentences = n
+		default:
+			return Length{}, fmt.Errorf("length %q: unknown unit %q, expected chars or sentences", strings.TrimSpace(part), fields[1])
+		}
+	}
+	return l, nil
+}
+
+// Within returns the length of the shorter of l and other in each limit.
+func (l Length) Within(other Length) Length {
+	shorter := func(a, b int) int {
+		if a == 0 || (b > 0 && b < a) {
+			return b
+		}
+		return a
+	}
+	return Length{Chars: shorter(l.Chars, other.Chars), Sentences: shorter(l.Sentences, other.Sentences)}
+}
+
+// instruction asks for a summary of the length, or is empty without a
+// limit.
+func (l Length) instruction() string {
+	switch {
+	case l.Chars > 0 && l.Sentences > 0:
+		return fmt.Sprintf("Write at most %d sentences and %d characters. ", l.Sentences, l.Chars)
+	case l.Chars > 0:
+		return fmt.Sprintf("Write at most %d characters. ", l.Chars)
+	case l.Sentences > 0:
+		return fmt.Sprintf("Write at most %d sentences. ", l.Sentences)
+	}
+	return ""
+}
+
+// sentenceEnd matches the end of a sentence, before the space following it.
+var sentenceEnd = regexp.MustCompile(`[.!?…](\s|$)`)
+
+// sentences splits text after the end of each sentence.
+func sentences(text string) []string {
+	var list []string
+	for text != "" {
+		loc := sentenceEnd.FindStringIndex(text)
+		if loc == nil {
+			return append(list, text)
+		}
+		end := loc[0] + utf8.RuneLen([]rune(text[loc[0]:])[0])
+		list = append(list, text[:end])
+		text = text[end:]
+	}
+	return list
+}
+
+// Fits reports whether text is within the length.
+func (l Length) Fits(text string) bool {
+	text = strings.TrimSpace(text)
+	return (l.Chars <= 0 || utf8.RuneCountInString(text) <= l.Chars) &&
+		(l.Sentences <= 0 || len(sentences(text)) <= l.Sentences)
+}
+
+// Trim cuts text to the length after the last whole sentence that fits. A
+// first sentence longer than the length is cut at a word boundary and ends
+// with an ellipsis.
+func (l Length) Trim(text string) string {
+	text = strings.TrimSpace(text)
+	if l.Fits(text) {
+		return text
+	}
+	var b strings.Builder
+	for i, s := range sentences(text) {
+		if l.Sentences > 0 && i == l.Sentences {
+			break
+		}
+		if l.Chars > 0 && utf8.RuneCountInString(b.String()+s) > l.Chars {
+			break
+		}
+		b.WriteString(s)
+	}
+	if trimmed := strings.TrimSpace(b.String()); trimmed != "" {
+		return trimmed
+	}
+	cut := []rune(text)[:l.Chars-1]
+	if i := strings.LastIndex(string(cut), " "); i > 0 {
+		return strings.TrimRight(string(cut)[:i], " ,.;:-") + "…"
+	}
+	return string(cut) + "…"
+}
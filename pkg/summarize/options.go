@@ -0,0 +1,64 @@
+package summarize
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Options are the generation settings of the model.
+type Options struct {
+	Temperature float32
+	TopK        int32
+	TopP        float32
+	// CandidateCount is the number of responses generated for a prompt.
+	// With several, the text most of them agree on is used.
+	CandidateCount int32
+	// StreamTimeout is the time a streamed response may pause, before its
+	// first part or between parts, before it is abandoned. Zero waits as
+	// long as the context allows.
+	StreamTimeout time.Duration
+}
+
+// Generation profiles.
+var (
+	// DefaultOptions write varied, natural summaries.
+	DefaultOptions = Options{Temperature: 0.2, TopK: 5, TopP: 0.95, CandidateCount: 1, StreamTimeout: DefaultStreamTimeout}
+	// DeterministicOptions always pick the most likely next token, so reruns
+	// over the same release notes produce the same summaries, as needed for
+	// audit replays and tests.
+	DeterministicOptions = Options{Temperature: 0, TopK: 1, TopP: 1, CandidateCount: 1, StreamTimeout: DefaultStreamTimeout}
+)
+
+// DefaultStreamTimeout is the StreamTimeout of the generation profiles.
+const DefaultStreamTimeout = 60 * time.Second
+
+var (
+	optionsMu sync.RWMutex
+	options   = DefaultOptions
+)
+
+// Profile returns the options of a generation profile, "default" or
+// "deterministic".
+func Profile(name string) (Options, error) {
+	switch name {
+	case "", "default":
+		return DefaultOptions, nil
+	case "deterministic":
+		return DeterministicOptions, nil
+	}
+	return Options{}, fmt.Errorf("unknown generation profile %q, expected default or deterministic", name)
+}
+
+// SetOptions sets the generation settings of all following model calls.
+func SetOptions(o Options) {
+	optionsMu.Lock()
+	defer optionsMu.Unlock()
+	options = o
+}
+
+func currentOptions() Options {
+	optionsMu.RLock()
+	defer optionsMu.RUnlock()
+	return options
+}
@@ -0,0 +1,79 @@
+package summarize
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/vertexai/genai"
+	"google.golang.org/api/iterator"
+)
+
+// stream generates the response to a prompt with the streaming API, so its
+// progress can be logged as it arrives and a stalled response is detected
+// after timeout instead of at the end of the run.
+func stream(ctx context.Context, model *genai.GenerativeModel, task string, prompt genai.Part, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	// The watchdog cancels the request when no part arrives within timeout.
+	var watchdog *time.Timer
+	var once sync.Once
+	stalled := make(chan struct{})
+	if timeout > 0 {
+		watchdog = time.AfterFunc(timeout, func() {
+			once.Do(func() { close(stalled) })
+			cancel()
+		})
+		defer watchdog.Stop()
+	}
+
+	start := time.Now()
+	var b strings.Builder
+	parts := 0
+	var feedback *genai.PromptFeedback
+	var candidate *genai.Candidate
+	iter := model.GenerateContentStream(ctx, prompt)
+	for {
+		resp, err := iter.Next()
+		if errors.Is(err, iterator.Done) {
+			break
+		}
+		if err != nil {
+			select {
+			case <-stalled:
+				return "", fmt.Errorf("no response for the %s within %s, after %d characters", task, timeout, b.Len())
+			default:
+			}
+			return "", blockedResponse(task, err)
+		}
+		if watchdog != nil {
+			watchdog.Reset(timeout)
+		}
+		if parts == 0 {
+			fmt.Printf("Receiving %s after %s...\n", task, time.Since(start).Round(time.Millisecond))
+		}
+		parts++
+		if resp.PromptFeedback != nil {
+			feedback = resp.PromptFeedback
+		}
+		if len(resp.Candidates) > 0 {
+			candidate = resp.Candidates[0]
+		}
+		if len(resp.Candidates) > 0 && resp.Candidates[0].Content != nil {
+			for _, part := range resp.Candidates[0].Content.Parts {
+				if textPart, ok := part.(genai.Text); ok {
+					b.WriteString(string(textPart))
+				}
+			}
+		}
+	}
+	fmt.Printf("Received %s: %d characters in %d parts after %s.\n", task, b.Len(), parts, time.Since(start).Round(time.Millisecond))
+	if strings.TrimSpace(b.String()) == "" {
+		return "", emptyResponse(task, feedback, candidate)
+	}
+	return b.String(), nil
+}
@@ -5,13 +5,86 @@ import (
 	"encoding/json"
 	"fmt"
 	"strings"
+	"text/template"
 
 	"cloud.google.com/go/vertexai/genai"
+	"github.com/mpolski/gcp-release-digest/pkg/i18n"
 )
 
 // Summarize uses a Vertex AI Generative Model to summarize a list of release notes for a given product.
 // The function returns a string containing the summarized text, or an error if any occurs during the process.
 func Summarize(ctx context.Context, projectID string, vertexModel string, location string, product string, releaseNotesSlice []string) (string, error) {
+	return DefaultPrompt.Summarize(ctx, projectID, vertexModel, location, product, releaseNotesSlice)
+}
+
+// Prompt is a template of the prompt asking for a summary, executed with
+// PromptData.
+type Prompt struct {
+	Name string
+	text string
+	tmpl *template.Template
+}
+
+// PromptData is the data of a prompt template.
+type PromptData struct {
+	// Product is the name of the product.
+	Product string
+	// Notes are the release notes in JSON format, alternating the type and
+	// the description of each note.
+	Notes string
+	// Language asks for the summary in the language of the locale, or is
+	// empty for English.
+	Language string
+	// Length asks for a summary within the target length of its channel,
+	// or is empty without one.
+	Length string
+}
+
+// DefaultPrompt is the prompt summaries are written with unless another one
+// is set. It includes the product name, the release notes in JSON format,
+// and instructions to keep the summary short and avoid mentioning the
+// release note types.
+var DefaultPrompt = MustParsePrompt("default",
+	"Here are release notes for {{.Product}}: {{.Notes}}"+
+		"Summarize descriptions into a single, plain paragraph like one person would say it to another. "+
+		"Don't mention the type of release notes. Don't go into details about specific versions. "+
+		"Cover the most important changes first, following the order of the release notes. "+
+		"Keep it short. {{.Length}}{{.Language}}")
+
+// ParsePrompt reads a prompt template in the text/template syntax, e.g.
+// "Summarize the release notes of {{.Product}} in one sentence: {{.Notes}}".
+func ParsePrompt(name, text string) (*Prompt, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing prompt %s: %v", name, err)
+	}
+	return &Prompt{Name: name, text: text, tmpl: tmpl}, nil
+}
+
+// Text returns the template the prompt was parsed from.
+func (p *Prompt) Text() string {
+	return p.text
+}
+
+// MustParsePrompt is like ParsePrompt but panics on an error.
+func MustParsePrompt(name, text string) *Prompt {
+	p, err := ParsePrompt(name, text)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Summarize asks the Vertex AI Generative Model for a summary of the release
+// notes of a product with the prompt.
+func (p *Prompt) Summarize(ctx context.Context, projectID string, vertexModel string, location string, product string, releaseNotesSlice []string) (string, error) {
+	return p.SummarizeWithin(ctx, projectID, vertexModel, location, product, releaseNotesSlice, Length{})
+}
+
+// SummarizeWithin is like Summarize, asking for a summary within length. A
+// longer summary is asked for once more, a quarter shorter, and if it is
+// still too long it is cut after the last whole sentence that fits.
+func (p *Prompt) SummarizeWithin(ctx context.Context, projectID string, vertexModel string, location string, product string, releaseNotesSlice []string, length Length) (string, error) {
 
 	// Marshal the release notes slice into JSON format.
 	releaseNotesSliceJSON, err := json.Marshal(releaseNotesSlice)
@@ -19,59 +92,115 @@ func Summarize(ctx context.Context, projectID string, vertexModel string, locati
 		return "", fmt.Errorf("json.Marshal: %v", err)
 	}
 
-	// Construct the prompt for the Vertex AI Generative Model.
-	// The prompt includes the product name, the release notes in JSON format,
-	// and instructions to keep the summary short and avoid mentioning the release note types.
-	prompt := genai.Text(
-		"Here are release notes for " + product + ": " + string(releaseNotesSliceJSON) +
-			"Summarize descriptions into a single, plain paragraph like one person would say it to another. " +
-			"Don't mention the type of release notes. Don't go into details about specific versions." +
-			"Keep it short. ")
-
-	// Create a new Vertex AI Generative Model client.
-	client, err := genai.NewClient(ctx, projectID, location)
-	if err != nil {
+	ask := func(length Length) (string, error) {
+		// Construct the prompt for the Vertex AI Generative Model.
+		var b strings.Builder
+		data := PromptData{Product: product, Notes: string(releaseNotesSliceJSON), Language: languageInstruction(), Length: length.instruction()}
+		if err := p.tmpl.Execute(&b, data); err != nil {
+			return "", fmt.Errorf("Error executing prompt %s: %v", p.Name, err)
+		}
+		return generate(ctx, projectID, vertexModel, location, "summary of "+product, genai.Text(b.String()))
+	}
+
+	summary, err := ask(length)
+	if err != nil || length.Fits(summary) {
+		return summary, err
+	}
+	fmt.Printf("Summary of %s is longer than its target length, asking for a shorter one\n", product)
+	shorter := Length{Chars: length.Chars * 3 / 4, Sentences: length.Sentences}
+	if retry, err := ask(shorter); err == nil {
+		summary = retry
+	}
+	return length.Trim(summary), nil
+}
+
+// generate sends a prompt to a Vertex AI Generative Model and returns the
+// text of its response. The task, e.g. "summary of Cloud SQL", names the
+// response in progress logs.
+func generate(ctx context.Context, projectID string, vertexModel string, location string, task string, prompt genai.Part) (string, error) {
+	// Stay within the model's quota when summaries run concurrently.
+	if err := modelThrottle.wait(ctx, vertexModel); err != nil {
 		return "", err
 	}
 
-	// Close the client when the function exits.
-	defer client.Close()
+	// Get the Vertex AI client of the region, shared by all summaries.
+	client, err := vertexClients.get(ctx, projectID, location)
+	if err != nil {
+		return "", err
+	}
 
 	// Get the Generative Model from the client.
 	model := client.GenerativeModel(vertexModel)
 
-	// Set the model parameters for temperature, top_k, and top_p.
-	// These parameters control the creativity and diversity of the generated text.
-	model.SetTemperature(0.2)
-	model.SetTopK(5)
-	model.SetTopP(0.95)
+	// Set the model parameters for temperature, top_k, top_p and the number
+	// of candidates. These parameters control the creativity and diversity
+	// of the generated text.
+	opts := currentOptions()
+	model.SetTemperature(opts.Temperature)
+	model.SetTopK(float32(opts.TopK))
+	model.SetTopP(opts.TopP)
+	if opts.CandidateCount > 1 {
+		model.SetCandidateCount(opts.CandidateCount)
+	} else {
+		// A single response is streamed, logging its progress.
+		return stream(ctx, model, task, prompt, opts.StreamTimeout)
+	}
 
 	// Generate content using the model and the prompt.
 	resp, err := model.GenerateContent(ctx, prompt)
 	if err != nil {
-		return "", err
+		return "", blockedResponse(task, err)
 	} else {
 		// Print a confirmation message indicating that the summarization was successful.
 		fmt.Println("Summarization executed with success.")
 	}
 
-	// Initialize a slice to store the text parts from the generated content.
-	var allTextParts []string
-
-	// Iterate over the candidates and their content parts.
-	// Extract the text parts and append them to the allTextParts slice.
+	// Extract the text of each candidate, joining its text parts with
+	// spaces, and return the one most candidates agree on.
+	var texts []string
 	for _, candidate := range resp.Candidates {
+		if candidate.Content == nil {
+			continue
+		}
+		var allTextParts []string
 		for _, part := range candidate.Content.Parts {
 			if textPart, ok := part.(genai.Text); ok {
 				allTextParts = append(allTextParts, string(textPart))
 			}
 		}
+		if text := strings.Join(allTextParts, " "); strings.TrimSpace(text) != "" {
+			texts = append(texts, text)
+		}
 	}
+	if len(texts) == 0 {
+		var candidate *genai.Candidate
+		if len(resp.Candidates) > 0 {
+			candidate = resp.Candidates[0]
+		}
+		return "", emptyResponse(task, resp.PromptFeedback, candidate)
+	}
+	return mostFrequent(texts), nil
+}
 
-	// Join the text parts into a single string, separated by spaces.
-	combinedText := strings.Join(allTextParts, " ")
-
-	// Return the combined text as the summary.
-	return combinedText, nil
+// mostFrequent returns the text occurring most often, the first of them on
+// a tie, or an empty string if there are none.
+func mostFrequent(texts []string) string {
+	best, bestCount := "", 0
+	counts := make(map[string]int)
+	for _, text := range texts {
+		counts[text]++
+		if counts[text] > bestCount {
+			best, bestCount = text, counts[text]
+		}
+	}
+	return best
+}
 
+// languageInstruction asks for the summary in the language of the selected
+// locale, or nothing for English.
+func languageInstruction() string {
+	if lang := i18n.M().Language; lang != "English" {
+		return "Write the summary in " + lang + ". "
+	}
+	return ""
 }
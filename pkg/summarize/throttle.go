@@ -0,0 +1,90 @@
+package summarize
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// throttle spaces the requests to each model evenly, so concurrent
+// summaries stay within the model's quota of requests per minute.
+type throttle struct {
+	mu sync.Mutex
+	// qpm holds the quota of the models with their own, and defaultQPM
+	// that of all others. Zero is unlimited.
+	qpm        map[string]int
+	defaultQPM int
+	next       map[string]time.Time
+}
+
+var modelThrottle = &throttle{}
+
+// ParseQPM reads quotas of requests per minute as a comma separated list of
+// model=qpm entries, e.g. "gemini-1.5-pro=60,gemini-1.5-flash=200", where an
+// entry without a model is the quota of all other models.
+func ParseQPM(spec string) (map[string]int, int, error) {
+	quotas := make(map[string]int)
+	defaultQPM := 0
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		model, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			model, value = "", entry
+		}
+		qpm, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || qpm < 0 {
+			return nil, 0, fmt.Errorf("quota %q: expected model=requests per minute", entry)
+		}
+		if model = strings.TrimSpace(model); model == "" {
+			defaultQPM = qpm
+		} else {
+			quotas[model] = qpm
+		}
+	}
+	return quotas, defaultQPM, nil
+}
+
+// SetQPM sets the quotas of requests per minute of the models, those of
+// ParseQPM.
+func SetQPM(quotas map[string]int, defaultQPM int) {
+	modelThrottle.mu.Lock()
+	defer modelThrottle.mu.Unlock()
+	modelThrottle.qpm = quotas
+	modelThrottle.defaultQPM = defaultQPM
+}
+
+// wait blocks until a request to the model is within its quota.
+func (t *throttle) wait(ctx context.Context, model string) error {
+	t.mu.Lock()
+	qpm, ok := t.qpm[model]
+	if !ok {
+		qpm = t.defaultQPM
+	}
+	if qpm <= 0 {
+		t.mu.Unlock()
+		return nil
+	}
+	if t.next == nil {
+		t.next = make(map[string]time.Time)
+	}
+	now := time.Now()
+	at := t.next[model]
+	if at.Before(now) {
+		at = now
+	}
+	t.next[model] = at.Add(time.Minute / time.Duration(qpm))
+	t.mu.Unlock()
+
+	select {
+	case <-time.After(time.Until(at)):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
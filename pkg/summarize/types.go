@@ -0,0 +1,78 @@
+package summarize
+
+// Prompts specialized for release note types. Each keeps the details that
+// matter for its type, which the default prompt leaves out.
+var (
+	// SecurityPrompt keeps the CVE IDs and affected versions of security
+	// bulletins.
+	SecurityPrompt = MustParsePrompt("SECURITY_BULLETIN",
+		"Here are release notes for {{.Product}}: {{.Notes}}"+
+			"Summarize descriptions into a single, plain paragraph. "+
+			"Don't mention the type of release notes. "+
+			"Cover the security bulletins first. Keep every CVE ID, severity and affected and fixed version exactly as written, "+
+			"and say what users need to do, if anything. "+
+			"Keep it short. {{.Length}}{{.Language}}")
+	// BreakingChangePrompt keeps the dates of breaking changes and what users
+	// need to change.
+	BreakingChangePrompt = MustParsePrompt("BREAKING_CHANGE",
+		"Here are release notes for {{.Product}}: {{.Notes}}"+
+			"Summarize descriptions into a single, plain paragraph. "+
+			"Don't mention the type of release notes. Don't go into details about specific versions unless users need them to act. "+
+			"Cover the breaking changes first. Keep every date exactly as written and say what users need to change. "+
+			"Keep it short. {{.Length}}{{.Language}}")
+	// DeprecationPrompt keeps the dates of deprecations and what replaces
+	// them.
+	DeprecationPrompt = MustParsePrompt("DEPRECATION",
+		"Here are release notes for {{.Product}}: {{.Notes}}"+
+			"Summarize descriptions into a single, plain paragraph. "+
+			"Don't mention the type of release notes. Don't go into details about specific versions. "+
+			"Cover the deprecations first. Keep every date and deadline exactly as written and say what replaces what is deprecated. "+
+			"Keep it short. {{.Length}}{{.Language}}")
+	// FeaturePrompt writes breezier summaries of new features.
+	FeaturePrompt = MustParsePrompt("FEATURE",
+		"Here are release notes for {{.Product}}: {{.Notes}}"+
+			"Summarize descriptions into a single, light paragraph, like one person would tell another what they can now do. "+
+			"Don't mention the type of release notes. Don't go into details about specific versions. "+
+			"Cover the most useful features first. "+
+			"Keep it short. {{.Length}}{{.Language}}")
+)
+
+// TypePrompts returns the built-in prompts by the release note type they are
+// specialized for.
+func TypePrompts() map[string]*Prompt {
+	return map[string]*Prompt{
+		"SECURITY_BULLETIN": SecurityPrompt,
+		"BREAKING_CHANGE":   BreakingChangePrompt,
+		"DEPRECATION":       DeprecationPrompt,
+		"FEATURE":           FeaturePrompt,
+	}
+}
+
+// criticalTypes are the release note types whose prompt is used for notes
+// mixed with other types, most critical first, as losing their details
+// costs readers most.
+var criticalTypes = []string{"SECURITY_BULLETIN", "BREAKING_CHANGE", "DEPRECATION"}
+
+// PromptFor returns the prompt of prompts summarizing release notes of the
+// types: that of the type if all have the same one, otherwise that of the
+// most critical type among them. It returns DefaultPrompt if prompts has
+// none for them.
+func PromptFor(prompts map[string]*Prompt, releaseNoteTypes []string) *Prompt {
+	present := make(map[string]bool)
+	for _, t := range releaseNoteTypes {
+		present[t] = true
+	}
+	if len(present) == 1 {
+		for t := range present {
+			if p := prompts[t]; p != nil {
+				return p
+			}
+		}
+	}
+	for _, t := range criticalTypes {
+		if p := prompts[t]; p != nil && present[t] {
+			return p
+		}
+	}
+	return DefaultPrompt
+}
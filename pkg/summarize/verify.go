@@ -0,0 +1,59 @@
+package summarize
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/vertexai/genai"
+)
+
+// Verdict is the outcome of checking a summary against its release notes.
+type Verdict struct {
+	// Supported is false if the summary makes claims the release notes do
+	// not support.
+	Supported bool `json:"supported"`
+	// Unsupported lists those claims.
+	Unsupported []string `json:"unsupported"`
+}
+
+// Verify asks a Vertex AI Generative Model, usually a cheaper one than the
+// summarizing model, whether every claim of a summary is supported by the
+// release notes it was written from.
+func Verify(ctx context.Context, projectID string, vertexModel string, location string, product string, releaseNotesSlice []string, summary string) (Verdict, error) {
+	releaseNotesSliceJSON, err := json.Marshal(releaseNotesSlice)
+	if err != nil {
+		return Verdict{}, fmt.Errorf("json.Marshal: %v", err)
+	}
+
+	prompt := genai.Text(
+		"Here are release notes for " + product + ": " + string(releaseNotesSliceJSON) +
+			" Here is a summary of them: " + summary +
+			" Check every claim of the summary against the release notes. A claim is unsupported if the release notes " +
+			"do not state it or contradict it; leaving out details is fine. Answer with JSON only, in the form " +
+			`{"supported": true or false, "unsupported": ["each unsupported claim"]}.`)
+
+	answer, err := generate(ctx, projectID, vertexModel, location, "verification of "+product, prompt)
+	if err != nil {
+		return Verdict{}, err
+	}
+	return parseVerdict(answer)
+}
+
+// parseVerdict reads the JSON answer of the model, which may be wrapped in a
+// Markdown code block.
+func parseVerdict(answer string) (Verdict, error) {
+	start, end := strings.Index(answer, "{"), strings.LastIndex(answer, "}")
+	if start < 0 || end < start {
+		return Verdict{}, fmt.Errorf("verification answer is not JSON: %q", answer)
+	}
+	var v Verdict
+	if err := json.Unmarshal([]byte(answer[start:end+1]), &v); err != nil {
+		return Verdict{}, fmt.Errorf("Error decoding verification answer: %v", err)
+	}
+	if len(v.Unsupported) > 0 {
+		v.Supported = false
+	}
+	return v, nil
+}
@@ -0,0 +1,75 @@
+package tasks
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"google.golang.org/api/cloudtasks/v2"
+)
+
+// Message is the body of a task: a webhook payload to deliver, with the
+// request headers it was first sent with.
+type Message struct {
+	WebhookURL string      `json:"webhook_url"`
+	Payload    string      `json:"payload"`
+	Header     http.Header `json:"header,omitempty"`
+}
+
+// Queue enqueues failed webhook sends to a Cloud Tasks queue, which calls the
+// send endpoint with retries and backoff until the webhook accepts them.
+type Queue struct {
+	// Name is the full queue name,
+	// e.g. projects/my-project/locations/us-central1/queues/digest-retries.
+	Name string
+	// SendURL is the URL of the send function delivering a single message.
+	SendURL string
+	// ServiceAccount is the email of the service account used to call the
+	// send function with an OIDC token.
+	ServiceAccount string
+
+	once sync.Once
+	svc  *cloudtasks.Service
+	err  error
+}
+
+// Enqueue creates a task delivering payload to webhookURL with header.
+func (q *Queue) Enqueue(ctx context.Context, webhookURL, payload string, header http.Header) error {
+	body, err := json.Marshal(Message{WebhookURL: webhookURL, Payload: payload, Header: header})
+	if err != nil {
+		return err
+	}
+	if err := q.Post(ctx, body); err != nil {
+		return fmt.Errorf("Error creating retry task: %v", err)
+	}
+	return nil
+}
+
+// Post creates a task posting a JSON body to SendURL, for work that takes
+// longer than the request that asked for it may wait.
+func (q *Queue) Post(ctx context.Context, body []byte) error {
+	q.once.Do(func() {
+		q.svc, q.err = cloudtasks.NewService(ctx)
+	})
+	if q.err != nil {
+		return fmt.Errorf("Error creating Cloud Tasks client: %v", q.err)
+	}
+
+	task := &cloudtasks.Task{
+		HttpRequest: &cloudtasks.HttpRequest{
+			HttpMethod: "POST",
+			Url:        q.SendURL,
+			Headers:    map[string]string{"Content-Type": "application/json"},
+			Body:       base64.StdEncoding.EncodeToString(body),
+		},
+	}
+	if q.ServiceAccount != "" {
+		task.HttpRequest.OidcToken = &cloudtasks.OidcToken{ServiceAccountEmail: q.ServiceAccount, Audience: q.SendURL}
+	}
+
+	_, err := q.svc.Projects.Locations.Queues.Tasks.Create(q.Name, &cloudtasks.CreateTaskRequest{Task: task}).Context(ctx).Do()
+	return err
+}
@@ -0,0 +1,149 @@
+package terraform
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mpolski/gcp-release-digest/pkg/notify"
+	"github.com/mpolski/gcp-release-digest/pkg/releasenotes"
+)
+
+// ReleasesURL lists the releases of the Google provider for Terraform on
+// GitHub.
+const ReleasesURL = "https://api.github.com/repos/hashicorp/terraform-provider-google/releases?per_page=50"
+
+// Release is a release of the provider and the services its changes touch.
+type Release struct {
+	// Version is the tag of the release, e.g. "v6.2.0".
+	Version   string
+	URL       string
+	Published time.Time
+	// Services are the provider services of its changes, e.g. "compute".
+	Services []string
+}
+
+// services maps the provider services, as the changelog prefixes its
+// entries, to the normalized names of the products whose release notes they
+// relate to.
+var services = map[string]string{
+	"alloydb":          "alloydb for postgresql",
+	"artifactregistry": "artifact registry",
+	"bigquery":         "bigquery",
+	"bigtable":         "bigtable",
+	"cloudbuild":       "build",
+	"clouddeploy":      "deploy",
+	"cloudfunctions":   "functions",
+	"cloudfunctions2":  "functions",
+	"cloudrun":         "run",
+	"cloudrunv2":       "run",
+	"cloudscheduler":   "scheduler",
+	"cloudtasks":       "tasks",
+	"composer":         "composer",
+	"compute":          "compute engine",
+	"container":        "kubernetes engine",
+	"datafusion":       "data fusion",
+	"dataflow":         "dataflow",
+	"dataplex":         "dataplex",
+	"dataproc":         "dataproc",
+	"dns":              "dns",
+	"eventarc":         "eventarc",
+	"filestore":        "filestore",
+	"firestore":        "firestore",
+	"iam":              "identity and access management",
+	"kms":              "key management service",
+	"logging":          "logging",
+	"looker":           "looker",
+	"monitoring":       "monitoring",
+	"pubsub":           "pub sub",
+	"redis":            "memorystore for redis",
+	"secretmanager":    "secret manager",
+	"securitycenter":   "security command center",
+	"spanner":          "spanner",
+	"sql":              "sql",
+	"storage":          "storage",
+	"vertexai":         "vertex ai",
+	"vmwareengine":     "vmware engine",
+	"workflows":        "workflows",
+	"workstations":     "workstations",
+}
+
+// changelogEntry matches an entry of the changelog of a release, e.g.
+// "* compute: added `foo` field to `google_compute_instance` resource".
+var changelogEntry = regexp.MustCompile(`(?m)^\s*[*-]\s+([a-z0-9]+):`)
+
+// release is a release as listed by the GitHub API.
+type release struct {
+	TagName     string    `json:"tag_name"`
+	HTMLURL     string    `json:"html_url"`
+	PublishedAt time.Time `json:"published_at"`
+	Body        string    `json:"body"`
+	Draft       bool      `json:"draft"`
+	Prerelease  bool      `json:"prerelease"`
+}
+
+// Fetch returns the releases of the provider published since the given
+// time, newest first. The token, if set, authenticates the GitHub API
+// requests, which are otherwise limited to 60 an hour.
+func Fetch(ctx context.Context, token string, since time.Time) ([]Release, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", ReleasesURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := notify.Client(ReleasesURL).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching Terraform provider releases: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("Error fetching Terraform provider releases: %s %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	var listed []release
+	if err := json.NewDecoder(resp.Body).Decode(&listed); err != nil {
+		return nil, fmt.Errorf("Error decoding Terraform provider releases: %v", err)
+	}
+
+	var releases []Release
+	for _, l := range listed {
+		if l.Draft || l.Prerelease || l.PublishedAt.Before(since) {
+			continue
+		}
+		r := Release{Version: l.TagName, URL: l.HTMLURL, Published: l.PublishedAt}
+		for _, m := range changelogEntry.FindAllStringSubmatch(l.Body, -1) {
+			if _, ok := services[m[1]]; ok && !slices.Contains(r.Services, m[1]) {
+				r.Services = append(r.Services, m[1])
+			}
+		}
+		releases = append(releases, r)
+	}
+	slices.SortFunc(releases, func(a, b Release) int { return b.Published.Compare(a.Published) })
+	return releases, nil
+}
+
+// Related returns the releases touching the services of a product, newest
+// first. A product matches a service by its normalized name, or a name it
+// starts with, e.g. "BigQuery BI Engine" matches bigquery.
+func Related(releases []Release, product string) []Release {
+	name := releasenotes.Normalize(product)
+	var related []Release
+	for _, r := range releases {
+		if slices.ContainsFunc(r.Services, func(s string) bool {
+			known := services[s]
+			return name == known || strings.HasPrefix(name, known+" ")
+		}) {
+			related = append(related, r)
+		}
+	}
+	return related
+}
@@ -0,0 +1,136 @@
+package textdiff
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Op is what happened to a line between two texts.
+type Op int
+
+const (
+	// Equal lines are in both texts.
+	Equal Op = iota
+	// Changed lines replace a line of the first text by one of the second.
+	Changed
+	// Deleted lines are only in the first text.
+	Deleted
+	// Inserted lines are only in the second text.
+	Inserted
+)
+
+// Line is a line of the first text, A, aligned with one of the second, B.
+type Line struct {
+	Op   Op
+	A, B string
+}
+
+// Lines aligns the lines of a and b along their longest common subsequence.
+// A run of deleted lines followed by inserted ones is paired into changed
+// lines.
+func Lines(a, b []string) []Line {
+	// common[i][j] is the length of the longest common subsequence of
+	// a[i:] and b[j:].
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var lines, deleted, inserted []Line
+	flush := func() {
+		for len(deleted) > 0 && len(inserted) > 0 {
+			lines = append(lines, Line{Op: Changed, A: deleted[0].A, B: inserted[0].B})
+			deleted, inserted = deleted[1:], inserted[1:]
+		}
+		lines = append(append(lines, deleted...), inserted...)
+		deleted, inserted = nil, nil
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			flush()
+			lines = append(lines, Line{Op: Equal, A: a[i], B: b[j]})
+			i, j = i+1, j+1
+		case j == len(b) || (i < len(a) && common[i+1][j] >= common[i][j+1]):
+			deleted = append(deleted, Line{Op: Deleted, A: a[i]})
+			i++
+		default:
+			inserted = append(inserted, Line{Op: Inserted, B: b[j]})
+			j++
+		}
+	}
+	flush()
+	return lines
+}
+
+// markers separate the columns of a row by the Op of its line, as diff -y
+// does.
+var markers = map[Op]string{Equal: "   ", Changed: " | ", Deleted: " < ", Inserted: " > "}
+
+// SideBySide renders two texts next to each other in columns of width
+// characters, titled with the given titles, marking changed lines with |,
+// lines only in a with < and lines only in b with >. Long lines are
+// wrapped within their column.
+func SideBySide(titleA, titleB, a, b string, width int) string {
+	var out strings.Builder
+	row := func(left, marker, right string) {
+		fmt.Fprintf(&out, "%s%s%s%s\n", left, strings.Repeat(" ", max(width-utf8.RuneCountInString(left), 0)), marker, right)
+	}
+	row(titleA, markers[Equal], titleB)
+	row(strings.Repeat("=", width), markers[Equal], strings.Repeat("=", width))
+	for _, l := range Lines(strings.Split(a, "\n"), strings.Split(b, "\n")) {
+		left, right := wrap(l.A, width), wrap(l.B, width)
+		for k := 0; k < max(len(left), len(right)); k++ {
+			var cellA, cellB string
+			if k < len(left) && l.Op != Inserted {
+				cellA = left[k]
+			}
+			if k < len(right) && l.Op != Deleted {
+				cellB = right[k]
+			}
+			row(cellA, markers[l.Op], cellB)
+		}
+	}
+	return out.String()
+}
+
+// Changes returns the number of lines that are not equal.
+func Changes(a, b string) int {
+	n := 0
+	for _, l := range Lines(strings.Split(a, "\n"), strings.Split(b, "\n")) {
+		if l.Op != Equal {
+			n++
+		}
+	}
+	return n
+}
+
+// wrap breaks a line into rows of at most width characters, between words
+// where possible. An empty line is one empty row.
+func wrap(line string, width int) []string {
+	var rows []string
+	runes := []rune(line)
+	for len(runes) > width {
+		cut := width
+		for k := width; k > 0; k-- {
+			if runes[k] == ' ' {
+				cut = k
+				break
+			}
+		}
+		rows = append(rows, strings.TrimRight(string(runes[:cut]), " "))
+		runes = []rune(strings.TrimLeft(string(runes[cut:]), " "))
+	}
+	return append(rows, string(runes))
+}
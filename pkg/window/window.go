@@ -0,0 +1,84 @@
+package window
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Window is a daily delivery window, e.g. 08:00-18:00 in Europe/Warsaw.
+// The zero Window is always open.
+type Window struct {
+	start, end int // minutes after midnight
+	loc        *time.Location
+	set        bool
+}
+
+// Parse parses a window in the form "HH:MM-HH:MM" evaluated in the IANA
+// timezone tz (UTC if empty). Windows may wrap around midnight, e.g.
+// "22:00-06:00". An empty spec returns a Window that is always open.
+func Parse(spec, tz string) (Window, error) {
+	if spec == "" {
+		return Window{}, nil
+	}
+	loc := time.UTC
+	if tz != "" {
+		var err error
+		loc, err = time.LoadLocation(tz)
+		if err != nil {
+			return Window{}, fmt.Errorf("invalid timezone %q: %v", tz, err)
+		}
+	}
+	from, to, ok := strings.Cut(spec, "-")
+	if !ok {
+		return Window{}, fmt.Errorf("invalid window %q, expected HH:MM-HH:MM", spec)
+	}
+	start, err := parseClock(from)
+	if err != nil {
+		return Window{}, err
+	}
+	end, err := parseClock(to)
+	if err != nil {
+		return Window{}, err
+	}
+	if start == end {
+		return Window{}, fmt.Errorf("invalid window %q, start and end are equal", spec)
+	}
+	return Window{start: start, end: end, loc: loc, set: true}, nil
+}
+
+// parseClock converts "HH:MM" into minutes after midnight.
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Always reports whether the window is unrestricted.
+func (w Window) Always() bool {
+	return !w.set
+}
+
+// Open reports whether t falls inside the window.
+func (w Window) Open(t time.Time) bool {
+	if !w.set {
+		return true
+	}
+	local := t.In(w.loc)
+	now := local.Hour()*60 + local.Minute()
+	if w.start < w.end {
+		return now >= w.start && now < w.end
+	}
+	// The window wraps around midnight.
+	return now >= w.start || now < w.end
+}
+
+// String returns the window in the form "08:00-18:00 Europe/Warsaw".
+func (w Window) String() string {
+	if !w.set {
+		return "always"
+	}
+	return fmt.Sprintf("%02d:%02d-%02d:%02d %s", w.start/60, w.start%60, w.end/60, w.end%60, w.loc)
+}
@@ -0,0 +1,1438 @@
+package digest
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mpolski/gcp-release-digest/pkg/archive"
+	"github.com/mpolski/gcp-release-digest/pkg/compliance"
+	"github.com/mpolski/gcp-release-digest/pkg/config"
+	"github.com/mpolski/gcp-release-digest/pkg/deprecations"
+	"github.com/mpolski/gcp-release-digest/pkg/digest"
+	"github.com/mpolski/gcp-release-digest/pkg/email"
+	"github.com/mpolski/gcp-release-digest/pkg/escalation"
+	"github.com/mpolski/gcp-release-digest/pkg/flags"
+	"github.com/mpolski/gcp-release-digest/pkg/i18n"
+	"github.com/mpolski/gcp-release-digest/pkg/impact"
+	"github.com/mpolski/gcp-release-digest/pkg/notify"
+	"github.com/mpolski/gcp-release-digest/pkg/pagerduty"
+	"github.com/mpolski/gcp-release-digest/pkg/products"
+	"github.com/mpolski/gcp-release-digest/pkg/push"
+	"github.com/mpolski/gcp-release-digest/pkg/releasenotes"
+	"github.com/mpolski/gcp-release-digest/pkg/report"
+	"github.com/mpolski/gcp-release-digest/pkg/routing"
+	"github.com/mpolski/gcp-release-digest/pkg/scrub"
+	"github.com/mpolski/gcp-release-digest/pkg/sms"
+	"github.com/mpolski/gcp-release-digest/pkg/store"
+	"github.com/mpolski/gcp-release-digest/pkg/summarize"
+	"github.com/mpolski/gcp-release-digest/pkg/terraform"
+)
+
+// run holds the settings and state shared by every channel of a digest run.
+type run struct {
+	projectID     string
+	model         string
+	modelLocation string
+	cadence       string
+	cadenceInt    int
+
+	noteOpts        releasenotes.Options
+	productOrder    string
+	productPriority []string
+	typeSections    bool
+	announceOpts    notify.AnnounceOptions
+	batchSize       int
+	batchMaxChars   int
+	messageMaxChars int
+	closingMsg      string
+
+	// verifyModel checks summaries against their release notes if set, and
+	// verifyAction is "label" or "notes" for summaries that fail.
+	verifyModel  string
+	verifyAction string
+	// impact scores every release note.
+	impact impactSettings
+	// citations has summaries written as bullet points citing their
+	// release notes.
+	citations bool
+	// variants summarize a share of the products with alternate prompts.
+	variants []promptVariant
+	// feedbackSecret signs the rating links of the summaries.
+	feedbackSecret string
+	// typePrompts are the prompts specialized for release note types, or
+	// nil to use the default prompt for all.
+	typePrompts map[string]*summarize.Prompt
+	// docsLinks links every summary to its product's release notes page.
+	docsLinks bool
+	// providerReleases are the recent releases of the Terraform provider
+	// linked from the summaries of the products they touch.
+	providerReleases []terraform.Release
+	// expander links every summary to the expand function, if set.
+	expander *expander
+	// concurrency is the number of products summarized at once.
+	concurrency int
+	// stream delivers each product as soon as it is summarized, and is set
+	// when the digest goes straight to its channels.
+	stream bool
+	// fetchOnly leaves the products without summaries, for the summarize
+	// steps.
+	fetchOnly bool
+	// deadline stops summarizing further products when it passes, unless
+	// it is zero.
+	deadline time.Time
+	// activity is the history of note counts products with unusually high
+	// activity are flagged against, if set.
+	activity *archive.Activity
+	// deprecations are the open deprecations the DEPRECATION release notes
+	// of the run are added to, if set.
+	deprecations *deprecations.Board
+	// unlock releases the leases the run holds on its channels, if any.
+	unlock func()
+
+	escalation  escalationSettings
+	pager       pagerSettings
+	jira        jiraSettings
+	github      githubSettings
+	compliance  complianceSettings
+	scrubber    scrub.Scrubber
+	attachments attachmentSettings
+	email       emailSettings
+	push        *push.FCM
+
+	// Feature flags of the channels with their own, and of all others.
+	features       map[string]flags.Set
+	globalFeatures flags.Set
+	// card renders the summaries of channels with the cards feature.
+	card digest.Card
+
+	// mirrors are the further webhook URLs of the channels fanning out to
+	// several targets.
+	mirrors map[string][]string
+
+	doc    *digest.Document
+	record *archive.Digest
+	report *report.Report
+}
+
+// impactSettings configures scoring the impact of release notes by rules,
+// and by a model if set.
+type impactSettings struct {
+	scorer *impact.Scorer
+	model  string
+}
+
+// escalationSettings configures sending an extra copy of high-impact
+// summaries to an escalation channel, and text message alerts of critical
+// ones.
+type escalationSettings struct {
+	rules      escalation.Rules
+	webhookURL string
+	mention    string
+
+	// Matches of smsRules are also texted to the on-call phone numbers.
+	smsRules    escalation.Rules
+	sms         *sms.Twilio
+	smsTo       []string
+	smsMaxChars int
+
+	// A digest exceeding any of the thresholds is marked urgent and
+	// reported to the threshold webhook.
+	thresholds       []escalation.Threshold
+	thresholdWebhook string
+}
+
+// pagerSettings configures the PagerDuty incidents paging on-call for
+// products whose release notes match the paging rules.
+type pagerSettings struct {
+	events   *pagerduty.Events
+	rules    escalation.Rules
+	severity string
+	// instead leaves the products paged for out of the chat messages of
+	// their channel.
+	instead bool
+	// paged records for each channel and product whether on-call was
+	// paged, shared with the mirrors of the channel.
+	paged map[string]bool
+}
+
+// complianceSettings configures the filter of banned phrases applied to
+// summaries before they are sent.
+type complianceSettings struct {
+	filter *compliance.Filter
+	// actions holds the action of the channels with their own, and
+	// defaultAction that of all others.
+	actions       map[string]string
+	defaultAction string
+	// Blocked summaries are reported to the ops webhook.
+	opsWebhook string
+}
+
+// attachmentSettings configures linking the full release notes of products
+// with many notes, uploaded to the state store, from their summary.
+type attachmentSettings struct {
+	threshold      int
+	expires        time.Duration
+	serviceAccount string
+	signer         store.URLSigner
+	store          store.Store
+	// exportFormats are the formats, "csv" or "json", the raw release notes
+	// of every channel are exported in.
+	exportFormats []string
+}
+
+// emailSettings configures emailing the whole digest at the end of the run.
+type emailSettings struct {
+	sender         email.Sender
+	template       *email.Template
+	profiles       []email.Profile
+	from           string
+	subject        string
+	unsubscribeURL string
+}
+
+// fetchFunc returns the release notes of a product for one channel.
+type fetchFunc func(ctx context.Context, product string) ([]releasenotes.ReleaseNote, error)
+
+// buildChannel adds a channel to the digest document, with a summary of each
+// product's release notes of the last cadence days returned by fetch. When
+// the run streams, each product is delivered as soon as it is summarized;
+// otherwise nothing is sent yet.
+func (r *run) buildChannel(ctx context.Context, channel, webhookURL string, cadence int, prods []products.Product, releaseNoteTypes []string, fetch fetchFunc) {
+	products.Sort(prods, r.productOrder, r.productPriority)
+	if r.featuresOf(channel).Enabled(flags.Sections) {
+		// Keep the order within each category.
+		var ordered []products.Product
+		for _, g := range products.GroupByCategory(prods) {
+			ordered = append(ordered, g.Products...)
+		}
+		prods = ordered
+	}
+	ch := r.doc.AddChannel(channel, webhookURL, releaseNoteTypes)
+	ch.Cadence = cadence
+	ch.Mirrors = r.mirrors[channel]
+
+	// Products with far more release notes than usual are flagged in the
+	// announcement, and this run joins the baseline of the next ones.
+	if r.activity != nil {
+		notes := make(map[string]int)
+		for _, p := range prods {
+			notes[p.Product] = p.NoteCount
+			if r.activity.Unusual(channel, p.Product, p.NoteCount, cadence) {
+				ch.Unusual = append(ch.Unusual, p.Product)
+			}
+		}
+		r.activity.Record(channel, r.doc.Created, cadence, notes)
+	}
+
+	if len(prods) > 0 {
+		var err error
+		ch.TypeCounts, err = products.GetTypeCounts(ctx, r.projectID, releaseNoteTypes, products.Names(prods), strconv.Itoa(cadence))
+		if err != nil {
+			r.fatalf("Error counting release notes by type: %v", err)
+		}
+	}
+
+	summarized := r.summarizeChannel(ctx, ch, prods, fetch)
+	if r.stream {
+		// Summaries are scrubbed and screened one by one on their way to
+		// the channel, instead of for the whole document.
+		screened := make(chan *digest.Product)
+		go func() {
+			defer close(screened)
+			for p := range summarized {
+				r.scrubProduct(ctx, ch, p)
+				if r.screenProduct(ctx, ch, p) {
+					ch.Products = append(ch.Products, p)
+					screened <- p
+				}
+			}
+		}()
+		r.sendChannel(ctx, ch, prods, screened)
+		return
+	}
+	for p := range summarized {
+		ch.Products = append(ch.Products, p)
+	}
+	if r.productOrder == products.OrderImpact {
+		r.sortByImpact(ch)
+	}
+}
+
+// summarizeChannel fetches and summarizes the release notes of the products
+// of a channel, up to r.concurrency products at once, and returns them in
+// order as they are done, leaving out those without release notes left.
+// Products not started when the run's time budget
+// runs out are deferred.
+func (r *run) summarizeChannel(ctx context.Context, ch *digest.Channel, prods []products.Product, fetch fetchFunc) <-chan *digest.Product {
+	// Every product in flight has a slot receiving it once summarized,
+	// queued in product order. The queue holds one slot fewer than the
+	// concurrency, as the slot read next is out of it.
+	slots := make(chan chan *digest.Product, max(r.concurrency, 1)-1)
+	go func() {
+		defer close(slots)
+		for i, t := range prods {
+			if r.pastDeadline() {
+				r.deferProducts(ch.Name, prods[i:])
+				return
+			}
+			slot := make(chan *digest.Product, 1)
+			slots <- slot
+			go func() {
+				slot <- r.summarizeProduct(ctx, ch, t, fetch)
+			}()
+		}
+	}()
+
+	out := make(chan *digest.Product)
+	go func() {
+		defer close(out)
+		for slot := range slots {
+			if p := <-slot; p != nil {
+				out <- p
+			}
+		}
+	}()
+	return out
+}
+
+// fatalf releases the leases of the run, so the next one need not wait for
+// them to expire, and exits like log.Fatalf.
+func (r *run) fatalf(format string, v ...any) {
+	if r.unlock != nil {
+		r.unlock()
+	}
+	log.Fatalf(format, v...)
+}
+
+// summarizeProduct fetches and summarizes the release notes of one product
+// of a channel. It returns nil if the product has none left after filtering.
+func (r *run) summarizeProduct(ctx context.Context, ch *digest.Channel, t products.Product, fetch fetchFunc) *digest.Product {
+	releaseNotes, err := fetch(ctx, t.Product)
+	if err != nil {
+		r.fatalf("Error querying for release notes by type: %v", err)
+	}
+	if r.deprecations != nil {
+		if added := r.deprecations.Track(r.doc.Number, r.doc.Created, t.Product, releaseNotes); added > 0 {
+			fmt.Printf("Tracking %d new deprecations of %s\n", added, t.Product)
+		}
+	}
+	r.scoreImpact(ctx, t.Product, releaseNotes)
+	releaseNotes = r.withMinImpact(ch.Name, releaseNotes)
+	if len(releaseNotes) == 0 {
+		// All release notes of the product were filtered out, e.g. GKE
+		// notes for release channels the clusters are not on.
+		fmt.Printf("No release notes left for %s, skipping it.\n", t.Product)
+		return nil
+	}
+	p := &digest.Product{
+		Info:            t,
+		Notes:           releaseNotes,
+		NotesURL:        r.attachNotes(ctx, ch.Name, ch.Cadence, t.Product, releaseNotes),
+		DocsURL:         r.docsURL(t.Product, releaseNotes),
+		ExpandURL:       r.expander.link(ctx, r.doc.Number, ch, t.Product, releaseNotes),
+		ProviderChanges: r.providerChanges(t.Product),
+		Variant:         r.variantOf(t.Product).String(),
+	}
+	if !r.fetchOnly {
+		p.Summary = r.summarize(ctx, ch, t.Product, releaseNotes)
+	}
+	return p
+}
+
+// scoreImpact sets the impact of every release note of a product by the
+// rules, raised where the impact model rates it higher.
+func (r *run) scoreImpact(ctx context.Context, product string, releaseNotes []releasenotes.ReleaseNote) {
+	scorer := r.impact.scorer
+	if scorer == nil {
+		scorer = impact.NewScorer(nil, nil)
+	}
+	var rated []impact.Level
+	if r.impact.model != "" && len(releaseNotes) > 0 {
+		descriptions := make([]string, len(releaseNotes))
+		for i, rn := range releaseNotes {
+			descriptions[i] = rn.ReleaseNoteType + ": " + rn.Description
+		}
+		ratings, err := summarize.RateImpact(ctx, r.projectID, r.impact.model, r.modelLocation, product, descriptions)
+		if err != nil {
+			fmt.Printf("Error rating impact of %s, keeping the rules' scores: %v\n", product, err)
+		}
+		for _, rating := range ratings {
+			level, err := impact.ParseLevel(rating)
+			if err != nil {
+				fmt.Printf("Error rating impact of %s, keeping the rules' scores: %v\n", product, err)
+				rated = nil
+				break
+			}
+			rated = append(rated, level)
+		}
+	}
+	scorer.Apply(product, releaseNotes, rated)
+}
+
+// withMinImpact drops the release notes below the impact MIN_IMPACT or
+// <CHANNEL>_MIN_IMPACT sets for a channel.
+func (r *run) withMinImpact(channel string, releaseNotes []releasenotes.ReleaseNote) []releasenotes.ReleaseNote {
+	setting := channelSetting(channel, "MIN_IMPACT")
+	if setting == "" {
+		return releaseNotes
+	}
+	floor, err := impact.ParseLevel(setting)
+	if err != nil {
+		fmt.Printf("Error in MIN_IMPACT of %s: %v\n", channel, err)
+		return releaseNotes
+	}
+	return impact.AtLeast(releaseNotes, floor)
+}
+
+// sortByImpact orders the products of a channel with the highest impact of
+// their release notes first, keeping categories together when the channel
+// is sectioned.
+func (r *run) sortByImpact(ch *digest.Channel) {
+	sections := r.featuresOf(ch.Name).Enabled(flags.Sections)
+	rank := make(map[string]int)
+	if sections {
+		for _, p := range ch.Products {
+			c := products.Category(p.Name())
+			if _, ok := rank[c]; !ok {
+				rank[c] = len(rank)
+			}
+		}
+	}
+	sort.SliceStable(ch.Products, func(i, j int) bool {
+		a, b := ch.Products[i], ch.Products[j]
+		if sections {
+			if ra, rb := rank[products.Category(a.Name())], rank[products.Category(b.Name())]; ra != rb {
+				return ra < rb
+			}
+		}
+		return impact.Highest(a.Notes) > impact.Highest(b.Notes)
+	})
+}
+
+// docsURL returns the link to a product's release notes page, if summaries
+// link them.
+func (r *run) docsURL(product string, releaseNotes []releasenotes.ReleaseNote) string {
+	if !r.docsLinks {
+		return ""
+	}
+	return releasenotes.URL(product, releaseNotes)
+}
+
+// maxProviderChanges is the number of provider releases linked per product.
+const maxProviderChanges = 3
+
+// providerChanges returns links to the newest releases of the Terraform
+// provider touching product.
+func (r *run) providerChanges(product string) []digest.Link {
+	var links []digest.Link
+	for _, rel := range terraform.Related(r.providerReleases, product) {
+		if len(links) == maxProviderChanges {
+			break
+		}
+		links = append(links, digest.Link{Text: rel.Version, URL: rel.URL})
+	}
+	return links
+}
+
+// exportNotes uploads the release notes of a channel in every export format
+// and returns the signed links to them, e.g. "<url|CSV>, <url|JSON>".
+func (r *run) exportNotes(ctx context.Context, channel string, export *releasenotes.Export) string {
+	a := r.attachments
+	if export == nil || export.Len() == 0 {
+		return ""
+	}
+	var links []string
+	for _, format := range a.exportFormats {
+		data := export.CSV()
+		if format == "json" {
+			data = export.JSON()
+		}
+		key := archive.ExportKey(r.doc.Number, channel, format)
+		if err := a.store.Put(ctx, key, data); err != nil {
+			fmt.Printf("Error exporting release notes of %s: %v\n", channel, err)
+			continue
+		}
+		url, err := a.signer.SignedURL(ctx, key, a.serviceAccount, a.expires)
+		if err != nil {
+			fmt.Printf("Error signing link to exported release notes of %s: %v\n", channel, err)
+			continue
+		}
+		links = append(links, fmt.Sprintf("<%s|%s>", url, strings.ToUpper(format)))
+	}
+	return strings.Join(links, ", ")
+}
+
+// pastDeadline reports whether the run used up its time budget.
+func (r *run) pastDeadline() bool {
+	return !r.deadline.IsZero() && time.Now().After(r.deadline)
+}
+
+// deferProducts reports the products of a channel left unsummarized when the
+// run's time budget ran out, for the next run to cover.
+func (r *run) deferProducts(channel string, prods []products.Product) {
+	names := products.Names(prods)
+	fmt.Printf("Run budget used up, deferring %d products of %s to the next run: %s\n", len(names), channel, strings.Join(names, ", "))
+	for _, name := range names {
+		r.report.Defer(channel, name)
+	}
+}
+
+// scrub removes credentials, email addresses and other sensitive strings
+// from every summary of the digest document. Only the kinds of strings found
+// are logged.
+func (r *run) scrub(ctx context.Context) {
+	if r.scrubber == nil {
+		return
+	}
+	for _, ch := range r.doc.Channels {
+		for _, p := range ch.Products {
+			r.scrubProduct(ctx, ch, p)
+		}
+	}
+}
+
+// scrubProduct removes sensitive strings from the summary of a product of a
+// channel.
+func (r *run) scrubProduct(ctx context.Context, ch *digest.Channel, p *digest.Product) {
+	if r.scrubber == nil {
+		return
+	}
+	summary, found, err := r.scrubber.Scrub(ctx, p.Summary)
+	if err != nil {
+		fmt.Println(err)
+	}
+	if len(found) > 0 {
+		fmt.Printf("Scrubbed %s from the summary of %s in %s.\n", strings.Join(found, ", "), p.Name(), ch.Name)
+	}
+	p.Summary = summary
+}
+
+// screen applies the compliance filter to every summary of the digest
+// document, redacting banned phrases or leaving the product out of its
+// channel as set for the channel. Blocked summaries are reported to the ops
+// webhook.
+func (r *run) screen(ctx context.Context) {
+	for _, ch := range r.doc.Channels {
+		var kept []*digest.Product
+		for _, p := range ch.Products {
+			if r.screenProduct(ctx, ch, p) {
+				kept = append(kept, p)
+			}
+		}
+		ch.Products = kept
+	}
+}
+
+// screenProduct applies the compliance filter to the summary of a product
+// of a channel and reports whether the product is kept.
+func (r *run) screenProduct(ctx context.Context, ch *digest.Channel, p *digest.Product) bool {
+	c := r.compliance
+	if c.filter == nil {
+		return true
+	}
+	action, ok := c.actions[ch.Name]
+	if !ok {
+		action = c.defaultAction
+	}
+	if action == compliance.ActionOff {
+		return true
+	}
+	found := c.filter.Find(p.Summary)
+	if len(found) == 0 {
+		return true
+	}
+	if action == compliance.ActionRedact {
+		fmt.Printf("Redacting %s from the summary of %s in %s.\n", strings.Join(found, ", "), p.Name(), ch.Name)
+		p.Summary = c.filter.Redact(p.Summary)
+		return true
+	}
+
+	fmt.Printf("Blocking the summary of %s in %s, it contains %s.\n", p.Name(), ch.Name, strings.Join(found, ", "))
+	if c.opsWebhook == "" {
+		// Only a local run blocks summaries without an ops channel.
+		return false
+	}
+	text := fmt.Sprintf("*Compliance filter blocked the summary of %s in %s*\nBanned phrases: %s\n\n%s",
+		p.Name(), ch.Name, strings.Join(found, ", "), p.Summary)
+	status, err := notify.SendText(ctx, c.opsWebhook, text)
+	if err != nil {
+		fmt.Printf("Error alerting ops channel: %v\n", err)
+	}
+	r.report.Record("OPS", c.opsWebhook, report.KindAlert, p.Name(), status, err)
+	return false
+}
+
+// summarize returns the summary of a product's release notes for a channel,
+// within the channel's target length.
+func (r *run) summarize(ctx context.Context, ch *digest.Channel, product string, releaseNotes []releasenotes.ReleaseNote) string {
+	// With type sections, each release note type is summarized separately
+	// and the summaries are sent as one message with a section per type,
+	// sharing the length.
+	groups := []releasenotes.TypeGroup{{ReleaseNotes: releaseNotes}}
+	if r.typeSections {
+		groups = releasenotes.GroupByType(releaseNotes)
+	}
+	length := r.summaryLength(ch)
+	length.Chars /= len(groups)
+
+	var sections []string
+	for _, g := range groups {
+		// Create a slice of strings to hold the release notes.
+		var releaseNotesSlice []string
+		for _, rn := range g.ReleaseNotes {
+			releaseNotesSlice = append(releaseNotesSlice, rn.ReleaseNoteType, rn.Description)
+		}
+
+		// Summarize the release notes using the Vertex AI Generative Model.
+		fmt.Printf("Asking for summary with model %s\n", r.model)
+		summary, ok := r.summarizeWithCitations(ctx, product, g.ReleaseNotes)
+		if !ok {
+			var err error
+			prompt := summarize.PromptFor(r.typePrompts, noteTypes(g.ReleaseNotes))
+			if v := r.variantOf(product); v != nil {
+				prompt = v.prompt
+			}
+			summary, err = prompt.SummarizeWithin(ctx, r.projectID, r.model, r.modelLocation, product, releaseNotesSlice, length)
+			if summarize.IsEmptyResponse(err) {
+				summary, err = r.summarizeAgain(ctx, product, g.ReleaseNotes, releaseNotesSlice, length, err)
+			}
+			if err != nil {
+				r.fatalf("Error summarizing: %v", err)
+			}
+		}
+		if len(groups) > 1 {
+			summary = fmt.Sprintf("_%s_\n%s", releasenotes.TypeTitle(g.ReleaseNoteType), summary)
+		}
+		sections = append(sections, summary)
+	}
+	return r.verify(ctx, product, releaseNotes, strings.Join(sections, "\n\n"))
+}
+
+// summarizeAgain handles a summary the model returned no text for, blocked
+// or empty: it asks again with the retry prompt, and falls back to the
+// release notes themselves if that gets no text either. Either way the
+// product is marked in the run report.
+func (r *run) summarizeAgain(ctx context.Context, product string, releaseNotes []releasenotes.ReleaseNote, releaseNotesSlice []string, length summarize.Length, empty error) (string, error) {
+	fmt.Printf("%v, retrying with another prompt\n", empty)
+	summary, err := summarize.RetryPrompt.SummarizeWithin(ctx, r.projectID, r.model, r.modelLocation, product, releaseNotesSlice, length)
+	if err == nil {
+		r.report.SetFallback(product, report.FallbackRetried)
+		return summary, nil
+	}
+	if !summarize.IsEmptyResponse(err) {
+		return "", err
+	}
+	fmt.Printf("%v, sending the release notes instead\n", err)
+	r.report.SetFallback(product, report.FallbackRawNotes)
+	return rawNotes(i18n.M().NoSummary, releaseNotes), nil
+}
+
+// rawNotes lists the release notes under a heading, sent instead of a
+// summary.
+func rawNotes(heading string, releaseNotes []releasenotes.ReleaseNote) string {
+	var b strings.Builder
+	b.WriteString(heading)
+	for _, rn := range releaseNotes {
+		fmt.Fprintf(&b, "\n• _%s_: %s", releasenotes.TypeTitle(rn.ReleaseNoteType), strings.TrimSpace(rn.Description))
+	}
+	return b.String()
+}
+
+// summaryOverhead is the room a summary message keeps for the product
+// heading and the links below the summary.
+const summaryOverhead = 300
+
+// summaryLength returns the target length of the summaries of a channel, set
+// with SUMMARY_LENGTH or <CHANNEL>_SUMMARY_LENGTH, and at most the message
+// size limit of each of the channel's targets less the summary overhead.
+func (r *run) summaryLength(ch *digest.Channel) summarize.Length {
+	var length summarize.Length
+	if spec := channelSetting(ch.Name, "SUMMARY_LENGTH"); spec != "" {
+		var err error
+		if length, err = summarize.ParseLength(spec); err != nil {
+			fmt.Printf("Error in SUMMARY_LENGTH of %s: %v\n", ch.Name, err)
+		}
+	}
+	for _, webhookURL := range append([]string{ch.WebhookURL}, ch.Mirrors...) {
+		maxChars := r.messageMaxChars
+		if maxChars <= 0 {
+			maxChars = notify.TargetCapabilities(webhookURL).MaxChars
+		}
+		if r.batchMaxChars > 0 && r.batchMaxChars < maxChars {
+			maxChars = r.batchMaxChars
+		}
+		if maxChars > summaryOverhead {
+			length = length.Within(summarize.Length{Chars: maxChars - summaryOverhead})
+		}
+	}
+	return length
+}
+
+// noteTypes returns the types of the release notes.
+func noteTypes(releaseNotes []releasenotes.ReleaseNote) []string {
+	types := make([]string, len(releaseNotes))
+	for i, rn := range releaseNotes {
+		types[i] = rn.ReleaseNoteType
+	}
+	return types
+}
+
+// summarizeWithCitations returns a summary made of bullet points citing the
+// release notes they are based on, with the cited notes quoted below, if
+// citations are enabled. It reports false if they are not or the model
+// failed to write one.
+func (r *run) summarizeWithCitations(ctx context.Context, product string, releaseNotes []releasenotes.ReleaseNote) (string, bool) {
+	if !r.citations {
+		return "", false
+	}
+	var notes []string
+	for _, rn := range releaseNotes {
+		notes = append(notes, rn.ReleaseNoteType+": "+rn.Description)
+	}
+	bullets, err := summarize.SummarizeWithCitations(ctx, r.projectID, r.model, r.modelLocation, product, notes)
+	if err != nil || len(bullets) == 0 {
+		fmt.Printf("Error summarizing %s with citations, summarizing without: %v\n", product, err)
+		return "", false
+	}
+
+	var b strings.Builder
+	cited := make(map[int]bool)
+	for _, bullet := range bullets {
+		fmt.Fprintf(&b, "• %s", strings.TrimSpace(bullet.Text))
+		for _, n := range bullet.Notes {
+			fmt.Fprintf(&b, " [%d]", n)
+			cited[n] = true
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString("\n")
+	for i, rn := range releaseNotes {
+		if cited[i+1] {
+			fmt.Fprintf(&b, "[%d] _%s_: \"%s\"\n", i+1, releasenotes.TypeTitle(rn.ReleaseNoteType), quote(rn.Description, quoteMaxChars))
+		}
+	}
+	return strings.TrimRight(b.String(), "\n"), true
+}
+
+// quoteMaxChars is the length of the quotes of cited release notes.
+const quoteMaxChars = 160
+
+// quote returns the first line of text, shortened to maxChars characters.
+func quote(text string, maxChars int) string {
+	text, _, _ = strings.Cut(strings.TrimSpace(text), "\n")
+	if runes := []rune(text); len(runes) > maxChars {
+		text = strings.TrimSpace(string(runes[:maxChars-1])) + "…"
+	}
+	return text
+}
+
+// verify checks a summary against its release notes with the verification
+// model, if one is set. A summary with unsupported claims gets a warning
+// label, or is replaced by the release notes themselves.
+func (r *run) verify(ctx context.Context, product string, releaseNotes []releasenotes.ReleaseNote, summary string) string {
+	if r.verifyModel == "" {
+		return summary
+	}
+	var releaseNotesSlice []string
+	for _, rn := range releaseNotes {
+		releaseNotesSlice = append(releaseNotesSlice, rn.ReleaseNoteType, rn.Description)
+	}
+
+	fmt.Printf("Verifying summary with model %s\n", r.verifyModel)
+	verdict, err := summarize.Verify(ctx, r.projectID, r.verifyModel, r.modelLocation, product, releaseNotesSlice, summary)
+	if err != nil {
+		fmt.Printf("Error verifying summary of %s: %v\n", product, err)
+		return summary
+	}
+	if verdict.Supported {
+		return summary
+	}
+	fmt.Printf("Summary of %s has unsupported claims: %s\n", product, strings.Join(verdict.Unsupported, "; "))
+
+	m := i18n.M()
+	if r.verifyAction != "notes" {
+		return m.Unverified + "\n" + summary
+	}
+	return rawNotes(m.RawNotes, releaseNotes)
+}
+
+// deliverChannel announces the products of a channel of the digest document
+// to its webhook, sends the summary of each product and ends with the
+// closing message. A channel fanning out to mirrors gets the same messages
+// sent to each of them, which are neither archived nor escalated again.
+func (r *run) deliverChannel(ctx context.Context, ch *digest.Channel) {
+	send := func(r *run, ch *digest.Channel) {
+		prods := make(chan *digest.Product, len(ch.Products))
+		for _, p := range ch.Products {
+			prods <- p
+		}
+		close(prods)
+		r.sendChannel(ctx, ch, ch.Infos(), prods)
+	}
+	send(r, ch)
+	for _, webhookURL := range ch.Mirrors {
+		mirror := *ch
+		mirror.WebhookURL, mirror.Mirrors = webhookURL, nil
+		send(r.preview(), &mirror)
+	}
+}
+
+// sendChannel announces the products to the channel's webhook, sends the
+// summary of each product as it is received and ends with the closing
+// message. A streaming run drops the release notes of each product once
+// its summary was sent.
+func (r *run) sendChannel(ctx context.Context, ch *digest.Channel, infos []products.Product, prods <-chan *digest.Product) {
+	channel, webhookURL := ch.Name, ch.WebhookURL
+	announceOpts := r.announceOpts
+	announceOpts.Unusual = ch.Unusual
+
+	// A channel without release notes gets a no news message if it asks for
+	// one, and the empty run is recorded either way.
+	if len(infos) == 0 {
+		r.sendNoNews(ctx, ch, announceOpts)
+		return
+	}
+
+	// In Google Chat spaces the messages of a channel can be kept in
+	// threads, as THREADS or <CHANNEL>_THREADS is set: "run" posts the
+	// summaries and the closing message as replies to the announcement,
+	// "product" starts a thread with the summary of each product, which its
+	// expanded release notes join.
+	threads := channelSetting(channel, "THREADS")
+	channelCtx := ctx
+	switch threads {
+	case "", "none", "product":
+	case "run":
+		channelCtx = notify.WithThread(ctx, r.threadKey(channel))
+	default:
+		fmt.Printf("Error in THREADS of %s: expected run, product or none, got %q\n", channel, threads)
+	}
+
+	// Announce the list and count of products with release notes to the webhook.
+	status, err := notify.Announce(channelCtx, webhookURL, ch.Cadence, infos, ch.TypeCounts, announceOpts)
+	if err != nil {
+		fmt.Printf("Error sending to Webhook: %v\n", err)
+	}
+	r.report.Record(channel, webhookURL, report.KindAnnounce, "", status, err)
+
+	// A product counts as delivered once the target accepted its summary or
+	// card, or it was queued for the delivery window or a retry.
+	delivered := make(map[string]bool)
+	markDelivered := func(product, status string, err error) {
+		if err == nil && (strings.HasPrefix(status, "2") || status == notify.StatusQueued || status == notify.StatusRetrying) {
+			delivered[product] = true
+		}
+	}
+
+	// Summaries are sent in batches of up to batchSize per message, and the
+	// delivery of each product is recorded once its batch was sent.
+	maxChars := r.messageMaxChars
+	if maxChars <= 0 {
+		maxChars = notify.TargetCapabilities(webhookURL).MaxChars
+	}
+	if r.batchMaxChars > 0 && r.batchMaxChars < maxChars {
+		maxChars = r.batchMaxChars
+	}
+	batch := notify.NewBatch(webhookURL, r.batchSize, maxChars, func(sent []string, status string, err error) {
+		if err != nil {
+			fmt.Printf("Error sending %s via webhook: %v\n", strings.Join(sent, ", "), err)
+		} else {
+			fmt.Printf("Sent %s via webhook: %s\n", strings.Join(sent, ", "), status)
+		}
+		for _, product := range sent {
+			r.report.Record(channel, webhookURL, report.KindSummary, product, status, err)
+			markDelivered(product, status, err)
+		}
+	})
+
+	// Receivers accepting extra fields can get the products of each message
+	// described alongside its text, and targets like Pub/Sub topics always
+	// do.
+	caps := notify.TargetCapabilities(webhookURL)
+	metadata := channelSetting(channel, "PAYLOAD_METADATA") == "true" || caps.Metadata
+	if metadata {
+		batch.SetMetadata(notify.Metadata{
+			Run:     r.report.RunID(),
+			Channel: channel,
+			From:    r.doc.Created.AddDate(0, 0, -ch.Cadence).Format("2006-01-02"),
+			To:      r.doc.Created.Format("2006-01-02"),
+		})
+	}
+
+	// With the cards feature, targets showing cards get a card per product.
+	cards := r.featuresOf(channel).Enabled(flags.Cards) && caps.Cards && caps.Dialect == notify.DialectChat
+
+	// With the sections feature, a table of contents lists the categories
+	// and each category starts a new message under its heading.
+	sections := r.featuresOf(channel).Enabled(flags.Sections) && !cards
+	if sections && len(infos) > 0 {
+		status, err := notify.SendText(channelCtx, webhookURL, digest.Chat{}.Contents(r.doc, products.GroupByCategory(infos)))
+		if err != nil {
+			fmt.Printf("Error sending table of contents via webhook: %v\n", err)
+		}
+		r.report.Record(channel, webhookURL, report.KindAnnounce, "", status, err)
+	}
+	category := ""
+
+	// The raw release notes of the channel may be exported for analysts.
+	var export *releasenotes.Export
+	if len(r.attachments.exportFormats) > 0 {
+		export = &releasenotes.Export{}
+	}
+
+	sent := 0
+	for p := range prods {
+		sent++
+		// Send the summary of release notes to the webhook.
+		// Summaries too long for the webhook link to the archived digest.
+		summaryResult := digest.Chat{}.Product(r.doc, p)
+		productCtx := channelCtx
+		if threads == "product" {
+			productCtx = notify.WithThread(ctx, r.threadKey(p.Name()))
+		}
+		if r.pageOnCall(ctx, channel, p) && r.pager.instead {
+			fmt.Printf("Leaving %s out of the chat, on-call was paged\n", p.Name())
+		} else if cards {
+			status, err := notify.SendPayload(productCtx, webhookURL, r.card.Message(r.doc, p))
+			if err != nil {
+				fmt.Printf("Error sending %s card via webhook: %v\n", p.Name(), err)
+			} else {
+				fmt.Printf("Sent %s card via webhook: %s\n", p.Name(), status)
+			}
+			r.report.Record(channel, webhookURL, report.KindSummary, p.Name(), status, err)
+			markDelivered(p.Name(), status, err)
+		} else {
+			if c := products.Category(p.Name()); sections && c != category {
+				category = c
+				batch.Section(channelCtx, digest.Chat{}.Section(c))
+			}
+			// Readers can rate the summary, for comparing prompt variants.
+			message := summaryResult
+			if feedback := (digest.Chat{}).Feedback(r.doc, p, r.feedbackSecret); feedback != "" {
+				message += "\n\n" + feedback
+			}
+			meta := notify.ProductMetadata{Product: p.Name()}
+			if metadata {
+				meta.Types, meta.Notes, meta.Severity = p.Types(), len(p.Notes), impact.Highest(p.Notes).String()
+			}
+			batch.AddProduct(productCtx, meta, message, r.doc.Link(p.Name()))
+			if threads == "product" {
+				// Every product has a message, and thread, of its own.
+				batch.Flush(productCtx)
+			}
+		}
+		if p.Variant != "" {
+			r.report.SetVariant(p.Name(), p.Variant)
+		}
+		r.record.Add(channel, p.Name(), p.Types(), summaryResult)
+
+		r.escalate(ctx, p.Name(), p.Notes, summaryResult)
+		r.openJiraIssue(ctx, channel, p)
+		r.openGitHubIssue(ctx, channel, p)
+		if export != nil {
+			export.Add(p.Name(), p.Notes)
+		}
+		if r.stream {
+			p.Release()
+		}
+	}
+
+	batch.Flush(channelCtx)
+	if ch.Cadence != r.doc.Cadence {
+		r.record.SetCadence(channel, ch.Cadence)
+	}
+
+	// A streaming run announces the products before summarizing them, so
+	// those filtered out, blocked or deferred on the way are corrected.
+	if r.stream {
+		r.sendCorrection(channelCtx, ch, infos, delivered)
+	}
+
+	// End with a closing message, a footer or nothing, as the channel is
+	// set.
+	if sent > 0 {
+		r.sendClosing(channelCtx, ch, sent, r.exportNotes(ctx, channel, export))
+	}
+}
+
+// sendCorrection lists the products actually sent to a channel, if some of
+// those announced were left out.
+func (r *run) sendCorrection(ctx context.Context, ch *digest.Channel, announced []products.Product, delivered map[string]bool) {
+	var sent, left []string
+	for _, name := range products.Names(announced) {
+		if delivered[name] {
+			sent = append(sent, name)
+		} else {
+			left = append(left, name)
+		}
+	}
+	if len(left) == 0 {
+		return
+	}
+	if len(sent) == 0 {
+		sent = []string{"-"}
+	}
+	text := fmt.Sprintf(i18n.M().Correction, len(announced)-len(left), len(announced), strings.Join(sent, ", "), strings.Join(left, ", "))
+	status, err := notify.SendText(ctx, ch.WebhookURL, text)
+	if err != nil {
+		fmt.Printf("Error sending correction via webhook: %v\n", err)
+	} else {
+		fmt.Printf("Sent correction, left out %s: %s\n", strings.Join(left, ", "), status)
+	}
+	r.report.Record(ch.Name, ch.WebhookURL, report.KindAnnounce, "", status, err)
+}
+
+// sendClosing ends the summaries of a channel as CLOSING or <CHANNEL>_CLOSING
+// is set: "message" (default) sends the closing message, "footer" a footer
+// with the digest number, period, number of products and archive link, and
+// "none" nothing. Links to the exported release notes are sent either way.
+func (r *run) sendClosing(ctx context.Context, ch *digest.Channel, sent int, exported string) {
+	var links string
+	if exported != "" {
+		links = fmt.Sprintf(i18n.M().Exported, exported)
+	}
+	var status string
+	var err error
+	fmt.Print("Closing message...")
+	switch mode := channelSetting(ch.Name, "CLOSING"); mode {
+	case "none":
+		if links == "" {
+			fmt.Print(" none\n\n")
+			return
+		}
+		status, err = notify.SendText(ctx, ch.WebhookURL, links)
+	case "footer":
+		footer := digest.Chat{}.Footer(r.doc, ch, sent)
+		if links != "" {
+			footer += "\n" + links
+		}
+		status, err = notify.SendText(ctx, ch.WebhookURL, footer)
+	default:
+		if mode != "" && mode != "message" {
+			fmt.Printf(" error in CLOSING of %s: expected message, footer or none, got %q...", ch.Name, mode)
+		}
+		closing := r.closingMsg
+		if links != "" {
+			closing += " " + links
+		}
+		status, err = notify.ClosingMessage(ctx, ch.WebhookURL, closing)
+	}
+	if err != nil {
+		fmt.Printf(" error: %v\n\n", err)
+	} else {
+		fmt.Printf(" %s\n\n", status)
+	}
+	r.report.Record(ch.Name, ch.WebhookURL, report.KindClosing, "", status, err)
+}
+
+// threadKey names the Google Chat thread of a channel or product in this
+// run, e.g. "digest-42-cloud-sql", the thread expand posts the release notes
+// of Cloud SQL to.
+func (r *run) threadKey(name string) string {
+	return r.report.RunID() + "-" + archive.Anchor(name)
+}
+
+// sendNoNews tells a channel without release notes that there were none,
+// if NO_NEWS or <CHANNEL>_NO_NEWS is "message". By default the channel stays
+// silent.
+func (r *run) sendNoNews(ctx context.Context, ch *digest.Channel, announceOpts notify.AnnounceOptions) {
+	switch mode := channelSetting(ch.Name, "NO_NEWS"); mode {
+	case "message":
+		status, err := notify.NoNews(ctx, ch.WebhookURL, ch.Cadence, announceOpts)
+		if err != nil {
+			fmt.Printf("Error sending no news message to %s: %v\n", ch.Name, err)
+		} else {
+			fmt.Printf("Sent no news message to %s: %s\n", ch.Name, status)
+		}
+		r.report.Record(ch.Name, ch.WebhookURL, report.KindNoNews, "", status, err)
+	default:
+		if mode != "" && mode != "silent" {
+			fmt.Printf("Error in NO_NEWS of %s: expected message or silent, got %q\n", ch.Name, mode)
+		}
+		fmt.Printf("No release notes for %s, staying silent.\n", ch.Name)
+		r.report.Record(ch.Name, ch.WebhookURL, report.KindNoNews, "", report.StatusSilent, nil)
+	}
+}
+
+// preview returns a copy of the run for sending the digest somewhere other
+// than its channels, such as a test or review space. Nothing the copy sends
+// is archived or escalated.
+func (r *run) preview() *run {
+	p := *r
+	p.record = &archive.Digest{}
+	p.escalation = escalationSettings{}
+	p.pager.events = nil
+	p.jira.client = nil
+	p.github.client = nil
+	return &p
+}
+
+// configSnapshot returns the effective configuration of the run, with the
+// hosts of its webhooks and its settings without secrets.
+func (r *run) configSnapshot() *archive.Config {
+	c := &archive.Config{
+		Number:        r.doc.Number,
+		Created:       r.doc.Created,
+		Cadence:       r.cadenceInt,
+		Model:         r.model,
+		ModelLocation: r.modelLocation,
+		VerifyModel:   r.verifyModel,
+		Prompts:       map[string]string{summarize.DefaultPrompt.Name: summarize.DefaultPrompt.Text()},
+		Settings:      config.Snapshot(),
+	}
+	for _, ch := range r.doc.Channels {
+		var targets []string
+		for _, webhookURL := range append([]string{ch.WebhookURL}, ch.Mirrors...) {
+			targets = append(targets, config.Redact("", webhookURL))
+		}
+		c.Channels = append(c.Channels, archive.ChannelConfig{Name: ch.Name, Targets: targets, Types: ch.Types, Cadence: ch.Cadence})
+	}
+	for t, p := range r.typePrompts {
+		c.Prompts[t] = p.Text()
+	}
+	for _, v := range r.variants {
+		c.Prompts[v.name] = v.prompt.Text()
+	}
+	return c
+}
+
+// featuresOf returns the feature flags of a channel.
+func (r *run) featuresOf(channel string) flags.Set {
+	if f, ok := r.features[channel]; ok {
+		return f
+	}
+	return r.globalFeatures
+}
+
+// attachNotes uploads the release notes of a product with at least the
+// threshold number of notes and returns their signed URL, or nothing if the
+// product has fewer notes or the upload fails.
+func (r *run) attachNotes(ctx context.Context, channel string, cadence int, product string, releaseNotes []releasenotes.ReleaseNote) string {
+	a := r.attachments
+	if a.threshold <= 0 || len(releaseNotes) < a.threshold {
+		return ""
+	}
+	key := archive.NotesKey(r.doc.Number, channel, product)
+	if err := a.store.Put(ctx, key, []byte(releasenotes.Markdown(product, cadence, releaseNotes))); err != nil {
+		fmt.Printf("Error uploading release notes of %s: %v\n", product, err)
+		return ""
+	}
+	url, err := a.signer.SignedURL(ctx, key, a.serviceAccount, a.expires)
+	if err != nil {
+		fmt.Printf("Error signing link to release notes of %s: %v\n", product, err)
+		return ""
+	}
+	return url
+}
+
+// sendEmail emails the summaries of all channels as one digest to every
+// recipient profile and Google Group, limited to the profile's channels.
+func (r *run) sendEmail(ctx context.Context) {
+	e := r.email
+	if e.sender == nil {
+		return
+	}
+	subject := e.subject
+	if subject == "" {
+		subject = i18n.M().Title
+		if r.doc.Number > 0 {
+			subject += fmt.Sprintf(" #%d", r.doc.Number)
+		}
+	}
+	if r.doc.Urgent {
+		subject = i18n.M().Urgent + ": " + subject
+	}
+
+	for _, profile := range e.profiles {
+		filtered := profile.Filter(r.record)
+		if len(filtered.Sections) == 0 {
+			continue
+		}
+		// Google Groups handle unsubscribing themselves, and a dated subject
+		// makes digests easy to find in the group archive.
+		msgSubject, unsubscribeURL := subject, e.unsubscribeURL
+		if profile.Group {
+			msgSubject += " – " + r.doc.Created.Format("2006-01-02")
+			unsubscribeURL = ""
+		}
+		htmlBody, textBody, err := e.template.Render(filtered, r.doc.Permalink, unsubscribeURL)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		fmt.Printf("Emailing digest to %s (%s)...", strings.Join(profile.To, ", "), profile.Name)
+		status, err := e.sender.Send(ctx, email.Message{From: e.from, To: profile.To, Subject: msgSubject, HTML: htmlBody, Text: textBody, UnsubscribeURL: unsubscribeURL, Headers: r.emailHeaders(filtered)})
+		if err != nil {
+			fmt.Printf(" error: %v\n", err)
+		} else {
+			fmt.Printf(" %s\n", status)
+		}
+		r.report.Record("EMAIL "+profile.Name, e.sender.Target(), report.KindEmail, "", status, err)
+	}
+}
+
+// emailHeaders describes the products of an emailed digest in headers, so
+// mail rules and scripts can sort it without reading the summaries.
+func (r *run) emailHeaders(d *archive.Digest) map[string]string {
+	highest := make(map[string]impact.Level)
+	for _, p := range r.doc.Products() {
+		highest[p.Name()] = impact.Highest(p.Notes)
+	}
+	var prods, types []string
+	severity := impact.Info
+	for _, s := range d.Sections {
+		for _, e := range s.Entries {
+			if !slices.Contains(prods, e.Product) {
+				prods = append(prods, e.Product)
+			}
+			for _, t := range e.Types {
+				if !slices.Contains(types, t) {
+					types = append(types, t)
+				}
+			}
+			severity = max(severity, highest[e.Product])
+		}
+	}
+	return map[string]string{
+		"X-Digest-Run":      r.report.RunID(),
+		"X-Digest-Products": strings.Join(prods, ", "),
+		"X-Digest-Types":    strings.Join(types, ", "),
+		"X-Digest-Severity": severity.String(),
+	}
+}
+
+// sendPush pushes a compact notification per product of the digest to the
+// Firebase Cloud Messaging topic. A product summarized in several channels
+// is pushed once, with the types of all its summaries.
+func (r *run) sendPush(ctx context.Context) {
+	if r.push == nil {
+		return
+	}
+	for _, p := range r.doc.Products() {
+		product := p.Name()
+		data := map[string]string{
+			"product": product,
+			"types":   strings.Join(p.Types(), ","),
+			"cadence": strconv.Itoa(r.cadenceInt),
+		}
+		if r.doc.Number > 0 {
+			data["digest"] = strconv.Itoa(r.doc.Number)
+		}
+		if link := r.doc.Link(product); link != "" {
+			data["link"] = link
+		}
+
+		fmt.Printf("Pushing %s to topic %s...", product, r.push.Topic)
+		status, err := r.push.Send(ctx, push.Notification{Title: product, Body: notify.Headline(digest.PlainText{}.Product(r.doc, p), 200), Data: data})
+		if err != nil {
+			fmt.Printf(" error: %v\n", err)
+		} else {
+			fmt.Printf(" %s\n", status)
+		}
+		r.report.Record("PUSH", r.push.Target(), report.KindPush, product, status, err)
+	}
+}
+
+// escalate sends an additional copy of the summary to the escalation channel
+// when the product's release notes match an escalation rule, and texts an
+// alert when they match an SMS escalation rule.
+func (r *run) escalate(ctx context.Context, product string, releaseNotes []releasenotes.ReleaseNote, summaryResult string) {
+	notes := escalationNotes(releaseNotes)
+	r.escalateSMS(ctx, product, notes, summaryResult)
+
+	if r.escalation.webhookURL == "" {
+		return
+	}
+	rule, ok := r.escalation.rules.Match(product, notes)
+	if !ok {
+		return
+	}
+
+	fmt.Printf("Escalating %s (rule: %s)...", product, rule)
+	msg := summaryResult
+	if r.escalation.mention != "" {
+		msg = r.escalation.mention + " " + msg
+	}
+	status, err := notify.SendToWebhook(ctx, product, msg, r.escalation.webhookURL)
+	if err != nil {
+		fmt.Printf(" error: %v\n", err)
+	} else {
+		fmt.Printf(" %s\n", status)
+	}
+	r.report.Record("ESCALATION", r.escalation.webhookURL, report.KindEscalation, product, status, err)
+}
+
+// pageOnCall triggers a PagerDuty incident for a product of a channel whose
+// release notes match the paging rules, and reports whether on-call was
+// paged for it. The mirrors of the channel are told the outcome of its page
+// rather than paging again.
+func (r *run) pageOnCall(ctx context.Context, channel string, p *digest.Product) bool {
+	pg := r.pager
+	if pg.paged == nil {
+		return false
+	}
+	key := channel + "\x00" + p.Name()
+	if paged, done := pg.paged[key]; done || pg.events == nil {
+		return paged
+	}
+	rule, ok := pg.rules.Match(p.Name(), escalationNotes(p.Notes))
+	if !ok {
+		pg.paged[key] = false
+		return false
+	}
+
+	// Release notes are sorted by type priority, so the first type is the
+	// most important one.
+	label := releasenotes.TypeLabel(p.Notes[0].ReleaseNoteType, 1)
+	summary := digest.PlainText{}.Product(r.doc, p)
+	notes := make([]string, len(p.Notes))
+	for i, rn := range p.Notes {
+		notes[i] = rn.ReleaseNoteType + ": " + rn.Description
+	}
+	event := pagerduty.Event{
+		Summary:   fmt.Sprintf("GCP %s: %s - %s", label, p.Name(), notify.Headline(summary, 300)),
+		Source:    p.Name(),
+		Severity:  pg.severity,
+		Component: p.Name(),
+		Group:     channel,
+		Class:     strings.Join(p.Types(), ","),
+		// Pages of the same product in a run add to one incident.
+		DedupKey: "gcp-release-digest/" + r.report.RunID() + "/" + p.Name(),
+		Details: map[string]any{
+			"product":       p.Name(),
+			"channel":       channel,
+			"rule":          rule.String(),
+			"summary":       summary,
+			"release_notes": notes,
+		},
+		Link:     r.doc.Link(p.Name()),
+		LinkText: i18n.M().ReadInArchive,
+	}
+	fmt.Printf("Paging on-call for %s (rule: %s)...", p.Name(), rule)
+	status, err := pg.events.Trigger(ctx, event)
+	if err != nil {
+		fmt.Printf(" error: %v\n", err)
+	} else {
+		fmt.Printf(" %s\n", status)
+	}
+	r.report.Record("PAGERDUTY", pg.events.Target(), report.KindPage, p.Name(), status, err)
+	pg.paged[key] = err == nil
+	return err == nil
+}
+
+// escalationNotes returns the types and impacts of release notes, which
+// escalation rules match.
+func escalationNotes(releaseNotes []releasenotes.ReleaseNote) []escalation.Note {
+	notes := make([]escalation.Note, len(releaseNotes))
+	for i, rn := range releaseNotes {
+		notes[i] = escalation.Note{Type: rn.ReleaseNoteType, Impact: rn.Impact}
+	}
+	return notes
+}
+
+// checkThresholds counts the release notes of the whole digest matching
+// each threshold. When any is exceeded, the digest is marked urgent and the
+// threshold webhook is told which thresholds were exceeded and by which
+// products.
+func (r *run) checkThresholds(ctx context.Context) {
+	e := r.escalation
+	if len(e.thresholds) == 0 {
+		return
+	}
+	var exceeded []string
+	for _, t := range e.thresholds {
+		count, prods := 0, []string{}
+		// A release note counts once, even when it reaches several channels.
+		seen := make(map[string]bool)
+		for _, ch := range r.doc.Channels {
+			for _, p := range ch.Products {
+				for _, rn := range p.Notes {
+					key := p.Name() + "\n" + rn.ReleaseNoteType + "\n" + rn.Description
+					if seen[key] || !t.Matches(p.Name(), escalation.Note{Type: rn.ReleaseNoteType, Impact: rn.Impact}) {
+						continue
+					}
+					seen[key] = true
+					count++
+					if !slices.Contains(prods, p.Name()) {
+						prods = append(prods, p.Name())
+					}
+				}
+			}
+		}
+		if t.Exceeded(count) {
+			fmt.Printf("Threshold %s exceeded by %d release notes.\n", t, count)
+			exceeded = append(exceeded, fmt.Sprintf("• %d release notes of %s (%s)", count, strings.Join(prods, ", "), t))
+		}
+	}
+	if len(exceeded) == 0 {
+		return
+	}
+	r.doc.Urgent = true
+	r.announceOpts.Urgent = true
+
+	m := i18n.M()
+	title := m.Title
+	if r.doc.Number > 0 {
+		title += fmt.Sprintf(" #%d", r.doc.Number)
+	}
+	text := fmt.Sprintf("*%s: %s*\n%s", m.Urgent, title, strings.Join(exceeded, "\n"))
+	if r.doc.Permalink != "" {
+		text += fmt.Sprintf("\n\n<%s|%s>", r.doc.Permalink, m.ReadArchive)
+	}
+	if r.escalation.mention != "" {
+		text = r.escalation.mention + " " + text
+	}
+	fmt.Printf("Reporting exceeded thresholds...")
+	status, err := notify.SendText(ctx, e.thresholdWebhook, text)
+	if err != nil {
+		fmt.Printf(" error: %v\n", err)
+	} else {
+		fmt.Printf(" %s\n", status)
+	}
+	r.report.Record("THRESHOLD", e.thresholdWebhook, report.KindEscalation, "", status, err)
+}
+
+// escalateSMS texts a short alert to the on-call phone numbers when the
+// release notes match an SMS escalation rule.
+func (r *run) escalateSMS(ctx context.Context, product string, notes []escalation.Note, summaryResult string) {
+	e := r.escalation
+	if e.sms == nil || len(notes) == 0 {
+		return
+	}
+	rule, ok := e.smsRules.Match(product, notes)
+	if !ok {
+		return
+	}
+
+	// Release notes are sorted by type priority, so the first type is the
+	// most important one.
+	body := sms.Render(releasenotes.TypeLabel(notes[0].Type, 1), product, summaryResult, e.smsMaxChars)
+	for _, to := range e.smsTo {
+		fmt.Printf("Texting %s alert to %s (rule: %s)...", product, to, rule)
+		status, err := e.sms.Send(ctx, to, body)
+		if err != nil {
+			fmt.Printf(" error: %v\n", err)
+		} else {
+			fmt.Printf(" %s\n", status)
+		}
+		r.report.Record("ESCALATION_SMS", e.sms.Target(), report.KindEscalation, product, status, err)
+	}
+}
+
+// withoutOwned drops the products delivered to their owning team.
+func withoutOwned(prods []products.Product, routes *routing.Routes) []products.Product {
+	var rest []products.Product
+	for _, p := range prods {
+		if _, owned := routes.Owner(p.Product); !owned {
+			rest = append(rest, p)
+		}
+	}
+	return rest
+}
@@ -0,0 +1,71 @@
+package digest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mpolski/gcp-release-digest/pkg/lease"
+	"github.com/mpolski/gcp-release-digest/pkg/store"
+)
+
+const (
+	// defaultRunLockTTL is how long a run holds its channels unless
+	// RUN_LOCK_TTL says otherwise, the longest timeout of an HTTP function.
+	defaultRunLockTTL = time.Hour
+	// runLockInterval is how often a run waiting for its channels checks
+	// whether they were released.
+	runLockInterval = 10 * time.Second
+)
+
+// runLockKey is the store key of the lease on a channel or team held by the
+// run delivering to it. Teams routed to a webhook URL rather than a name are
+// keyed by its hash, as webhook URLs are secrets.
+func runLockKey(channel string) string {
+	if strings.Contains(channel, "://") {
+		sum := sha256.Sum256([]byte(channel))
+		return "locks/target-" + hex.EncodeToString(sum[:16])
+	}
+	return "locks/" + channel
+}
+
+// lockChannels takes the lease on every channel of a run, by name, so an
+// overlapping run, e.g. a retry of Cloud Scheduler, cannot post to the same
+// channels twice. While another run holds a channel it waits up to
+// patience, then gives up with a *lease.HeldError. The returned function
+// releases the leases.
+func lockChannels(ctx context.Context, s store.Store, channels []string, ttl, patience time.Duration) (func(), error) {
+	names := append([]string{}, channels...)
+	// Channels are locked in the same order by every run, so two runs never
+	// wait for each other.
+	sort.Strings(names)
+
+	holder := lease.Holder()
+	var held []*lease.Lease
+	// The leases are released once, whether the run returns or exits on a
+	// fatal error.
+	var once sync.Once
+	release := func() {
+		once.Do(func() {
+			for _, l := range held {
+				if err := l.Release(context.WithoutCancel(ctx)); err != nil {
+					fmt.Printf("Error releasing run lock: %v\n", err)
+				}
+			}
+		})
+	}
+	for _, name := range names {
+		l, err := lease.Wait(ctx, s, runLockKey(name), holder, ttl, patience, runLockInterval)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		held = append(held, l)
+	}
+	return release, nil
+}
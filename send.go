@@ -0,0 +1,60 @@
+package digest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/mpolski/gcp-release-digest/pkg/notify"
+	"github.com/mpolski/gcp-release-digest/pkg/tasks"
+)
+
+// send delivers a single webhook message on behalf of the Cloud Tasks retry
+// queue. Retryable failures are answered with an error status, so Cloud Tasks
+// retries the message with backoff; permanent rejections are logged and
+// acknowledged, as retrying them cannot succeed.
+func send(w http.ResponseWriter, r *http.Request) {
+	var msg tasks.Message
+	if err := json.NewDecoder(r.Body).Decode(&msg); err != nil || msg.WebhookURL == "" {
+		http.Error(w, "invalid message", http.StatusBadRequest)
+		return
+	}
+
+	// Messages for Matrix rooms and Zulip streams need the credentials of
+	// the digest, which may have changed in the configuration file.
+	if err := reloadConfig(r.Context()); err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+	if err := setTargetCredentials(r.Context()); err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+	// A retry goes through the proxy and certificates of the webhook as the
+	// first attempt did, and is signed again over the payload as sent.
+	if err := setWebhookClients(r.Context()); err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+
+	// Messages from older tasks carry no headers.
+	header := msg.Header
+	if header == nil {
+		header = http.Header{"Content-Type": {"application/json; charset=UTF-8"}}
+	}
+	status, err := notify.PostHeader(r.Context(), msg.WebhookURL, msg.Payload, header)
+	switch {
+	case notify.Retryable(status, err):
+		fmt.Printf("Retryable delivery failure: %s %v\n", status, err)
+		http.Error(w, "delivery failed", http.StatusBadGateway)
+	case !strings.HasPrefix(status, "2"):
+		fmt.Printf("Dropping message, webhook responded with %s\n", status)
+		fmt.Fprintf(w, "dropped: %s", status)
+	default:
+		fmt.Fprint(w, status)
+	}
+}
@@ -0,0 +1,107 @@
+package digest
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/mpolski/gcp-release-digest/pkg/i18n"
+	"github.com/mpolski/gcp-release-digest/pkg/notify"
+	"github.com/mpolski/gcp-release-digest/pkg/report"
+	"github.com/mpolski/gcp-release-digest/pkg/source"
+	"github.com/mpolski/gcp-release-digest/pkg/stats"
+)
+
+// statsHandler is the HTTP function posting the monthly statistics of
+// release notes, meant to be scheduled on the first day of every month. It
+// posts the volume of the previous month, or of ?month=2006-01, per type,
+// product and category with the trend against the month before, to
+// STATS_WEBHOOK or GENERAL.
+func statsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if err := reloadConfig(ctx); err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+
+	projectID := os.Getenv("PROJECT_ID")
+	webhookURL := os.Getenv("STATS_WEBHOOK")
+	if webhookURL == "" {
+		// A GENERAL channel fanning out posts statistics to its first target.
+		if targets := webhookTargets(os.Getenv("GENERAL")); len(targets) > 0 {
+			webhookURL = targets[0]
+		}
+	}
+	if projectID == "" || webhookURL == "" {
+		fmt.Println("Set PROJECT_ID= and STATS_WEBHOOK= or GENERAL= in environment variables to post statistics")
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+	top, err := optionalInt("STATS_TOP")
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+	if top == 0 {
+		top = 10
+	}
+
+	now := time.Now().UTC()
+	month := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -1, 0)
+	if m := r.URL.Query().Get("month"); m != "" {
+		if month, err = time.Parse("2006-01", m); err != nil {
+			http.Error(w, fmt.Sprintf("invalid month %q, expected e.g. 2024-06", m), http.StatusBadRequest)
+			return
+		}
+	}
+
+	if err := i18n.SetLocale(os.Getenv("LOCALE")); err != nil {
+		fmt.Printf("Error in LOCALE: %v", err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+	if err := setTargetCredentials(ctx); err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+	if err := setWebhookClients(ctx); err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+	if err := source.LoadFile(os.Getenv("NOTES_FILE")); err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+
+	fmt.Printf("Counting release notes of %s...\n", month.Format("January 2006"))
+	current, previous, err := stats.Get(ctx, projectID, month)
+	if err != nil {
+		fmt.Printf("Error querying for release note statistics: %v\n", err)
+		http.Error(w, "query error", http.StatusInternalServerError)
+		return
+	}
+
+	rep := report.New(0)
+	fmt.Printf("Posting statistics of %d release notes...", current.Total)
+	status, err := notify.SendText(ctx, webhookURL, stats.Render(current, previous, top))
+	if err != nil {
+		fmt.Printf(" error: %v\n", err)
+	} else {
+		fmt.Printf(" %s\n", status)
+	}
+	rep.Record("STATS", webhookURL, report.KindStats, "", status, err)
+
+	reportJSON, err := rep.JSON()
+	if err != nil {
+		fmt.Printf("Error encoding run report: %v\n", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(reportJSON)
+}
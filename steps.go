@@ -0,0 +1,165 @@
+package digest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/mpolski/gcp-release-digest/pkg/digest"
+	"github.com/mpolski/gcp-release-digest/pkg/releasenotes"
+	"github.com/mpolski/gcp-release-digest/pkg/store"
+)
+
+// stepKey is the context key of the step of the pipeline a run is limited
+// to.
+type stepKey struct{}
+
+// stepRequest is the JSON input of the step function, running one step of
+// the pipeline of a digest:
+//
+//   - fetch queries the products and release notes of the channels, like a
+//     run, and stores them as digest number n without summarizing them;
+//   - summarize summarizes the products of digest n still without a
+//     summary, all of them or those of one channel or product;
+//   - deliver delivers digest n once all of its products are summarized,
+//     like ?publish=n.
+type stepRequest struct {
+	Step string `json:"step"`
+	// Digest is the number of the digest returned by fetch, for the later
+	// steps.
+	Digest int `json:"digest,omitempty"`
+	// Channels selects the channels fetched, as ?channels= does.
+	Channels []string `json:"channels,omitempty"`
+	// Channel and Product limit a summarize step to the products of one
+	// channel, or one product, so they can run and be retried in parallel.
+	Channel string `json:"channel,omitempty"`
+	Product string `json:"product,omitempty"`
+}
+
+// is reports whether the run is limited to the named step.
+func (s *stepRequest) is(name string) bool {
+	return s != nil && s.Step == name
+}
+
+// partial reports whether the run is limited to a step that delivers
+// nothing.
+func (s *stepRequest) partial() bool {
+	return s.is("fetch") || s.is("summarize")
+}
+
+// stepResponse is the JSON output of the fetch and summarize steps,
+// listing the products of the digest to summarize.
+type stepResponse struct {
+	Step   string `json:"step"`
+	Digest int    `json:"digest"`
+	// Pending is the number of products without a summary yet; the digest
+	// can be delivered once it is zero.
+	Pending  int           `json:"pending"`
+	Channels []stepChannel `json:"channels"`
+}
+
+type stepChannel struct {
+	Name     string        `json:"name"`
+	Products []stepProduct `json:"products"`
+}
+
+type stepProduct struct {
+	Product    string   `json:"product"`
+	Notes      int      `json:"notes"`
+	Types      []string `json:"types"`
+	Summarized bool     `json:"summarized"`
+}
+
+// step is the HTTP function running one step of the pipeline, for
+// orchestrators such as Cloud Workflows composing and retrying the steps
+// individually. It takes a stepRequest as JSON body and answers with a
+// stepResponse, or with the run report of the deliver step.
+func step(w http.ResponseWriter, r *http.Request) {
+	var req stepRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Error decoding step request: %v", err), http.StatusBadRequest)
+		return
+	}
+	q := url.Values{}
+	switch req.Step {
+	case "fetch":
+		if len(req.Channels) > 0 {
+			q.Set("channels", strings.Join(req.Channels, ","))
+		}
+	case "summarize":
+		if req.Digest <= 0 {
+			http.Error(w, "summarize needs the digest number returned by fetch", http.StatusBadRequest)
+			return
+		}
+	case "deliver":
+		if req.Digest <= 0 {
+			http.Error(w, "deliver needs the digest number returned by fetch", http.StatusBadRequest)
+			return
+		}
+		q.Set("publish", strconv.Itoa(req.Digest))
+	default:
+		http.Error(w, fmt.Sprintf("unknown step %q, use fetch, summarize or deliver", req.Step), http.StatusBadRequest)
+		return
+	}
+
+	fmt.Printf("Running the %s step of the pipeline.\n", req.Step)
+	run := r.Clone(context.WithValue(r.Context(), stepKey{}, &req))
+	run.URL.RawQuery = q.Encode()
+	runDigest(w, run)
+}
+
+// summarizeStep summarizes the products of the stored document selected by
+// the step that have no summary yet, storing each summary under
+// digest.SummaryKey as soon as it is done, so parallel steps do not
+// overwrite each other's and a retried step only summarizes what is left.
+func (r *run) summarizeStep(ctx context.Context, s store.Store, st *stepRequest) {
+	for _, ch := range r.doc.Channels {
+		if st.Channel != "" && ch.Name != st.Channel {
+			continue
+		}
+		for _, p := range ch.Products {
+			if p.Summary != "" || (st.Product != "" && releasenotes.Normalize(p.Name()) != releasenotes.Normalize(st.Product)) {
+				continue
+			}
+			p.Summary = r.summarize(ctx, ch, p.Name(), p.Notes)
+			if err := s.Put(ctx, digest.SummaryKey(r.doc.Number, ch.Name, p.Name()), []byte(p.Summary)); err != nil {
+				fmt.Printf("Error storing the summary of %s in %s: %v\n", p.Name(), ch.Name, err)
+				p.Summary = ""
+			}
+		}
+	}
+}
+
+// writeStep stores the document of a fetch step and answers a fetch or
+// summarize step with the products of the document.
+func (r *run) writeStep(ctx context.Context, w http.ResponseWriter, s store.Store, st *stepRequest) error {
+	if st.is("fetch") {
+		if err := r.doc.Save(ctx, s); err != nil {
+			return fmt.Errorf("Error storing digest #%d: %v", r.doc.Number, err)
+		}
+		fmt.Printf("Fetched digest #%d, summarize it with the summarize step.\n", r.doc.Number)
+	}
+
+	resp := stepResponse{Step: st.Step, Digest: r.doc.Number, Channels: []stepChannel{}}
+	for _, ch := range r.doc.Channels {
+		c := stepChannel{Name: ch.Name, Products: []stepProduct{}}
+		for _, p := range ch.Products {
+			c.Products = append(c.Products, stepProduct{Product: p.Name(), Notes: len(p.Notes), Types: p.Types(), Summarized: p.Summary != ""})
+			if p.Summary == "" {
+				resp.Pending++
+			}
+		}
+		resp.Channels = append(resp.Channels, c)
+	}
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("Error encoding step response: %v", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+	return nil
+}
@@ -0,0 +1,122 @@
+package digest
+
+import (
+	"fmt"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/mpolski/gcp-release-digest/pkg/summarize"
+)
+
+// promptVariant is an alternate prompt summarizing a share of the products,
+// to compare it with the default prompt on readers' feedback.
+type promptVariant struct {
+	name    string
+	prompt  *summarize.Prompt
+	percent int
+}
+
+// parseVariants reads prompt variants separated by semicolons in the form
+// name=file@percent, e.g. "short=prompts/short.txt@20", where file is a
+// prompt template and percent the share of products it summarizes.
+func parseVariants(spec string) ([]promptVariant, error) {
+	var variants []promptVariant
+	total := 0
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, rest, ok := strings.Cut(entry, "=")
+		at := strings.LastIndex(rest, "@")
+		if !ok || at < 0 {
+			return nil, fmt.Errorf("prompt variant %q: expected name=file@percent", entry)
+		}
+		name, file := strings.TrimSpace(name), strings.TrimSpace(rest[:at])
+		if name == "" || name == "default" {
+			return nil, fmt.Errorf("prompt variant %q: name must be set and not default", entry)
+		}
+		percent, err := strconv.Atoi(strings.TrimSuffix(strings.TrimSpace(rest[at+1:]), "%"))
+		if err != nil || percent <= 0 {
+			return nil, fmt.Errorf("prompt variant %q: percent must be a positive int", entry)
+		}
+		if total += percent; total > 100 {
+			return nil, fmt.Errorf("prompt variants summarize more than 100%% of the products")
+		}
+		text, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("prompt variant %s: %v", name, err)
+		}
+		prompt, err := summarize.ParsePrompt(name, string(text))
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, promptVariant{name: name, prompt: prompt, percent: percent})
+	}
+	return variants, nil
+}
+
+// parseTypePrompts reads the prompts specialized for release note types:
+// the built-in ones, overridden by entries separated by semicolons in the
+// form TYPE=file, e.g. "FEATURE=prompts/feature.txt", where file is a prompt
+// template. It returns nil for "off", leaving the default prompt for all
+// release notes.
+func parseTypePrompts(spec string) (map[string]*summarize.Prompt, error) {
+	if strings.TrimSpace(spec) == "off" {
+		return nil, nil
+	}
+	prompts := summarize.TypePrompts()
+	for _, entry := range strings.Split(spec, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		releaseNoteType, file, ok := strings.Cut(entry, "=")
+		releaseNoteType, file = strings.ToUpper(strings.TrimSpace(releaseNoteType)), strings.TrimSpace(file)
+		if !ok || releaseNoteType == "" || file == "" {
+			return nil, fmt.Errorf("type prompt %q: expected TYPE=file", entry)
+		}
+		text, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("type prompt %s: %v", releaseNoteType, err)
+		}
+		prompt, err := summarize.ParsePrompt(releaseNoteType, string(text))
+		if err != nil {
+			return nil, err
+		}
+		prompts[releaseNoteType] = prompt
+	}
+	return prompts, nil
+}
+
+// variantOf returns the prompt variant summarizing product in this run, or
+// nil for the default prompt. Products are assigned by a hash of the digest
+// number and the product, so every variant gets its share of the products
+// over the runs, and a product keeps its variant within a run.
+// Summaries with citations have their own prompt and no variants.
+func (r *run) variantOf(product string) *promptVariant {
+	if len(r.variants) == 0 || r.citations {
+		return nil
+	}
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%d/%s", r.doc.Number, product)
+	bucket := int(h.Sum32() % 100)
+	for i := range r.variants {
+		if bucket < r.variants[i].percent {
+			return &r.variants[i]
+		}
+		bucket -= r.variants[i].percent
+	}
+	return nil
+}
+
+// String returns the name of the variant, or an empty string for the
+// default prompt.
+func (v *promptVariant) String() string {
+	if v == nil {
+		return ""
+	}
+	return v.name
+}
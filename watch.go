@@ -0,0 +1,178 @@
+package digest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mpolski/gcp-release-digest/pkg/archive"
+	"github.com/mpolski/gcp-release-digest/pkg/i18n"
+	"github.com/mpolski/gcp-release-digest/pkg/notify"
+	"github.com/mpolski/gcp-release-digest/pkg/products"
+	"github.com/mpolski/gcp-release-digest/pkg/releasenotes"
+	"github.com/mpolski/gcp-release-digest/pkg/report"
+	"github.com/mpolski/gcp-release-digest/pkg/source"
+	"github.com/mpolski/gcp-release-digest/pkg/store"
+)
+
+// defaultWatchTypes are the release note types alerted by the watch function
+// unless WATCH_TYPES is set.
+var defaultWatchTypes = []string{"SECURITY_BULLETIN", "BREAKING_CHANGE"}
+
+// watch is the HTTP function of the alert-only watch mode, meant to run on a
+// tight schedule, e.g. hourly, alongside the digest. It skips summarization
+// and alerts WATCH_WEBHOOK of every new release note of the WATCH_TYPES for
+// the WATCH_PRODUCTS. Alerted notes are remembered in the state store, so
+// each one is alerted once however often the function runs.
+func watch(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	if err := reloadConfig(ctx); err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+
+	projectID := os.Getenv("PROJECT_ID")
+	webhookURL := os.Getenv("WATCH_WEBHOOK")
+	if projectID == "" || webhookURL == "" {
+		fmt.Println("Set PROJECT_ID= and WATCH_WEBHOOK= in environment variables to watch release notes")
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+	types := defaultWatchTypes
+	if spec := os.Getenv("WATCH_TYPES"); spec != "" {
+		types = nil
+		for _, t := range strings.Split(spec, ",") {
+			t = strings.ToUpper(strings.TrimSpace(t))
+			if !slices.Contains(allReleaseNoteTypes, t) {
+				fmt.Printf("Error in WATCH_TYPES: unknown release note type %q\n", t)
+				http.Error(w, "configuration error", http.StatusInternalServerError)
+				return
+			}
+			types = append(types, t)
+		}
+	}
+	// Without WATCH_PRODUCTS every product is watched.
+	watched := make(map[string]bool)
+	for _, p := range strings.Split(os.Getenv("WATCH_PRODUCTS"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			watched[strings.ToLower(p)] = true
+		}
+	}
+	// Release notes reach BigQuery with a delay, so a few days are scanned
+	// every time and the notes alerted before are skipped.
+	days, err := optionalInt("WATCH_DAYS")
+	if err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+	if days == 0 {
+		days = 2
+	}
+
+	stateStore, err := store.New(ctx, os.Getenv("STATE_BUCKET"), os.Getenv("STATE_DIR"))
+	if err != nil || stateStore == nil {
+		fmt.Printf("Set STATE_BUCKET= or STATE_DIR= in environment variables to watch release notes: %v\n", err)
+		http.Error(w, "state store error", http.StatusInternalServerError)
+		return
+	}
+	if err := setTargetCredentials(ctx); err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+	if err := setWebhookClients(ctx); err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+	if err := source.LoadFile(os.Getenv("NOTES_FILE")); err != nil {
+		fmt.Println(err)
+		http.Error(w, "configuration error", http.StatusInternalServerError)
+		return
+	}
+
+	cadence := strconv.Itoa(days)
+	prods, err := products.GetProducts(ctx, projectID, types, cadence)
+	if err != nil {
+		fmt.Printf("Error querying for watched release notes: %v\n", err)
+		http.Error(w, "query error", http.StatusInternalServerError)
+		return
+	}
+
+	rep := report.New(0)
+	alerted := 0
+	for _, p := range prods {
+		if len(watched) > 0 && !watched[strings.ToLower(p.Product)] {
+			continue
+		}
+		releaseNotes, err := releasenotes.GetReleaseNotes(ctx, projectID, p.Product, types, cadence, releasenotes.Options{TypePriority: releasenotes.DefaultTypePriority})
+		if err != nil {
+			fmt.Printf("Error querying for release notes of %s: %v\n", p.Product, err)
+			continue
+		}
+		for _, rn := range releaseNotes {
+			n, err := alertNote(ctx, stateStore, webhookURL, p.Product, rn, rep)
+			if err != nil {
+				fmt.Println(err)
+			}
+			alerted += n
+		}
+	}
+	fmt.Printf("Alerted %d new release notes.\n", alerted)
+
+	reportJSON, err := rep.JSON()
+	if err != nil {
+		fmt.Printf("Error encoding run report: %v\n", err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(reportJSON)
+}
+
+// alertNote sends an alert of a release note to the webhook unless it was
+// alerted before, and returns 1 if it was sent. A note whose alert fails is
+// alerted again by the next run.
+func alertNote(ctx context.Context, s store.Store, webhookURL, product string, rn releasenotes.ReleaseNote, rep *report.Report) (int, error) {
+	key := watchKey(product, rn)
+	if _, err := s.Get(ctx, key); err == nil {
+		return 0, nil
+	} else if !errors.Is(err, store.ErrNotFound) {
+		return 0, fmt.Errorf("Error reading watch state of %s: %v", product, err)
+	}
+
+	text := fmt.Sprintf("*%s: %s*\n%s\n\n<%s|%s>", strings.ReplaceAll(rn.ReleaseNoteType, "_", " "), product, rn.Description,
+		releasenotes.URL(product, []releasenotes.ReleaseNote{rn}), i18n.M().NotesPage)
+	fmt.Printf("Alerting %s of %s...", rn.ReleaseNoteType, product)
+	status, err := notify.SendText(ctx, webhookURL, text)
+	// A send handed to the retry queue is delivered by it.
+	if err == nil && !strings.HasPrefix(status, "2") && status != notify.StatusRetrying {
+		err = fmt.Errorf("webhook responded with %s", status)
+	}
+	rep.Record("WATCH", webhookURL, report.KindAlert, product, status, err)
+	if err != nil {
+		fmt.Printf(" error: %v\n", err)
+		return 0, nil
+	}
+	fmt.Printf(" %s\n", status)
+	if err := s.Put(ctx, key, []byte(time.Now().UTC().Format(time.RFC3339))); err != nil {
+		return 1, fmt.Errorf("Error saving watch state of %s: %v", product, err)
+	}
+	return 1, nil
+}
+
+// watchKey returns the store key remembering that a release note of a
+// product was alerted, named after the product and a hash of the note.
+func watchKey(product string, rn releasenotes.ReleaseNote) string {
+	sum := sha256.Sum256([]byte(rn.ReleaseNoteType + "\n" + rn.Description))
+	return fmt.Sprintf("watch/%s/%s", archive.Anchor(product), hex.EncodeToString(sum[:12]))
+}